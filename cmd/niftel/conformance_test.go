@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/difftest"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/format"
+)
+
+var update = flag.Bool("update", false, "rewrite the .out and .err files in testdata")
+
+// compiledDirective, as the first line of a program, runs it through the
+// compiled path as well as the interpreter.
+const compiledDirective = "// compiled"
+
+// runMainEnv makes the test binary act as the niftel command, so the
+// conformance tests run programs exactly as a user would.
+const runMainEnv = "NIFTEL_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// TestConformance runs every testdata/*.nif program and checks what it
+// writes to stdout against its .out file, what it writes to stderr against
+// its .err file and its exit code against its .exit file. The .err and .exit
+// files are absent if nothing should be written and the program should exit
+// with 0. Programs marked with compiledDirective are also compiled, when the
+// LLVM tools are installed, and their output and exit code checked against
+// the same .out and .exit files.
+func TestConformance(t *testing.T) {
+	programs, err := filepath.Glob(filepath.Join("testdata", "*.nif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(programs) == 0 {
+		t.Fatal("no test programs")
+	}
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, program := range programs {
+		name := strings.TrimSuffix(filepath.Base(program), ".nif")
+		t.Run(name, func(t *testing.T) {
+			cmd := exec.Command(self, name+".nif")
+			cmd.Dir = "testdata"
+			cmd.Env = append(os.Environ(), runMainEnv+"=1")
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout, cmd.Stderr = &stdout, &stderr
+			if err := cmd.Run(); err != nil && !errors.As(err, new(*exec.ExitError)) {
+				t.Fatal(err)
+			}
+			golden(t, program, ".out", stdout.String())
+			golden(t, program, ".err", stderr.String())
+			golden(t, program, ".exit", exitCode(cmd.ProcessState.ExitCode()))
+		})
+
+		src, err := os.ReadFile(program)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(string(src), compiledDirective+"\n") {
+			t.Run(name+"/compiled", func(t *testing.T) {
+				got, code := runCompiled(t, string(src))
+				if !*update {
+					golden(t, program, ".out", got)
+					golden(t, program, ".exit", exitCode(code))
+				}
+			})
+		}
+	}
+}
+
+// exitCode is how a .exit file records code: empty for 0, which is what a
+// missing file expects.
+func exitCode(code int) string {
+	if code == 0 {
+		return ""
+	}
+	return strconv.Itoa(code) + "\n"
+}
+
+// golden compares got with the file program has in place of its .nif
+// extension, or rewrites the file with -update. An empty or missing file
+// expects no output, and only a .out file is kept when it is empty.
+func golden(t *testing.T, program, ext, got string) {
+	t.Helper()
+	path := strings.TrimSuffix(program, ".nif") + ext
+	if *update {
+		var err error
+		if got == "" && ext != ".out" {
+			err = os.Remove(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				err = nil
+			}
+		} else {
+			err = os.WriteFile(path, []byte(got), 0o644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s:\n%s", path, format.Diff(path, string(want), got))
+	}
+}
+
+// runCompiled compiles src to a native executable and returns what it
+// prints and its exit code, skipping the test if the LLVM tools are not
+// installed.
+func runCompiled(t *testing.T, src string) (string, int) {
+	t.Helper()
+	if ok, missing := difftest.HaveToolchain(); !ok {
+		t.Skipf("%s not found", missing)
+	}
+	out, err := difftest.Compile(src, t.TempDir())
+	if exitErr := (*exec.ExitError)(nil); errors.As(err, &exitErr) {
+		return out, exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out, 0
+}
@@ -2,20 +2,38 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/ithinkiborkedit/niftelv2.git/internal/codegen"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/coverage"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/debugger"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/flow"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/format"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lint"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lsp"
 	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/niftest"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/profile"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/resolver"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/trace"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/typechecker"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
 )
 
 // Helper: counts '{' and '}' in a line, ignoring those in strings
@@ -84,12 +102,18 @@ func compileProject(path string) {
 	}
 	source := string(data)
 	lex := lexer.New(source)
-	par := parser.New(lex)
+	par := newParser(lex)
 	stmts, err := par.Parse()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Parse error: %v\n", err)
+		report(os.Stderr, path, source, err)
 		os.Exit(3)
 	}
+	errs, warnings := staticCheck(stmts)
+	report(os.Stderr, path, source, warnings...)
+	if len(errs) > 0 {
+		report(os.Stderr, path, source, errs...)
+		os.Exit(1)
+	}
 
 	cg := codegen.NewCodeGen()
 	ir, err := cg.GenerateLLVM(stmts)
@@ -126,13 +150,508 @@ func compileProject(path string) {
 	// fmt.Printf("LLVM IR WRITTEN to: %s\n", outfile)
 }
 
+// staticCheck reports the scope, type and control flow errors in a whole
+// program, and its control flow warnings.
+func staticCheck(stmts []ast.Stmt) (errs, warnings []error) {
+	if errs := resolver.Resolve(stmts); len(errs) > 0 {
+		return errs, nil
+	}
+	errs = typechecker.NewChecker().Check(stmts)
+	flowErrs, warnings := flow.Analyze(stmts)
+	return append(errs, flowErrs...), warnings
+}
+
+// errorFormat is how report writes diagnostics, set with --error-format:
+// "text" renders each with the offending source line, "json" writes each as
+// one line of JSON for tools.
+var errorFormat = "text"
+
+// maxErrors is the number of syntax errors after which a parse stops, set
+// with --max-errors; zero means no limit.
+var maxErrors = parser.DefaultMaxErrors
+
+// newParser returns a parser for src that stops after maxErrors errors.
+func newParser(src lexer.TokenSource) *parser.Parser {
+	par := parser.New(src)
+	par.MaxErrors = maxErrors
+	return par
+}
+
+// report writes diagnostics for the file at path, whose source is src.
+func report(w io.Writer, path, src string, errs ...error) {
+	for _, err := range flatten(errs) {
+		nifErr := niferrors.As(err)
+		if nifErr.File == "" {
+			nifErr.File = path
+		}
+		if errorFormat == "json" {
+			niferrors.WriteJSON(w, nifErr)
+		} else {
+			niferrors.Render(w, nifErr, src)
+		}
+	}
+}
+
+// flatten replaces each list of syntax errors in errs with its errors.
+func flatten(errs []error) []error {
+	var flat []error
+	for _, err := range errs {
+		flat = append(flat, parser.Errors(err)...)
+	}
+	return flat
+}
+
+// extractFlag removes --name=value (or -name=value) from args and returns the
+// value, so options that apply to every command can appear anywhere.
+func extractFlag(args []string, name string) (string, []string, bool) {
+	for idx, arg := range args {
+		for _, prefix := range []string{"--" + name + "=", "-" + name + "="} {
+			if value, ok := strings.CutPrefix(arg, prefix); ok {
+				return value, append(args[:idx:idx], args[idx+1:]...), true
+			}
+		}
+	}
+	return "", args, false
+}
+
+// checkFile type-checks a source file without running it.
+func checkFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read file: %v\n", err)
+		os.Exit(2)
+	}
+	stmts, err := newParser(lexer.New(string(data))).Parse()
+	if err != nil {
+		report(os.Stderr, path, string(data), err)
+		os.Exit(3)
+	}
+	errs, warnings := staticCheck(stmts)
+	report(os.Stderr, path, string(data), warnings...)
+	if len(errs) > 0 {
+		report(os.Stderr, path, string(data), errs...)
+		os.Exit(1)
+	}
+}
+
+// lintFile runs the linter for: niftel lint [-format text|json] [-config file] <file.nif>
+// Without -config, a .niftel-lint.json next to the source file is used if
+// there is one. It returns the exit code: 1 if anything was reported.
+func lintFile(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	format := flags.String("format", "text", "output format: text or json")
+	configPath := flags.String("config", "", "lint config file")
+	flags.Parse(args)
+	if flags.NArg() != 1 || (*format != "text" && *format != "json") {
+		fmt.Fprintf(os.Stderr, "Usage %s lint [-format text|json] [-config file] <source-code-file.nif>\n", os.Args[0])
+		return 2
+	}
+	path := flags.Arg(0)
+
+	var cfg *lint.Config
+	if *configPath == "" {
+		if candidate := filepath.Join(filepath.Dir(path), ".niftel-lint.json"); fileExists(candidate) {
+			*configPath = candidate
+		}
+	}
+	if *configPath != "" {
+		var err error
+		if cfg, err = lint.LoadConfig(*configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "lint config: %v\n", err)
+			return 2
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read file: %v\n", err)
+		return 2
+	}
+	stmts, err := newParser(lexer.New(string(data))).Parse()
+	if err != nil {
+		report(os.Stderr, path, string(data), err)
+		return 3
+	}
+	diags := lint.Lint(string(data), stmts, cfg)
+	if *format == "json" {
+		err = lint.WriteJSON(os.Stdout, path, diags)
+	} else {
+		err = lint.WriteText(os.Stdout, path, diags)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lint: %v\n", err)
+		return 2
+	}
+	if len(diags) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// formatFiles rewrites each file in its canonical format. With -check it only
+// lists the files that are not formatted, with -diff it prints the changes.
+// Either way nothing is written and the exit code is 1 if a file would change.
+func formatFiles(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := flags.Bool("check", false, "list files whose formatting differs, without rewriting them")
+	diff := flags.Bool("diff", false, "print a diff of the changes instead of rewriting files")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Usage %s fmt [-check] [-diff] <source-code-file.nif>...\n", os.Args[0])
+		return 2
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not read file: %v\n", err)
+			status = 2
+			continue
+		}
+		formatted, err := format.Source(string(data))
+		if err != nil {
+			report(os.Stderr, path, string(data), err)
+			status = 2
+			continue
+		}
+		if formatted == string(data) {
+			continue
+		}
+		switch {
+		case *check || *diff:
+			if *check {
+				fmt.Println(path)
+			}
+			if *diff {
+				fmt.Print(format.Diff(path, string(data), formatted))
+			}
+			status = max(status, 1)
+		default:
+			info, err := os.Stat(path)
+			if err == nil {
+				err = os.WriteFile(path, []byte(formatted), info.Mode().Perm())
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "could not write file: %v\n", err)
+				status = 2
+			}
+		}
+	}
+	return status
+}
+
+// testFiles runs the tests in the *_test.nif files named by args, for:
+// niftel test [-run regexp] [-junit file] [-coverprofile file] [-v] [path|dir|dir/...]...
+// It returns the exit code: 1 if a test failed or a file could not run.
+func testFiles(args []string, traceDepth int) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	run := flags.String("run", "", "run only the tests whose names match this regexp")
+	junit := flags.String("junit", "", "also write the results as JUnit XML to this file")
+	verbose := flags.Bool("v", false, "list every test, not just the failures")
+	coverPath := flags.String("coverprofile", "", "write the lines the tests covered to this file, in LCOV format")
+	flags.Parse(args)
+	opts := niftest.Options{TraceDepth: traceDepth}
+	if *coverPath != "" {
+		opts.Cover = coverage.New()
+	}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -run pattern: %v\n", err)
+			return 2
+		}
+		opts.Run = re
+	}
+	patterns := flags.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	paths, err := niftest.Discover(patterns)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "test: %v\n", err)
+		return 2
+	}
+
+	status := 0
+	var files []niftest.File
+	var passed, failed, skipped int
+	for _, path := range paths {
+		file := niftest.RunFile(path, opts)
+		files = append(files, file)
+		if len(file.Errs) > 0 {
+			fmt.Printf("FAIL %s\n", path)
+			report(os.Stdout, path, file.Source, file.Errs...)
+			status = 1
+			continue
+		}
+		for _, result := range file.Results {
+			switch {
+			case result.Status == niftest.Fail:
+				fmt.Printf("--- FAIL: %s (%s)\n", result.Name, result.Duration.Round(time.Microsecond))
+				report(os.Stdout, path, file.Source, result.Err)
+			case result.Status == niftest.Skip && *verbose:
+				fmt.Printf("--- SKIP: %s (%s)\n", result.Name, niferrors.As(result.Err).Message)
+			case *verbose:
+				fmt.Printf("--- PASS: %s (%s)\n", result.Name, result.Duration.Round(time.Microsecond))
+			}
+		}
+		p, f, s := niftest.Counts(file.Results)
+		passed, failed, skipped = passed+p, failed+f, skipped+s
+		if f > 0 {
+			fmt.Printf("FAIL %s\n", path)
+			status = 1
+		} else {
+			fmt.Printf("ok   %s\n", path)
+		}
+	}
+	fmt.Printf("%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+	if opts.Cover != nil {
+		if err := writeCoverProfile(*coverPath, opts.Cover); err != nil {
+			fmt.Fprintf(os.Stderr, "coverprofile: %v\n", err)
+			return 2
+		}
+		fmt.Printf("coverage: %.1f%% of lines\n", opts.Cover.Percent())
+	}
+
+	if *junit != "" {
+		out, err := os.Create(*junit)
+		if err == nil {
+			err = niftest.WriteJUnit(out, files)
+			if closeErr := out.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "junit: %v\n", err)
+			return 2
+		}
+	}
+	return status
+}
+
+// runProgram runs a program, for:
+// niftel run [-profile file] [-coverprofile file] <file.nif>
+// With -profile it also prints a table of where the time went to stderr and
+// writes a pprof profile to the file. With -coverprofile it writes the lines
+// that ran to the file, in LCOV format. It returns the exit code.
+func runProgram(args []string, interp *interpreter.Interpreter) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	profilePath := flags.String("profile", "", "write a pprof profile of the run to this file")
+	coverPath := flags.String("coverprofile", "", "write the lines the run covered to this file, in LCOV format")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage %s run [-profile file] [-coverprofile file] <source-code-file.nif>\n", os.Args[0])
+		return 2
+	}
+	path := flags.Arg(0)
+	var cover *coverage.Profile
+	if *coverPath != "" {
+		cover = startCover(path, interp)
+	}
+	var profiler *profile.Profiler
+	var profileOut *os.File
+	if *profilePath != "" {
+		out, err := os.Create(*profilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "profile: %v\n", err)
+			return 2
+		}
+		defer out.Close()
+		// The profiler hooks in ahead of coverage and calls its hook in turn.
+		profiler, profileOut = profile.Start(path, interp), out
+	}
+	runFile(path, interp)
+	status := 0
+	if profiler != nil {
+		status = max(status, writeProfile(profiler.Stop(), profileOut))
+	}
+	if cover != nil {
+		interp.Hook = nil
+		status = max(status, writeCover(*coverPath, cover))
+	}
+	return status
+}
+
+// writeProfile prints a table of where the time went in prof to stderr and
+// writes prof to out in pprof format. It returns the exit code.
+func writeProfile(prof *profile.Profile, out io.Writer) int {
+	if err := prof.WriteTable(os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "profile: %v\n", err)
+		return 2
+	}
+	if err := prof.WritePprof(out); err != nil {
+		fmt.Fprintf(os.Stderr, "profile: %v\n", err)
+		return 2
+	}
+	return 0
+}
+
+// startCover hooks coverage of the program at path into interp.
+func startCover(path string, interp *interpreter.Interpreter) *coverage.Profile {
+	cover := coverage.New()
+	// A program that does not parse runs no lines; runFile reports why.
+	if data, err := os.ReadFile(path); err == nil {
+		if stmts, err := newParser(lexer.New(string(data))).Parse(); err == nil {
+			cover.Register(path, stmts)
+		}
+	}
+	interp.Hook = cover.Hook(path)
+	return cover
+}
+
+// writeCover writes the lines that ran to coverPath and prints the share
+// covered. It returns the exit code.
+func writeCover(coverPath string, cover *coverage.Profile) int {
+	if err := writeCoverProfile(coverPath, cover); err != nil {
+		fmt.Fprintf(os.Stderr, "coverprofile: %v\n", err)
+		return 2
+	}
+	fmt.Fprintf(os.Stderr, "coverage: %.1f%% of lines\n", cover.Percent())
+	return 0
+}
+
+func writeCoverProfile(path string, cover *coverage.Profile) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = cover.WriteLCOV(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// coverReport merges the coverage profiles named by args and prints the share
+// of lines covered in each function and file, for:
+// niftel cover [-o file] <profile.lcov>...
+// With -o it also writes the merged profile to the file.
+func coverReport(args []string) int {
+	flags := flag.NewFlagSet("cover", flag.ExitOnError)
+	outPath := flags.String("o", "", "write the merged profile to this file")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Usage %s cover [-o file] <profile.lcov>...\n", os.Args[0])
+		return 2
+	}
+	merged := coverage.New()
+	for _, path := range flags.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not read file: %v\n", err)
+			return 2
+		}
+		cover, err := coverage.ReadLCOV(file)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 2
+		}
+		merged.Merge(cover)
+	}
+	if err := merged.WriteSummary(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "cover: %v\n", err)
+		return 2
+	}
+	if *outPath != "" {
+		if err := writeCoverProfile(*outPath, merged); err != nil {
+			fmt.Fprintf(os.Stderr, "cover: %v\n", err)
+			return 2
+		}
+	}
+	return 0
+}
+
+// debugFile runs a program under the debugger, for:
+// niftel debug [-break [file:]line]... <file.nif>
+// The program stops before its first statement; commands are read from stdin.
+// It returns the exit code: 1 if the program failed.
+func debugFile(args []string, interp *interpreter.Interpreter) int {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	var breaks []string
+	flags.Func("break", "set a breakpoint at `[file:]line`; may be repeated", func(spec string) error {
+		breaks = append(breaks, spec)
+		return nil
+	})
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage %s debug [-break [file:]line]... <source-code-file.nif>\n", os.Args[0])
+		return 2
+	}
+	path := flags.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read file: %v\n", err)
+		return 2
+	}
+	stmts, err := newParser(lexer.New(string(data))).Parse()
+	if err != nil {
+		report(os.Stderr, path, string(data), err)
+		return 3
+	}
+	errs, warnings := interp.Check(stmts)
+	report(os.Stderr, path, string(data), warnings...)
+	if len(errs) > 0 {
+		report(os.Stderr, path, string(data), errs...)
+		return 1
+	}
+
+	dbg := debugger.New(path, string(data), stmts, os.Stdin, os.Stdout)
+	for _, spec := range breaks {
+		if _, err := dbg.Break(spec); err != nil {
+			fmt.Fprintf(os.Stderr, "break: %v\n", err)
+			return 2
+		}
+	}
+	switch err := dbg.Run(interp); {
+	case errors.Is(err, debugger.ErrQuit):
+		return 0
+	case err != nil:
+		report(os.Stderr, path, string(data), err)
+		return 1
+	}
+	fmt.Println("program finished")
+	return 0
+}
+
+// serveLSP runs the language server on stdin and stdout. Anything else the
+// compiler prints goes to stderr so it cannot corrupt the protocol stream.
+func serveLSP() int {
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	if err := lsp.NewServer(os.Stdin, out).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "lsp: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
 func runFile(path string, interp *interpreter.Interpreter) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Could not read file: %v\n", path)
 		os.Exit(2)
 	}
+	// Check the whole program up front. If it does not parse as a whole, the
+	// chunked run below reports the parse errors.
+	if stmts, err := newParser(lexer.New(string(data))).Parse(); err == nil {
+		errs, warnings := interp.Check(stmts)
+		report(os.Stderr, path, string(data), warnings...)
+		if len(errs) > 0 {
+			report(os.Stderr, path, string(data), errs...)
+			os.Exit(1)
+		}
+	}
 	reader := bufio.NewReader(strings.NewReader(string(data)))
+	// read counts the lines read so far; offset is how many precede the
+	// current chunk, which is lexed from the line after them.
+	read, offset := 0, 0
 
 	for {
 		var buffer strings.Builder
@@ -148,12 +667,16 @@ func runFile(path string, interp *interpreter.Interpreter) {
 			if err == io.EOF && line == "" && buffer.Len() == 0 {
 				return
 			}
+			read++
 			if firstLine && strings.TrimSpace(line) == "" {
 				if err == io.EOF {
 					return
 				}
 				continue
 			}
+			if firstLine {
+				offset = read - 1
+			}
 			buffer.WriteString(line)
 
 			o, c := countBraces(line)
@@ -172,27 +695,95 @@ func runFile(path string, interp *interpreter.Interpreter) {
 		if buffer.Len() == 0 {
 			return
 		}
-		lex := lexer.New(buffer.String())
-		par := parser.New(lex)
+		lex := lexer.NewAt(buffer.String(), offset+1)
+		par := newParser(lex)
 		stmts, err := par.Parse()
-		if err == parser.ErrIncomplete {
+		if err != nil {
+			report(os.Stderr, path, string(data), err)
 			continue
-		} else if err != nil {
-			fmt.Fprintf(os.Stderr, "parser error: %v\n", err)
+		}
+		// Globals carry over between chunks, so only this chunk's locals get slots.
+		if errs := resolver.Resolve(stmts); len(errs) > 0 {
+			report(os.Stderr, path, string(data), errs...)
 			continue
 		}
 		for _, stmt := range stmts {
 			result := interp.Execute(stmt)
 			if result.Err != nil {
-				fmt.Fprintf(os.Stderr, "runtime error: %v\n", result.Err)
+				report(os.Stderr, path, string(data), result.Err)
 			}
 		}
 	}
 }
 
+// setupTrace switches on the tracing asked for with --trace=components,
+// written to stderr or the --trace-file, from --trace-level up (debug by
+// default).
+func setupTrace() error {
+	spec, rest, ok := extractFlag(os.Args[1:], "trace")
+	if !ok {
+		return nil
+	}
+	os.Args = append(os.Args[:1], rest...)
+	components, err := trace.ParseComponents(spec)
+	if err != nil {
+		return err
+	}
+	level := slog.LevelDebug
+	if name, rest, ok := extractFlag(os.Args[1:], "trace-level"); ok {
+		if err := level.UnmarshalText([]byte(name)); err != nil {
+			return fmt.Errorf("invalid trace level %q: want debug, info, warn or error", name)
+		}
+		os.Args = append(os.Args[:1], rest...)
+	}
+	var out io.Writer = os.Stderr
+	if path, rest, ok := extractFlag(os.Args[1:], "trace-file"); ok {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("trace file: %w", err)
+		}
+		out = file
+		os.Args = append(os.Args[:1], rest...)
+	}
+	trace.Enable(out, level, components...)
+	return nil
+}
+
 func main() {
+	if format, rest, ok := extractFlag(os.Args[1:], "error-format"); ok {
+		if format != "text" && format != "json" {
+			fmt.Fprintf(os.Stderr, "unknown error format %q: want text or json\n", format)
+			os.Exit(2)
+		}
+		errorFormat = format
+		os.Args = append(os.Args[:1], rest...)
+	}
+	traceDepth := interpreter.DefaultTraceDepth
+	if depth, rest, ok := extractFlag(os.Args[1:], "trace-depth"); ok {
+		n, err := strconv.Atoi(depth)
+		if err != nil || n < 0 {
+			fmt.Fprintf(os.Stderr, "invalid trace depth %q: want a number of calls\n", depth)
+			os.Exit(2)
+		}
+		traceDepth = n
+		os.Args = append(os.Args[:1], rest...)
+	}
+	if limit, rest, ok := extractFlag(os.Args[1:], "max-errors"); ok {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			fmt.Fprintf(os.Stderr, "invalid error limit %q: want a number of errors, 0 for no limit\n", limit)
+			os.Exit(2)
+		}
+		maxErrors = n
+		os.Args = append(os.Args[:1], rest...)
+	}
+	if err := setupTrace(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
 	value.BuiltinTypesInit()
 	interp := interpreter.NewInterpreter()
+	interp.TraceDepth = traceDepth
 	// value.BuiltinTypesInit()
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
@@ -203,6 +794,28 @@ func main() {
 			}
 			compileProject(os.Args[2])
 			return
+		case "lint":
+			os.Exit(lintFile(os.Args[2:]))
+		case "fmt":
+			os.Exit(formatFiles(os.Args[2:]))
+		case "lsp":
+			os.Exit(serveLSP())
+		case "test":
+			os.Exit(testFiles(os.Args[2:], traceDepth))
+		case "debug":
+			os.Exit(debugFile(os.Args[2:], interp))
+		case "run":
+			interp.ShouldPrintResults = false
+			os.Exit(runProgram(os.Args[2:], interp))
+		case "cover":
+			os.Exit(coverReport(os.Args[2:]))
+		case "check":
+			if len(os.Args) < 3 {
+				fmt.Fprintf(os.Stderr, "Usage %s check <source-code-file.nif>\n", os.Args[0])
+				os.Exit(1)
+			}
+			checkFile(os.Args[2])
+			return
 		default:
 			interp.ShouldPrintResults = false
 			runFile(os.Args[1], interp)
@@ -252,22 +865,25 @@ func main() {
 			firstLine = false
 		}
 
-		// Debug: show what was read
-		// fmt.Printf("[REPL RAW BUFFER]\n%q\n", buffer.String())
-		for i, c := range buffer.String() {
-			fmt.Printf("%03d: %q (%d)\n", i, c, c)
-		}
+		trace.Logger(trace.Interp).Debug("repl input", "source", buffer.String())
 
 		lex := lexer.New(buffer.String())
-		par := parser.New(lex)
+		par := newParser(lex)
 		stmts, err := par.Parse()
 
 		// fmt.Printf("DEBUG stms: %#v\n", stmts)
-		if err == parser.ErrIncomplete {
+		if errors.Is(err, parser.ErrIncomplete) {
 			prompt = "... "
 			continue
 		} else if err != nil {
-			fmt.Printf("Parser error: %v \n", err)
+			report(os.Stdout, "", buffer.String(), err)
+			prompt = ">>> "
+			continue
+		}
+		errs, warnings := interp.Check(stmts)
+		report(os.Stdout, "", buffer.String(), warnings...)
+		if len(errs) > 0 {
+			report(os.Stdout, "", buffer.String(), errs...)
 			prompt = ">>> "
 			continue
 		}
@@ -278,7 +894,7 @@ func main() {
 			case *ast.ExprStmt:
 				res := interp.Eval(s.Expr)
 				if res.Err != nil {
-					fmt.Printf("Runtime error: %v\n", res.Err)
+					report(os.Stdout, "", buffer.String(), res.Err)
 					break
 				}
 				result := res.Value
@@ -289,7 +905,7 @@ func main() {
 			default:
 				result := interp.Execute(stmt)
 				if result.Err != nil {
-					fmt.Printf("Runtime Error %v\n", result.Err)
+					report(os.Stdout, "", buffer.String(), result.Err)
 				}
 				// err := interp.Execute(stmt)
 				// if err != nil {
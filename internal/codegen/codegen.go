@@ -7,8 +7,11 @@ import (
 	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
 	tokens "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/tokentoval"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/trace"
 )
 
+var log = trace.Logger(trace.Codegen)
+
 type Codegen struct {
 	entryBuilder strings.Builder
 	builder      strings.Builder
@@ -17,7 +20,8 @@ type Codegen struct {
 	nextStrIndex int
 	symbols      map[string]VariableInfo
 	// symbolTypes  map[string]string
-	nextReg int
+	nextReg   int
+	nextLabel int
 }
 
 func NewCodeGen() *Codegen {
@@ -61,6 +65,7 @@ func (c *Codegen) emitStructDefinition(info *StructTypeInfo) {
 }
 
 func (c *Codegen) GenerateLLVM(stmts []ast.Stmt) (string, error) {
+	log.Debug("generate", "statements", len(stmts))
 	c.emitPreamble()
 	for _, stmt := range stmts {
 		if s, ok := stmt.(*ast.StructStmt); ok {
@@ -100,6 +105,12 @@ func (c *Codegen) freshReg() string {
 	return reg
 }
 
+func (c *Codegen) freshLabel(prefix string) string {
+	label := fmt.Sprintf("%s%d", prefix, c.nextLabel)
+	c.nextLabel++
+	return label
+}
+
 func (c *Codegen) emitExpr(e ast.Expr) (string, string) {
 	switch expr := e.(type) {
 	case *ast.LiteralExpr:
@@ -119,21 +130,131 @@ func (c *Codegen) emitExpr(e ast.Expr) (string, string) {
 		return reg, typ.LLVMType
 	case *ast.StructLiteralExpr:
 		return c.emitStructLiteralExpr(expr)
+	case *ast.BinaryExpr:
+		return c.emitBinaryExpr(expr)
+	case *ast.UnaryExpr:
+		return c.emitUnaryExpr(expr)
 	default:
 		panic(fmt.Sprintf("unsupported expr %T", expr))
 	}
 }
 
+// intBinaryInstrs maps integer binary operators to their LLVM instructions.
+var intBinaryInstrs = map[tokens.TokenType]string{
+	tokens.TokenPlus:     "add",
+	tokens.TokenMinus:    "sub",
+	tokens.TokenStar:     "mul",
+	tokens.TokenFWDSlash: "sdiv",
+	tokens.TokenPercent:  "srem",
+	tokens.TokenAmper:    "and",
+	tokens.TokenPipe:     "or",
+	tokens.TokenCaret:    "xor",
+	tokens.TokenShl:      "shl",
+	tokens.TokenShr:      "ashr",
+}
+
+// intCompareConds maps integer comparisons to their icmp conditions.
+var intCompareConds = map[tokens.TokenType]string{
+	tokens.TokenEqality:   "eq",
+	tokens.TokenBangEqal:  "ne",
+	tokens.TokenLess:      "slt",
+	tokens.TokenLessEq:    "sle",
+	tokens.TokenGreater:   "sgt",
+	tokens.TokenGreaterEq: "sge",
+}
+
+func (c *Codegen) emitBinaryExpr(expr *ast.BinaryExpr) (string, string) {
+	instr, ok := intBinaryInstrs[expr.Operator.Type]
+	cond, isCompare := intCompareConds[expr.Operator.Type]
+	if !ok && !isCompare {
+		panic(fmt.Sprintf("unsupported binary operator %s", expr.Operator.Lexeme))
+	}
+	leftReg, leftType := c.emitExpr(expr.Left)
+	rightReg, rightType := c.emitExpr(expr.Right)
+	if leftType != "i64" || rightType != "i64" {
+		panic(fmt.Sprintf("operator %s requires int operands, got %s and %s", expr.Operator.Lexeme, leftType, rightType))
+	}
+	if isCompare {
+		reg := c.freshReg()
+		c.builder.WriteString(fmt.Sprintf(" %s = icmp %s i64 %s, %s\n", reg, cond, leftReg, rightReg))
+		return reg, "i1"
+	}
+	if instr == "shl" || instr == "ashr" {
+		// Shifting by 64 or more is poison in LLVM. Take the count modulo
+		// 64, as the interpreter does.
+		rightReg = c.emitIntOp("and", rightReg, "63")
+	}
+	if instr == "sdiv" || instr == "srem" {
+		return c.emitDivision(instr, leftReg, rightReg), "i64"
+	}
+	return c.emitIntOp(instr, leftReg, rightReg), "i64"
+}
+
+// emitDivision emits sdiv or srem with the checks the interpreter makes.
+// Dividing by zero stops the program with the interpreter's error, where
+// LLVM leaves it undefined. The minimum int divided by -1 overflows, which
+// LLVM also leaves undefined, so it wraps as it does in the interpreter.
+func (c *Codegen) emitDivision(instr, left, right string) string {
+	isZero := c.freshReg()
+	c.builder.WriteString(fmt.Sprintf(" %s = icmp eq i64 %s, 0\n", isZero, right))
+	zero, ok := c.freshLabel("div_zero"), c.freshLabel("div_ok")
+	c.builder.WriteString(fmt.Sprintf(" br i1 %s, label %%%s, label %%%s\n", isZero, zero, ok))
+	c.builder.WriteString(zero + ":\n")
+	c.builder.WriteString(" call void @division_by_zero()\n unreachable\n")
+	c.builder.WriteString(ok + ":\n")
+
+	isMinusOne := c.freshReg()
+	c.builder.WriteString(fmt.Sprintf(" %s = icmp eq i64 %s, -1\n", isMinusOne, right))
+	divisor := c.freshReg()
+	c.builder.WriteString(fmt.Sprintf(" %s = select i1 %s, i64 1, i64 %s\n", divisor, isMinusOne, right))
+	result := c.emitIntOp(instr, left, divisor)
+	overflowed := "0"
+	if instr == "sdiv" {
+		overflowed = c.emitIntOp("sub", "0", left)
+	}
+	reg := c.freshReg()
+	c.builder.WriteString(fmt.Sprintf(" %s = select i1 %s, i64 %s, i64 %s\n", reg, isMinusOne, overflowed, result))
+	return reg
+}
+
+func (c *Codegen) emitUnaryExpr(expr *ast.UnaryExpr) (string, string) {
+	operandReg, operandType := c.emitExpr(expr.Right)
+	if expr.Operator.Type == tokens.TokenBang {
+		if operandType != "i1" {
+			panic(fmt.Sprintf("operator ! requires bool operand, got %s", operandType))
+		}
+		reg := c.freshReg()
+		c.builder.WriteString(fmt.Sprintf(" %s = xor i1 %s, true\n", reg, operandReg))
+		return reg, "i1"
+	}
+	if operandType != "i64" {
+		panic(fmt.Sprintf("operator %s requires int operand, got %s", expr.Operator.Lexeme, operandType))
+	}
+	switch expr.Operator.Type {
+	case tokens.TokenTilde:
+		return c.emitIntOp("xor", operandReg, "-1"), "i64"
+	case tokens.TokenMinus:
+		return c.emitIntOp("sub", "0", operandReg), "i64"
+	default:
+		panic(fmt.Sprintf("unsupported unary operator %s", expr.Operator.Lexeme))
+	}
+}
+
+func (c *Codegen) emitIntOp(instr, left, right string) string {
+	reg := c.freshReg()
+	c.builder.WriteString(fmt.Sprintf(" %s = %s i64 %s, %s\n", reg, instr, left, right))
+	return reg
+}
+
 func (c *Codegen) loadVariable(name string) (string, VariableInfo) {
-	allocaReg, ok := c.symbols[name]
+	info, ok := c.symbols[name]
 	if !ok {
 		panic("undefined varaible: " + name)
 	}
-	llvmType := c.symbols[name]
 	loadReg := c.freshReg()
 
-	c.builder.WriteString(fmt.Sprintf(" %s = load %s, %s* %s\n", loadReg, llvmType, llvmType, allocaReg))
-	return loadReg, llvmType
+	c.builder.WriteString(fmt.Sprintf(" %s = load %s, %s* %s\n", loadReg, info.LLVMType, info.LLVMType, info.LLVMName))
+	return loadReg, info
 }
 
 func (c *Codegen) emitVarStmt(s *ast.VarStmt) {
@@ -142,14 +263,21 @@ func (c *Codegen) emitVarStmt(s *ast.VarStmt) {
 
 	allocaReg := c.freshReg()
 	c.builder.WriteString(fmt.Sprintf(" %s = alloca %s\n", allocaReg, llvmType))
+	if s.Init == nil {
+		c.symbols[name] = VariableInfo{
+			LLVMName: allocaReg,
+			LLVMType: llvmType,
+		}
+		return
+	}
 
-	initValReg, initValType := c.emitExpr(s.Init)
+	initValReg, _ := c.emitExpr(s.Init)
 
 	if len(llvmType) > 0 && llvmType[0] == '%' {
 		structName := llvmType[1:]
 		structInfo, ok := c.structs[structName]
 		if !ok {
-			fmt.Printf("unkown struct type %s for print", structName)
+			log.Warn("unknown struct type for print", "struct", structName)
 		}
 
 		for i := range structInfo.FieldNames {
@@ -172,8 +300,10 @@ func (c *Codegen) emitVarStmt(s *ast.VarStmt) {
 
 			c.builder.WriteString(fmt.Sprintf(
 				" store %s %s, %s* %s\n",
-				initValType, initValReg, initValType, allocaReg))
+				fieldType, loadReg, fieldType, fieldPtrReg))
 		}
+	} else {
+		c.builder.WriteString(fmt.Sprintf(" store %s %s, %s* %s\n", llvmType, initValReg, llvmType, allocaReg))
 	}
 
 	c.symbols[name] = VariableInfo{
@@ -228,6 +358,16 @@ func (c *Codegen) emitPreamble() {
 	@print_str_open_brace = private constant [2 x i8] c"{\00"
 	@print_str_close_brace = private constant [2 x i8] c"}\00"
 	@print_str_comma = private constant [3 x i8] c", \00"
+	@print_true = private constant [5 x i8] c"true\00"
+	@print_false = private constant [6 x i8] c"false\00"
+	declare i32 @dprintf(i32, i8*, ...)
+	declare void @exit(i32)
+	@division_by_zero_message = private constant [32 x i8] c"error[E0602]: division by zero\0A\00"
+	define private void @division_by_zero() {
+	 call i32 (i32, i8*, ...) @dprintf(i32 2, i8* getelementptr ([32 x i8], [32 x i8]* @division_by_zero_message, i32 0, i32 0))
+	 call void @exit(i32 1)
+	 unreachable
+	}
 	`)
 }
 
@@ -258,6 +398,26 @@ func (c *Codegen) llvmTypeForTypeExpr(typ *ast.TypeExpr) string {
 	}
 }
 
+// emitShortVarStmt declares a variable with the type of its initializer. A
+// struct literal is already a fresh alloca, so the variable is that alloca.
+func (c *Codegen) emitShortVarStmt(s *ast.ShortVarStmt) {
+	initValReg, initValType := c.emitExpr(s.Init)
+	if structType, ok := strings.CutSuffix(initValType, "*"); ok && strings.HasPrefix(structType, "%") {
+		c.symbols[s.Name.Lexeme] = VariableInfo{
+			LLVMName: initValReg,
+			LLVMType: structType,
+		}
+		return
+	}
+	allocaReg := c.freshReg()
+	c.builder.WriteString(fmt.Sprintf(" %s = alloca %s\n", allocaReg, initValType))
+	c.builder.WriteString(fmt.Sprintf(" store %s %s, %s* %s\n", initValType, initValReg, initValType, allocaReg))
+	c.symbols[s.Name.Lexeme] = VariableInfo{
+		LLVMName: allocaReg,
+		LLVMType: initValType,
+	}
+}
+
 func (c *Codegen) emitStructLiteralExpr(expr *ast.StructLiteralExpr) (string, string) {
 	structName := expr.TypeName.Name.Lexeme
 	structType := "%" + structName
@@ -321,6 +481,11 @@ func (c *Codegen) emitStructStmt(s *ast.StructStmt) {
 		FieldIndices: fieldIndices,
 		Emitted:      false,
 	}
+	// The format is a string constant, so it has to be known before main.
+	if format, ok := structPrintFormat(st); ok {
+		st.PrintFormat = format
+		c.registerStringLiteral(format)
+	}
 	c.structs.Register(st)
 	c.emitStructDefinition(st)
 	st.Emitted = true
@@ -349,11 +514,38 @@ func (c *Codegen) emitStmt(s ast.Stmt) {
 		c.emitStructStmt(stmt)
 	case *ast.VarStmt:
 		c.emitVarStmt(stmt)
+	case *ast.ShortVarStmt:
+		c.emitShortVarStmt(stmt)
+	case *ast.AssignStmt:
+		c.emitAssign(stmt.Name.Lexeme, stmt.Value, nil)
+	case *ast.CompoundAssignStmt:
+		op := stmt.Operator
+		op.Type = tokens.CompoundOperators[stmt.Operator.Type]
+		c.emitAssign(stmt.Name.Lexeme, stmt.Value, &op)
 	default:
-		fmt.Printf("warning: unsupported statement type: %T\n", stmt)
+		log.Warn("unsupported statement", "type", fmt.Sprintf("%T", stmt))
 	}
 }
 
+// emitAssign stores value into an existing scalar variable. With a non-nil op the
+// variable's current value is combined with value first, as in x op= value.
+func (c *Codegen) emitAssign(name string, value ast.Expr, op *tokens.Token) {
+	info, ok := c.symbols[name]
+	if !ok {
+		panic("undefined varaible: " + name)
+	}
+	var rhs ast.Expr = value
+	if op != nil {
+		rhs = &ast.BinaryExpr{
+			Left:     &ast.VariableExpr{Name: tokens.Token{Type: tokens.TokenIdentifier, Lexeme: name}},
+			Operator: *op,
+			Right:    value,
+		}
+	}
+	valReg, valType := c.emitExpr(rhs)
+	c.builder.WriteString(fmt.Sprintf(" store %s %s, %s* %s\n", valType, valReg, info.LLVMType, info.LLVMName))
+}
+
 func (c *Codegen) lookupVariable(name string) (VariableInfo, bool) {
 	info, ok := c.symbols[name]
 	return info, ok
@@ -364,14 +556,17 @@ func (c *Codegen) emitPrint(s *ast.PrintStmt) {
 	case *ast.LiteralExpr:
 		pl := &printableLiteralExp{lit: expr}
 		if err := pl.EmitPrint(c); err != nil {
-			fmt.Printf("error emitting print: %v", err)
+			log.Warn("emitting print", "err", err)
 		}
 	case *ast.VariableExpr:
 		pv := &printableVariableExpr{varExpr: expr}
 		if err := pv.EmitPrint(c); err != nil {
-			fmt.Printf("error emitting print: %v\n", err)
+			log.Warn("emitting print", "err", err)
 		}
 	default:
-		fmt.Printf("unsupported print expression type: %T\n", expr)
+		pe := &printableExpr{expr: expr}
+		if err := pe.EmitPrint(c); err != nil {
+			log.Warn("emitting print", "err", err)
+		}
 	}
 }
@@ -8,6 +8,9 @@ type StructTypeInfo struct {
 	FieldNames   []string
 	FieldIndices map[string]int
 	Emitted      bool
+	// PrintFormat is the printf format print uses for the struct, or empty
+	// if a field has a type print does not support.
+	PrintFormat string
 }
 
 func NewStructTypes() structTypes {
@@ -2,6 +2,7 @@ package codegen
 
 import (
 	"fmt"
+	"strings"
 
 	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/tokentoval"
@@ -16,6 +17,11 @@ type printableVariableExpr struct {
 	varExpr *ast.VariableExpr
 }
 
+// printableExpr is any other expression, printed by the type of its value.
+type printableExpr struct {
+	expr ast.Expr
+}
+
 type Printable interface {
 	EmitPrint(c *Codegen) error
 }
@@ -23,7 +29,7 @@ type Printable interface {
 func (p *printableLiteralExp) EmitPrint(c *Codegen) error {
 	val, err := tokentoval.Convert(p.lit.Value)
 	if err != nil {
-		fmt.Printf("error converting token to value %v\n", err)
+		log.Warn("converting token to value", "err", err)
 		return err
 	}
 	llvmLiteral := c.emitValueLiteral(val)
@@ -37,7 +43,7 @@ func (p *printableLiteralExp) EmitPrint(c *Codegen) error {
 	case value.ValueString:
 		formatName = "@print_str_format"
 	default:
-		fmt.Printf("usupported literal type in print %v", val.Type)
+		log.Warn("unsupported literal type in print", "type", val.Type)
 	}
 	c.builder.WriteString(fmt.Sprintf(
 		"call i32 (i8*,...) @printf(i8* getelementptr ([4 x i8], [4 x i8]* %s, i32 0, i32 0), %s)",
@@ -58,8 +64,11 @@ func (p *printableVariableExpr) EmitPrint(c *Codegen) error {
 			return fmt.Errorf("unkown struct type %s for print", structName)
 		}
 
-		c.builder.WriteString(`call i32 (i8*,...) @printf(i8* getelementptr ([2 x i8], [2 x i8]* @print_str_open_brace, i32 0, i32 0))` + "\n")
+		if structInfo.PrintFormat == "" {
+			return fmt.Errorf("unsupported struct field types %v for print", structInfo.FieldTypes)
+		}
 
+		args := make([]string, len(structInfo.FieldNames))
 		for i := range structInfo.FieldNames {
 			fieldType := structInfo.FieldTypes[i]
 
@@ -72,29 +81,13 @@ func (p *printableVariableExpr) EmitPrint(c *Codegen) error {
 			c.builder.WriteString(fmt.Sprintf(
 				"%s = load %s, %s* %s\n",
 				loadReg, fieldType, fieldType, gepReg))
-
-			switch fieldType {
-			case "i64":
-				c.builder.WriteString(fmt.Sprintf(
-					"call i32 (i8*,...) @printf(i8* getelementptr ([4 x i8], [4 x i8]* @print_int_format, i32 0, i32 0), i64 %s)\n",
-					loadReg))
-			case "double":
-				c.builder.WriteString(fmt.Sprintf(
-					"call i32 (i8*,...) @printf(i8* getelementptr ([4 x i8], [4 x i8]* @print_float_format, i32 0, i32 0), double %s)\n",
-					loadReg))
-			case "i8*":
-				c.builder.WriteString(fmt.Sprintf(
-					"call i32 (i8*,...) @printf(i8* getelementptr ([4 x i8], [4 x i8]* @print_str_format, i32 0, i32 0), i8* %s)\n",
-					loadReg))
-			default:
-				return fmt.Errorf("unsupported struct field type %s for print", fieldType)
-
-			}
-			if i < len(structInfo.FieldNames)-1 {
-				c.builder.WriteString(`call i32 (i8*,...) @printf(i8* getelementptr ([2 x i8], [2 x i8]* @print_str_comma, i32 0, i32 0))` + "\n")
-			}
+			args[i] = fieldType + " " + loadReg
 		}
-		c.builder.WriteString(`call i32 (i8*,...) @printf(i8* getelementptr ([2 x i8], [2 x i8]* @print_str_close_brace, i32 0, i32 0))` + "\n")
+		formatName := c.registerStringLiteral(structInfo.PrintFormat)
+		length := len(structInfo.PrintFormat) + 1
+		c.builder.WriteString(fmt.Sprintf(
+			"call i32 (i8*,...) @printf(i8* getelementptr ([%d x i8], [%d x i8]* %s, i32 0, i32 0), %s)\n",
+			length, length, formatName, strings.Join(args, ", ")))
 		return nil
 	}
 
@@ -110,10 +103,60 @@ func (p *printableVariableExpr) EmitPrint(c *Codegen) error {
 		return fmt.Errorf("unsupported variable type %s for print", llvmVarInfo.LLVMType)
 	}
 
+	loadReg, _ := c.loadVariable(p.varExpr.Name.Lexeme)
 	c.builder.WriteString(fmt.Sprintf(
 		"call i32 (i8*,...) @printf(i8* getelementptr ([4 x i8], [4 x i8]* %s, i32 0, i32 0), %s %s)",
-		formatName, llvmVarInfo.LLVMType, llvmVarInfo.LLVMName))
+		formatName, llvmVarInfo.LLVMType, loadReg))
 
 	return nil
 
 }
+
+func (p *printableExpr) EmitPrint(c *Codegen) error {
+	reg, typ := c.emitExpr(p.expr)
+	var formatName string
+	switch typ {
+	case "i64":
+		formatName = "@print_int_format"
+	case "double":
+		formatName = "@print_float_format"
+	case "i8*":
+		formatName = "@print_str_format"
+	case "i1":
+		// Bools print as true or false, as in the interpreter.
+		strReg := c.freshReg()
+		c.builder.WriteString(fmt.Sprintf(
+			" %s = select i1 %s, i8* getelementptr ([5 x i8], [5 x i8]* @print_true, i32 0, i32 0), i8* getelementptr ([6 x i8], [6 x i8]* @print_false, i32 0, i32 0)\n",
+			strReg, reg))
+		reg, typ, formatName = strReg, "i8*", "@print_str_format"
+	default:
+		return fmt.Errorf("unsupported expression type %s for print", typ)
+	}
+	c.builder.WriteString(fmt.Sprintf(
+		"call i32 (i8*,...) @printf(i8* getelementptr ([4 x i8], [4 x i8]* %s, i32 0, i32 0), %s %s)\n",
+		formatName, typ, reg))
+	return nil
+}
+
+// structPrintFormat returns the printf format that prints a struct the way
+// the interpreter does, Name{field: value, ...} with the fields in
+// declaration order. ok is false if a field has a type print does not
+// support.
+func structPrintFormat(info *StructTypeInfo) (format string, ok bool) {
+	fields := make([]string, len(info.FieldNames))
+	for i, name := range info.FieldNames {
+		var verb string
+		switch info.FieldTypes[i] {
+		case "i64":
+			verb = "%lld"
+		case "double":
+			verb = "%g"
+		case "i8*":
+			verb = "%s"
+		default:
+			return "", false
+		}
+		fields[i] = name + ": " + verb
+	}
+	return info.Name + "{" + strings.Join(fields, ", ") + "}\n", true
+}
@@ -10,10 +10,12 @@ const (
 	FlowReturn
 	FlowBreak
 	FlowContinue
+	FlowFallthrough
 )
 
 type ExecResult struct {
 	Value value.Value
 	Flow  ControlFlow
 	Err   error
+	Label string
 }
@@ -0,0 +1,263 @@
+// Package coverage records which lines of a program ran, across any number
+// of interpreter runs, and reports them per file and per function. Profiles
+// are written and read in the LCOV tracefile format, so that runs recorded
+// separately can be merged and other LCOV tools can show them.
+package coverage
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+)
+
+// Func is a function of a file, declared on Line and ending on EndLine. Its
+// lines are those after Line up to EndLine that are not in a function nested
+// inside it; the declaration itself runs in the enclosing scope.
+type Func struct {
+	Name          string
+	Line, EndLine int
+}
+
+// File is the coverage of one file.
+type File struct {
+	Path string
+	// Lines counts the runs of each line that has a statement on it. A line
+	// runs when its first statement does.
+	Lines map[int]int64
+	Funcs []Func
+}
+
+// Profile is the coverage of a set of files. It is safe to record into from
+// several interpreters at once.
+type Profile struct {
+	mu    sync.Mutex
+	files map[string]*File
+	// firsts holds, for each registered file, the position of the first
+	// statement on each of its lines.
+	firsts map[string]map[[2]int]bool
+}
+
+// New returns an empty profile.
+func New() *Profile {
+	return &Profile{files: make(map[string]*File), firsts: make(map[string]map[[2]int]bool)}
+}
+
+// Register adds the statements and functions of stmts, parsed from the file
+// at path, to p, so that the lines that never run are reported too.
+// Registering a file again keeps what was recorded for it.
+func (p *Profile) Register(path string, stmts []ast.Stmt) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	file := p.file(path)
+	firsts := make(map[[2]int]bool)
+	firstCol := make(map[int]int)
+	var visit func(node ast.Node, fn string) bool
+	inspect := func(node ast.Node, fn string) {
+		ast.Inspect(node, func(node ast.Node) bool { return visit(node, fn) })
+	}
+	visit = func(node ast.Node, fn string) bool {
+		stmt, ok := node.(ast.Stmt)
+		if !ok {
+			return true
+		}
+		if _, isBlock := stmt.(*ast.BlockStmt); !isBlock {
+			line, col := stmt.Pos()
+			if prev, seen := firstCol[line]; !seen || col < prev {
+				firstCol[line] = col
+			}
+			if _, ok := file.Lines[line]; !ok {
+				file.Lines[line] = 0
+			}
+		}
+		switch s := stmt.(type) {
+		case *ast.FuncStmt:
+			name := qualify(fn, s.Name.Lexeme)
+			file.addFunc(name, s)
+			inspect(s.Body, name)
+			return false
+		case *ast.StructStmt:
+			// Fields are declarations, and methods are defined along with
+			// the struct, so only the method bodies run.
+			for idx := range s.Methods {
+				method := &s.Methods[idx]
+				name := qualify(fn, s.Name.Lexeme+"."+method.Name.Lexeme)
+				file.addFunc(name, method)
+				inspect(method.Body, name)
+			}
+			return false
+		}
+		return true
+	}
+	for _, stmt := range stmts {
+		inspect(stmt, "")
+	}
+	for line, col := range firstCol {
+		firsts[[2]int{line, col}] = true
+	}
+	if p.firsts[path] == nil {
+		p.firsts[path] = firsts
+	} else {
+		maps.Copy(p.firsts[path], firsts)
+	}
+}
+
+// qualify names a function declared inside another after it.
+func qualify(outer, name string) string {
+	if outer == "" {
+		return name
+	}
+	return outer + "." + name
+}
+
+func (f *File) addFunc(name string, stmt *ast.FuncStmt) {
+	_, last := ast.Bounds(stmt)
+	fn := Func{Name: name, Line: stmt.Func.Line, EndLine: last.Line}
+	if !slices.Contains(f.Funcs, fn) {
+		f.Funcs = append(f.Funcs, fn)
+	}
+}
+
+// file returns the coverage of path, creating it if need be. The caller
+// holds p.mu.
+func (p *Profile) file(path string) *File {
+	file, ok := p.files[path]
+	if !ok {
+		file = &File{Path: path, Lines: make(map[int]int64)}
+		p.files[path] = file
+	}
+	return file
+}
+
+// Hook returns an interpreter Hook that records the statements run from the
+// registered file at path.
+func (p *Profile) Hook(path string) func(*interpreter.Interpreter, ast.Stmt) error {
+	return func(_ *interpreter.Interpreter, stmt ast.Stmt) error {
+		line, col := stmt.Pos()
+		p.mu.Lock()
+		if p.firsts[path][[2]int{line, col}] {
+			p.files[path].Lines[line]++
+		}
+		p.mu.Unlock()
+		return nil
+	}
+}
+
+// Merge adds the coverage recorded in other to p.
+func (p *Profile) Merge(other *Profile) {
+	if p == other {
+		return
+	}
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for path, theirs := range other.files {
+		ours := p.file(path)
+		for line, hits := range theirs.Lines {
+			ours.Lines[line] += hits
+		}
+		for _, fn := range theirs.Funcs {
+			if !slices.Contains(ours.Funcs, fn) {
+				ours.Funcs = append(ours.Funcs, fn)
+			}
+		}
+	}
+}
+
+// Files returns the files of p, ordered by path.
+func (p *Profile) Files() []*File {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.SortedFunc(maps.Values(p.files), func(a, b *File) int {
+		return cmp.Compare(a.Path, b.Path)
+	})
+}
+
+// Covered returns how many of the file's lines ran, and how many it has.
+func (f *File) Covered() (covered, total int) {
+	for _, hits := range f.Lines {
+		if hits > 0 {
+			covered++
+		}
+	}
+	return covered, len(f.Lines)
+}
+
+// FuncLines returns the lines of fn, in order.
+func (f *File) FuncLines(fn Func) []int {
+	var lines []int
+	for line := range f.Lines {
+		if inner, ok := f.innermost(line); ok && inner == fn {
+			lines = append(lines, line)
+		}
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// innermost returns the function whose lines include line.
+func (f *File) innermost(line int) (Func, bool) {
+	var found Func
+	ok := false
+	for _, fn := range f.Funcs {
+		if fn.Line < line && line <= fn.EndLine && (!ok || fn.Line >= found.Line && fn.EndLine <= found.EndLine) {
+			found, ok = fn, true
+		}
+	}
+	return found, ok
+}
+
+// funcs returns the file's functions ordered by line.
+func (f *File) funcs() []Func {
+	return slices.SortedFunc(slices.Values(f.Funcs), func(a, b Func) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Name, b.Name))
+	})
+}
+
+// Percent returns the share of the lines of p that ran, as a percentage.
+func (p *Profile) Percent() float64 {
+	var covered, total int
+	for _, file := range p.Files() {
+		c, t := file.Covered()
+		covered, total = covered+c, total+t
+	}
+	return percent(covered, total)
+}
+
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+// WriteSummary writes the share of the lines covered in each function, each
+// file and the whole profile.
+func (p *Profile) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, '\t', 0)
+	var covered, total int
+	for _, file := range p.Files() {
+		for _, fn := range file.funcs() {
+			lines := file.FuncLines(fn)
+			hit := 0
+			for _, line := range lines {
+				if file.Lines[line] > 0 {
+					hit++
+				}
+			}
+			fmt.Fprintf(tw, "%s:%d:\t%s\t%.1f%%\n", file.Path, fn.Line, fn.Name, percent(hit, len(lines)))
+		}
+		c, t := file.Covered()
+		covered, total = covered+c, total+t
+		fmt.Fprintf(tw, "%s:\t(file)\t%.1f%%\n", file.Path, percent(c, t))
+	}
+	fmt.Fprintf(tw, "total:\t(lines)\t%.1f%%\n", percent(covered, total))
+	return tw.Flush()
+}
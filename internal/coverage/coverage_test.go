@@ -0,0 +1,148 @@
+package coverage_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/coverage"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+)
+
+const source = `func sign(n: int) -> int {
+    if n < 0 {
+        return -1
+    }
+    return 1
+}
+
+struct Box {
+    v: int
+    func get() -> int {
+        return 1
+    }
+}
+
+var i: int = 0
+while i < 2 { i = i + 1 }
+print(sign(N))
+`
+
+// run runs source, with N defined as n, recording into cover.
+func run(t *testing.T, cover *coverage.Profile, n string) {
+	t.Helper()
+	value.BuiltinTypesInit()
+	src := "var N: int = " + n + "\n" + source
+	stmts, err := parser.New(lexer.New(src)).Parse()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	cover.Register("prog.nif", stmts)
+	interp := interpreter.NewInterpreter()
+	interp.Out = io.Discard
+	if errs, _ := interp.Check(stmts); len(errs) > 0 {
+		t.Fatalf("check: %v", errs)
+	}
+	interp.Hook = cover.Hook("prog.nif")
+	for _, stmt := range stmts {
+		if result := interp.Execute(stmt); result.Err != nil {
+			t.Fatalf("run: %v", result.Err)
+		}
+	}
+}
+
+func TestCoverage_Lines(t *testing.T) {
+	cover := coverage.New()
+	run(t, cover, "5")
+	files := cover.Files()
+	if len(files) != 1 {
+		t.Fatalf("expected one file, got %d", len(files))
+	}
+	file := files[0]
+	// Line 17 is a loop on one line. It runs once: the statement in its
+	// body is not the first on the line.
+	want := map[int]int64{1: 1, 2: 1, 3: 1, 4: 0, 6: 1, 9: 1, 12: 0, 16: 1, 17: 1, 18: 1}
+	for line, hits := range want {
+		if got, ok := file.Lines[line]; !ok || got != hits {
+			t.Errorf("line %d: expected %d hits, got %d (present %v)", line, hits, got, ok)
+		}
+	}
+	if len(file.Lines) != len(want) {
+		t.Errorf("expected lines %v, got %v", want, file.Lines)
+	}
+	if got := file.FuncLines(coverage.Func{Name: "sign", Line: 2, EndLine: 6}); len(got) != 3 {
+		t.Errorf("expected sign to have 3 lines, got %v", got)
+	}
+}
+
+func TestCoverage_MergeAndLCOV(t *testing.T) {
+	first, second := coverage.New(), coverage.New()
+	run(t, first, "5")
+	run(t, second, "-5")
+
+	var a, b strings.Builder
+	if err := first.WriteLCOV(&a); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.WriteLCOV(&b); err != nil {
+		t.Fatal(err)
+	}
+	merged := coverage.New()
+	for _, lcov := range []string{a.String(), b.String()} {
+		cover, err := coverage.ReadLCOV(strings.NewReader(lcov))
+		if err != nil {
+			t.Fatalf("ReadLCOV: %v\n%s", err, lcov)
+		}
+		merged.Merge(cover)
+	}
+
+	var out strings.Builder
+	if err := merged.WriteLCOV(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"SF:prog.nif\n",
+		"FN:2,6,sign\nFN:11,12,Box.get\n",
+		"FNDA:2,sign\nFNDA:0,Box.get\n",
+		"FNF:2\nFNH:1\n",
+		"DA:3,2\nDA:4,1\nDA:6,1\n",
+		"LF:10\nLH:9\nend_of_record\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected merged profile to contain %q, got:\n%s", want, out.String())
+		}
+	}
+
+	var summary strings.Builder
+	if err := merged.WriteSummary(&summary); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"prog.nif:2:\tsign\t100.0%", "prog.nif:11:\tBox.get\t0.0%", "prog.nif:\t(file)\t90.0%", "total:\t(lines)\t90.0%"} {
+		if !strings.Contains(strings.Join(strings.Fields(summary.String()), "\t"), strings.Join(strings.Fields(want), "\t")) {
+			t.Errorf("expected summary to contain %q, got:\n%s", want, summary.String())
+		}
+	}
+}
+
+func TestReadLCOV_Errors(t *testing.T) {
+	for _, lcov := range []string{
+		"SF:a.nif\nDA:x,1\nend_of_record\n",
+		"SF:a.nif\nDA:1\nend_of_record\n",
+		"SF:a.nif\nFN:f\nend_of_record\n",
+	} {
+		if _, err := coverage.ReadLCOV(strings.NewReader(lcov)); err == nil {
+			t.Errorf("expected an error reading %q", lcov)
+		}
+	}
+	// Records from other tools are skipped.
+	cover, err := coverage.ReadLCOV(strings.NewReader("TN:\nSF:a.nif\nBRDA:1,0,0,1\nDA:1,3,abc\nend_of_record\n"))
+	if err != nil {
+		t.Fatalf("ReadLCOV: %v", err)
+	}
+	if files := cover.Files(); len(files) != 1 || files[0].Lines[1] != 3 {
+		t.Errorf("expected a.nif line 1 with 3 hits, got %+v", files)
+	}
+}
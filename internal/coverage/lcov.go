@@ -0,0 +1,105 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// WriteLCOV writes p as an LCOV tracefile. A function's hit count is that of
+// its first line.
+func (p *Profile) WriteLCOV(w io.Writer) error {
+	var b strings.Builder
+	for _, file := range p.Files() {
+		fmt.Fprintf(&b, "TN:\nSF:%s\n", file.Path)
+		funcsHit := 0
+		for _, fn := range file.funcs() {
+			fmt.Fprintf(&b, "FN:%d,%d,%s\n", fn.Line, fn.EndLine, fn.Name)
+		}
+		for _, fn := range file.funcs() {
+			var hits int64
+			if lines := file.FuncLines(fn); len(lines) > 0 {
+				hits = file.Lines[lines[0]]
+			}
+			if hits > 0 {
+				funcsHit++
+			}
+			fmt.Fprintf(&b, "FNDA:%d,%s\n", hits, fn.Name)
+		}
+		fmt.Fprintf(&b, "FNF:%d\nFNH:%d\n", len(file.Funcs), funcsHit)
+		for _, line := range slices.Sorted(maps.Keys(file.Lines)) {
+			fmt.Fprintf(&b, "DA:%d,%d\n", line, file.Lines[line])
+		}
+		covered, total := file.Covered()
+		fmt.Fprintf(&b, "LF:%d\nLH:%d\nend_of_record\n", total, covered)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ReadLCOV reads an LCOV tracefile, such as WriteLCOV writes. Records it has
+// no use for, such as branch coverage, are skipped.
+func ReadLCOV(r io.Reader) (*Profile, error) {
+	p := New()
+	var file *File
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		kind, data, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if kind == "end_of_record" {
+			file = nil
+			continue
+		}
+		if kind == "SF" {
+			file = p.file(data)
+			continue
+		}
+		if file == nil {
+			continue
+		}
+		var err error
+		switch kind {
+		case "FN":
+			// FN:line,name, or FN:line,end line,name since LCOV 2.
+			fields := strings.Split(data, ",")
+			fn := Func{Name: fields[len(fields)-1]}
+			if len(fields) < 2 {
+				err = fmt.Errorf("want line,[end line,]name")
+				break
+			}
+			if fn.Line, err = strconv.Atoi(fields[0]); err != nil {
+				break
+			}
+			fn.EndLine = fn.Line
+			if len(fields) > 2 {
+				fn.EndLine, err = strconv.Atoi(fields[1])
+			}
+			if err == nil && !slices.Contains(file.Funcs, fn) {
+				file.Funcs = append(file.Funcs, fn)
+			}
+		case "DA":
+			// DA:line,hits[,checksum]
+			fields := strings.Split(data, ",")
+			if len(fields) < 2 {
+				err = fmt.Errorf("want line,hits")
+				break
+			}
+			var line int
+			var hits int64
+			if line, err = strconv.Atoi(fields[0]); err != nil {
+				break
+			}
+			if hits, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+				break
+			}
+			file.Lines[line] += hits
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s record: %w", n, kind, err)
+		}
+	}
+	return p, scanner.Err()
+}
@@ -0,0 +1,421 @@
+// Package debugger runs a program in the interpreter one statement at a time,
+// stopping at breakpoints and after each step, and answers commands about the
+// paused program: its locals, the value of an expression, the call stack.
+package debugger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/environment"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// ErrQuit is returned by Run when the program was stopped with quit, or when
+// the commands ran out.
+var ErrQuit = errors.New("debugger quit")
+
+// Prompt is written before each command is read.
+const Prompt = "(niftel) "
+
+// mode is what the program is doing until it next stops.
+type mode int
+
+const (
+	// modeContinue stops only at breakpoints.
+	modeContinue mode = iota
+	// modeStep stops at the next statement on another line, in any call.
+	modeStep
+	// modeNext stops at the next statement on another line that is not in
+	// a call made from the current one.
+	modeNext
+	// modeFinish stops once the current call has returned.
+	modeFinish
+)
+
+// Debugger runs one program. Commands are read from in, one per line, and
+// answered on out; the program's own output goes wherever the interpreter
+// writes it.
+type Debugger struct {
+	file  string
+	lines []string
+	stmts []ast.Stmt
+	// stmtLines holds the lines a statement starts on, where a breakpoint
+	// can stop.
+	stmtLines   map[int]bool
+	breakpoints map[int]bool
+
+	in  *bufio.Scanner
+	out io.Writer
+
+	// mu is held while the program is stopped, so that spawned tasks that
+	// reach a statement wait for the prompt to be done.
+	mu   sync.Mutex
+	mode mode
+	// stepFrom and stepDepth are the task and call depth that next and
+	// finish are measured from.
+	stepFrom  *interpreter.Interpreter
+	stepDepth int
+	// last is where the program was at its previous statement, so a line
+	// with several statements only stops once each time it is reached.
+	// entry is the statement the line was reached at; reaching it again
+	// means a loop has come round.
+	last struct {
+		interp      *interpreter.Interpreter
+		line, depth int
+		entry       ast.Stmt
+	}
+	// builtins are the globals defined before the program started, which
+	// globals leaves out.
+	builtins map[string]bool
+	// evaluating is set while print runs an expression, whose calls must
+	// not stop. It is checked before taking mu, which print holds.
+	evaluating atomic.Bool
+}
+
+// New returns a debugger for stmts, parsed from source, which was read from
+// file.
+func New(file, source string, stmts []ast.Stmt, in io.Reader, out io.Writer) *Debugger {
+	d := &Debugger{
+		file:        file,
+		lines:       strings.Split(source, "\n"),
+		stmts:       stmts,
+		stmtLines:   make(map[int]bool),
+		breakpoints: make(map[int]bool),
+		in:          bufio.NewScanner(in),
+		out:         out,
+	}
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			if _, ok := node.(ast.Stmt); ok {
+				if _, isBlock := node.(*ast.BlockStmt); !isBlock {
+					line, _ := node.Pos()
+					d.stmtLines[line] = true
+				}
+			}
+			return true
+		})
+	}
+	return d
+}
+
+// Break sets a breakpoint at spec, a line number optionally preceded by the
+// file name and a colon, and returns its line.
+func (d *Debugger) Break(spec string) (int, error) {
+	line, err := d.parseLine(spec)
+	if err != nil {
+		return 0, err
+	}
+	d.breakpoints[line] = true
+	return line, nil
+}
+
+// parseLine parses a [file:]line location, checking that a statement starts
+// on the line.
+func (d *Debugger) parseLine(spec string) (int, error) {
+	if file, rest, ok := strings.Cut(spec, ":"); ok {
+		if file != d.file && filepath.Base(file) != filepath.Base(d.file) {
+			return 0, fmt.Errorf("no file %q: debugging %s", file, d.file)
+		}
+		spec = rest
+	}
+	line, err := strconv.Atoi(spec)
+	if err != nil || line < 1 {
+		return 0, fmt.Errorf("invalid line %q", spec)
+	}
+	if !d.stmtLines[line] {
+		return 0, fmt.Errorf("no statement on line %d", line)
+	}
+	return line, nil
+}
+
+// Run runs the program in interp, stopped before its first statement. It
+// returns the program's runtime error, if any, or ErrQuit if it was stopped.
+func (d *Debugger) Run(interp *interpreter.Interpreter) error {
+	d.builtins = make(map[string]bool)
+	names, _ := globalScope(interp.GetEnv()).Vars()
+	for _, name := range names {
+		d.builtins[name] = true
+	}
+	d.mode = modeStep
+	interp.Hook = d.hook
+	defer func() { interp.Hook = nil }()
+	for _, stmt := range d.stmts {
+		if result := interp.Execute(stmt); result.Err != nil {
+			if errors.Is(result.Err, ErrQuit) {
+				return ErrQuit
+			}
+			return result.Err
+		}
+	}
+	return nil
+}
+
+// hook is the interpreter's Hook: it decides whether to stop before stmt and,
+// if so, reads commands until one resumes the program.
+func (d *Debugger) hook(interp *interpreter.Interpreter, stmt ast.Stmt) error {
+	if d.evaluating.Load() {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	line, _ := stmt.Pos()
+	depth := interp.CallDepth()
+	moved := d.last.interp != interp || d.last.line != line || d.last.depth != depth || d.last.entry == stmt
+	if !moved {
+		return nil
+	}
+	d.last.interp, d.last.line, d.last.depth, d.last.entry = interp, line, depth, stmt
+
+	stop := d.breakpoints[line]
+	switch d.mode {
+	case modeStep:
+		stop = true
+	case modeNext:
+		stop = stop || interp == d.stepFrom && depth <= d.stepDepth
+	case modeFinish:
+		stop = stop || interp == d.stepFrom && depth < d.stepDepth
+	}
+	if !stop {
+		return nil
+	}
+	d.mode = modeContinue
+	d.where(interp, line)
+	return d.prompt(interp, line)
+}
+
+// prompt reads and runs commands until one resumes the program.
+func (d *Debugger) prompt(interp *interpreter.Interpreter, line int) error {
+	for {
+		fmt.Fprint(d.out, Prompt)
+		if !d.in.Scan() {
+			fmt.Fprintln(d.out)
+			return ErrQuit
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(d.in.Text()), " ")
+		arg = strings.TrimSpace(arg)
+		switch cmd {
+		case "":
+		case "s", "step":
+			d.mode = modeStep
+			return nil
+		case "n", "next":
+			d.mode, d.stepFrom, d.stepDepth = modeNext, interp, interp.CallDepth()
+			return nil
+		case "f", "finish":
+			if interp.CallDepth() == 0 {
+				fmt.Fprintln(d.out, "not in a function call")
+				continue
+			}
+			d.mode, d.stepFrom, d.stepDepth = modeFinish, interp, interp.CallDepth()
+			return nil
+		case "c", "continue":
+			d.mode = modeContinue
+			return nil
+		case "q", "quit":
+			return ErrQuit
+		case "b", "break":
+			d.breakCommand(arg)
+		case "clear":
+			d.clearCommand(arg)
+		case "l", "list":
+			d.list(line, 5)
+		case "locals":
+			d.locals(interp)
+		case "globals":
+			d.globals(interp)
+		case "p", "print":
+			d.print(interp, arg)
+		case "bt", "stack":
+			d.stack(interp, line)
+		case "h", "help":
+			fmt.Fprint(d.out, help)
+		default:
+			fmt.Fprintf(d.out, "unknown command %q; try help\n", cmd)
+		}
+	}
+}
+
+const help = `step, s              run to the next line, stepping into calls
+next, n              run to the next line in this call
+finish, f            run until this call returns
+continue, c          run to the next breakpoint
+break, b [file:]line set a breakpoint; with no line, list them
+clear [file:]line    remove a breakpoint
+list, l              show the source around this line
+locals               show the variables of this call
+globals              show the program's global variables
+print, p expr        evaluate expr here
+stack, bt            show the calls in progress
+quit, q              stop the program
+`
+
+// where reports the line the program stopped before.
+func (d *Debugger) where(interp *interpreter.Interpreter, line int) {
+	fn := "<main>"
+	if stack := interp.CallStack(); len(stack) > 0 {
+		fn = stack[0].Function
+	}
+	reason := "stopped"
+	if d.breakpoints[line] {
+		reason = "breakpoint"
+	}
+	fmt.Fprintf(d.out, "%s at %s:%d in %s\n", reason, d.file, line, fn)
+	d.list(line, 0)
+}
+
+// list shows the source lines within context of line, marking line.
+func (d *Debugger) list(line, context int) {
+	for n := max(1, line-context); n <= min(len(d.lines), line+context); n++ {
+		marker := " "
+		if n == line {
+			marker = ">"
+		}
+		fmt.Fprintf(d.out, "%s%4d | %s\n", marker, n, d.lines[n-1])
+	}
+}
+
+func (d *Debugger) breakCommand(arg string) {
+	if arg == "" {
+		if len(d.breakpoints) == 0 {
+			fmt.Fprintln(d.out, "no breakpoints")
+		}
+		for _, line := range d.breakpointLines() {
+			fmt.Fprintf(d.out, "breakpoint at %s:%d\n", d.file, line)
+		}
+		return
+	}
+	line, err := d.Break(arg)
+	if err != nil {
+		fmt.Fprintln(d.out, err)
+		return
+	}
+	fmt.Fprintf(d.out, "breakpoint set at %s:%d\n", d.file, line)
+}
+
+func (d *Debugger) clearCommand(arg string) {
+	line, err := d.parseLine(arg)
+	if err == nil && !d.breakpoints[line] {
+		err = fmt.Errorf("no breakpoint on line %d", line)
+	}
+	if err != nil {
+		fmt.Fprintln(d.out, err)
+		return
+	}
+	delete(d.breakpoints, line)
+	fmt.Fprintf(d.out, "breakpoint cleared at %s:%d\n", d.file, line)
+}
+
+func (d *Debugger) breakpointLines() []int {
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// locals shows the variables of the scopes between the paused statement and
+// the globals, innermost first.
+func (d *Debugger) locals(interp *interpreter.Interpreter) {
+	found := false
+	for env := interp.GetEnv(); env.Parent() != nil; env = env.Parent() {
+		names, values := env.Vars()
+		for idx, name := range names {
+			fmt.Fprintf(d.out, "%s = %s\n", name, describe(values[idx]))
+			found = true
+		}
+	}
+	if !found {
+		fmt.Fprintln(d.out, "no locals")
+	}
+}
+
+func (d *Debugger) globals(interp *interpreter.Interpreter) {
+	found := false
+	names, values := globalScope(interp.GetEnv()).Vars()
+	for idx, name := range names {
+		if !d.builtins[name] {
+			fmt.Fprintf(d.out, "%s = %s\n", name, describe(values[idx]))
+			found = true
+		}
+	}
+	if !found {
+		fmt.Fprintln(d.out, "no globals")
+	}
+}
+
+// print evaluates src, a single expression, in the paused scope. Calls it
+// makes run to completion without stopping.
+func (d *Debugger) print(interp *interpreter.Interpreter, src string) {
+	if src == "" {
+		fmt.Fprintln(d.out, "print needs an expression")
+		return
+	}
+	stmts, err := parser.New(lexer.New(src)).Parse()
+	if err != nil {
+		fmt.Fprintln(d.out, niferrors.As(parser.Errors(err)[0]).Message)
+		return
+	}
+	exprStmt, ok := singleExpr(stmts)
+	if !ok {
+		fmt.Fprintln(d.out, "print needs a single expression")
+		return
+	}
+	d.evaluating.Store(true)
+	result := interp.Evaluate(exprStmt.Expr)
+	d.evaluating.Store(false)
+	if result.Err != nil {
+		fmt.Fprintf(d.out, "error: %s\n", niferrors.As(result.Err).Message)
+		return
+	}
+	fmt.Fprintln(d.out, describe(result.Value))
+}
+
+func singleExpr(stmts []ast.Stmt) (*ast.ExprStmt, bool) {
+	if len(stmts) != 1 {
+		return nil, false
+	}
+	exprStmt, ok := stmts[0].(*ast.ExprStmt)
+	return exprStmt, ok
+}
+
+// stack shows the calls in progress, innermost first, each with the line it
+// has reached.
+func (d *Debugger) stack(interp *interpreter.Interpreter, line int) {
+	stack := interp.CallStack()
+	for idx, frame := range stack {
+		fmt.Fprintf(d.out, "#%d %s at %s:%d\n", idx, frame.Function, d.file, line)
+		line = frame.Line
+	}
+	fmt.Fprintf(d.out, "#%d <main> at %s:%d\n", len(stack), d.file, line)
+}
+
+func globalScope(env *environment.Environment) *environment.Environment {
+	for env.Parent() != nil {
+		env = env.Parent()
+	}
+	return env
+}
+
+// describe shows v the way it would be written in source, so that strings
+// read as strings.
+func describe(v value.Value) string {
+	if s, ok := v.Data.(string); ok && v.Type == value.ValueString {
+		return strconv.Quote(s)
+	}
+	return v.String()
+}
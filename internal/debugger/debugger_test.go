@@ -0,0 +1,159 @@
+package debugger_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/debugger"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+)
+
+const source = `func square(n: int) -> int {
+    var r: int = n * n
+    return r
+}
+
+var total: int = 0
+var i: int = 1
+while i < 4 {
+    total = total + square(i)
+    i = i + 1
+}
+print(total)
+`
+
+// debug runs source under the debugger with the given commands, one per
+// line, and returns what the debugger and the program wrote.
+func debug(t *testing.T, commands string, breaks ...string) (dbgOut, progOut string, err error) {
+	t.Helper()
+	value.BuiltinTypesInit()
+	stmts, err := parser.New(lexer.New(source)).Parse()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	interp := interpreter.NewInterpreter()
+	if errs, _ := interp.Check(stmts); len(errs) > 0 {
+		t.Fatalf("check: %v", errs)
+	}
+	var dbg, prog bytes.Buffer
+	interp.Out = &prog
+	d := debugger.New("prog.nif", source, stmts, strings.NewReader(commands), &dbg)
+	for _, spec := range breaks {
+		if _, err := d.Break(spec); err != nil {
+			t.Fatalf("break %s: %v", spec, err)
+		}
+	}
+	err = d.Run(interp)
+	return dbg.String(), prog.String(), err
+}
+
+// stops lists the lines the debugger stopped at, in order.
+func stops(out string) []string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimPrefix(line, debugger.Prompt)
+		if strings.HasPrefix(line, "stopped at ") || strings.HasPrefix(line, "breakpoint at ") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestDebugger_Step(t *testing.T) {
+	out, prog, err := debug(t, "n\nn\nn\nn\ns\ns\ns\nc\n")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := []string{
+		"stopped at prog.nif:1 in <main>",
+		"stopped at prog.nif:6 in <main>",
+		"stopped at prog.nif:7 in <main>",
+		"stopped at prog.nif:8 in <main>",
+		"stopped at prog.nif:9 in <main>",
+		"stopped at prog.nif:2 in square",
+		"stopped at prog.nif:3 in square",
+		"stopped at prog.nif:10 in <main>",
+	}
+	if got := stops(out); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("stopped at:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if prog != "14\n" {
+		t.Errorf("expected the program to print 14, got %q", prog)
+	}
+}
+
+func TestDebugger_NextSkipsCalls(t *testing.T) {
+	out, _, err := debug(t, "c\nn\nn\nn\nq\n", "prog.nif:9")
+	if !errors.Is(err, debugger.ErrQuit) {
+		t.Fatalf("expected ErrQuit, got %v", err)
+	}
+	want := []string{
+		"stopped at prog.nif:1 in <main>",
+		"breakpoint at prog.nif:9 in <main>",
+		"stopped at prog.nif:10 in <main>",
+		"breakpoint at prog.nif:9 in <main>",
+		"stopped at prog.nif:10 in <main>",
+	}
+	if got := stops(out); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("stopped at:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDebugger_Inspect(t *testing.T) {
+	out, _, err := debug(t, "b 3\nc\nlocals\np r + total\np nope\nbt\nglobals\nfinish\nclear 3\nc\n")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	for _, want := range []string{
+		"breakpoint set at prog.nif:3\n",
+		"breakpoint at prog.nif:3 in square\n",
+		"n = 1\nr = 1\n",
+		debugger.Prompt + "1\n",
+		"error: undefined variable nope\n",
+		"#0 square at prog.nif:3\n#1 <main> at prog.nif:9\n",
+		"square = <func square>\ntotal = 0\ni = 1\n",
+		"stopped at prog.nif:10 in <main>\n",
+		"breakpoint cleared at prog.nif:3\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestDebugger_Break(t *testing.T) {
+	stmts, _ := parser.New(lexer.New(source)).Parse()
+	d := debugger.New("dir/prog.nif", source, stmts, strings.NewReader(""), io.Discard)
+	for spec, wantErr := range map[string]string{
+		"9":               "",
+		"prog.nif:3":      "",
+		"dir/prog.nif:10": "",
+		"5":               "no statement on line 5",
+		"other.nif:9":     `no file "other.nif"`,
+		"x":               `invalid line "x"`,
+	} {
+		_, err := d.Break(spec)
+		switch {
+		case wantErr == "" && err != nil:
+			t.Errorf("Break(%q): %v", spec, err)
+		case wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)):
+			t.Errorf("Break(%q): expected error containing %q, got %v", spec, wantErr, err)
+		}
+	}
+}
+
+func TestDebugger_EndOfInputQuits(t *testing.T) {
+	_, prog, err := debug(t, "")
+	if !errors.Is(err, debugger.ErrQuit) {
+		t.Fatalf("expected ErrQuit, got %v", err)
+	}
+	if prog != "" {
+		t.Errorf("expected the program not to run, got output %q", prog)
+	}
+}
@@ -0,0 +1,200 @@
+// Package difftest checks the interpreter and the LLVM backend against each
+// other: it generates random programs in the subset of Niftel both support,
+// runs each through both and shrinks any program on which they disagree to
+// the fewest statements that still show the difference.
+//
+// The subset leaves out what the backends cannot agree on yet: struct
+// fields are never read or assigned one by one, as codegen has no field
+// access.
+package difftest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/codegen"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+)
+
+// Toolchain is the programs that build a native executable from the IR
+// codegen writes. Without them, IRInterpreter runs the IR instead.
+var (
+	Toolchain     = []string{"llc", "clang"}
+	IRInterpreter = "lli"
+)
+
+// HaveToolchain reports whether the compiled path can run, with the programs
+// in Toolchain or with IRInterpreter, or the first program it is missing.
+func HaveToolchain() (bool, string) {
+	if missing := missingTool(); missing == "" {
+		return true, ""
+	}
+	if _, err := exec.LookPath(IRInterpreter); err != nil {
+		return false, IRInterpreter
+	}
+	return true, ""
+}
+
+// missingTool returns the first program in Toolchain that is not installed,
+// or "" if all of them are.
+func missingTool() string {
+	for _, tool := range Toolchain {
+		if _, err := exec.LookPath(tool); err != nil {
+			return tool
+		}
+	}
+	return ""
+}
+
+// Interpret runs src in a fresh interpreter and returns what it printed. It
+// fails if src does not parse or check, or raises a runtime error.
+func Interpret(src string) (string, error) {
+	stmts, err := parser.New(lexer.New(src)).Parse()
+	if err != nil {
+		return "", err
+	}
+	value.BuiltinTypesInit()
+	interp := interpreter.NewInterpreter()
+	if errs, _ := interp.Check(stmts); len(errs) > 0 {
+		return "", errs[0]
+	}
+	var out bytes.Buffer
+	interp.Out = &out
+	for _, stmt := range stmts {
+		if result := interp.Execute(stmt); result.Err != nil {
+			return out.String(), result.Err
+		}
+	}
+	return out.String(), nil
+}
+
+// Compile builds src with codegen, llc and clang in dir, runs the program
+// and returns what it printed. Without llc or clang it runs the IR with lli.
+// A program that exits with a status other than 0 returns an
+// *exec.ExitError.
+func Compile(src, dir string) (out string, err error) {
+	stmts, err := parser.New(lexer.New(src)).Parse()
+	if err != nil {
+		return "", err
+	}
+	// Codegen panics on what it does not support.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("code gen: %v", r)
+		}
+	}()
+	ir, err := codegen.NewCodeGen().GenerateLLVM(stmts)
+	if err != nil {
+		return "", fmt.Errorf("code gen: %w", err)
+	}
+	llFile, objFile, binFile := filepath.Join(dir, "prog.ll"), filepath.Join(dir, "prog.o"), filepath.Join(dir, "prog")
+	if err := os.WriteFile(llFile, []byte(ir), 0o644); err != nil {
+		return "", err
+	}
+	run := exec.Command(IRInterpreter, llFile)
+	if missingTool() == "" {
+		for _, args := range [][]string{
+			{"llc", llFile, "-filetype=obj", "-o", objFile},
+			{"clang", objFile, "-o", binFile},
+		} {
+			if msg, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+				return "", fmt.Errorf("%s: %v\n%s", args[0], err, msg)
+			}
+		}
+		run = exec.Command(binFile)
+	}
+	printed, err := run.Output()
+	return string(printed), err
+}
+
+// Mismatch is a program on which the backends disagree.
+type Mismatch struct {
+	Program     string
+	Interpreted string
+	Compiled    string
+	// Err is the error either backend failed with, if one did.
+	Err error
+}
+
+func (m *Mismatch) String() string {
+	msg := fmt.Sprintf("program:\n%s\ninterpreter printed:\n%s\ncompiled program printed:\n%s", m.Program, m.Interpreted, m.Compiled)
+	if m.Err != nil {
+		msg += "\nerror: " + m.Err.Error()
+	}
+	return msg
+}
+
+// Compare runs src through both backends, using dir for the build, and
+// returns how they disagree, or nil if they print the same.
+func Compare(src, dir string) *Mismatch {
+	interpreted, interpErr := Interpret(src)
+	compiled, compileErr := Compile(src, dir)
+	if interpErr == nil && compileErr == nil && interpreted == compiled {
+		return nil
+	}
+	m := &Mismatch{Program: src, Interpreted: interpreted, Compiled: compiled, Err: interpErr}
+	if m.Err == nil {
+		m.Err = compileErr
+	}
+	return m
+}
+
+// Shrink minimizes the program of m, keeping the programs that still check,
+// and returns how the backends disagree on the result.
+func Shrink(m *Mismatch, dir string) *Mismatch {
+	program := Minimize(m.Program, func(src string) bool {
+		return checks(src) && Compare(src, dir) != nil
+	})
+	if shrunk := Compare(program, dir); shrunk != nil {
+		return shrunk
+	}
+	return m
+}
+
+// checks reports whether src parses and type-checks.
+func checks(src string) bool {
+	stmts, err := parser.New(lexer.New(src)).Parse()
+	if err != nil {
+		return false
+	}
+	value.BuiltinTypesInit()
+	errs, _ := interpreter.NewInterpreter().Check(stmts)
+	return len(errs) == 0
+}
+
+// Minimize removes the lines of src, one statement each but for declarations
+// such as structs that span lines, that fails does not
+// need: it returns the smallest program it finds for which fails still
+// reports true. fails must be false for programs that no longer check, such
+// as one that lost the declaration of a variable it uses.
+func Minimize(src string, fails func(string) bool) string {
+	lines := strings.SplitAfter(strings.TrimSuffix(src, "\n"), "\n")
+	// Try dropping runs of lines, halving their length whenever no run can
+	// go, down to single lines.
+	for size := len(lines) / 2; size >= 1; size /= 2 {
+		for start := 0; start+size <= len(lines); {
+			candidate := append(append([]string(nil), lines[:start]...), lines[start+size:]...)
+			if len(candidate) > 0 && fails(join(candidate)) {
+				lines = candidate
+				continue
+			}
+			start++
+		}
+	}
+	return join(lines)
+}
+
+func join(lines []string) string {
+	src := strings.Join(lines, "")
+	if !strings.HasSuffix(src, "\n") {
+		src += "\n"
+	}
+	return src
+}
@@ -0,0 +1,77 @@
+package difftest_test
+
+import (
+	"flag"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/difftest"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+)
+
+var (
+	programs = flag.Int("difftest.programs", 100, "number of random programs to compare")
+	seed     = flag.Int64("difftest.seed", 1, "seed of the first random program")
+)
+
+func TestGenerate_Interprets(t *testing.T) {
+	for n := range 200 {
+		src := difftest.Generate(rand.New(rand.NewSource(int64(n))), difftest.DefaultConfig)
+		if _, err := difftest.Interpret(src); err != nil {
+			t.Fatalf("seed %d: %v\n%s", n, err, src)
+		}
+		stmts, err := parser.New(lexer.New(src)).Parse()
+		if err != nil {
+			t.Fatalf("seed %d: %v\n%s", n, err, src)
+		}
+		if got := len(stmts); got != difftest.DefaultConfig.Statements {
+			t.Fatalf("seed %d: expected %d statements, got %d", n, difftest.DefaultConfig.Statements, got)
+		}
+	}
+}
+
+func TestGenerate_Deterministic(t *testing.T) {
+	a := difftest.Generate(rand.New(rand.NewSource(7)), difftest.DefaultConfig)
+	b := difftest.Generate(rand.New(rand.NewSource(7)), difftest.DefaultConfig)
+	if a != b {
+		t.Errorf("expected the same seed to generate the same program:\n%s\n%s", a, b)
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	src := `var v0: int = 3
+print(1)
+var v1: int = v0 * 2
+print("noise")
+v0 += 1
+print(v1)
+print(v0)
+`
+	// The "bug" is printing 6.
+	fails := func(src string) bool {
+		out, err := difftest.Interpret(src)
+		return err == nil && strings.Contains(out, "6\n")
+	}
+	want := "var v0: int = 3\nvar v1: int = v0 * 2\nprint(v1)\n"
+	if got := difftest.Minimize(src, fails); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+// TestDifferential compares the backends on random programs. Run it with
+// -difftest.programs and -difftest.seed to search further.
+func TestDifferential(t *testing.T) {
+	if ok, missing := difftest.HaveToolchain(); !ok {
+		t.Skipf("%s not found", missing)
+	}
+	dir := t.TempDir()
+	for n := range *programs {
+		s := *seed + int64(n)
+		src := difftest.Generate(rand.New(rand.NewSource(s)), difftest.DefaultConfig)
+		if m := difftest.Compare(src, dir); m != nil {
+			t.Fatalf("seed %d: the backends disagree\n%s", s, difftest.Shrink(m, dir))
+		}
+	}
+}
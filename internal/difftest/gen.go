@@ -0,0 +1,248 @@
+package difftest
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Config bounds the programs Generate writes.
+type Config struct {
+	// Statements is how many statements a program has.
+	Statements int
+	// MaxDepth is how deeply expressions nest.
+	MaxDepth int
+}
+
+// DefaultConfig is what the differential tests generate with.
+var DefaultConfig = Config{Statements: 20, MaxDepth: 3}
+
+// limit bounds every value a generated program computes. The interpreter
+// keeps ints as float64 and the compiled program prints them with %d, so
+// both only agree on values that fit in 32 bits.
+const limit = 1<<31 - 1
+
+// binaryOps are the operators of the subset.
+var binaryOps = []string{"+", "-", "*", "/", "%", "&", "|", "^", "<<", ">>"}
+
+// compoundOps are the compound assignments both backends support.
+var compoundOps = []string{"+=", "-=", "&=", "|=", "^=", "<<=", ">>="}
+
+var words = []string{"alpha", "beta", "gamma", "delta", "hello world", "x", "done"}
+
+// generator writes one program, one statement per line but for the struct
+// declaration. It evaluates the program as it goes, so it can keep every
+// value within limit.
+type generator struct {
+	rng  *rand.Rand
+	cfg  Config
+	vars []string
+	vals map[string]int64
+	// fields are the fields of struct S, if the program declares it, and
+	// structs the variables of type S.
+	fields  []field
+	structs []string
+	out     strings.Builder
+}
+
+type field struct {
+	name string
+	str  bool
+}
+
+// Generate returns a random well-typed program in the subset of Niftel that
+// both the interpreter and the LLVM backend support: int variables declared
+// with var, assignments, compound assignments, integer arithmetic, a struct
+// of int and string fields whose values are declared with var and printed
+// whole, and printing variables and literals.
+func Generate(rng *rand.Rand, cfg Config) string {
+	g := &generator{rng: rng, cfg: cfg, vals: map[string]int64{}}
+	for n := range cfg.Statements {
+		if n == 0 && g.rng.Intn(2) == 0 {
+			g.structDecl()
+			continue
+		}
+		g.stmt()
+	}
+	return g.out.String()
+}
+
+// structDecl declares struct S with one to three fields.
+func (g *generator) structDecl() {
+	g.out.WriteString("struct S {\n")
+	for idx := range 1 + g.rng.Intn(3) {
+		f := field{name: fmt.Sprintf("f%d", idx), str: g.rng.Intn(2) == 0}
+		typ := "int"
+		if f.str {
+			typ = "string"
+		}
+		fmt.Fprintf(&g.out, "\t%s: %s\n", f.name, typ)
+		g.fields = append(g.fields, f)
+	}
+	g.out.WriteString("}\n")
+}
+
+// structStmt declares a variable of type S or prints one.
+func (g *generator) structStmt() {
+	if len(g.structs) > 0 && g.rng.Intn(2) == 0 {
+		fmt.Fprintf(&g.out, "print(%s)\n", g.structs[g.rng.Intn(len(g.structs))])
+		return
+	}
+	inits := make([]string, len(g.fields))
+	for idx, f := range g.fields {
+		text := fmt.Sprintf("%q", words[g.rng.Intn(len(words))])
+		if !f.str {
+			text, _ = g.expr(g.cfg.MaxDepth - 1)
+		}
+		inits[idx] = f.name + ": " + text
+	}
+	name := fmt.Sprintf("s%d", len(g.structs))
+	g.structs = append(g.structs, name)
+	fmt.Fprintf(&g.out, "var %s: S = S{%s}\n", name, strings.Join(inits, ", "))
+}
+
+func (g *generator) stmt() {
+	if len(g.fields) > 0 && g.rng.Intn(5) == 0 {
+		g.structStmt()
+		return
+	}
+	if len(g.vars) == 0 {
+		g.declare()
+		return
+	}
+	switch n := g.rng.Intn(10); {
+	case n < 3:
+		g.declare()
+	case n < 5:
+		name := g.pick()
+		text, val := g.expr(g.cfg.MaxDepth)
+		g.vals[name] = val
+		fmt.Fprintf(&g.out, "%s = %s\n", name, text)
+	case n < 7:
+		name := g.pick()
+		op := compoundOps[g.rng.Intn(len(compoundOps))]
+		binary := strings.TrimSuffix(op, "=")
+		text, val := g.operand(binary, g.cfg.MaxDepth-1)
+		if result, ok := apply(binary, g.vals[name], val); ok {
+			g.vals[name] = result
+			fmt.Fprintf(&g.out, "%s %s %s\n", name, op, text)
+		} else {
+			fmt.Fprintf(&g.out, "print(%s)\n", name)
+		}
+	case n < 9:
+		fmt.Fprintf(&g.out, "print(%s)\n", g.pick())
+	default:
+		if g.rng.Intn(2) == 0 {
+			fmt.Fprintf(&g.out, "print(%d)\n", g.rng.Intn(1000))
+		} else {
+			fmt.Fprintf(&g.out, "print(%q)\n", words[g.rng.Intn(len(words))])
+		}
+	}
+}
+
+func (g *generator) declare() {
+	name := fmt.Sprintf("v%d", len(g.vars))
+	text, val := g.expr(g.cfg.MaxDepth)
+	g.vars = append(g.vars, name)
+	g.vals[name] = val
+	fmt.Fprintf(&g.out, "var %s: int = %s\n", name, text)
+}
+
+func (g *generator) pick() string {
+	return g.vars[g.rng.Intn(len(g.vars))]
+}
+
+// expr returns an int expression and its value.
+func (g *generator) expr(depth int) (string, int64) {
+	n := g.rng.Intn(10)
+	switch {
+	case depth <= 0 || n < 3:
+		return g.leaf()
+	case n < 5:
+		text, val := g.expr(depth - 1)
+		op, result := "~", ^val
+		if g.rng.Intn(2) == 0 {
+			op, result = "-", -val
+		}
+		if result < -limit || result > limit {
+			return text, val
+		}
+		return op + g.wrap(text), result
+	}
+	op := binaryOps[g.rng.Intn(len(binaryOps))]
+	left, lval := g.expr(depth - 1)
+	right, rval := g.operand(op, depth-1)
+	if result, ok := apply(op, lval, rval); ok {
+		return g.wrap(left) + " " + op + " " + g.wrap(right), result
+	}
+	return g.leaf()
+}
+
+// operand returns a right operand for op: a literal for shifts, including
+// negative counts and counts of 64 or more, which both backends take modulo
+// 64.
+func (g *generator) operand(op string, depth int) (string, int64) {
+	if op != "<<" && op != ">>" {
+		return g.expr(depth)
+	}
+	n := g.rng.Int63n(80) - 8
+	if n < 0 {
+		return "(" + fmt.Sprint(n) + ")", n
+	}
+	return fmt.Sprint(n), n
+}
+
+func (g *generator) leaf() (string, int64) {
+	if len(g.vars) > 0 && g.rng.Intn(2) == 0 {
+		name := g.pick()
+		return name, g.vals[name]
+	}
+	n := g.rng.Int63n(100)
+	return fmt.Sprint(n), n
+}
+
+// wrap parenthesizes every expression but a literal or a variable, so the
+// program does not depend on precedence.
+func (g *generator) wrap(text string) string {
+	if strings.ContainsAny(text, " -~") {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+// apply computes a op b with int64 semantics. It reports false if the result
+// is out of bounds, or for division by zero, which stops the program in both
+// backends.
+func apply(op string, a, b int64) (int64, bool) {
+	var result int64
+	switch op {
+	case "+":
+		result = a + b
+	case "-":
+		result = a - b
+	case "*":
+		if a != 0 && (b > limit || b < -limit || a*b/a != b) {
+			return 0, false
+		}
+		result = a * b
+	case "/", "%":
+		if b == 0 {
+			return 0, false
+		}
+		result = a / b
+		if op == "%" {
+			result = a % b
+		}
+	case "&":
+		result = a & b
+	case "|":
+		result = a | b
+	case "^":
+		result = a ^ b
+	case "<<":
+		result = a << (b & 63)
+	case ">>":
+		result = a >> (b & 63)
+	}
+	return result, result >= -limit && result <= limit
+}
@@ -2,42 +2,60 @@ package environment
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
 )
 
+// Environment is one lexical scope. Scopes can be shared between spawned tasks
+// (globals and closure parents), so each level guards its own symbols and values.
 type Environment struct {
+	mu      sync.RWMutex
 	symbols *symtable.SymbolTable
-	values  map[string]value.Value
+	// values holds this scope's variables in declaration order, which is the
+	// slot order the resolver assigns. slots maps names to their index for
+	// lookups that were not resolved ahead of time.
+	values   []value.Value
+	assigned []bool
+	slots    map[string]int
 	// variables map[string]value.Value
 	enclosing *Environment
 	// types     map[string]*value.StructType
 }
 
+// NewEnvironment returns an empty scope inside parent. Its symbol table is
+// created on the first definition, since most block scopes declare nothing.
 func NewEnvironment(parent *Environment) *Environment {
-	var parentTable *symtable.SymbolTable
-	if parent != nil {
-		parentTable = parent.symbols
-
-	}
-
-	return &Environment{
-		symbols:   symtable.NewSymbolTable(parentTable),
-		values:    make(map[string]value.Value),
-		enclosing: parent,
-	}
+	return &Environment{enclosing: parent}
 }
 
 func (e *Environment) envForSymbol(kind symtable.SymbolKind, name string) *Environment {
-	if e.symbols.HasLocal(kind, name) {
-		return e
+	for env := e; env != nil; env = env.enclosing {
+		env.mu.RLock()
+		ok := env.symbols != nil && env.symbols.HasLocal(kind, name)
+		env.mu.RUnlock()
+		if ok {
+			return env
+		}
 	}
-	if e.enclosing != nil {
-		return e.enclosing.envForSymbol(kind, name)
+	return nil
+}
 
+func (e *Environment) lookup(kind symtable.SymbolKind, name string) (symtable.Symbol, bool) {
+	for env := e; env != nil; env = env.enclosing {
+		env.mu.RLock()
+		if env.symbols == nil {
+			env.mu.RUnlock()
+			continue
+		}
+		sym, ok := env.symbols.LookupLocal(kind, name)
+		env.mu.RUnlock()
+		if ok {
+			return sym, true
+		}
 	}
-	return nil
+	return nil, false
 }
 
 func (e *Environment) Parent() *Environment {
@@ -45,6 +63,22 @@ func (e *Environment) Parent() *Environment {
 }
 
 func (e *Environment) SymbolTable() *symtable.SymbolTable {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.table()
+}
+
+// table returns the scope's symbol table, creating it (and any missing
+// enclosing tables) if needed. The caller holds e.mu for writing.
+func (e *Environment) table() *symtable.SymbolTable {
+	if e.symbols == nil {
+		var parentTable *symtable.SymbolTable
+		if e.enclosing != nil {
+			parentTable = e.enclosing.SymbolTable()
+		}
+		e.symbols = symtable.NewSymbolTable(parentTable)
+		e.slots = make(map[string]int)
+	}
 	return e.symbols
 }
 
@@ -53,25 +87,135 @@ func (e *Environment) AssignVar(name string, val value.Value) error {
 	if env == nil {
 		return fmt.Errorf("undefined  variable '%s'", name)
 	}
-	env.values[name] = val
+	env.mu.Lock()
+	env.store(env.slots[name], val)
+	env.mu.Unlock()
+	return nil
+}
+
+// ancestor returns the scope depth levels out from e, or nil.
+func (e *Environment) ancestor(depth int) *Environment {
+	env := e
+	for ; depth > 0 && env != nil; depth-- {
+		env = env.enclosing
+	}
+	return env
+}
+
+// GetAt reads the variable the resolver placed in slot of the scope depth
+// levels out from e.
+func (e *Environment) GetAt(depth, slot int) (value.Value, error) {
+	env := e.ancestor(depth)
+	if env == nil {
+		return value.Null(), fmt.Errorf("no scope %d levels out", depth)
+	}
+	env.mu.RLock()
+	defer env.mu.RUnlock()
+	if slot >= len(env.values) {
+		return value.Null(), fmt.Errorf("no variable in slot %d", slot)
+	}
+	if !env.assigned[slot] {
+		return value.Null(), fmt.Errorf("uninitialised variable in slot %d", slot)
+	}
+	return env.values[slot], nil
+}
+
+// AssignAt stores val in the variable the resolver placed in slot of the scope
+// depth levels out from e.
+func (e *Environment) AssignAt(depth, slot int, val value.Value) error {
+	env := e.ancestor(depth)
+	if env == nil {
+		return fmt.Errorf("no scope %d levels out", depth)
+	}
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if slot >= len(env.values) {
+		return fmt.Errorf("no variable in slot %d", slot)
+	}
+	env.store(slot, val)
+	return nil
+}
+
+// UpdateVar replaces the value of the variable name with what update makes
+// of it, holding the scope's lock throughout so that no other task changes
+// the variable in between. update must not use the environment.
+func (e *Environment) UpdateVar(name string, update func(value.Value) (value.Value, error)) error {
+	env := e.envForSymbol(symtable.SymbolVar, name)
+	if env == nil {
+		return fmt.Errorf("undefined variable '%s'", name)
+	}
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	slot := env.slots[name]
+	if !env.assigned[slot] {
+		return fmt.Errorf("uninitialised variable '%s'", name)
+	}
+	return env.update(slot, update)
+}
+
+// UpdateAt is UpdateVar for the variable the resolver placed in slot of the
+// scope depth levels out from e.
+func (e *Environment) UpdateAt(depth, slot int, update func(value.Value) (value.Value, error)) error {
+	env := e.ancestor(depth)
+	if env == nil {
+		return fmt.Errorf("no scope %d levels out", depth)
+	}
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	if slot >= len(env.values) {
+		return fmt.Errorf("no variable in slot %d", slot)
+	}
+	if !env.assigned[slot] {
+		return fmt.Errorf("uninitialised variable in slot %d", slot)
+	}
+	return env.update(slot, update)
+}
+
+// update applies update to a slot; the caller holds e.mu.
+func (e *Environment) update(slot int, update func(value.Value) (value.Value, error)) error {
+	val, err := update(e.values[slot])
+	if err != nil {
+		return err
+	}
+	e.store(slot, val)
+	return nil
+}
+
+// store sets a slot; the caller holds e.mu.
+func (e *Environment) store(slot int, val value.Value) {
+	e.values[slot] = val
+	e.assigned[slot] = true
+}
+
+func (e *Environment) define(sym symtable.Symbol) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.table().DefineValue(sym); err != nil {
+		return err
+	}
+	if sym.Kind() == symtable.SymbolVar {
+		e.slots[sym.Name()] = len(e.values)
+		e.values = append(e.values, value.Null())
+		e.assigned = append(e.assigned, false)
+	}
 	return nil
 }
 
 func (e *Environment) DefineVar(sym *symtable.VarSymbol) error {
-	return e.symbols.DefineValue(sym)
+	return e.define(sym)
 }
 
 func (e *Environment) DefineFunc(sym *symtable.FuncSymbol) error {
-	return e.symbols.DefineValue(sym)
+	return e.define(sym)
 }
 
 func (e *Environment) DefineType(sym *symtable.TypeSymbol) error {
 	// fmt.Printf("DEBUG DefineValue SKIPPED duplicate: %q, kind=%v\n", sym.Name(), sym.Kind())
-	return e.symbols.DefineValue(sym)
+	return e.define(sym)
 }
 
 func (e *Environment) DefineTypeParam(sym *symtable.TypeParamSymbol) error {
-	return e.symbols.DefineValue(sym)
+	return e.define(sym)
 }
 
 func (e *Environment) GetVar(name string) (value.Value, error) {
@@ -79,15 +223,36 @@ func (e *Environment) GetVar(name string) (value.Value, error) {
 	if env == nil {
 		return value.Null(), fmt.Errorf("undefined variable '%s'", name)
 	}
-	val, ok := env.values[name]
+	env.mu.RLock()
+	slot := env.slots[name]
+	val, ok := env.values[slot], env.assigned[slot]
+	env.mu.RUnlock()
 	if !ok {
 		return value.Null(), fmt.Errorf("uninitialised variable '%s'", name)
 	}
 	return val, nil
 }
 
+// Vars returns the variables defined in this scope itself that have been
+// assigned, in declaration order.
+func (e *Environment) Vars() (names []string, values []value.Value) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	bySlot := make([]string, len(e.values))
+	for name, slot := range e.slots {
+		bySlot[slot] = name
+	}
+	for slot, name := range bySlot {
+		if e.assigned[slot] {
+			names = append(names, name)
+			values = append(values, e.values[slot])
+		}
+	}
+	return names, values
+}
+
 func (e *Environment) LookupVar(name string) (*symtable.VarSymbol, bool) {
-	sym, ok := e.symbols.Lookup(symtable.SymbolVar, name)
+	sym, ok := e.lookup(symtable.SymbolVar, name)
 	if !ok {
 		return nil, false
 	}
@@ -96,7 +261,7 @@ func (e *Environment) LookupVar(name string) (*symtable.VarSymbol, bool) {
 }
 
 func (e *Environment) LookupFunc(name string) (*symtable.FuncSymbol, bool) {
-	sym, ok := e.symbols.Lookup(symtable.SymbolFuncs, name)
+	sym, ok := e.lookup(symtable.SymbolFuncs, name)
 	if !ok {
 		return nil, false
 	}
@@ -105,7 +270,7 @@ func (e *Environment) LookupFunc(name string) (*symtable.FuncSymbol, bool) {
 }
 
 func (e *Environment) LookupType(name string) (*symtable.TypeSymbol, bool) {
-	sym, ok := e.symbols.Lookup(symtable.SymbolTypes, name)
+	sym, ok := e.lookup(symtable.SymbolTypes, name)
 	if !ok {
 		return nil, false
 	}
@@ -114,7 +279,7 @@ func (e *Environment) LookupType(name string) (*symtable.TypeSymbol, bool) {
 }
 
 func (e *Environment) LookupTypeParam(name string) (*symtable.TypeParamSymbol, bool) {
-	sym, ok := e.symbols.Lookup(symtable.SymbolTypeParams, name)
+	sym, ok := e.lookup(symtable.SymbolTypeParams, name)
 	if !ok {
 		return nil, false
 	}
@@ -122,18 +287,24 @@ func (e *Environment) LookupTypeParam(name string) (*symtable.TypeParamSymbol, b
 	return typeparamSym, ok
 }
 
+func (e *Environment) hasLocal(kind symtable.SymbolKind, name string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.symbols != nil && e.symbols.HasLocal(kind, name)
+}
+
 func (e *Environment) HasLocalVar(name string) bool {
-	return e.symbols.HasLocal(symtable.SymbolVar, name)
+	return e.hasLocal(symtable.SymbolVar, name)
 }
 
 func (e *Environment) HasLocalFunc(name string) bool {
-	return e.symbols.HasLocal(symtable.SymbolFuncs, name)
+	return e.hasLocal(symtable.SymbolFuncs, name)
 }
 
 func (e *Environment) HasLocalType(name string) bool {
-	return e.symbols.HasLocal(symtable.SymbolTypes, name)
+	return e.hasLocal(symtable.SymbolTypes, name)
 }
 
 func (e *Environment) HasLocalTypeparam(name string) bool {
-	return e.symbols.HasLocal(symtable.SymbolTypeParams, name)
+	return e.hasLocal(symtable.SymbolTypeParams, name)
 }
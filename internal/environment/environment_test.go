@@ -1,10 +1,13 @@
 package environment_test
 
 import (
+	"slices"
+	"sync"
 	"testing"
 
 	"github.com/ithinkiborkedit/niftelv2.git/internal/environment"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
 )
 
 func TestEnvironmentDefineAndLookup(t *testing.T) {
@@ -72,3 +75,155 @@ func TestEnvironmentDefineTypeParam(t *testing.T) {
 		t.Fatalf("failed to lookup type param")
 	}
 }
+
+func TestEnvironmentSlots(t *testing.T) {
+	outer := environment.NewEnvironment(nil)
+	inner := environment.NewEnvironment(outer)
+	for _, name := range []string{"a", "b"} {
+		if err := outer.DefineVar(&symtable.VarSymbol{SymName: name, SymKind: symtable.SymbolVar, Mutable: true}); err != nil {
+			t.Fatalf("define %s: %v", name, err)
+		}
+	}
+	// Function and type symbols do not take variable slots.
+	if err := outer.DefineFunc(&symtable.FuncSymbol{SymName: "f"}); err != nil {
+		t.Fatalf("define f: %v", err)
+	}
+	if _, err := inner.GetAt(1, 1); err == nil {
+		t.Errorf("expected error reading unassigned slot")
+	}
+	if err := inner.AssignAt(1, 1, value.Value{Type: value.ValueInt, Data: float64(7)}); err != nil {
+		t.Fatalf("AssignAt: %v", err)
+	}
+	got, err := outer.GetVar("b")
+	if err != nil || got.Data.(float64) != 7 {
+		t.Errorf("expected b == 7 by name, got %v (err=%v)", got, err)
+	}
+	if err := outer.AssignVar("a", value.Value{Type: value.ValueInt, Data: float64(3)}); err != nil {
+		t.Fatalf("AssignVar: %v", err)
+	}
+	got, err = inner.GetAt(1, 0)
+	if err != nil || got.Data.(float64) != 3 {
+		t.Errorf("expected slot 0 == 3, got %v (err=%v)", got, err)
+	}
+	if _, err := inner.GetAt(1, 2); err == nil {
+		t.Errorf("expected error reading slot past the last variable")
+	}
+	if _, err := inner.GetAt(2, 0); err == nil {
+		t.Errorf("expected error reading past the outermost scope")
+	}
+}
+
+func TestEnvironmentVars(t *testing.T) {
+	env := environment.NewEnvironment(nil)
+	for _, name := range []string{"b", "a", "unset"} {
+		if err := env.DefineVar(&symtable.VarSymbol{SymName: name, SymKind: symtable.SymbolVar, Mutable: true}); err != nil {
+			t.Fatalf("define %s: %v", name, err)
+		}
+	}
+	for name, n := range map[string]float64{"a": 1, "b": 2} {
+		if err := env.AssignVar(name, value.Value{Type: value.ValueInt, Data: n}); err != nil {
+			t.Fatalf("AssignVar %s: %v", name, err)
+		}
+	}
+	names, values := env.Vars()
+	if !slices.Equal(names, []string{"b", "a"}) {
+		t.Fatalf("expected names [b a], got %v", names)
+	}
+	if values[0].Data.(float64) != 2 || values[1].Data.(float64) != 1 {
+		t.Errorf("expected values [2 1], got %v", values)
+	}
+}
+
+// baselineEnv is the Environment as it was before slots and locking: a map
+// of values per scope, found by walking out through the symbol tables. The
+// benchmarks below measure lookups against it.
+type baselineEnv struct {
+	symbols   *symtable.SymbolTable
+	values    map[string]value.Value
+	enclosing *baselineEnv
+}
+
+func newBaselineEnv(parent *baselineEnv) *baselineEnv {
+	var parentTable *symtable.SymbolTable
+	if parent != nil {
+		parentTable = parent.symbols
+	}
+	return &baselineEnv{symbols: symtable.NewSymbolTable(parentTable), values: make(map[string]value.Value), enclosing: parent}
+}
+
+func (e *baselineEnv) getVar(name string) (value.Value, bool) {
+	for env := e; env != nil; env = env.enclosing {
+		if env.symbols.HasLocal(symtable.SymbolVar, name) {
+			val, ok := env.values[name]
+			return val, ok
+		}
+	}
+	return value.Null(), false
+}
+
+// lookupDepth is how many scopes out the benchmarks read a variable from,
+// as a loop body inside a function reads a global.
+const lookupDepth = 3
+
+func BenchmarkGetVar_Baseline(b *testing.B) {
+	env := newBaselineEnv(nil)
+	if err := env.symbols.DefineValue(&symtable.VarSymbol{SymName: "x", SymKind: symtable.SymbolVar}); err != nil {
+		b.Fatal(err)
+	}
+	env.values["x"] = value.Value{Type: value.ValueInt, Data: float64(1)}
+	for range lookupDepth {
+		env = newBaselineEnv(env)
+	}
+	b.ResetTimer()
+	for range b.N {
+		if _, ok := env.getVar("x"); !ok {
+			b.Fatal("x not found")
+		}
+	}
+}
+
+// nestedEnv returns a scope lookupDepth levels inside one that holds x.
+func nestedEnv(b *testing.B) *environment.Environment {
+	env := environment.NewEnvironment(nil)
+	if err := env.DefineVar(&symtable.VarSymbol{SymName: "x", SymKind: symtable.SymbolVar}); err != nil {
+		b.Fatal(err)
+	}
+	if err := env.AssignVar("x", value.Value{Type: value.ValueInt, Data: float64(1)}); err != nil {
+		b.Fatal(err)
+	}
+	for range lookupDepth {
+		env = environment.NewEnvironment(env)
+	}
+	return env
+}
+
+func BenchmarkGetVar_ByName(b *testing.B) {
+	env := nestedEnv(b)
+	b.ResetTimer()
+	for range b.N {
+		if _, err := env.GetVar("x"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetVar_Slot(b *testing.B) {
+	env := nestedEnv(b)
+	b.ResetTimer()
+	for range b.N {
+		if _, err := env.GetAt(lookupDepth, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGetVar_Lock is the cost of the read lock that every lookup now
+// takes once per scope it looks in, so that it can be told apart from the
+// cost of the lookup itself.
+func BenchmarkGetVar_Lock(b *testing.B) {
+	var mu sync.RWMutex
+	for range b.N {
+		mu.RLock()
+		mu.RUnlock()
+	}
+}
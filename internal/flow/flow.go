@@ -0,0 +1,428 @@
+// Package flow checks how control moves through function bodies. It reports
+// functions that can finish without returning a declared result, variables
+// declared without an initializer that may be read before they are assigned,
+// and code that can never run.
+package flow
+
+import (
+	"maps"
+	"slices"
+
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// Issue is an error or warning found by flow analysis. Its Kind is
+// niferrors.FlowError.
+type Issue = niferrors.NifError
+
+// state is what is known at one point of a function body: which tracked
+// variables are definitely assigned, and whether the point can be reached.
+type state struct {
+	assigned map[int]bool
+	dead     bool
+}
+
+func (s state) clone() state {
+	return state{assigned: maps.Clone(s.assigned), dead: s.dead}
+}
+
+// join merges the states of paths that meet. Unreachable paths add nothing.
+func join(states ...state) state {
+	var out *state
+	for _, st := range states {
+		if st.dead {
+			continue
+		}
+		if out == nil {
+			joined := st.clone()
+			out = &joined
+			continue
+		}
+		for id := range out.assigned {
+			if !st.assigned[id] {
+				delete(out.assigned, id)
+			}
+		}
+	}
+	if out == nil {
+		return state{assigned: map[int]bool{}, dead: true}
+	}
+	return *out
+}
+
+// target collects the states at the break statements that leave a loop,
+// switch or select.
+type target struct {
+	label  string
+	isLoop bool
+	breaks []state
+}
+
+// analyzer checks one function body at a time.
+type analyzer struct {
+	// scopes map names to tracked variable ids; -1 marks a name that shadows
+	// with an initialized variable.
+	scopes   []map[string]int
+	names    []token.Token
+	reported map[int]bool
+	targets  []*target
+	// quiet is set while analyzing code already reported as unreachable.
+	quiet    bool
+	errs     []error
+	warnings []error
+}
+
+// Analyze checks stmts, which run at top level, and every function declared
+// in them. It returns errors and warnings separately.
+func Analyze(stmts []ast.Stmt) (errs, warnings []error) {
+	a := &analyzer{scopes: []map[string]int{{}}, reported: map[int]bool{}}
+	a.stmts(stmts, state{assigned: map[int]bool{}})
+	return a.errs, a.warnings
+}
+
+func (a *analyzer) errorAt(tok token.Token, code niferrors.Code, format string, args ...any) {
+	a.errs = append(a.errs, niferrors.New(niferrors.FlowError, code, tok, format, args...))
+}
+
+func (a *analyzer) warnAt(tok token.Token, code niferrors.Code, format string, args ...any) {
+	warning := niferrors.New(niferrors.FlowError, code, tok, format, args...)
+	warning.Severity = niferrors.SeverityWarning
+	a.warnings = append(a.warnings, warning)
+}
+
+func (a *analyzer) checkFunc(fn *ast.FuncStmt) {
+	end := a.checkBody(fn.Params, fn.Body)
+	if end.dead || fn.IsGenerator {
+		return
+	}
+	for _, ret := range fn.Return {
+		if ret != nil && ret.Name.Lexeme != "" {
+			a.errorAt(fn.Name, niferrors.CodeMissingReturn, "missing return at end of function '%s'", fn.Name.Lexeme)
+			return
+		}
+	}
+}
+
+// checkBody analyzes a function body on its own: variables of enclosing
+// functions may be assigned by the time it runs, so they are not tracked.
+func (a *analyzer) checkBody(params []ast.Param, body *ast.BlockStmt) state {
+	scopes, targets, quiet := a.scopes, a.targets, a.quiet
+	a.scopes, a.targets, a.quiet = []map[string]int{{}}, nil, false
+	for _, param := range params {
+		a.declare(param.Name, false)
+	}
+	end := state{assigned: map[int]bool{}}
+	if body != nil {
+		end = a.stmts(body.Statements, end)
+	}
+	a.scopes, a.targets, a.quiet = scopes, targets, quiet
+	return end
+}
+
+func (a *analyzer) pushScope() {
+	a.scopes = append(a.scopes, map[string]int{})
+}
+
+func (a *analyzer) popScope() {
+	a.scopes = a.scopes[:len(a.scopes)-1]
+}
+
+// declare adds name to the current scope, tracked if it has no initializer.
+func (a *analyzer) declare(name token.Token, tracked bool) {
+	id := -1
+	if tracked {
+		id = len(a.names)
+		a.names = append(a.names, name)
+	}
+	a.scopes[len(a.scopes)-1][name.Lexeme] = id
+}
+
+func (a *analyzer) lookup(name string) int {
+	for idx := len(a.scopes) - 1; idx >= 0; idx-- {
+		if id, ok := a.scopes[idx][name]; ok {
+			return id
+		}
+	}
+	return -1
+}
+
+// use reports a read of name that may happen before it is assigned.
+func (a *analyzer) use(name token.Token, st state) {
+	id := a.lookup(name.Lexeme)
+	if id < 0 || st.dead || st.assigned[id] || a.reported[id] {
+		return
+	}
+	a.reported[id] = true
+	a.errorAt(name, niferrors.CodeUnassigned, "variable '%s' may be used before assignment", name.Lexeme)
+}
+
+func (a *analyzer) assign(name token.Token, st state) {
+	if id := a.lookup(name.Lexeme); id >= 0 {
+		st.assigned[id] = true
+	}
+}
+
+// block analyzes statements in a scope of their own.
+func (a *analyzer) block(stmts []ast.Stmt, st state) state {
+	a.pushScope()
+	defer a.popScope()
+	return a.stmts(stmts, st)
+}
+
+func (a *analyzer) stmts(stmts []ast.Stmt, st state) state {
+	quiet := a.quiet
+	defer func() { a.quiet = quiet }()
+	for _, stmt := range stmts {
+		if st.dead && !a.quiet {
+			a.warnAt(stmt.PosToken(), niferrors.CodeUnreachable, "unreachable code")
+			a.quiet = true
+		}
+		st = a.stmt(stmt, st)
+	}
+	return st
+}
+
+func (a *analyzer) body(stmt ast.Stmt, st state) state {
+	if block, ok := stmt.(*ast.BlockStmt); ok {
+		return a.block(block.Statements, st)
+	}
+	return a.stmt(stmt, st)
+}
+
+func (a *analyzer) stmt(stmt ast.Stmt, st state) state {
+	switch s := stmt.(type) {
+	case *ast.VarStmt:
+		st = a.expr(s.Init, st)
+		for _, name := range s.Names {
+			a.declare(name, s.Init == nil)
+		}
+	case *ast.ShortVarStmt:
+		st = a.expr(s.Init, st)
+		a.declare(s.Name, false)
+	case *ast.AssignStmt:
+		st = a.expr(s.Value, st)
+		a.assign(s.Name, st)
+	case *ast.CompoundAssignStmt:
+		a.use(s.Name, st)
+		st = a.expr(s.Value, st)
+	case *ast.PrintStmt:
+		st = a.expr(s.Expr, st)
+	case *ast.ExprStmt:
+		st = a.expr(s.Expr, st)
+	case *ast.YieldStmt:
+		st = a.expr(s.Value, st)
+	case *ast.ReturnStmt:
+		for _, val := range s.Values {
+			st = a.expr(val, st)
+		}
+		st = state{assigned: st.assigned, dead: true}
+	case *ast.BreakStmt:
+		if t := a.breakTarget(s.Label.Lexeme); t != nil {
+			t.breaks = append(t.breaks, st.clone())
+		}
+		st = state{assigned: st.assigned, dead: true}
+	case *ast.ContinueStmt, *ast.FallthroughStmt:
+		st = state{assigned: st.assigned, dead: true}
+	case *ast.BlockStmt:
+		st = a.block(s.Statements, st)
+	case *ast.IfStmt:
+		st = a.expr(s.Conditon, st)
+		then := a.body(s.ThenBranch, st.clone())
+		other := st
+		if s.ElseBranch != nil {
+			other = a.body(s.ElseBranch, st.clone())
+		}
+		st = join(then, other)
+	case *ast.WhileStmt:
+		st = a.expr(s.Conditon, st)
+		st = a.loop(s.Label, isTrue(s.Conditon), st, func(in state) state {
+			return a.body(s.Body, in)
+		})
+	case *ast.ForStmt:
+		a.pushScope()
+		if s.Init != nil {
+			st = a.stmt(s.Init, st)
+		}
+		st = a.expr(s.CondExpr, st)
+		st = a.loop(s.Label, s.CondExpr == nil || isTrue(s.CondExpr), st, func(in state) state {
+			end := a.body(s.BodyStmt, in)
+			if s.Update != nil && !end.dead {
+				end = a.stmt(s.Update, end)
+			}
+			return end
+		})
+		a.popScope()
+	case *ast.ForInStmt:
+		st = a.expr(s.Iterable, st)
+		a.pushScope()
+		a.declare(s.Name, false)
+		st = a.loop(s.Label, false, st, func(in state) state {
+			return a.body(s.BodyStmt, in)
+		})
+		a.popScope()
+	case *ast.SwitchStmt:
+		st = a.switchStmt(s, st)
+	case *ast.SelectStmt:
+		st = a.selectStmt(s, st)
+	case *ast.FuncStmt:
+		a.declare(s.Name, false)
+		a.checkFunc(s)
+	case *ast.StructStmt:
+		for idx := range s.Methods {
+			a.checkFunc(&s.Methods[idx])
+		}
+	}
+	return st
+}
+
+// loop analyzes a loop body. The loop may run zero times, so it leaves the
+// state it was entered with, unless it is infinite: then it is left only by
+// a break.
+func (a *analyzer) loop(label token.Token, infinite bool, st state, body func(state) state) state {
+	t := &target{label: label.Lexeme, isLoop: true}
+	a.targets = append(a.targets, t)
+	body(st.clone())
+	a.targets = a.targets[:len(a.targets)-1]
+	if infinite {
+		return join(t.breaks...)
+	}
+	return st
+}
+
+func (a *analyzer) breakTarget(label string) *target {
+	for idx := len(a.targets) - 1; idx >= 0; idx-- {
+		t := a.targets[idx]
+		if label == "" || (t.isLoop && t.label == label) {
+			return t
+		}
+	}
+	return nil
+}
+
+func (a *analyzer) switchStmt(s *ast.SwitchStmt, st state) state {
+	st = a.expr(s.Subject, st)
+	hasDefault := false
+	for _, clause := range s.Cases {
+		hasDefault = hasDefault || clause.IsDefault
+		for _, val := range clause.Values {
+			st = a.expr(val, st)
+		}
+	}
+	t := &target{}
+	a.targets = append(a.targets, t)
+	var ends []state
+	for _, clause := range s.Cases {
+		ends = append(ends, a.block(clause.Body, st.clone()))
+	}
+	a.targets = a.targets[:len(a.targets)-1]
+	// A case ending in fallthrough continues into the next case, which was
+	// analyzed from the switch's entry state.
+	ends = append(ends, t.breaks...)
+	if !hasDefault {
+		ends = append(ends, st)
+	}
+	return join(ends...)
+}
+
+func (a *analyzer) selectStmt(s *ast.SelectStmt, st state) state {
+	for _, clause := range s.Cases {
+		if clause.Comm != nil {
+			for _, arg := range clause.Comm.Arguments {
+				st = a.expr(arg, st)
+			}
+		}
+	}
+	t := &target{}
+	a.targets = append(a.targets, t)
+	var ends []state
+	for _, clause := range s.Cases {
+		a.pushScope()
+		if clause.Name.Lexeme != "" {
+			a.declare(clause.Name, false)
+		}
+		ends = append(ends, a.block(clause.Body, st.clone()))
+		a.popScope()
+	}
+	a.targets = a.targets[:len(a.targets)-1]
+	return join(append(ends, t.breaks...)...)
+}
+
+// expr reports reads in expr. Only if-expression blocks can assign.
+func (a *analyzer) expr(expr ast.Expr, st state) state {
+	switch e := expr.(type) {
+	case *ast.VariableExpr:
+		a.use(e.Name, st)
+	case *ast.BinaryExpr:
+		st = a.expr(e.Left, st)
+		st = a.expr(e.Right, st)
+	case *ast.UnaryExpr:
+		st = a.expr(e.Right, st)
+	case *ast.CallExpr:
+		st = a.expr(e.Callee, st)
+		for _, arg := range e.Arguments {
+			st = a.expr(arg, st)
+		}
+	case *ast.GetExpr:
+		st = a.expr(e.Object, st)
+	case *ast.IndexExpr:
+		st = a.expr(e.Collection, st)
+		st = a.expr(e.Index, st)
+	case *ast.SliceExpr:
+		st = a.expr(e.Collection, st)
+		st = a.expr(e.Low, st)
+		st = a.expr(e.High, st)
+	case *ast.RangeExpr:
+		st = a.expr(e.Start, st)
+		st = a.expr(e.End, st)
+		st = a.expr(e.Step, st)
+	case *ast.IfExpr:
+		st = a.ifExpr(e, st)
+	case *ast.TernaryExpr:
+		st = a.expr(e.Conditon, st)
+		st = join(a.expr(e.Then, st.clone()), a.expr(e.Else, st.clone()))
+	case *ast.ListExpr:
+		for _, elem := range e.Elements {
+			st = a.expr(elem, st)
+		}
+	case *ast.DictExpr:
+		for _, pair := range e.Pairs {
+			st = a.expr(pair[0], st)
+			st = a.expr(pair[1], st)
+		}
+	case *ast.StructLiteralExpr:
+		for _, name := range slices.Sorted(maps.Keys(e.Fields)) {
+			st = a.expr(e.Fields[name], st)
+		}
+	case *ast.FuncExpr:
+		a.checkBody(e.Params, e.Body)
+	case *ast.SpawnExpr:
+		st = a.expr(e.Call, st)
+	}
+	return st
+}
+
+func (a *analyzer) ifExpr(e *ast.IfExpr, st state) state {
+	st = a.expr(e.Conditon, st)
+	var then, other state
+	if e.ThenBranch != nil {
+		then = a.block(e.ThenBranch.Statements, st.clone())
+	} else {
+		then = st.clone()
+	}
+	switch {
+	case e.ElseIf != nil:
+		other = a.ifExpr(e.ElseIf, st.clone())
+	case e.ElseBranch != nil:
+		other = a.block(e.ElseBranch.Statements, st.clone())
+	default:
+		other = st.clone()
+	}
+	return join(then, other)
+}
+
+func isTrue(expr ast.Expr) bool {
+	lit, ok := expr.(*ast.LiteralExpr)
+	return ok && lit.Value.Type == token.TokenTrue
+}
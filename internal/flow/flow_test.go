@@ -0,0 +1,199 @@
+package flow_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/flow"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+)
+
+func analyze(t *testing.T, source string) (errs, warnings []error) {
+	t.Helper()
+	stmts, err := parser.New(lexer.New(source)).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	return flow.Analyze(stmts)
+}
+
+func expectIssue(t *testing.T, kind string, issues []error, want string) {
+	t.Helper()
+	for _, issue := range issues {
+		if strings.Contains(issue.Error(), want) {
+			return
+		}
+	}
+	t.Errorf("expected %s containing %q, got %v", kind, want, issues)
+}
+
+func TestAnalyze_CleanPrograms(t *testing.T) {
+	cases := map[string]string{
+		"if else returns": `func sign(n: int) -> int {
+	if n < 0 {
+		return -1
+	} else {
+		return 1
+	}
+}`,
+		"infinite loop": `func first(n: int) -> int {
+	while true {
+		if n > 3 {
+			return n
+		}
+		n += 1
+	}
+}`,
+		"switch with default": `func name(n: int) -> string {
+	switch n {
+	case 1:
+		return "one"
+	default:
+		return "many"
+	}
+}`,
+		"no result": `func log(n: int) {
+	if n > 0 {
+		print(n)
+	}
+}`,
+		"generator": `func count(n: int) -> int {
+	yield n
+}`,
+		"assigned in both branches": `func pick(c: bool) -> int {
+	var x: int
+	if c {
+		x = 1
+	} else {
+		x = 2
+	}
+	return x
+}`,
+		"assigned before loop": `var total: int
+total = 0
+for i := 0; i < 3; i += 1 {
+	total += i
+}`,
+	}
+	for name, source := range cases {
+		t.Run(name, func(t *testing.T) {
+			errs, warnings := analyze(t, source)
+			if len(errs) > 0 || len(warnings) > 0 {
+				t.Errorf("unexpected issues: %v %v", errs, warnings)
+			}
+		})
+	}
+}
+
+func TestAnalyze_Errors(t *testing.T) {
+	cases := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"missing return", `func f(n: int) -> int {
+	print(n)
+}`, "1:6: missing return at end of function 'f'"},
+		{"if without else", `func f(n: int) -> int {
+	if n > 0 {
+		return n
+	}
+}`, "missing return at end of function 'f'"},
+		{"loop may not run", `func f(n: int) -> int {
+	while n > 0 {
+		return n
+	}
+}`, "missing return at end of function 'f'"},
+		{"break leaves infinite loop", `func f() -> int {
+	while true {
+		break
+	}
+}`, "missing return at end of function 'f'"},
+		{"switch without default", `func f(n: int) -> int {
+	switch n {
+	case 1:
+		return 1
+	}
+}`, "missing return at end of function 'f'"},
+		{"method", `struct P {
+	x: int
+	func get() -> int {
+		print(self.x)
+	}
+}`, "missing return at end of function 'get'"},
+		{"unassigned read", `func f() {
+	var x: int
+	print(x)
+}`, "3:8: variable 'x' may be used before assignment"},
+		{"assigned in one branch", `func f(c: bool) {
+	var x: int
+	if c {
+		x = 1
+	}
+	print(x)
+}`, "variable 'x' may be used before assignment"},
+		{"assigned in loop", `var x: int
+for i in 0..3 {
+	x = i
+}
+print(x)`, "variable 'x' may be used before assignment"},
+		{"compound assign", `var x: int
+x += 1`, "variable 'x' may be used before assignment"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs, _ := analyze(t, tc.source)
+			expectIssue(t, "error", errs, tc.want)
+		})
+	}
+}
+
+func TestAnalyze_UnreachableCode(t *testing.T) {
+	cases := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"after return", `func f() -> int {
+	return 1
+	print(2)
+}`, "3:2: unreachable code"},
+		{"after break", `for i := 0; i < 3; i += 1 {
+	break
+	print(i)
+}`, "unreachable code"},
+		{"after continue", `for i in 0..3 {
+	continue
+	print(i)
+}`, "unreachable code"},
+		{"after infinite loop", `func f() {
+	while true {
+	}
+	print(1)
+}`, "unreachable code"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs, warnings := analyze(t, tc.source)
+			if len(errs) > 0 {
+				t.Errorf("unreachable code should only warn, got errors %v", errs)
+			}
+			expectIssue(t, "warning", warnings, tc.want)
+		})
+	}
+}
+
+func TestAnalyze_WarnsOncePerUnreachableRun(t *testing.T) {
+	_, warnings := analyze(t, `func f() -> int {
+	return 0
+	print(1)
+	if true {
+		print(2)
+	}
+	print(3)
+}`)
+	if len(warnings) != 1 {
+		t.Errorf("expected 1 warning, got %d: %v", len(warnings), warnings)
+	}
+}
@@ -0,0 +1,423 @@
+package format
+
+import (
+	"slices"
+	"strings"
+
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+)
+
+// Binding strength of each expression form, loosest first. An operand that
+// binds looser than its position allows is wrapped in parentheses.
+const (
+	precLowest = iota
+	precTernary
+	precOr
+	precAnd
+	precEquality
+	precCompare
+	precRange
+	precBitOr
+	precBitXor
+	precBitAnd
+	precShift
+	precTerm
+	precFactor
+	precUnary
+	precPostfix
+	precPrimary
+)
+
+var binaryPrec = map[token.TokenType]int{
+	token.TokenOr:        precOr,
+	token.TokenAnd:       precAnd,
+	token.TokenEqality:   precEquality,
+	token.TokenBangEqal:  precEquality,
+	token.TokenLess:      precCompare,
+	token.TokenLessEq:    precCompare,
+	token.TokenGreater:   precCompare,
+	token.TokenGreaterEq: precCompare,
+	token.TokenIn:        precCompare,
+	token.TokenPipe:      precBitOr,
+	token.TokenCaret:     precBitXor,
+	token.TokenAmper:     precBitAnd,
+	token.TokenShl:       precShift,
+	token.TokenShr:       precShift,
+	token.TokenPlus:      precTerm,
+	token.TokenMinus:     precTerm,
+	token.TokenStar:      precFactor,
+	token.TokenFWDSlash:  precFactor,
+	token.TokenPercent:   precFactor,
+}
+
+func precOf(expr ast.Expr) int {
+	switch e := expr.(type) {
+	case *ast.TernaryExpr:
+		return precTernary
+	case *ast.BinaryExpr:
+		return binaryPrec[e.Operator.Type]
+	case *ast.RangeExpr:
+		return precRange
+	case *ast.UnaryExpr, *ast.SpawnExpr:
+		return precUnary
+	case *ast.CallExpr, *ast.GetExpr, *ast.IndexExpr, *ast.SliceExpr:
+		return precPostfix
+	}
+	return precPrimary
+}
+
+// expr prints expr, in parentheses if it binds looser than min.
+func (p *printer) expr(expr ast.Expr, min int) {
+	if precOf(expr) < min || p.stmtStart && opensStmt(expr) {
+		p.write("(")
+		p.nested(expr)
+		p.write(")")
+		return
+	}
+	switch e := expr.(type) {
+	case *ast.LiteralExpr:
+		p.literal(e.Value)
+	case *ast.VariableExpr:
+		p.tok(e.Name, e.Name.Lexeme)
+	case *ast.BinaryExpr:
+		prec := binaryPrec[e.Operator.Type]
+		p.expr(e.Left, prec)
+		p.write(" ")
+		p.tok(e.Operator, strings.TrimSpace(e.Operator.Lexeme))
+		p.write(" ")
+		p.expr(e.Right, prec+1)
+	case *ast.UnaryExpr:
+		p.tok(e.Operator, strings.TrimSpace(e.Operator.Lexeme))
+		p.expr(e.Right, precUnary)
+	case *ast.TernaryExpr:
+		p.expr(e.Conditon, precOr)
+		p.write(" ? ")
+		p.expr(e.Then, precTernary)
+		p.write(" : ")
+		p.expr(e.Else, precTernary)
+	case *ast.RangeExpr:
+		p.expr(e.Start, precBitOr)
+		if e.Inclusive {
+			p.write("..=")
+		} else {
+			p.write("..")
+		}
+		p.expr(e.End, precBitOr)
+		if e.Step != nil {
+			p.write(" step ")
+			p.expr(e.Step, precBitOr)
+		}
+	case *ast.SpawnExpr:
+		p.write("spawn ")
+		p.expr(e.Call, precPostfix)
+	case *ast.CallExpr:
+		p.callee(e.Callee)
+		if len(e.TypeArgs) > 0 {
+			args := make([]string, len(e.TypeArgs))
+			for idx, arg := range e.TypeArgs {
+				args[idx] = typeString(arg)
+			}
+			p.write("[", strings.Join(args, ", "), "]")
+		}
+		if p.broken(e) {
+			p.vertical("(", ")", e, len(e.Arguments), func(idx int) ast.Expr { return e.Arguments[idx] }, func(idx int) {
+				p.nested(e.Arguments[idx])
+			})
+			return
+		}
+		p.write("(")
+		p.list(e.Arguments)
+		p.write(")")
+	case *ast.GetExpr:
+		p.expr(e.Object, precPostfix)
+		p.write(".")
+		p.tok(e.Name, e.Name.Lexeme)
+	case *ast.IndexExpr:
+		p.expr(e.Collection, precPostfix)
+		p.write("[")
+		p.nested(e.Index)
+		p.write("]")
+	case *ast.SliceExpr:
+		p.expr(e.Collection, precPostfix)
+		p.write("[")
+		if e.Low != nil {
+			p.nested(e.Low)
+		}
+		p.write(":")
+		if e.High != nil {
+			p.nested(e.High)
+		}
+		p.write("]")
+	case *ast.ListExpr:
+		if p.broken(e) {
+			p.vertical("[", "]", e, len(e.Elements), func(idx int) ast.Expr { return e.Elements[idx] }, func(idx int) {
+				p.nested(e.Elements[idx])
+			})
+			return
+		}
+		p.write("[")
+		p.list(e.Elements)
+		p.write("]")
+	case *ast.DictExpr:
+		if p.broken(e) {
+			p.vertical("{", "}", e, len(e.Pairs), func(idx int) ast.Expr { return e.Pairs[idx][0] }, func(idx int) {
+				p.expr(e.Pairs[idx][0], precLowest)
+				p.write(": ")
+				p.expr(e.Pairs[idx][1], precLowest)
+			})
+			return
+		}
+		p.write("{")
+		for idx, pair := range e.Pairs {
+			if idx > 0 {
+				p.write(", ")
+			}
+			p.expr(pair[0], precLowest)
+			p.write(": ")
+			p.expr(pair[1], precLowest)
+		}
+		p.write("}")
+	case *ast.StructLiteralExpr:
+		p.structLiteral(e)
+	case *ast.FuncExpr:
+		p.write("func")
+		p.params(e.Params)
+		p.write(" ")
+		p.nestedBlock(e.Body)
+	case *ast.IfExpr:
+		p.ifExpr(e)
+	}
+}
+
+// opensStmt reports whether expr begins with a token that, at the start of a
+// statement, opens a declaration, an if statement or a block instead.
+func opensStmt(expr ast.Expr) bool {
+	switch expr.(type) {
+	case *ast.FuncExpr, *ast.IfExpr, *ast.DictExpr:
+		return true
+	}
+	return false
+}
+
+// nested prints an expression inside brackets or parentheses, where struct
+// literals need no protection.
+func (p *printer) nested(expr ast.Expr) {
+	header := p.header
+	p.header = false
+	p.expr(expr, precLowest)
+	p.header = header
+}
+
+// headerExpr prints the condition of an if, loop or switch.
+func (p *printer) headerExpr(expr ast.Expr) {
+	header := p.header
+	p.header = true
+	p.expr(expr, precLowest)
+	p.header = header
+}
+
+// nestedBlock prints a block that is part of an expression; its statements
+// are not part of any enclosing header.
+func (p *printer) nestedBlock(b *ast.BlockStmt) {
+	header := p.header
+	p.header = false
+	p.block(b)
+	p.header = header
+}
+
+func (p *printer) list(exprs []ast.Expr) {
+	for idx, expr := range exprs {
+		if idx > 0 {
+			p.write(", ")
+		}
+		p.nested(expr)
+	}
+}
+
+// broken reports whether a comment that ends its line sits between the
+// brackets of the list, dict or call expr, which is then printed one element
+// per line so the comment keeps its place.
+func (p *printer) broken(expr ast.Expr) bool {
+	return slices.ContainsFunc(p.comments, func(c comment) bool {
+		return !c.midLine() && innermost(expr, c.prev) == expr
+	})
+}
+
+// vertical prints the n elements of the collection expr one per line between
+// open and close, each followed by the comments that come after it. start
+// returns the first expression of an element and item prints it.
+func (p *printer) vertical(open, close string, expr ast.Node, n int, start func(int) ast.Expr, item func(int)) {
+	from, to, _ := inside(expr)
+	p.write(open)
+	p.indent++
+	for idx := range n {
+		first, _ := ast.Bounds(start(idx))
+		p.endLine(from, first)
+		p.startLine()
+		item(idx)
+		if idx+1 < n {
+			p.write(",")
+		}
+	}
+	p.endLine(from, to)
+	p.indent--
+	p.startLine()
+	p.write(close)
+}
+
+// endLine ends the current line with the comments that follow a token in
+// [from, to): the ones after code stay on the line, the others get lines of
+// their own.
+func (p *printer) endLine(from, to token.Token) {
+	taken := p.take(func(c comment) bool {
+		return !c.midLine() && !before(c.prev, from) && before(c.prev, to)
+	})
+	for _, c := range taken {
+		if c.Inline {
+			p.write(" ", c.Text)
+		}
+	}
+	p.newline()
+	for _, c := range taken {
+		if !c.Inline {
+			p.startLine()
+			p.write(c.Text)
+			p.newline()
+		}
+	}
+}
+
+// innermost returns the innermost list, dict, call or block in root whose
+// brackets enclose t, or nil.
+func innermost(root ast.Node, t token.Token) ast.Node {
+	var found ast.Node
+	ast.Inspect(root, func(node ast.Node) bool {
+		if from, to, ok := inside(node); ok && !before(t, from) && before(t, to) {
+			found = node
+		}
+		return true
+	})
+	return found
+}
+
+// inside returns the tokens bounding the inside of the brackets of a list,
+// dict, call or block: from the open bracket up to, not including, to. For
+// collections to is just past their last element, so a comment after it
+// counts as inside.
+func inside(node ast.Node) (from, to token.Token, ok bool) {
+	switch n := node.(type) {
+	case *ast.ListExpr:
+		if len(n.Elements) > 0 {
+			return n.LBracket, past(n.Elements[len(n.Elements)-1]), true
+		}
+	case *ast.DictExpr:
+		if len(n.Pairs) > 0 {
+			return n.LBrace, past(n.Pairs[len(n.Pairs)-1][1]), true
+		}
+	case *ast.CallExpr:
+		if len(n.Arguments) > 0 {
+			return past(n.Callee), n.Paren, true
+		}
+	case *ast.BlockStmt:
+		if n.LBrace.Line > 0 {
+			after := n.RBrace
+			after.Column++
+			return n.LBrace, after, true
+		}
+	}
+	return token.Token{}, token.Token{}, false
+}
+
+// past returns a position just after the start of the last token of node.
+func past(node ast.Node) token.Token {
+	_, last := ast.Bounds(node)
+	last.Column++
+	return last
+}
+
+// callee prints the function of a call. An index by a plain name before the
+// argument list would read as type arguments, f[T](...), so it keeps its
+// parentheses.
+func (p *printer) callee(expr ast.Expr) {
+	if idx, ok := expr.(*ast.IndexExpr); ok {
+		if _, ok := idx.Index.(*ast.VariableExpr); ok {
+			p.write("(")
+			p.nested(expr)
+			p.write(")")
+			return
+		}
+	}
+	p.expr(expr, precPostfix)
+}
+
+// structLiteral prints T{a: x, b: y} with the fields sorted by name; the
+// AST does not keep their order.
+func (p *printer) structLiteral(e *ast.StructLiteralExpr) {
+	if p.header {
+		p.write("(")
+		p.nested(e)
+		p.write(")")
+		return
+	}
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	p.write(typeString(e.TypeName), "{")
+	for idx, name := range names {
+		if idx > 0 {
+			p.write(", ")
+		}
+		p.write(name, ": ")
+		p.expr(e.Fields[name], precLowest)
+	}
+	p.write("}")
+}
+
+func (p *printer) ifExpr(e *ast.IfExpr) {
+	p.write("if ")
+	p.headerExpr(e.Conditon)
+	p.write(" ")
+	p.nestedBlock(e.ThenBranch)
+	p.write(" else ")
+	if e.ElseIf != nil {
+		p.ifExpr(e.ElseIf)
+		return
+	}
+	p.nestedBlock(e.ElseBranch)
+}
+
+func (p *printer) literal(tok token.Token) {
+	if tok.Type != token.TokenString {
+		p.tok(tok, strings.TrimSpace(tok.Lexeme))
+		return
+	}
+	p.tok(tok, quote(tok.Lexeme))
+}
+
+// quote writes s as a double-quoted string literal using the escapes the
+// lexer understands.
+func quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\\':
+			sb.WriteString(`\\`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,620 @@
+// Package format prints Niftel programs in a canonical layout. Formatting
+// keeps comments and the meaning of the program: the formatted source parses
+// to the same AST as the original, and formatting it again changes nothing.
+package format
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+)
+
+// Source formats a whole source file. It fails if src does not parse.
+func Source(src string) (string, error) {
+	collector := &commentCollector{src: lexer.New(src)}
+	stmts, err := parser.New(collector).Parse()
+	if err != nil {
+		return "", err
+	}
+	p := &printer{lines: strings.Split(src, "\n"), comments: collector.comments}
+	p.stmts(stmts, -1)
+	return p.buf.String(), nil
+}
+
+// commentCollector passes tokens through to the parser and keeps the comments
+// the lexer attached to them, which the parser does not.
+type commentCollector struct {
+	src      lexer.TokenSource
+	last     token.Token
+	comments []comment
+}
+
+func (c *commentCollector) NextToken() (token.Token, error) {
+	tok, err := c.src.NextToken()
+	for _, text := range tok.Comments {
+		c.comments = append(c.comments, comment{Comment: text, prev: c.last, next: tok})
+	}
+	c.last = tok
+	return tok, err
+}
+
+// comment is a source comment with the tokens on either side of it.
+type comment struct {
+	token.Comment
+	prev, next token.Token
+}
+
+// midLine reports whether c is a block comment with code on both sides of it
+// on its line. It is printed next to the token it follows or precedes.
+func (c comment) midLine() bool {
+	return c.Inline && strings.HasPrefix(c.Text, "/*") && c.next.Line == c.Line && c.next.Type != token.TokenEOF
+}
+
+// sameToken reports whether a and b are the same source token.
+func sameToken(a, b token.Token) bool {
+	return a.Line > 0 && a.Line == b.Line && a.Column == b.Column && a.Type == b.Type
+}
+
+// before reports whether a starts before b in the source.
+func before(a, b token.Token) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+type printer struct {
+	buf    strings.Builder
+	indent int
+	// lines is the original source, used to keep blank lines between
+	// statements.
+	lines []string
+	// comments holds the comments not printed yet, in source order.
+	comments []comment
+	// header is set while printing an if, while, for or switch header, where
+	// a struct literal would be read as the start of the body.
+	header bool
+	// stmtStart is set until the first token of an expression statement is
+	// written.
+	stmtStart bool
+}
+
+func (p *printer) write(parts ...string) {
+	for _, part := range parts {
+		p.buf.WriteString(part)
+		p.stmtStart = p.stmtStart && part == ""
+	}
+}
+
+// newline ends the current line. Indentation is written by startLine, so
+// blank lines carry no trailing whitespace.
+func (p *printer) newline() {
+	p.buf.WriteByte('\n')
+}
+
+func (p *printer) startLine() {
+	p.buf.WriteString(strings.Repeat("\t", p.indent))
+}
+
+// blankBefore reports whether the source has an empty line just above line.
+func (p *printer) blankBefore(line int) bool {
+	idx := line - 2
+	return idx >= 0 && idx < len(p.lines) && strings.TrimSpace(p.lines[idx]) == ""
+}
+
+// flushComments prints, each on its own line, the comments that come before
+// line; line < 0 flushes all of them. first tells whether nothing has been
+// printed yet in the enclosing block, where a blank line is dropped.
+func (p *printer) flushComments(line int, first bool) bool {
+	for len(p.comments) > 0 && (line < 0 || p.comments[0].Line < line) {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		if !first && p.blankBefore(c.Line) {
+			p.newline()
+		}
+		p.startLine()
+		p.write(c.Text)
+		p.newline()
+		first = false
+	}
+	return first
+}
+
+// takeInline removes and returns the comments that follow code on line and
+// end it. Comments inside a list, dict or call of node stay for the
+// collection to print.
+func (p *printer) takeInline(line int, node ast.Node) []comment {
+	return p.take(func(c comment) bool {
+		if !c.Inline || c.Line != line || c.midLine() {
+			return false
+		}
+		if node == nil {
+			return true
+		}
+		_, inside := innermost(node, c.prev).(ast.Expr)
+		return !inside
+	})
+}
+
+// take removes and returns the comments for which keep reports true.
+func (p *printer) take(keep func(comment) bool) []comment {
+	var taken []comment
+	p.comments = slices.DeleteFunc(p.comments, func(c comment) bool {
+		if keep(c) {
+			taken = append(taken, c)
+			return true
+		}
+		return false
+	})
+	return taken
+}
+
+// tok writes text, the spelling of t, with the comments that sit right
+// before or after t on its line. A comment between t and an operand is left
+// for the operand, which is also written by tok.
+func (p *printer) tok(t token.Token, text string) {
+	for _, c := range p.take(func(c comment) bool { return c.midLine() && sameToken(c.next, t) }) {
+		p.write(c.Text, " ")
+	}
+	p.write(text)
+	for _, c := range p.take(func(c comment) bool {
+		return c.midLine() && sameToken(c.prev, t) && !operand[c.next.Type]
+	}) {
+		p.write(" ", c.Text)
+	}
+}
+
+// operand holds the tokens that print as a whole operand.
+var operand = map[token.TokenType]bool{
+	token.TokenIdentifier: true,
+	token.TokenNumber:     true,
+	token.TokenFloat:      true,
+	token.TokenString:     true,
+	token.TokenBool:       true,
+	token.TokenNull:       true,
+	token.TokenTrue:       true,
+	token.TokenFalse:      true,
+	token.TokenNil:        true,
+}
+
+// stmts prints a statement list. end is the line of the brace closing the
+// list, so comments before it stay inside; end < 0 means the end of the file.
+func (p *printer) stmts(stmts []ast.Stmt, end int) {
+	first := true
+	for idx, stmt := range stmts {
+		line := firstLine(stmt)
+		first = p.flushComments(line, first)
+		if !first && p.blankBefore(line) {
+			p.newline()
+		}
+		inline := p.takeInline(line, stmt)
+		mark := p.buf.Len()
+		p.startLine()
+		p.stmt(stmt)
+		p.newline()
+		// A comment after code further into the statement that nothing
+		// printed yet goes at the end of it.
+		bound := end
+		if idx+1 < len(stmts) {
+			bound = firstLine(stmts[idx+1])
+		}
+		rest := p.take(func(c comment) bool { return c.Inline && (bound < 0 || c.Line < bound) })
+		if out := p.buf.String(); strings.Count(out[mark:], "\n") == 1 {
+			rest = append(rest, inline...)
+			slices.SortStableFunc(rest, func(a, b comment) int { return cmp.Compare(a.Line, b.Line) })
+			p.appendComments(mark, rest)
+		} else {
+			p.appendComments(mark, inline)
+			out = p.buf.String()
+			p.appendComments(strings.LastIndexByte(out[:len(out)-1], '\n')+1, rest)
+		}
+		first = false
+	}
+	p.flushComments(end, first)
+}
+
+// appendComments adds comments to the end of the first line printed after
+// mark.
+func (p *printer) appendComments(mark int, comments []comment) {
+	if len(comments) == 0 {
+		return
+	}
+	out := p.buf.String()
+	eol := mark + strings.IndexByte(out[mark:], '\n')
+	var sb strings.Builder
+	sb.WriteString(out[:eol])
+	for _, c := range comments {
+		sb.WriteString(" ")
+		sb.WriteString(c.Text)
+	}
+	sb.WriteString(out[eol:])
+	p.buf.Reset()
+	p.buf.WriteString(sb.String())
+}
+
+// block prints { stmts } with the body indented.
+func (p *printer) block(b *ast.BlockStmt) {
+	p.write("{")
+	for _, c := range p.take(func(c comment) bool { return c.Inline && sameToken(c.prev, b.LBrace) }) {
+		p.write(" ", c.Text)
+	}
+	p.newline()
+	p.indent++
+	p.stmts(b.Statements, b.RBrace.Line)
+	p.indent--
+	p.startLine()
+	p.write("}")
+}
+
+func (p *printer) stmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.VarStmt:
+		p.write("var ", joinNames(s.Names))
+		if s.Type != nil {
+			p.write(": ", typeString(s.Type))
+		}
+		if s.Init != nil {
+			p.write(" = ")
+			p.expr(s.Init, precLowest)
+		}
+	case *ast.ShortVarStmt:
+		p.tok(s.Name, s.Name.Lexeme)
+		p.write(" := ")
+		p.expr(s.Init, precLowest)
+	case *ast.AssignStmt:
+		p.tok(s.Name, s.Name.Lexeme)
+		p.write(" = ")
+		p.expr(s.Value, precLowest)
+	case *ast.CompoundAssignStmt:
+		p.tok(s.Name, s.Name.Lexeme)
+		p.write(" ")
+		p.tok(s.Operator, strings.TrimSpace(s.Operator.Lexeme))
+		p.write(" ")
+		p.expr(s.Value, precLowest)
+	case *ast.PrintStmt:
+		p.write("print(")
+		p.nested(s.Expr)
+		p.write(")")
+	case *ast.ExprStmt:
+		p.stmtStart = true
+		p.expr(s.Expr, precLowest)
+	case *ast.IfStmt:
+		p.write("if ")
+		p.headerExpr(s.Conditon)
+		p.write(" ")
+		p.body(s.ThenBranch)
+		if s.ElseBranch != nil {
+			p.write(" else ")
+			p.body(s.ElseBranch)
+		}
+	case *ast.WhileStmt:
+		p.label(s.Label)
+		p.write("while ")
+		p.headerExpr(s.Conditon)
+		p.write(" ")
+		p.body(s.Body)
+	case *ast.ForStmt:
+		p.label(s.Label)
+		p.write("for ")
+		if s.Init != nil {
+			p.stmt(s.Init)
+		}
+		p.write("; ")
+		if s.CondExpr != nil {
+			p.headerExpr(s.CondExpr)
+		}
+		p.write(";")
+		if s.Update != nil {
+			p.write(" ")
+			p.stmt(s.Update)
+		}
+		p.write(" ")
+		p.body(s.BodyStmt)
+	case *ast.ForInStmt:
+		p.label(s.Label)
+		p.write("for ", s.Name.Lexeme, " in ")
+		p.headerExpr(s.Iterable)
+		p.write(" ")
+		p.body(s.BodyStmt)
+	case *ast.BlockStmt:
+		p.block(s)
+	case *ast.FuncStmt:
+		p.funcStmt(s)
+	case *ast.StructStmt:
+		p.structStmt(s)
+	case *ast.ReturnStmt:
+		p.write("return")
+		for idx, val := range s.Values {
+			if idx == 0 {
+				p.write(" ")
+			} else {
+				p.write(", ")
+			}
+			p.expr(val, precLowest)
+		}
+	case *ast.YieldStmt:
+		p.write("yield ")
+		p.expr(s.Value, precLowest)
+	case *ast.BreakStmt:
+		p.write("break")
+		if s.Label.Lexeme != "" {
+			p.write(" ", s.Label.Lexeme)
+		}
+	case *ast.ContinueStmt:
+		p.write("continue")
+		if s.Label.Lexeme != "" {
+			p.write(" ", s.Label.Lexeme)
+		}
+	case *ast.FallthroughStmt:
+		p.write("fallthrough")
+	case *ast.SwitchStmt:
+		p.switchStmt(s)
+	case *ast.SelectStmt:
+		p.selectStmt(s)
+	}
+}
+
+func (p *printer) label(label token.Token) {
+	if label.Lexeme != "" {
+		p.write(label.Lexeme, ": ")
+	}
+}
+
+// body prints the block of an if or loop.
+func (p *printer) body(stmt ast.Stmt) {
+	if b, ok := stmt.(*ast.BlockStmt); ok {
+		p.block(b)
+		return
+	}
+	p.stmt(stmt)
+}
+
+func (p *printer) funcStmt(fn *ast.FuncStmt) {
+	p.write("func ", fn.Name.Lexeme)
+	if len(fn.TypeParams) > 0 {
+		p.write("[", joinNames(fn.TypeParams), "]")
+	}
+	p.params(fn.Params)
+	switch len(fn.Return) {
+	case 0:
+	case 1:
+		p.write(" -> ", typeString(fn.Return[0]))
+	default:
+		types := make([]string, len(fn.Return))
+		for idx, typ := range fn.Return {
+			types[idx] = typeString(typ)
+		}
+		p.write(" -> (", strings.Join(types, ", "), ")")
+	}
+	p.write(" ")
+	p.block(fn.Body)
+}
+
+func (p *printer) params(params []ast.Param) {
+	parts := make([]string, len(params))
+	for idx, param := range params {
+		parts[idx] = param.Name.Lexeme + ": " + typeString(param.Type)
+	}
+	p.write("(", strings.Join(parts, ", "), ")")
+}
+
+// structStmt prints fields and methods in their source order.
+func (p *printer) structStmt(s *ast.StructStmt) {
+	p.write("struct ", s.Name.Lexeme)
+	if len(s.TypeParams) > 0 {
+		p.write("[", joinNames(s.TypeParams), "]")
+	}
+	p.write(" {")
+	p.newline()
+
+	type member struct {
+		line  int
+		field *ast.VarStmt
+		fn    *ast.FuncStmt
+	}
+	var members []member
+	for idx := range s.Fields {
+		members = append(members, member{line: s.Fields[idx].Names[0].Line, field: &s.Fields[idx]})
+	}
+	for idx := range s.Methods {
+		members = append(members, member{line: s.Methods[idx].Func.Line, fn: &s.Methods[idx]})
+	}
+	slices.SortStableFunc(members, func(a, b member) int { return cmp.Compare(a.line, b.line) })
+
+	p.indent++
+	first := true
+	for _, m := range members {
+		before := p.buf.Len()
+		first = p.flushComments(m.line, first)
+		commented := p.buf.Len() > before
+		if !first && (p.blankBefore(m.line) || m.fn != nil && !commented) {
+			p.newline()
+		}
+		var node ast.Node = m.fn
+		if m.field != nil {
+			node = m.field
+		}
+		inline := p.takeInline(m.line, node)
+		mark := p.buf.Len()
+		p.startLine()
+		if m.field != nil {
+			p.write(m.field.Names[0].Lexeme, ": ", typeString(m.field.Type))
+		} else {
+			p.funcStmt(m.fn)
+		}
+		p.newline()
+		p.appendComments(mark, inline)
+		first = false
+	}
+	p.flushComments(s.RBrace.Line, first)
+	p.indent--
+	p.startLine()
+	p.write("}")
+}
+
+func (p *printer) switchStmt(s *ast.SwitchStmt) {
+	p.write("switch ")
+	p.headerExpr(s.Subject)
+	p.write(" {")
+	p.newline()
+	for idx, clause := range s.Cases {
+		p.flushComments(clause.Case.Line, idx == 0)
+		inline := p.takeInline(clause.Case.Line, nil)
+		mark := p.buf.Len()
+		p.startLine()
+		if clause.IsDefault {
+			p.write("default:")
+		} else {
+			p.write("case ")
+			for idx, val := range clause.Values {
+				if idx > 0 {
+					p.write(", ")
+				}
+				p.expr(val, precLowest)
+			}
+			p.write(":")
+		}
+		p.newline()
+		p.appendComments(mark, inline)
+		p.caseBody(clause.Body, s.Cases, idx, s.RBrace.Line)
+	}
+	p.flushComments(s.RBrace.Line, len(s.Cases) == 0)
+	p.startLine()
+	p.write("}")
+}
+
+func (p *printer) selectStmt(s *ast.SelectStmt) {
+	p.write("select {")
+	p.newline()
+	next := make([]ast.SwitchCase, len(s.Cases))
+	for idx, clause := range s.Cases {
+		next[idx].Case = clause.Case
+	}
+	for idx, clause := range s.Cases {
+		p.flushComments(clause.Case.Line, idx == 0)
+		inline := p.takeInline(clause.Case.Line, nil)
+		mark := p.buf.Len()
+		p.startLine()
+		if clause.IsDefault {
+			p.write("default:")
+		} else {
+			p.write("case ")
+			if clause.Name.Lexeme != "" {
+				p.write(clause.Name.Lexeme, " := ")
+			}
+			p.expr(clause.Comm, precLowest)
+			p.write(":")
+		}
+		p.newline()
+		p.appendComments(mark, inline)
+		p.caseBody(clause.Body, next, idx, s.RBrace.Line)
+	}
+	p.flushComments(s.RBrace.Line, len(s.Cases) == 0)
+	p.startLine()
+	p.write("}")
+}
+
+// caseBody prints the body of clause idx of cases, which ends where the next
+// clause or the closing brace starts.
+func (p *printer) caseBody(body []ast.Stmt, cases []ast.SwitchCase, idx, rbrace int) {
+	end := rbrace
+	if idx+1 < len(cases) {
+		end = cases[idx+1].Case.Line
+		// A comment indented no deeper than the next case belongs to it.
+		caseIndent := p.indentOf(end)
+		for _, c := range p.comments {
+			if c.Line >= end {
+				break
+			}
+			if c.Line > lastLine(body) && p.indentOf(c.Line) <= caseIndent {
+				end = c.Line
+				break
+			}
+		}
+	}
+	p.indent++
+	p.stmts(body, end)
+	p.indent--
+}
+
+// indentOf returns the width of the leading whitespace of a source line.
+func (p *printer) indentOf(line int) int {
+	if line < 1 || line > len(p.lines) {
+		return 0
+	}
+	text := p.lines[line-1]
+	return len(text) - len(strings.TrimLeft(text, " \t"))
+}
+
+// lastLine returns the first line of the last statement in stmts, or 0.
+func lastLine(stmts []ast.Stmt) int {
+	if len(stmts) == 0 {
+		return 0
+	}
+	return firstLine(stmts[len(stmts)-1])
+}
+
+func joinNames(names []token.Token) string {
+	parts := make([]string, len(names))
+	for idx, name := range names {
+		parts[idx] = name.Lexeme
+	}
+	return strings.Join(parts, ", ")
+}
+
+func typeString(t *ast.TypeExpr) string {
+	if t == nil {
+		return ""
+	}
+	if len(t.TypeArgs) == 0 {
+		return t.Name.Lexeme
+	}
+	args := make([]string, len(t.TypeArgs))
+	for idx := range t.TypeArgs {
+		args[idx] = typeString(&t.TypeArgs[idx])
+	}
+	return t.Name.Lexeme + "[" + strings.Join(args, ", ") + "]"
+}
+
+// firstLine returns the line a statement starts on: the smallest line of
+// any position inside it.
+func firstLine(stmt ast.Stmt) int {
+	first := 0
+	ast.Inspect(stmt, func(node ast.Node) bool {
+		line := nodeLine(node)
+		if line > 0 && (first == 0 || line < first) {
+			first = line
+		}
+		return true
+	})
+	return first
+}
+
+// nodeLine is the line of node's own position, also looking at the name
+// tokens that begin statements such as assignments.
+func nodeLine(node ast.Node) int {
+	line, _ := node.Pos()
+	var start token.Token
+	switch n := node.(type) {
+	case *ast.VariableExpr:
+		start = n.Name
+	case *ast.ShortVarStmt:
+		start = n.Name
+	case *ast.AssignStmt:
+		start = n.Name
+	case *ast.CompoundAssignStmt:
+		start = n.Name
+	case *ast.WhileStmt:
+		start = n.Label
+	case *ast.ForStmt:
+		start = n.Label
+	case *ast.ForInStmt:
+		start = n.Label
+	}
+	if start.Line > 0 && (line == 0 || start.Line < line) {
+		return start.Line
+	}
+	return line
+}
@@ -0,0 +1,220 @@
+package format_test
+
+import (
+	"flag"
+	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/format"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files in testdata")
+
+func parse(t *testing.T, name, src string) []ast.Stmt {
+	t.Helper()
+	stmts, err := parser.New(lexer.New(src)).Parse()
+	if err != nil {
+		t.Fatalf("%s: parse error: %v", name, err)
+	}
+	return stmts
+}
+
+// TestGolden formats every testdata/*.input file and checks the result
+// against its .golden file, that formatting it again changes nothing and
+// that it parses to the same program.
+func TestGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.input"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no test inputs")
+	}
+	for _, input := range inputs {
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := format.Source(string(src))
+			if err != nil {
+				t.Fatalf("format: %v", err)
+			}
+
+			golden := strings.TrimSuffix(input, ".input") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s:\n%s", golden, got)
+			}
+
+			again, err := format.Source(got)
+			if err != nil {
+				t.Fatalf("formatted output does not parse: %v", err)
+			}
+			if again != got {
+				t.Errorf("formatting is not idempotent:\n%s", again)
+			}
+
+			if before, after := dump(parse(t, input, string(src))), dump(parse(t, golden, got)); before != after {
+				t.Errorf("formatting changed the AST\nbefore: %s\nafter:  %s", before, after)
+			}
+		})
+	}
+}
+
+// TestGoldenCoversAST checks that the corpus contains every node type the
+// AST package declares.
+func TestGoldenCoversAST(t *testing.T) {
+	seen := make(map[string]bool)
+	inputs, _ := filepath.Glob(filepath.Join("testdata", "*.input"))
+	for _, input := range inputs {
+		src, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, stmt := range parse(t, input, string(src)) {
+			ast.Inspect(stmt, func(node ast.Node) bool {
+				seen[reflect.TypeOf(node).Elem().Name()] = true
+				return true
+			})
+		}
+	}
+	for _, name := range nodeTypes(t) {
+		if !seen[name] {
+			t.Errorf("no %s in testdata", name)
+		}
+	}
+}
+
+// nodeTypes lists the types in the nifast sources that have a Pos method,
+// except BadStmt and BadExpr, which only a program with syntax errors has.
+func nodeTypes(t *testing.T) []string {
+	t.Helper()
+	pkgs, err := goparser.ParseDir(gotoken.NewFileSet(), filepath.Join("..", "nifast"), func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*goast.FuncDecl)
+				if !ok || fn.Recv == nil || fn.Name.Name != "Pos" {
+					continue
+				}
+				if star, ok := fn.Recv.List[0].Type.(*goast.StarExpr); ok {
+					if name := star.X.(*goast.Ident).Name; !strings.HasPrefix(name, "Bad") {
+						names = append(names, name)
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dump renders an AST without positions, comments or resolver results, so
+// two parses of the same program compare equal.
+func dump(stmts []ast.Stmt) string {
+	var sb strings.Builder
+	dumpValue(&sb, reflect.ValueOf(stmts))
+	return sb.String()
+}
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+func dumpValue(sb *strings.Builder, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			sb.WriteString("nil")
+			return
+		}
+		dumpValue(sb, v.Elem())
+	case reflect.Struct:
+		if v.Type() == tokenType {
+			tok := v.Interface().(token.Token)
+			fmt.Fprintf(sb, "%v(%q %v)", tok.Type, strings.TrimSpace(tok.Lexeme), tok.Data)
+			return
+		}
+		sb.WriteString(v.Type().Name() + "{")
+		for idx := 0; idx < v.NumField(); idx++ {
+			field := v.Type().Field(idx)
+			if field.Name == "Binding" {
+				continue
+			}
+			sb.WriteString(field.Name + ":")
+			dumpValue(sb, v.Field(idx))
+			sb.WriteString(" ")
+		}
+		sb.WriteString("}")
+	case reflect.Slice, reflect.Array:
+		sb.WriteString("[")
+		for idx := 0; idx < v.Len(); idx++ {
+			dumpValue(sb, v.Index(idx))
+			sb.WriteString(" ")
+		}
+		sb.WriteString("]")
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		sb.WriteString("map[")
+		for _, key := range keys {
+			sb.WriteString(key.String() + ":")
+			dumpValue(sb, v.MapIndex(key))
+			sb.WriteString(" ")
+		}
+		sb.WriteString("]")
+	default:
+		fmt.Fprintf(sb, "%v", v.Interface())
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	want := `--- x.nif
++++ x.nif
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -8,3 +8,4 @@
+ h
+ i
+ j
++k
+`
+	if got := format.Diff("x.nif", before, after); got != want {
+		t.Errorf("unexpected diff:\n%s", got)
+	}
+	if got := format.Diff("x.nif", before, before); got != "" {
+		t.Errorf("expected no diff for equal input, got:\n%s", got)
+	}
+}
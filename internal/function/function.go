@@ -7,19 +7,23 @@ import (
 	"github.com/ithinkiborkedit/niftelv2.git/internal/environment"
 	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/trace"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
 )
 
+var log = trace.Logger(trace.Interp)
+
 type Function struct {
-	name       string
-	params     []ast.Param
-	typeParams []string
-	body       *ast.BlockStmt
-	env        *environment.Environment
-	isNative   bool
-	sourceLine int
-	sourceCol  int
-	nativeFunc func([]value.Value, InterpreterAPI) controlflow.ExecResult
+	name        string
+	params      []ast.Param
+	typeParams  []string
+	body        *ast.BlockStmt
+	env         *environment.Environment
+	isNative    bool
+	isGenerator bool
+	sourceLine  int
+	sourceCol   int
+	nativeFunc  func([]value.Value, InterpreterAPI) controlflow.ExecResult
 }
 
 type InterpreterAPI interface {
@@ -27,6 +31,7 @@ type InterpreterAPI interface {
 	PopEnv()
 	GetEnv() *environment.Environment
 	ExecuteBlock(*ast.BlockStmt, *environment.Environment) controlflow.ExecResult
+	StartGenerator(name string, body *ast.BlockStmt, env *environment.Environment) controlflow.ExecResult
 	// ExecuteBlock(*ast.BlockStmt, *environment.Environment) (ret value.Value, err error)
 }
 
@@ -51,6 +56,14 @@ func NewUserFunc(name string, params []ast.Param, body *ast.BlockStmt, env *envi
 
 }
 
+// NewGeneratorFunc returns a function whose body yields. Calling it returns a
+// generator instead of running the body.
+func NewGeneratorFunc(name string, params []ast.Param, body *ast.BlockStmt, env *environment.Environment, line, col int) *Function {
+	fn := NewUserFunc(name, params, body, env, line, col)
+	fn.isGenerator = true
+	return fn
+}
+
 func NewNativeFunc(name string, fn func([]value.Value, InterpreterAPI) controlflow.ExecResult) *Function {
 	return &Function{
 		name:       name,
@@ -61,7 +74,7 @@ func NewNativeFunc(name string, fn func([]value.Value, InterpreterAPI) controlfl
 
 func (f *Function) Call(args []value.Value, typeArgs []*symtable.TypeSymbol, interp InterpreterAPI) controlflow.ExecResult {
 
-	fmt.Printf("CALLING Function: %v, args: %v", f.name, f.params)
+	log.Debug("call", "function", f.name, "args", len(args), "native", f.isNative)
 	if f.isNative {
 		return f.nativeFunc(args, interp)
 	}
@@ -85,6 +98,10 @@ func (f *Function) Call(args []value.Value, typeArgs []*symtable.TypeSymbol, int
 		// callEnv.Define(param.Name.Lexeme, args[i])
 	}
 
+	if f.isGenerator {
+		return interp.StartGenerator(f.name, f.body, callEnv)
+	}
+
 	interp.PushEnv(callEnv)
 	defer interp.PopEnv()
 	// fmt.Printf("RETURNING from function.Call: %#v, err: %v\n", controlflow.ExecResult{Value: })
@@ -94,6 +111,24 @@ func (f *Function) Call(args []value.Value, typeArgs []*symtable.TypeSymbol, int
 
 // return value.Null(), nil
 
+// Bind returns a copy of a method with self bound to receiver.
+func (f *Function) Bind(receiver value.Value) (*Function, error) {
+	selfEnv := environment.NewEnvironment(f.env)
+	if err := selfEnv.DefineVar(&symtable.VarSymbol{
+		SymName: "self",
+		SymKind: symtable.SymbolVar,
+		Mutable: false,
+	}); err != nil {
+		return nil, err
+	}
+	if err := selfEnv.AssignVar("self", receiver); err != nil {
+		return nil, err
+	}
+	bound := *f
+	bound.env = selfEnv
+	return &bound, nil
+}
+
 func (f *Function) Arity() int            { return len(f.params) }
 func (f *Function) Name() string          { return f.name }
 func (f *Function) IsNative() bool        { return f.isNative }
@@ -0,0 +1,176 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/controlflow"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/function"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// ErrSkipped is wrapped by the error skip() raises, so a test runner can
+// tell a skipped test from a failed one.
+var ErrSkipped = errors.New("test skipped")
+
+// builtinAssertEq fails unless its two arguments are equal, comparing lists,
+// tuples, dicts and structs element by element.
+func builtinAssertEq(args []value.Value, _ function.InterpreterAPI) controlflow.ExecResult {
+	if len(args) != 2 && len(args) != 3 {
+		return controlflow.ExecResult{Err: fmt.Errorf("assert_eq() expects 2 or 3 arguments, got %d", len(args))}
+	}
+	actual, expected := args[0], args[1]
+	if sameValue(actual, expected) {
+		return controlflow.ExecResult{Value: value.Null()}
+	}
+	msg := fmt.Sprintf("expected %s, got %s", describeValue(expected), describeValue(actual))
+	if actual.Type != expected.Type {
+		msg = fmt.Sprintf("expected %s %s, got %s %s", typeOf(expected), describeValue(expected), typeOf(actual), describeValue(actual))
+	}
+	return assertionFailed("assert_eq", msg, args, 2)
+}
+
+// builtinAssertTrue fails unless its argument is true.
+func builtinAssertTrue(args []value.Value, _ function.InterpreterAPI) controlflow.ExecResult {
+	if len(args) != 1 && len(args) != 2 {
+		return controlflow.ExecResult{Err: fmt.Errorf("assert_true() expects 1 or 2 arguments, got %d", len(args))}
+	}
+	if args[0].Type == value.ValueBool && args[0].Data == true {
+		return controlflow.ExecResult{Value: value.Null()}
+	}
+	return assertionFailed("assert_true", "expected true, got "+describeValue(args[0]), args, 1)
+}
+
+// builtinAssertErr calls its argument, a function of no parameters, and fails
+// unless the call raises an error; with a second argument, one whose message
+// contains it.
+func builtinAssertErr(args []value.Value, interp function.InterpreterAPI) controlflow.ExecResult {
+	if len(args) != 1 && len(args) != 2 {
+		return controlflow.ExecResult{Err: fmt.Errorf("assert_err() expects 1 or 2 arguments, got %d", len(args))}
+	}
+	fn, ok := args[0].Data.(function.Callable)
+	if args[0].Type != value.ValueFunc || !ok {
+		return controlflow.ExecResult{Err: fmt.Errorf("assert_err() expects a function, got %s", typeOf(args[0]))}
+	}
+	result := interp.(*Interpreter).call(fn, nil, nil, nil)
+	var want string
+	if len(args) == 2 {
+		want, _ = args[1].Data.(string)
+	}
+	switch {
+	case result.Err == nil:
+		msg := "expected an error, got none"
+		if want != "" {
+			msg = fmt.Sprintf("expected an error containing %q, got none", want)
+		}
+		return assertionFailed("assert_err", msg, nil, 0)
+	case !strings.Contains(errorMessage(result.Err), want):
+		return assertionFailed("assert_err", fmt.Sprintf("expected an error containing %q, got %q", want, errorMessage(result.Err)), nil, 0)
+	}
+	return controlflow.ExecResult{Value: value.Null()}
+}
+
+// builtinSkip ends the running test without failing it.
+func builtinSkip(args []value.Value, _ function.InterpreterAPI) controlflow.ExecResult {
+	if len(args) > 1 {
+		return controlflow.ExecResult{Err: fmt.Errorf("skip() expects at most 1 argument, got %d", len(args))}
+	}
+	msg := "skipped"
+	if len(args) == 1 {
+		msg += ": " + args[0].String()
+	}
+	return controlflow.ExecResult{Err: &niferrors.NifError{Kind: niferrors.RuntimeError, Code: niferrors.CodeRuntime, Message: msg, Err: ErrSkipped}}
+}
+
+// assertionFailed returns the error of a failed assertion, prefixed with the
+// optional message argument at args[msgIdx].
+func assertionFailed(name, msg string, args []value.Value, msgIdx int) controlflow.ExecResult {
+	if msgIdx > 0 && len(args) > msgIdx {
+		msg = args[msgIdx].String() + ": " + msg
+	}
+	return controlflow.ExecResult{Err: runtimeErrorf(niferrors.CodeAssertion, "%s failed: %s", name, msg)}
+}
+
+// errorMessage is the message of err without its position.
+func errorMessage(err error) string {
+	if nifErr, ok := err.(*niferrors.NifError); ok {
+		return nifErr.Message
+	}
+	return err.Error()
+}
+
+// describeValue shows v the way it would be written in source, so that the
+// string "1" and the int 1 read differently.
+func describeValue(v value.Value) string {
+	if s, ok := v.Data.(string); ok && v.Type == value.ValueString {
+		return strconv.Quote(s)
+	}
+	return v.String()
+}
+
+func typeOf(v value.Value) string {
+	if t := v.TypeInfo(); t != nil {
+		return t.SymName
+	}
+	return "value"
+}
+
+// sameValue reports whether a and b are equal, comparing containers and
+// struct instances by their contents.
+func sameValue(a, b value.Value) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	switch a.Type {
+	case value.ValueList:
+		x, _ := a.Data.([]value.Value)
+		y, _ := b.Data.([]value.Value)
+		return sameValues(x, y)
+	case value.ValueTuple:
+		x, xok := a.Data.(*value.NiftelTupleValue)
+		y, yok := b.Data.(*value.NiftelTupleValue)
+		return xok && yok && sameValues(x.Elements, y.Elements)
+	case value.ValueDict:
+		x, xok := a.Data.(*value.NiftelDict)
+		y, yok := b.Data.(*value.NiftelDict)
+		if !xok || !yok || len(x.Keys()) != len(y.Keys()) {
+			return false
+		}
+		for _, entry := range x.Iter() {
+			other, ok := y.Get(entry.Key)
+			if !ok || !sameValue(entry.Value, other) {
+				return false
+			}
+		}
+		return true
+	case value.ValueStruct:
+		x, xok := a.Data.(*value.StructInstance)
+		y, yok := b.Data.(*value.StructInstance)
+		if !xok || !yok || x.Type.Name != y.Type.Name || len(x.Fields) != len(y.Fields) {
+			return false
+		}
+		for name, field := range x.Fields {
+			other, ok := y.Fields[name]
+			if !ok || !sameValue(field, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.Equals(b)
+}
+
+func sameValues(a, b []value.Value) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if !sameValue(a[idx], b[idx]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package interpreter_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+func TestAssert_Pass(t *testing.T) {
+	runSource(t, `
+struct P { x: int }
+assert_eq(1 + 1, 2)
+assert_eq("a", "a", "strings")
+assert_eq([1, [2, 3]], [1, [2, 3]])
+assert_eq({"a": [1]}, {"a": [1]})
+assert_eq(P{x: 1}, P{x: 1})
+assert_true(2 > 1)
+assert_err(func() { print(1 / 0) })
+assert_err(func() { print(1 / 0) }, "division")
+`)
+}
+
+func TestAssert_FailureMessages(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{`assert_eq(1 + 1, 3)`, "assert_eq failed: expected 3, got 2"},
+		{`assert_eq("1", 1)`, `assert_eq failed: expected int 1, got string "1"`},
+		{`assert_eq([1, 2], [1, 3], "list")`, "assert_eq failed: list: expected [1, 3], got [1, 2]"},
+		{`assert_true(1 > 2)`, "assert_true failed: expected true, got false"},
+		{`assert_err(func() { print(1) })`, "assert_err failed: expected an error, got none"},
+		{`assert_err(func() { print(1 / 0) }, "index")`, `assert_err failed: expected an error containing "index", got "division by zero"`},
+	}
+	for _, tc := range cases {
+		err := runSourceTo(t, tc.source, io.Discard)
+		nifErr := niferrors.As(err)
+		if err == nil || nifErr.Code != niferrors.CodeAssertion || nifErr.Message != tc.want {
+			t.Errorf("%s: expected %q, got %v", tc.source, tc.want, err)
+		}
+	}
+}
+
+func TestAssert_Skip(t *testing.T) {
+	err := runSourceErr(t, `skip("not yet")`)
+	if !errors.Is(err, interpreter.ErrSkipped) {
+		t.Errorf("expected a skip, got %v", err)
+	}
+}
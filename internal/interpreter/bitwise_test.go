@@ -0,0 +1,56 @@
+package interpreter_test
+
+import "testing"
+
+func TestInterpreter_BitwiseOperators(t *testing.T) {
+	interp := runSource(t, `
+and := 12 & 10
+or := 12 | 3
+xor := 12 ^ 10
+not := ~5
+shl := 1 << 10
+shr := -16 >> 2
+prec := 1 | 2 & 3 << 1
+mixed := 1 + 2 << 3
+`)
+	expectInt(t, interp, "and", 8)
+	expectInt(t, interp, "or", 15)
+	expectInt(t, interp, "xor", 6)
+	expectInt(t, interp, "not", -6)
+	expectInt(t, interp, "shl", 1024)
+	expectInt(t, interp, "shr", -4)
+	expectInt(t, interp, "prec", 3)
+	expectInt(t, interp, "mixed", 24)
+}
+
+func TestInterpreter_CompoundAssignment(t *testing.T) {
+	interp := runSource(t, `
+flags := 0
+flags |= 1 << 3
+flags |= 1
+flags &= ~1
+mask := 255
+mask ^= 15
+mask >>= 4
+mask <<= 1
+count := 0
+for i := 0; i < 4; i += 1 {
+	count += 2
+}
+count -= 1
+`)
+	expectInt(t, interp, "flags", 8)
+	expectInt(t, interp, "mask", 30)
+	expectInt(t, interp, "count", 7)
+}
+
+func TestInterpreter_ShiftCountsWrap(t *testing.T) {
+	interp := runSource(t, `
+big := 1 << 65
+neg := 1 << -63
+sign := -8 >> 64
+`)
+	expectInt(t, interp, "big", 2)
+	expectInt(t, interp, "neg", 2)
+	expectInt(t, interp, "sign", -8)
+}
@@ -0,0 +1,110 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/controlflow"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/function"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+)
+
+// RegisterBuiltInFuncs defines the native functions available to every program.
+func (i *Interpreter) RegisterBuiltInFuncs() error {
+	builtins := []*function.Function{
+		function.NewNativeFunc("len", builtinLen),
+		function.NewNativeFunc("list", builtinList),
+		function.NewNativeFunc("send", builtinSend),
+		function.NewNativeFunc("recv", builtinRecv),
+		function.NewNativeFunc("close", builtinClose),
+		function.NewNativeFunc("join", builtinJoin),
+		function.NewNativeFunc("wait", builtinWait),
+		function.NewNativeFunc("mutex", builtinMutex),
+		function.NewNativeFunc("lock", builtinLock),
+		function.NewNativeFunc("unlock", builtinUnlock),
+		function.NewNativeFunc("next", builtinNext),
+		function.NewNativeFunc("assert_eq", builtinAssertEq),
+		function.NewNativeFunc("assert_true", builtinAssertTrue),
+		function.NewNativeFunc("assert_err", builtinAssertErr),
+		function.NewNativeFunc("skip", builtinSkip),
+	}
+	for _, fn := range builtins {
+		if err := i.defineNative(fn); err != nil {
+			return err
+		}
+	}
+	return i.defineNative(chanConstructor{})
+}
+
+func (i *Interpreter) defineNative(fn function.Callable) error {
+	if err := i.env.DefineFunc(&symtable.FuncSymbol{SymName: fn.Name()}); err != nil {
+		return err
+	}
+	if err := i.env.DefineVar(&symtable.VarSymbol{
+		SymName: fn.Name(),
+		SymKind: symtable.SymbolVar,
+		Mutable: false,
+	}); err != nil {
+		return err
+	}
+	return i.env.AssignVar(fn.Name(), value.Value{Type: value.ValueFunc, Data: fn})
+}
+
+func builtinLen(args []value.Value, _ function.InterpreterAPI) controlflow.ExecResult {
+	if len(args) != 1 {
+		return controlflow.ExecResult{Err: fmt.Errorf("len() expects 1 argument, got %d", len(args))}
+	}
+	n, err := lengthOf(args[0])
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	return controlflow.ExecResult{Value: value.Value{Type: value.ValueInt, Data: float64(n)}, Flow: controlflow.FlowNone}
+}
+
+func builtinList(args []value.Value, interp function.InterpreterAPI) controlflow.ExecResult {
+	if len(args) != 1 {
+		return controlflow.ExecResult{Err: fmt.Errorf("list() expects 1 argument, got %d", len(args))}
+	}
+	i := interp.(*Interpreter)
+	seq, err := i.iterValue(args[0])
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	elems := []value.Value{}
+	for elem, err := range seq {
+		if err == nil {
+			err = i.alloc(valueSize)
+		}
+		if err == nil {
+			err = i.canceled()
+		}
+		if err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+		elems = append(elems, elem)
+	}
+	return controlflow.ExecResult{Value: value.Value{Type: value.ValueList, Data: elems}, Flow: controlflow.FlowNone}
+}
+
+// builtinNext resumes a generator and returns its next value. Once the generator
+// is exhausted it returns the optional default, or fails without one.
+func builtinNext(args []value.Value, _ function.InterpreterAPI) controlflow.ExecResult {
+	if len(args) != 1 && len(args) != 2 {
+		return controlflow.ExecResult{Err: fmt.Errorf("next() expects 1 or 2 arguments, got %d", len(args))}
+	}
+	gen, ok := args[0].Data.(*value.NiftelGenerator)
+	if args[0].Type != value.ValueGenerator || !ok {
+		return controlflow.ExecResult{Err: fmt.Errorf("next() expects a generator, got %v", args[0].Type)}
+	}
+	elem, ok, err := gen.Next()
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	if !ok {
+		if len(args) == 2 {
+			return controlflow.ExecResult{Value: args[1], Flow: controlflow.FlowNone}
+		}
+		return controlflow.ExecResult{Err: fmt.Errorf("generator '%s' is exhausted", gen.Name)}
+	}
+	return controlflow.ExecResult{Value: elem, Flow: controlflow.FlowNone}
+}
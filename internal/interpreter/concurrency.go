@@ -0,0 +1,339 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/controlflow"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/environment"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/function"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+)
+
+// fork returns an interpreter for a spawned task. It shares the environment
+// chain it was spawned from, but has its own current scope and env stack, so
+// tasks never step on each other's frames. Its tracebacks start with the
+// calls that led to the spawn.
+func (i *Interpreter) fork() *Interpreter {
+	return &Interpreter{
+		env:                i.env,
+		typEnv:             i.typEnv,
+		methods:            i.methods,
+		ShouldPrintResults: i.ShouldPrintResults,
+		frames:             slices.Clone(i.frames),
+		TraceDepth:         i.TraceDepth,
+		Out:                i.Out,
+		opts:               i.opts,
+		usage:              i.usage,
+		Hook:               i.Hook,
+		CallHook:           i.CallHook,
+	}
+}
+
+// VisitSpawnExpr evaluates the callee and arguments on the current task, then
+// runs the call on a new goroutine. The task shares the globals and the
+// variables its callee closes over, so updates to them from several tasks
+// need compound assignment, a mutex or a channel.
+func (i *Interpreter) VisitSpawnExpr(expr *ast.SpawnExpr) controlflow.ExecResult {
+	callable, args, typeSyms, err := i.prepareCall(expr.Call)
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	task := value.NewNiftelTask()
+	child := i.fork()
+	go func() {
+		var result controlflow.ExecResult
+		defer func() {
+			if r := recover(); r != nil {
+				task.Finish(value.Null(), fmt.Errorf("task '%s' panicked: %v", callable.Name(), r))
+				return
+			}
+			task.Finish(result.Value, result.Err)
+		}()
+		result = child.call(callable, args, typeSyms, expr.Call)
+	}()
+	return controlflow.ExecResult{Value: value.Value{Type: value.ValueTask, Data: task}, Flow: controlflow.FlowNone}
+}
+
+// VisitSelectStmt waits until one of its communications can proceed, or runs
+// default if none can. An unlabeled break leaves the select.
+func (i *Interpreter) VisitSelectStmt(stmt *ast.SelectStmt) controlflow.ExecResult {
+	cases := make([]reflect.SelectCase, len(stmt.Cases))
+	for idx, clause := range stmt.Cases {
+		if clause.IsDefault {
+			cases[idx] = reflect.SelectCase{Dir: reflect.SelectDefault}
+			continue
+		}
+		_, args, _, err := i.prepareCall(clause.Comm)
+		if err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+		op := clause.Comm.Callee.(*ast.VariableExpr).Name.Lexeme
+		if op == "recv" {
+			if len(args) != 1 {
+				return controlflow.ExecResult{Err: fmt.Errorf("recv() expects 1 argument, got %d", len(args))}
+			}
+			ch, err := chanArg("recv", args[0])
+			if err != nil {
+				return controlflow.ExecResult{Err: err}
+			}
+			cases[idx] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.Chan())}
+			continue
+		}
+		if len(args) != 2 {
+			return controlflow.ExecResult{Err: fmt.Errorf("send() expects 2 arguments, got %d", len(args))}
+		}
+		ch, err := chanArg("send", args[0])
+		if err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+		if err := ch.CheckElem(args[1]); err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+		cases[idx] = reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(ch.Chan()), Send: reflect.ValueOf(args[1])}
+	}
+	if len(cases) == 0 {
+		return controlflow.ExecResult{Err: fmt.Errorf("select with no cases blocks forever")}
+	}
+	if done := i.interrupt(); done != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)})
+	}
+
+	chosen, received, ok, err := selectCase(cases)
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	if chosen == len(stmt.Cases) {
+		return controlflow.ExecResult{Err: i.stopped()}
+	}
+	clause := stmt.Cases[chosen]
+
+	bindEnv := environment.NewEnvironment(i.env)
+	if clause.Name.Lexeme != "" {
+		val := value.Null()
+		if ok {
+			val = received.Interface().(value.Value)
+		}
+		if err := bindEnv.DefineVar(&symtable.VarSymbol{
+			SymName: clause.Name.Lexeme,
+			SymKind: symtable.SymbolVar,
+			Mutable: true,
+		}); err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+		if err := bindEnv.AssignVar(clause.Name.Lexeme, val); err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+	}
+	result := i.within(bindEnv, func() controlflow.ExecResult {
+		return i.executeCaseBody(clause.Body)
+	})
+	if result.Flow == controlflow.FlowBreak && result.Label == "" {
+		return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+	}
+	return result
+}
+
+// selectCase wraps reflect.Select, turning a send on a closed channel into an error.
+func selectCase(cases []reflect.SelectCase) (chosen int, received reflect.Value, ok bool, err error) {
+	defer func() {
+		if recover() != nil {
+			err = fmt.Errorf("send on closed channel")
+		}
+	}()
+	chosen, received, ok = reflect.Select(cases)
+	return chosen, received, ok, nil
+}
+
+// chanConstructor implements chan[T](capacity?), creating a channel of element type T.
+type chanConstructor struct{}
+
+func (chanConstructor) Call(args []value.Value, typeArgs []*symtable.TypeSymbol, interp function.InterpreterAPI) controlflow.ExecResult {
+	if len(typeArgs) != 1 {
+		return controlflow.ExecResult{Err: fmt.Errorf("chan expects 1 type argument, got %d", len(typeArgs))}
+	}
+	if len(args) > 1 {
+		return controlflow.ExecResult{Err: fmt.Errorf("chan() expects at most 1 argument, got %d", len(args))}
+	}
+	capacity := int64(0)
+	if len(args) == 1 {
+		n, ok := intOf(args[0])
+		if !ok {
+			return controlflow.ExecResult{Err: fmt.Errorf("channel capacity must be integer")}
+		}
+		capacity = n
+	}
+	gen, ok := interp.GetEnv().LookupType("chan")
+	if !ok {
+		return controlflow.ExecResult{Err: fmt.Errorf("unknown type 'chan'")}
+	}
+	typ := symtable.InstantiateGenericType(gen, typeArgs)
+	ch, err := value.NewNiftelChan(typ, typeArgs[0], int(capacity))
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	return controlflow.ExecResult{Value: value.Value{Type: value.ValueChan, Data: ch}, Flow: controlflow.FlowNone}
+}
+
+func (chanConstructor) Arity() int            { return -1 }
+func (chanConstructor) Name() string          { return "chan" }
+func (chanConstructor) IsNative() bool        { return true }
+func (chanConstructor) SourcePos() (int, int) { return 0, 0 }
+
+func chanArg(name string, v value.Value) (*value.NiftelChan, error) {
+	ch, ok := v.Data.(*value.NiftelChan)
+	if v.Type != value.ValueChan || !ok {
+		return nil, fmt.Errorf("%s() expects a channel, got %v", name, v.Type)
+	}
+	return ch, nil
+}
+
+func taskArg(name string, v value.Value) (*value.NiftelTask, error) {
+	task, ok := v.Data.(*value.NiftelTask)
+	if v.Type != value.ValueTask || !ok {
+		return nil, fmt.Errorf("%s() expects a task, got %v", name, v.Type)
+	}
+	return task, nil
+}
+
+func builtinSend(args []value.Value, interp function.InterpreterAPI) controlflow.ExecResult {
+	if len(args) != 2 {
+		return controlflow.ExecResult{Err: fmt.Errorf("send() expects 2 arguments, got %d", len(args))}
+	}
+	ch, err := chanArg("send", args[0])
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	if err := ch.Send(args[1], interp.(*Interpreter).interrupt()); err != nil {
+		return controlflow.ExecResult{Err: interp.(*Interpreter).interrupted(err)}
+	}
+	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+}
+
+// builtinRecv returns the next value on the channel, or null once it is closed and drained.
+func builtinRecv(args []value.Value, interp function.InterpreterAPI) controlflow.ExecResult {
+	if len(args) != 1 {
+		return controlflow.ExecResult{Err: fmt.Errorf("recv() expects 1 argument, got %d", len(args))}
+	}
+	ch, err := chanArg("recv", args[0])
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	val, _, err := ch.Recv(interp.(*Interpreter).interrupt())
+	if err != nil {
+		return controlflow.ExecResult{Err: interp.(*Interpreter).interrupted(err)}
+	}
+	return controlflow.ExecResult{Value: val, Flow: controlflow.FlowNone}
+}
+
+// builtinClose closes a channel, or stops a generator that is no longer needed.
+func builtinClose(args []value.Value, _ function.InterpreterAPI) controlflow.ExecResult {
+	if len(args) != 1 {
+		return controlflow.ExecResult{Err: fmt.Errorf("close() expects 1 argument, got %d", len(args))}
+	}
+	if gen, ok := args[0].Data.(*value.NiftelGenerator); ok && args[0].Type == value.ValueGenerator {
+		gen.Stop()
+		return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+	}
+	ch, err := chanArg("close", args[0])
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	if err := ch.Close(); err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+}
+
+// builtinJoin waits for a task and returns its result, propagating its error.
+func builtinJoin(args []value.Value, interp function.InterpreterAPI) controlflow.ExecResult {
+	if len(args) != 1 {
+		return controlflow.ExecResult{Err: fmt.Errorf("join() expects 1 argument, got %d", len(args))}
+	}
+	task, err := taskArg("join", args[0])
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	val, err := task.Wait(interp.(*Interpreter).interrupt())
+	if errors.Is(err, value.ErrInterrupted) {
+		return controlflow.ExecResult{Err: interp.(*Interpreter).stopped()}
+	}
+	if err != nil {
+		return controlflow.ExecResult{Err: fmt.Errorf("joined task failed: %w", err)}
+	}
+	return controlflow.ExecResult{Value: val, Flow: controlflow.FlowNone}
+}
+
+// builtinWait waits for every task given and reports the first failure.
+func builtinWait(args []value.Value, interp function.InterpreterAPI) controlflow.ExecResult {
+	var firstErr error
+	for _, arg := range args {
+		task, err := taskArg("wait", arg)
+		if err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+		_, err = task.Wait(interp.(*Interpreter).interrupt())
+		if errors.Is(err, value.ErrInterrupted) {
+			return controlflow.ExecResult{Err: interp.(*Interpreter).stopped()}
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("waited task failed: %w", err)
+		}
+	}
+	if firstErr != nil {
+		return controlflow.ExecResult{Err: firstErr}
+	}
+	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+}
+
+func mutexArg(name string, v value.Value) (*value.NiftelMutex, error) {
+	m, ok := v.Data.(*value.NiftelMutex)
+	if v.Type != value.ValueMutex || !ok {
+		return nil, fmt.Errorf("%s() expects a mutex, got %v", name, v.Type)
+	}
+	return m, nil
+}
+
+// builtinMutex returns a new, unlocked mutex. Tasks share globals, and only
+// compound assignment updates one atomically, so a task holds a mutex to
+// read and write shared variables in several steps.
+func builtinMutex(args []value.Value, _ function.InterpreterAPI) controlflow.ExecResult {
+	if len(args) != 0 {
+		return controlflow.ExecResult{Err: fmt.Errorf("mutex() expects no arguments, got %d", len(args))}
+	}
+	return controlflow.ExecResult{Value: value.Value{Type: value.ValueMutex, Data: value.NewNiftelMutex()}, Flow: controlflow.FlowNone}
+}
+
+// builtinLock waits until the mutex is free and takes it.
+func builtinLock(args []value.Value, interp function.InterpreterAPI) controlflow.ExecResult {
+	if len(args) != 1 {
+		return controlflow.ExecResult{Err: fmt.Errorf("lock() expects 1 argument, got %d", len(args))}
+	}
+	m, err := mutexArg("lock", args[0])
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	if err := m.Lock(interp.(*Interpreter).interrupt()); err != nil {
+		return controlflow.ExecResult{Err: interp.(*Interpreter).interrupted(err)}
+	}
+	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+}
+
+// builtinUnlock frees the mutex, failing if it is not locked.
+func builtinUnlock(args []value.Value, _ function.InterpreterAPI) controlflow.ExecResult {
+	if len(args) != 1 {
+		return controlflow.ExecResult{Err: fmt.Errorf("unlock() expects 1 argument, got %d", len(args))}
+	}
+	m, err := mutexArg("unlock", args[0])
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	if err := m.Unlock(); err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+}
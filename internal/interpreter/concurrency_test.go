@@ -0,0 +1,161 @@
+package interpreter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+)
+
+func TestInterpreter_SpawnWorkersOverChannel(t *testing.T) {
+	interp := runSource(t, `
+total := 0
+func worker(id: int, out: chan[int]) {
+	for k in 0..100 {
+		send(out, id)
+	}
+}
+func bump() {
+	for k in 0..50 {
+		total += 1
+	}
+}
+results := chan[int](4)
+w1 := spawn worker(1, results)
+w2 := spawn worker(2, results)
+w3 := spawn worker(3, results)
+sum := 0
+for k in 0..300 {
+	sum += recv(results)
+}
+wait(w1, w2, w3)
+close(results)
+drained := recv(results)
+b := spawn bump()
+join(b)
+`)
+	expectInt(t, interp, "sum", 600)
+	expectInt(t, interp, "total", 50)
+	drained, _ := interp.GetEnv().GetVar("drained")
+	if !drained.IsNull() {
+		t.Errorf("expected recv on closed channel to return null, got %v", drained)
+	}
+}
+
+func TestInterpreter_SpawnSharedCounter(t *testing.T) {
+	interp := runSource(t, `
+counter := 0
+guarded := 0
+m := mutex()
+func bump() {
+	for k in 0..2000 {
+		counter += 1
+		lock(m)
+		guarded = guarded + 1
+		unlock(m)
+	}
+}
+tasks := [spawn bump(), spawn bump(), spawn bump(), spawn bump()]
+for task in tasks {
+	join(task)
+}
+`)
+	expectInt(t, interp, "counter", 8000)
+	expectInt(t, interp, "guarded", 8000)
+}
+
+func TestInterpreter_UnlockUnlockedMutex(t *testing.T) {
+	err := runSourceErr(t, `
+m := mutex()
+unlock(m)
+`)
+	if err == nil || !strings.Contains(err.Error(), "unlock of unlocked mutex") {
+		t.Fatalf("expected unlock of unlocked mutex, got %v", err)
+	}
+}
+
+func TestInterpreter_JoinReturnsResult(t *testing.T) {
+	interp := runSource(t, `
+func square(n: int) -> int {
+	return n * n
+}
+tasks := [spawn square(3), spawn square(4)]
+a := join(tasks[0])
+b := join(tasks[1])
+`)
+	expectInt(t, interp, "a", 9)
+	expectInt(t, interp, "b", 16)
+}
+
+func TestInterpreter_Select(t *testing.T) {
+	interp := runSource(t, `
+data := chan[int](1)
+quit := chan[int]()
+picked := 0
+select {
+case v := recv(data):
+	picked = v
+default:
+	picked = -1
+}
+send(data, 42)
+select {
+case v := recv(quit):
+	picked = 0
+case v := recv(data):
+	picked = v
+}
+sent := 0
+select {
+case send(data, 7):
+	sent = recv(data)
+}
+`)
+	expectInt(t, interp, "picked", 42)
+	expectInt(t, interp, "sent", 7)
+}
+
+func TestInterpreter_ConcurrencyErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"wrong element type", `c := chan[int](1)
+send(c, "x")`, "cannot send string value on chan[int]"},
+		{"send on closed", `c := chan[int](1)
+close(c)
+send(c, 1)`, "send on closed channel"},
+		{"double close", `c := chan[int]()
+close(c)
+close(c)`, "close of closed channel"},
+		{"failed task", `func boom() -> int {
+	return 1 / 0
+}
+join(spawn boom())`, "division by zero"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := runSourceErr(t, tc.source)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestParser_SpawnAndSelectErrors(t *testing.T) {
+	cases := map[string]string{
+		"spawn 1 + 2":                        "must be function call",
+		"select {\ncase x := send(c, 1):\n}": "cannot be assigned",
+		"select {\ncase len(c):\n}":          "must be recv or send",
+		"select {\ndefault:\ndefault:\n}":    "multiple defaults",
+	}
+	for src, want := range cases {
+		_, err := parser.New(lexer.New(src)).Parse()
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected error containing %q, got %v", src, want, err)
+		}
+	}
+}
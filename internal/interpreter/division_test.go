@@ -0,0 +1,18 @@
+package interpreter_test
+
+import "testing"
+
+func TestInterpreter_IntegerDivision(t *testing.T) {
+	interp := runSource(t, `
+q := 7 / 2
+negQ := -7 / 2
+r := 7 % 3
+negR := -7 % 3
+divR := 7 % -3
+`)
+	expectInt(t, interp, "q", 3)
+	expectInt(t, interp, "negQ", -3)
+	expectInt(t, interp, "r", 1)
+	expectInt(t, interp, "negR", -1)
+	expectInt(t, interp, "divR", 1)
+}
@@ -0,0 +1,40 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// runtimeErrorf returns a runtime error with a specific code. It has no
+// position yet; Evaluate or Execute gives it the one of the failing node.
+func runtimeErrorf(code niferrors.Code, format string, args ...any) error {
+	return &niferrors.NifError{Kind: niferrors.RuntimeError, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// runtimeError converts err to a runtime NifError located at node, unless it
+// is one that already has a position. An error that wraps a NifError keeps
+// its message but takes the wrapped error's code, position and traceback.
+func runtimeError(err error, node ast.Node) error {
+	if nifErr, ok := err.(*niferrors.NifError); ok {
+		if nifErr.Line == 0 {
+			nifErr.Between(ast.Bounds(node))
+		}
+		return nifErr
+	}
+	wrapped := &niferrors.NifError{Kind: niferrors.RuntimeError, Code: niferrors.CodeRuntime, Message: err.Error(), Err: err}
+	wrapped.Between(ast.Bounds(node))
+	var inner *niferrors.NifError
+	if errors.As(err, &inner) {
+		wrapped.Kind, wrapped.Code = inner.Kind, inner.Code
+		if inner.Line > 0 {
+			wrapped.Line, wrapped.Column = inner.Line, inner.Column
+			wrapped.EndLine, wrapped.EndColumn = inner.EndLine, inner.EndColumn
+			wrapped.Token = inner.Token
+		}
+		wrapped.Trace, wrapped.TraceElided = inner.Trace, inner.TraceElided
+	}
+	return wrapped
+}
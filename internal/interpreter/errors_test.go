@@ -0,0 +1,162 @@
+package interpreter_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+func TestErrors_AreNifErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		source string
+		kind   niferrors.ErrorKind
+		code   niferrors.Code
+		span   [4]int
+	}{
+		{"lexer", "x := 1 $ 2", niferrors.LexError, niferrors.CodeUnexpectedChar, [4]int{1, 8, 1, 9}},
+		{"unterminated string", `s := "abc`, niferrors.LexError, niferrors.CodeUnterminatedString, [4]int{1, 6, 1, 10}},
+		{"parser", "x := 1\ny := )", niferrors.ParseError, niferrors.CodeUnexpectedToken, [4]int{2, 6, 2, 7}},
+		{"division by zero", "x := 0\ny := 4 / x", niferrors.RuntimeError, niferrors.CodeDivisionByZero, [4]int{2, 6, 2, 11}},
+		{"remainder by zero", "x := 0\ny := 4 % x", niferrors.RuntimeError, niferrors.CodeDivisionByZero, [4]int{2, 6, 2, 11}},
+		{"index", "l := [1]\nprint(l[3])", niferrors.RuntimeError, niferrors.CodeIndexOutOfRange, [4]int{2, 7, 2, 11}},
+		{"inside a function", `func f(d: int) -> int {
+	return 1 / d
+}
+f(0)`, niferrors.RuntimeError, niferrors.CodeDivisionByZero, [4]int{2, 9, 2, 14}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := runSourceErr(t, tc.source)
+			var nifErr *niferrors.NifError
+			if !errors.As(err, &nifErr) {
+				t.Fatalf("expected a NifError, got %T: %v", err, err)
+			}
+			if nifErr.Kind != tc.kind || nifErr.Code != tc.code {
+				t.Errorf("expected %v %s, got %v %s: %v", tc.kind, tc.code, nifErr.Kind, nifErr.Code, err)
+			}
+			if span := [4]int{nifErr.Line, nifErr.Column, nifErr.EndLine, nifErr.EndColumn}; span != tc.span {
+				t.Errorf("expected span %v, got %v", tc.span, span)
+			}
+		})
+	}
+}
+
+func TestErrors_Incomplete(t *testing.T) {
+	err := runSourceErr(t, "x := (1 +\n2")
+	if !errors.Is(err, parser.ErrIncomplete) {
+		t.Errorf("expected an unclosed parenthesis to be incomplete, got %v", err)
+	}
+	var nifErr *niferrors.NifError
+	if !errors.As(err, &nifErr) || nifErr.Code != niferrors.CodeExpectedToken {
+		t.Errorf("expected an expected-token error, got %v", err)
+	}
+}
+
+const traceSource = `func shut(c: chan[int]) {
+	close(c)
+}
+func twice(c: chan[int]) {
+	shut(c)
+	shut(c)
+}
+c := chan[int](1)
+twice(c)`
+
+// traceOf runs source with the given trace depth and describes the frames of
+// the traceback of the error it stops with.
+func traceOf(t *testing.T, source string, depth int) (string, *niferrors.NifError) {
+	t.Helper()
+	value.BuiltinTypesInit()
+	interp := interpreter.NewInterpreter()
+	interp.TraceDepth = depth
+	stmts, err := parser.New(lexer.New(source)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range stmts {
+		if res := interp.Execute(stmt); res.Err != nil {
+			nifErr := niferrors.As(res.Err)
+			var frames []string
+			for _, f := range nifErr.Trace {
+				frames = append(frames, fmt.Sprintf("%s@%d:%d/%d:%d/%v", f.Function, f.Line, f.Column, f.DefLine, f.DefColumn, f.Native))
+			}
+			return strings.Join(frames, " "), nifErr
+		}
+	}
+	t.Fatal("expected a runtime error")
+	return "", nil
+}
+
+func TestErrors_Traceback(t *testing.T) {
+	got, nifErr := traceOf(t, traceSource, interpreter.DefaultTraceDepth)
+	want := "close@2:2/0:0/true shut@6:2/1:1/false twice@9:1/4:1/false"
+	if got != want {
+		t.Errorf("expected frames %q, got %q", want, got)
+	}
+	if nifErr.Line != 2 || nifErr.TraceElided != 0 {
+		t.Errorf("expected the error on line 2 with nothing elided, got %d and %d", nifErr.Line, nifErr.TraceElided)
+	}
+
+	got, nifErr = traceOf(t, traceSource, 1)
+	if got != "close@2:2/0:0/true" || nifErr.TraceElided != 2 {
+		t.Errorf("expected one frame and two elided, got %q and %d", got, nifErr.TraceElided)
+	}
+	if got, _ := traceOf(t, traceSource, 0); got != "" {
+		t.Errorf("expected no traceback at depth 0, got %q", got)
+	}
+}
+
+func TestErrors_TracebackThroughClosuresAndTasks(t *testing.T) {
+	cases := []struct {
+		name, source, want string
+	}{
+		{"closure", `f := func(d: int) {
+	print(1 / d)
+}
+f(0)`, "<anonymous>@4:1/1:6/false"},
+		{"top level", "x := 0\ny := 1 / x", ""},
+		{"task", `func work(d: int) -> int {
+	return 1 / d
+}
+t := spawn work(0)
+x := wait(t)`, "work@4:12/1:1/false"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, _ := traceOf(t, tc.source, interpreter.DefaultTraceDepth); got != tc.want {
+				t.Errorf("expected frames %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestErrors_StepLimit(t *testing.T) {
+	value.BuiltinTypesInit()
+	stmts, err := parser.New(lexer.New("n := 0\nwhile true {\n\tn += 1\n}")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	interp := interpreter.NewInterpreterWithOptions(interpreter.Options{MaxSteps: 100})
+	var runErr error
+	for _, stmt := range stmts {
+		if result := interp.Execute(stmt); result.Err != nil {
+			runErr = result.Err
+			break
+		}
+	}
+	if !errors.Is(runErr, interpreter.ErrStepLimit) {
+		t.Fatalf("expected the step limit to stop the loop, got %v", runErr)
+	}
+	n, _ := interp.GetEnv().GetVar("n")
+	if got := n.Data.(float64); got < 1 || got > 100 {
+		t.Errorf("expected the loop to stop within 100 steps, got n = %v", got)
+	}
+}
@@ -0,0 +1,51 @@
+package interpreter_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+)
+
+// blocking matches programs that could wait forever on a channel or task,
+// which no step budget can stop.
+var blocking = regexp.MustCompile(`\b(spawn|select|send|recv|join|wait|chan)\b`)
+
+// FuzzEval checks that every program that checks runs to completion without
+// a panic, within a budget of steps.
+func FuzzEval(f *testing.F) {
+	paths, _ := filepath.Glob("../../cmd/niftel/testdata/*.nif")
+	for _, path := range paths {
+		if data, err := os.ReadFile(path); err == nil {
+			f.Add(string(data))
+		}
+	}
+	f.Add("func f(n: int) -> int {\n\treturn f(n + 1)\n}\nf(0)")
+	f.Add("while true {\n}")
+	f.Fuzz(func(t *testing.T, src string) {
+		if blocking.MatchString(src) {
+			t.Skip("may block")
+		}
+		stmts, err := parser.New(lexer.New(src)).Parse()
+		if err != nil {
+			return
+		}
+		value.BuiltinTypesInit()
+		interp := interpreter.NewInterpreterWithOptions(interpreter.Options{MaxSteps: 10_000, MaxCallDepth: 200})
+		if errs, _ := interp.Check(stmts); len(errs) > 0 {
+			return
+		}
+		interp.Out = io.Discard
+		for _, stmt := range stmts {
+			if result := interp.Execute(stmt); result.Err != nil {
+				return
+			}
+		}
+	})
+}
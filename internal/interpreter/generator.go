@@ -0,0 +1,83 @@
+package interpreter
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/controlflow"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/environment"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/function"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// newUserFunc builds the runtime function for a declaration or literal.
+// Its source position is where funcTok starts.
+func newUserFunc(name string, params []ast.Param, body *ast.BlockStmt, isGenerator bool, env *environment.Environment, funcTok token.Token) *function.Function {
+	line, col, _, _ := niferrors.Span(funcTok)
+	if isGenerator {
+		return function.NewGeneratorFunc(name, params, body, env, line, col)
+	}
+	return function.NewUserFunc(name, params, body, env, line, col)
+}
+
+// StartGenerator returns a generator that runs body in env on its own
+// interpreter frame, suspending at each yield until the next value is asked for.
+func (i *Interpreter) StartGenerator(name string, body *ast.BlockStmt, env *environment.Environment) controlflow.ExecResult {
+	child := i.fork()
+	seq := func(yield func(value.Value, error) bool) {
+		child.yield = func(v value.Value) bool { return yield(v, nil) }
+		if result := child.ExecuteBlock(body, env); result.Err != nil {
+			yield(value.Null(), child.traceback(result.Err))
+		}
+	}
+	gen := value.NewNiftelGenerator(name, seq)
+	return controlflow.ExecResult{Value: value.Value{Type: value.ValueGenerator, Data: gen}, Flow: controlflow.FlowNone}
+}
+
+// VisitYieldStmt suspends the generator until its consumer resumes it. If the
+// consumer has stopped, the body unwinds as though it had returned.
+func (i *Interpreter) VisitYieldStmt(stmt *ast.YieldStmt) controlflow.ExecResult {
+	if i.yield == nil {
+		return controlflow.ExecResult{Err: fmt.Errorf("yield outside generator at line %d", stmt.Keyword.Line)}
+	}
+	valRes := i.Evaluate(stmt.Value)
+	if valRes.Err != nil {
+		return controlflow.ExecResult{Err: valRes.Err}
+	}
+	if !i.yield(valRes.Value) {
+		return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowReturn}
+	}
+	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+}
+
+// methodTable holds the methods declared on each struct type. It is shared by
+// every task forked from the same interpreter.
+type methodTable struct {
+	mu     sync.RWMutex
+	byType map[*symtable.TypeSymbol]map[string]value.Value
+}
+
+func newMethodTable() *methodTable {
+	return &methodTable{byType: make(map[*symtable.TypeSymbol]map[string]value.Value)}
+}
+
+func (m *methodTable) set(typ *symtable.TypeSymbol, methods map[string]value.Value) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.byType[typ] = methods
+}
+
+// get returns the methods of typ, looking through generic instantiations to
+// the struct they were made from.
+func (m *methodTable) get(typ *symtable.TypeSymbol) map[string]value.Value {
+	if typ.Origin != nil {
+		typ = typ.Origin
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.byType[typ]
+}
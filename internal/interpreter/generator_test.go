@@ -0,0 +1,180 @@
+package interpreter_test
+
+import (
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+)
+
+func TestInterpreter_GeneratorForIn(t *testing.T) {
+	interp := runSource(t, `
+func countdown(n: int) {
+	while n > 0 {
+		yield n
+		n = n - 1
+	}
+}
+sum := 0
+steps := 0
+for x in countdown(4) {
+	sum += x
+	steps += 1
+}
+`)
+	expectInt(t, interp, "sum", 10)
+	expectInt(t, interp, "steps", 4)
+}
+
+func TestInterpreter_GeneratorNext(t *testing.T) {
+	interp := runSource(t, `
+func pair() {
+	yield 1
+	yield 2
+}
+g := pair()
+a := next(g)
+b := next(g)
+c := next(g, -1)
+partial := 0
+h := pair()
+for x in h {
+	partial = x
+	break
+}
+rest := next(h)
+`)
+	expectInt(t, interp, "a", 1)
+	expectInt(t, interp, "b", 2)
+	expectInt(t, interp, "c", -1)
+	expectInt(t, interp, "partial", 1)
+	expectInt(t, interp, "rest", 2)
+}
+
+func TestInterpreter_StructIterMethod(t *testing.T) {
+	interp := runSource(t, `
+struct Span {
+	lo: int
+	hi: int
+	func iter() {
+		i := self.lo
+		while i < self.hi {
+			yield i
+			i += 1
+		}
+	}
+}
+s := Span{lo: 3, hi: 6}
+total := 0
+for v in s {
+	total += v
+}
+n := len(list(s))
+`)
+	expectInt(t, interp, "total", 12)
+	expectInt(t, interp, "n", 3)
+}
+
+func TestInterpreter_InfiniteGeneratorBreakDoesNotLeak(t *testing.T) {
+	// With the collector off, no finalizer can stop a generator: the loops
+	// must do it themselves.
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	before := runtime.NumGoroutine()
+	interp := runSource(t, `
+func naturals() {
+	n := 0
+	while true {
+		yield n
+		n += 1
+	}
+}
+struct Forever {
+	func iter() {
+		n := 0
+		while true {
+			yield n
+			n += 1
+		}
+	}
+}
+func firstOver(limit: int) -> int {
+	for v in naturals() {
+		if v > limit {
+			return v
+		}
+	}
+	return -1
+}
+forever := Forever{}
+last := 0
+found := 0
+for round in 0..50 {
+	for v in naturals() {
+		if v == 5 {
+			break
+		}
+		last = v
+	}
+	for v in forever {
+		break
+	}
+	over := firstOver(round)
+	if 3 in naturals() {
+		found += 1
+	}
+}
+g := naturals()
+first := next(g)
+close(g)
+after := next(g, -1)
+`)
+	expectInt(t, interp, "last", 4)
+	expectInt(t, interp, "found", 50)
+	expectInt(t, interp, "first", 0)
+	expectInt(t, interp, "after", -1)
+
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("generator goroutines leaked: %d before, %d after", before, n)
+	}
+}
+
+func TestInterpreter_GeneratorErrors(t *testing.T) {
+	cases := []struct {
+		source string
+		want   string
+	}{
+		{`func one() {
+	yield 1
+}
+g := one()
+next(g)
+next(g)`, "is exhausted"},
+		{`func bad() {
+	yield 1
+	yield 1 / 0
+}
+for x in bad() {
+	print(x)
+}`, "division by zero"},
+		{`for x in 5 {
+	print(x)
+}`, "not iterable"},
+	}
+	for _, tc := range cases {
+		err := runSourceTo(t, tc.source, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("expected error containing %q, got %v", tc.want, err)
+		}
+	}
+}
+
+func TestParser_YieldOutsideFunction(t *testing.T) {
+	_, err := parser.New(lexer.New("yield 1")).Parse()
+	if err == nil || !strings.Contains(err.Error(), "yield outside function") {
+		t.Errorf("expected yield outside function error, got %v", err)
+	}
+}
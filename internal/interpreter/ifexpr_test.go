@@ -0,0 +1,38 @@
+package interpreter_test
+
+import "testing"
+
+func TestInterpreter_IfExpression(t *testing.T) {
+	interp := runSource(t, `
+n := 7
+parity := if n & 1 == 0 { 0 } else { 1 }
+size := if n < 5 {
+	1
+} else if n < 10 {
+	tmp := n * 2
+	tmp
+} else {
+	3
+}
+func pick(flag: bool) -> int {
+	return if flag { 10 } else { 20 }
+}
+picked := pick(false)
+`)
+	expectInt(t, interp, "parity", 1)
+	expectInt(t, interp, "size", 14)
+	expectInt(t, interp, "picked", 20)
+}
+
+func TestInterpreter_TernaryExpression(t *testing.T) {
+	interp := runSource(t, `
+a := 3
+b := 8
+max := a > b ? a : b
+sign := a < 0 ? -1 : a == 0 ? 0 : 1
+nested := (a > 1 ? 100 : 200) + 1
+`)
+	expectInt(t, interp, "max", 8)
+	expectInt(t, interp, "sign", 1)
+	expectInt(t, interp, "nested", 101)
+}
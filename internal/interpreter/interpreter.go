@@ -3,61 +3,133 @@ package interpreter
 import (
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
 
 	"github.com/ithinkiborkedit/niftelv2.git/internal/controlflow"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/environment"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/flow"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/function"
 	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
 	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/resolver"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/trace"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/typechecker"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/typeenv"
 	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
 )
 
+var log = trace.Logger(trace.Interp)
+
 // Interpreter interprets and executes Niftel code.
 type Interpreter struct {
 	env                *environment.Environment
 	envStack           []*environment.Environment
 	ShouldPrintResults bool
 	typEnv             *typeenv.TypeEnv
+	methods            *methodTable
+	checker            *typechecker.Checker
+	// yield hands a value to the consumer of the generator this interpreter
+	// is running, if any. It reports false once the consumer has stopped.
+	yield func(value.Value) bool
+	// frames holds the calls in progress, outermost first.
+	frames []frame
+	// TraceDepth caps the calls shown in the traceback of a runtime error;
+	// zero leaves tracebacks out.
+	TraceDepth int
+	// Out receives what the program prints.
+	Out io.Writer
+	// opts limits the program, and usage is what it has used of the limits
+	// so far, which spawned tasks share.
+	opts  Options
+	usage *usage
+	// Hook, if set, is called with the interpreter about to run it before
+	// each statement other than a block, which is how a debugger follows the
+	// program. An error from it stops the program. Spawned tasks inherit it.
+	Hook func(interp *Interpreter, stmt ast.Stmt) error
+	// CallHook, if set, is called with the interpreter making it as each
+	// call starts, and again with returned set once it has returned, which
+	// is how a profiler times calls. Spawned tasks inherit it.
+	CallHook func(interp *Interpreter, fn function.Callable, returned bool)
+	// running is set while an Execute or Evaluate from outside is in
+	// progress, so that only the outermost one recovers from a panic.
+	running bool
 	// Add flags, call stacks, etc. here as needed
 }
 
 // NewInterpreter returns a fresh Interpreter with a global environment.
 func NewInterpreter() *Interpreter {
 	interp := &Interpreter{
-		env:    environment.NewEnvironment(nil),
-		typEnv: typeenv.NewTypeEnv(nil),
+		env:        environment.NewEnvironment(nil),
+		typEnv:     typeenv.NewTypeEnv(nil),
+		methods:    newMethodTable(),
+		checker:    typechecker.NewChecker(),
+		TraceDepth: DefaultTraceDepth,
+		Out:        os.Stdout,
+		usage:      new(usage),
 	}
 	if err := interp.RegisterBuiltInTypes(); err != nil {
 		panic(fmt.Sprintf("Interpreter failed to register builtin types: %v", err))
 	}
+	if err := interp.RegisterBuiltInFuncs(); err != nil {
+		panic(fmt.Sprintf("Interpreter failed to register builtin functions: %v", err))
+	}
 
 	return interp
 }
 
+// Check resolves the local variables of stmts to slots, type-checks them and
+// analyzes their control flow before they are executed. Declarations from
+// statements that check cleanly are remembered for later calls. Warnings do
+// not stop stmts from running.
+func (i *Interpreter) Check(stmts []ast.Stmt) (errs, warnings []error) {
+	if errs := resolver.Resolve(stmts); len(errs) > 0 {
+		return errs, nil
+	}
+	errs = i.checker.Check(stmts)
+	flowErrs, warnings := flow.Analyze(stmts)
+	return append(errs, flowErrs...), warnings
+}
+
 func (i *Interpreter) Eval(expr ast.Expr) controlflow.ExecResult {
 	return i.Evaluate(expr)
 }
 
 func (i *Interpreter) RegisterBuiltInTypes() error {
-	for _, typ := range value.BuiltInTypes {
+	for _, typ := range value.BuiltinTypeList() {
 		if err := i.env.DefineType(typ); err != nil {
 			// fmt.Printf("SKIP DEBUG TYPE %q already defined", name)
 			continue
 		}
 	}
 
-	fmt.Println("DEBUG ENV TYPES after registration:[")
-	for name := range i.env.SymbolTable().Types {
-		fmt.Printf("%q ", name)
+	if trace.On(trace.Interp, slog.LevelDebug) {
+		log.Debug("registered builtin types", "types", slices.Sorted(maps.Keys(i.env.SymbolTable().Types)))
 	}
-	fmt.Println("]")
 	return nil
 }
 
-// Evaluate dispatches to the correct Expr handler.
-func (i *Interpreter) Evaluate(expr ast.Expr) controlflow.ExecResult {
+// Evaluate dispatches to the correct Expr handler. Errors come back as
+// runtime NifErrors located at the innermost expression that failed.
+func (i *Interpreter) Evaluate(expr ast.Expr) (result controlflow.ExecResult) {
+	if !i.running {
+		i.running = true
+		defer i.recoverAt(&result, expr)
+	}
+	result = i.evaluate(expr)
+	if result.Err != nil {
+		result.Err = runtimeError(result.Err, expr)
+	}
+	return result
+}
+
+func (i *Interpreter) evaluate(expr ast.Expr) controlflow.ExecResult {
 	switch e := expr.(type) {
 	case *ast.LiteralExpr:
 		return i.VisitLiteralExpr(e)
@@ -71,6 +143,16 @@ func (i *Interpreter) Evaluate(expr ast.Expr) controlflow.ExecResult {
 		return i.VisitCallExpr(e)
 	case *ast.GetExpr:
 		return i.VisitGetExpr(e)
+	case *ast.IndexExpr:
+		return i.VisitIndexExpr(e)
+	case *ast.SliceExpr:
+		return i.VisitSliceExpr(e)
+	case *ast.RangeExpr:
+		return i.VisitRangeExpr(e)
+	case *ast.IfExpr:
+		return i.VisitIfExpr(e)
+	case *ast.TernaryExpr:
+		return i.VisitTernaryExpr(e)
 	case *ast.ListExpr:
 		return i.VisitListExpr(e)
 	case *ast.DictExpr:
@@ -79,13 +161,36 @@ func (i *Interpreter) Evaluate(expr ast.Expr) controlflow.ExecResult {
 		return i.VisitStructLiteralExpr(e)
 	case *ast.FuncExpr:
 		return i.VisitFuncExpr(e)
+	case *ast.SpawnExpr:
+		return i.VisitSpawnExpr(e)
 	default:
 		return controlflow.ExecResult{Err: fmt.Errorf("unknown expression type %T", expr)}
 	}
 }
 
-// Execute dispatches to the correct Stmt handler.
-func (i *Interpreter) Execute(stmt ast.Stmt) controlflow.ExecResult {
+// Execute dispatches to the correct Stmt handler. Errors not located by an
+// expression are located at the statement.
+func (i *Interpreter) Execute(stmt ast.Stmt) (result controlflow.ExecResult) {
+	if !i.running {
+		i.running = true
+		defer i.recoverAt(&result, stmt)
+	}
+	result = i.execute(stmt)
+	if result.Err != nil {
+		result.Err = runtimeError(result.Err, stmt)
+	}
+	return result
+}
+
+func (i *Interpreter) execute(stmt ast.Stmt) controlflow.ExecResult {
+	if err := i.step(); err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	if _, isBlock := stmt.(*ast.BlockStmt); i.Hook != nil && !isBlock {
+		if err := i.Hook(i, stmt); err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+	}
 	switch s := stmt.(type) {
 	case *ast.VarStmt:
 		return i.VisitVarStmt(s)
@@ -93,6 +198,8 @@ func (i *Interpreter) Execute(stmt ast.Stmt) controlflow.ExecResult {
 		return i.VisitShortVarStmt(s)
 	case *ast.AssignStmt:
 		return i.VisitAssignStmt(s)
+	case *ast.CompoundAssignStmt:
+		return i.VisitCompoundAssignStmt(s)
 	case *ast.PrintStmt:
 		return i.VisitPrintStmt(s)
 	case *ast.ExprStmt:
@@ -105,6 +212,16 @@ func (i *Interpreter) Execute(stmt ast.Stmt) controlflow.ExecResult {
 		return i.VisitWhileStmt(s)
 	case *ast.ForStmt:
 		return i.VisitForStmt(s)
+	case *ast.ForInStmt:
+		return i.VisitForInStmt(s)
+	case *ast.SwitchStmt:
+		return i.VisitSwitchStmt(s)
+	case *ast.FallthroughStmt:
+		return i.VisitFallthroughStmt(s)
+	case *ast.SelectStmt:
+		return i.VisitSelectStmt(s)
+	case *ast.YieldStmt:
+		return i.VisitYieldStmt(s)
 	case *ast.FuncStmt:
 		return i.VisitFuncStmt(s)
 	case *ast.ReturnStmt:
@@ -159,15 +276,16 @@ func (i *Interpreter) VisitStructLiteralExpr(expr *ast.StructLiteralExpr) contro
 		return controlflow.ExecResult{Value: value.Null(), Err: fmt.Errorf("struct type '%s' not found %v", expr.TypeName.Name.Lexeme, err)}
 	}
 
+	// Fields print in the order the struct declares them.
 	var orderedFields []token.Token
-
-	for fname := range typeSym.Fields {
+	for _, fname := range typeSym.FieldOrder {
 		orderedFields = append(orderedFields, token.Token{Lexeme: fname})
 	}
 
 	structType := &value.StructType{
-		Name:   typeSym.SymName,
-		Fields: orderedFields,
+		Name:    typeSym.SymName,
+		Fields:  orderedFields,
+		Methods: i.methods.get(typeSym),
 	}
 	// for fname := range typeInfo.Fields {
 	// 	structType.Fields = append(structType.Fields, token.Token{Lexeme: fname})
@@ -232,6 +350,8 @@ func (i *Interpreter) VisitLiteralExpr(expr *ast.LiteralExpr) controlflow.ExecRe
 			return controlflow.ExecResult{Value: value.Value{Type: value.ValueBool, Data: false}, Flow: controlflow.FlowNone}
 		}
 		return controlflow.ExecResult{Err: errors.New("invalid bool literal token")}
+	case token.TokenTrue, token.TokenFalse:
+		return controlflow.ExecResult{Value: value.Value{Type: value.ValueBool, Data: tok.Type == token.TokenTrue}, Flow: controlflow.FlowNone}
 	case token.TokenNull:
 		return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
 	default:
@@ -240,13 +360,38 @@ func (i *Interpreter) VisitLiteralExpr(expr *ast.LiteralExpr) controlflow.ExecRe
 }
 
 func (i *Interpreter) VisitVariableExpr(expr *ast.VariableExpr) controlflow.ExecResult {
-	val, err := i.env.GetVar(expr.Name.Lexeme)
+	val, err := i.getVar(expr.Name, expr.Binding)
 	if err != nil {
 		return controlflow.ExecResult{Err: fmt.Errorf("undefined variable %s", expr.Name.Lexeme)}
 	}
 	return controlflow.ExecResult{Value: val, Flow: controlflow.FlowNone}
 }
 
+// getVar reads a variable through the slot the resolver gave it, or by name
+// if it was not resolved.
+func (i *Interpreter) getVar(name token.Token, binding ast.Binding) (value.Value, error) {
+	if binding.Resolved {
+		return i.env.GetAt(binding.Depth, binding.Slot)
+	}
+	return i.env.GetVar(name.Lexeme)
+}
+
+func (i *Interpreter) assignVar(name token.Token, binding ast.Binding, val value.Value) error {
+	if binding.Resolved {
+		return i.env.AssignAt(binding.Depth, binding.Slot, val)
+	}
+	return i.env.AssignVar(name.Lexeme, val)
+}
+
+// updateVar replaces the value of a variable with what update makes of it,
+// as one step that no other task can interleave with.
+func (i *Interpreter) updateVar(name token.Token, binding ast.Binding, update func(value.Value) (value.Value, error)) error {
+	if binding.Resolved {
+		return i.env.UpdateAt(binding.Depth, binding.Slot, update)
+	}
+	return i.env.UpdateVar(name.Lexeme, update)
+}
+
 func (i *Interpreter) VisitBinaryExpr(expr *ast.BinaryExpr) controlflow.ExecResult {
 	leftRes := i.Evaluate(expr.Left)
 	if leftRes.Err != nil {
@@ -258,7 +403,28 @@ func (i *Interpreter) VisitBinaryExpr(expr *ast.BinaryExpr) controlflow.ExecResu
 		return controlflow.ExecResult{Err: rightRes.Err}
 	}
 	right := rightRes.Value
-	switch expr.Operator.Type {
+	if gen, ok := temporaryGenerator(expr.Right, right); ok && expr.Operator.Type == token.TokenIn {
+		defer gen.Stop()
+	}
+	return i.binary(expr.Operator, left, right)
+}
+
+// binary applies a binary operator, counting the string a concatenation
+// builds against the memory limit.
+func (i *Interpreter) binary(operator token.Token, left, right value.Value) controlflow.ExecResult {
+	if operator.Type == token.TokenPlus && left.Type == value.ValueString && right.Type == value.ValueString {
+		l, _ := left.Data.(string)
+		r, _ := right.Data.(string)
+		if err := i.alloc(int64(len(l) + len(r))); err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+	}
+	return applyBinary(operator, left, right)
+}
+
+// applyBinary applies a binary operator to two evaluated operands.
+func applyBinary(operator token.Token, left, right value.Value) controlflow.ExecResult {
+	switch operator.Type {
 	case token.TokenPlus:
 		if left.Type == value.ValueInt && right.Type == value.ValueInt {
 			return controlflow.ExecResult{Value: value.Value{
@@ -285,16 +451,8 @@ func (i *Interpreter) VisitBinaryExpr(expr *ast.BinaryExpr) controlflow.ExecResu
 				Flow: controlflow.FlowNone}
 		}
 		return controlflow.ExecResult{Err: fmt.Errorf("unsupported operand types for *: %v and %v", left.Type, right.Type)}
-	case token.TokenFWDSlash:
-		if left.Type == value.ValueInt && right.Type == value.ValueInt {
-			if right.Data.(float64) == 0 {
-				return controlflow.ExecResult{Err: fmt.Errorf("division by zero")}
-			}
-			return controlflow.ExecResult{Value: value.Value{
-				Type: value.ValueInt, Data: left.Data.(float64) / right.Data.(float64)},
-				Flow: controlflow.FlowNone}
-		}
-		return controlflow.ExecResult{Err: fmt.Errorf("unsupported operand types for /: %v and %v", left.Type, right.Type)}
+	case token.TokenFWDSlash, token.TokenPercent:
+		return applyDivision(operator, left, right)
 	case token.TokenGreater:
 		if left.Type == value.ValueInt && right.Type == value.ValueInt {
 			return controlflow.ExecResult{Value: value.Value{
@@ -323,11 +481,64 @@ func (i *Interpreter) VisitBinaryExpr(expr *ast.BinaryExpr) controlflow.ExecResu
 				Flow: controlflow.FlowNone}
 		}
 		return controlflow.ExecResult{Value: value.Value{Type: value.ValueBool, Data: left.Data != right.Data}, Flow: controlflow.FlowNone}
+	case token.TokenIn:
+		found, err := contains(right, left)
+		if err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+		return controlflow.ExecResult{Value: value.Value{Type: value.ValueBool, Data: found}, Flow: controlflow.FlowNone}
+	case token.TokenAmper, token.TokenPipe, token.TokenCaret, token.TokenShl, token.TokenShr:
+		return applyBitwise(operator, left, right)
 	default:
-		return controlflow.ExecResult{Err: fmt.Errorf("unsupported binary operator %v", expr.Operator.Lexeme)}
+		return controlflow.ExecResult{Err: fmt.Errorf("unsupported binary operator %v", operator.Lexeme)}
 	}
 }
 
+// applyDivision implements / and % on ints, as int64: / truncates toward
+// zero and % takes the sign of the dividend, as sdiv and srem do in codegen.
+// Dividing by zero is a runtime error in both backends.
+func applyDivision(operator token.Token, left, right value.Value) controlflow.ExecResult {
+	l, lok := intOf(left)
+	r, rok := intOf(right)
+	if !lok || !rok {
+		return controlflow.ExecResult{Err: fmt.Errorf("unsupported operand types for %s: %v and %v", operator.Lexeme, left.Type, right.Type)}
+	}
+	if r == 0 {
+		return controlflow.ExecResult{Err: runtimeErrorf(niferrors.CodeDivisionByZero, "division by zero")}
+	}
+	result := l / r
+	if operator.Type == token.TokenPercent {
+		result = l % r
+	}
+	return controlflow.ExecResult{Value: value.Value{Type: value.ValueInt, Data: float64(result)}, Flow: controlflow.FlowNone}
+}
+
+// applyBitwise implements &, |, ^, << and >> on ints, as int64. Ints are
+// stored as float64, so operands are exact only up to 2^53 in magnitude, and
+// results beyond that are rounded to the nearest float64. Shift counts are
+// taken modulo 64, as in codegen, so negative and large counts are defined.
+func applyBitwise(operator token.Token, left, right value.Value) controlflow.ExecResult {
+	l, lok := intOf(left)
+	r, rok := intOf(right)
+	if !lok || !rok {
+		return controlflow.ExecResult{Err: fmt.Errorf("unsupported operand types for %s: %v and %v", operator.Lexeme, left.Type, right.Type)}
+	}
+	var result int64
+	switch operator.Type {
+	case token.TokenAmper:
+		result = l & r
+	case token.TokenPipe:
+		result = l | r
+	case token.TokenCaret:
+		result = l ^ r
+	case token.TokenShl:
+		result = l << (r & 63)
+	case token.TokenShr:
+		result = l >> (r & 63)
+	}
+	return controlflow.ExecResult{Value: value.Value{Type: value.ValueInt, Data: float64(result)}, Flow: controlflow.FlowNone}
+}
+
 func (i *Interpreter) VisitUnaryExpr(expr *ast.UnaryExpr) controlflow.ExecResult {
 	rightRes := i.Evaluate(expr.Right)
 	if rightRes.Err != nil {
@@ -351,6 +562,15 @@ func (i *Interpreter) VisitUnaryExpr(expr *ast.UnaryExpr) controlflow.ExecResult
 			Value: value.Value{Type: value.ValueInt, Data: -right.Data.(float64)},
 			Flow:  controlflow.FlowNone,
 		}
+	case token.TokenTilde:
+		n, ok := intOf(right)
+		if !ok {
+			return controlflow.ExecResult{Err: fmt.Errorf("operator ~ requires int operand")}
+		}
+		return controlflow.ExecResult{
+			Value: value.Value{Type: value.ValueInt, Data: float64(^n)},
+			Flow:  controlflow.FlowNone,
+		}
 	default:
 		return controlflow.ExecResult{Err: fmt.Errorf("unsupported unary operator %v", expr.Operator.Lexeme)}
 	}
@@ -384,6 +604,7 @@ func (i *Interpreter) VisitStructStmt(stmt *ast.StructStmt) controlflow.ExecResu
 	}
 
 	fields := make(map[string]*symtable.TypeSymbol)
+	var order []string
 	for _, field := range stmt.Fields {
 		if len(field.Names) != 1 {
 			return controlflow.ExecResult{Err: fmt.Errorf("struct field must have exactly one name!")}
@@ -395,24 +616,32 @@ func (i *Interpreter) VisitStructStmt(stmt *ast.StructStmt) controlflow.ExecResu
 			return controlflow.ExecResult{Err: fmt.Errorf("Uknown type '%s' for struct field '%s'", fieldName, err)}
 		}
 		fields[fieldName] = fieldType
+		order = append(order, fieldName)
 	}
-	methods := make(map[string]*value.FuncInfo)
+	methods := make(map[string]value.Value)
+	methodSyms := make(map[string]*symtable.FuncSymbol)
 	for _, method := range stmt.Methods {
-		methods[method.Name.Lexeme] = &value.FuncInfo{
-			Name: method.Name.Lexeme,
+		if _, exists := methods[method.Name.Lexeme]; exists {
+			return controlflow.ExecResult{Err: fmt.Errorf("method '%s' already defined on struct '%s'", method.Name.Lexeme, structName)}
 		}
+		fn := newUserFunc(method.Name.Lexeme, method.Params, method.Body, method.IsGenerator, i.env, method.Func)
+		methods[method.Name.Lexeme] = value.Value{Type: value.ValueFunc, Data: fn}
+		methodSyms[method.Name.Lexeme] = &symtable.FuncSymbol{SymName: method.Name.Lexeme}
 	}
 
 	structSym := &symtable.TypeSymbol{
-		SymName: structName,
-		SymKind: symtable.SymbolTypes,
-		Fields:  fields,
+		SymName:    structName,
+		SymKind:    symtable.SymbolTypes,
+		Fields:     fields,
+		FieldOrder: order,
+		Methods:    methodSyms,
 	}
 
 	if err := i.env.DefineType(structSym); err != nil {
 		return controlflow.ExecResult{Err: err}
 	}
-	fmt.Printf("[INFO] REGISTERED struct type: '%s'\n", stmt.Name.Lexeme)
+	i.methods.set(structSym, methods)
+	log.Debug("struct type", "name", stmt.Name.Lexeme, "fields", len(fields), "methods", len(methods))
 	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
 }
 
@@ -421,6 +650,20 @@ func (i *Interpreter) VisitVarStmt(stmt *ast.VarStmt) controlflow.ExecResult {
 	if err != nil {
 		return controlflow.ExecResult{Err: fmt.Errorf("var declarations require a type %w", err)}
 	}
+	if stmt.Init == nil {
+		// Declared but unassigned; reading it fails until it is assigned.
+		for _, name := range stmt.Names {
+			if err := i.env.DefineVar(&symtable.VarSymbol{
+				SymName: name.Lexeme,
+				SymKind: symtable.SymbolVar,
+				Type:    varTypeSym,
+				Mutable: true,
+			}); err != nil {
+				return controlflow.ExecResult{Err: err}
+			}
+		}
+		return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+	}
 
 	valRes := i.Evaluate(stmt.Init)
 	if valRes.Err != nil {
@@ -509,7 +752,33 @@ func (i *Interpreter) VisitAssignStmt(stmt *ast.AssignStmt) controlflow.ExecResu
 		return controlflow.ExecResult{Err: valRes.Err}
 	}
 	val := valRes.Value
-	if err := i.env.AssignVar(stmt.Name.Lexeme, val); err != nil {
+	if err := i.assignVar(stmt.Name, stmt.Binding, val); err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+}
+
+// VisitCompoundAssignStmt evaluates the right-hand side, then reads, updates
+// and writes the variable as one step, so tasks that share the variable do
+// not lose each other's updates. A plain assignment reads and writes in
+// separate steps; tasks guard those with a mutex.
+func (i *Interpreter) VisitCompoundAssignStmt(stmt *ast.CompoundAssignStmt) controlflow.ExecResult {
+	opType, ok := token.CompoundOperators[stmt.Operator.Type]
+	if !ok {
+		return controlflow.ExecResult{Err: fmt.Errorf("unsupported compound assignment %s", stmt.Operator.Lexeme)}
+	}
+	valRes := i.Evaluate(stmt.Value)
+	if valRes.Err != nil {
+		return controlflow.ExecResult{Err: valRes.Err}
+	}
+	operator := stmt.Operator
+	operator.Type = opType
+	operator.Lexeme = strings.TrimSuffix(stmt.Operator.Lexeme, "=")
+	err := i.updateVar(stmt.Name, stmt.Binding, func(current value.Value) (value.Value, error) {
+		result := i.binary(operator, current, valRes.Value)
+		return result.Value, result.Err
+	})
+	if err != nil {
 		return controlflow.ExecResult{Err: err}
 	}
 	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
@@ -521,7 +790,7 @@ func (i *Interpreter) VisitPrintStmt(stmt *ast.PrintStmt) controlflow.ExecResult
 		return controlflow.ExecResult{Err: valRes.Err}
 	}
 	val := valRes.Value
-	fmt.Println(val.String())
+	fmt.Fprintln(i.Out, val.String())
 	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
 }
 
@@ -532,7 +801,7 @@ func (i *Interpreter) VisitExprStmt(stmt *ast.ExprStmt) controlflow.ExecResult {
 	}
 	result := resultRes.Value
 	if i.ShouldPrintResults && !result.IsNull() {
-		fmt.Println(result.String())
+		fmt.Fprintln(i.Out, result.String())
 	}
 
 	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
@@ -544,7 +813,7 @@ func (i *Interpreter) VisitIfStmt(stmt *ast.IfStmt) controlflow.ExecResult {
 		return controlflow.ExecResult{Err: condRes.Err}
 	}
 	cond := condRes.Value
-	fmt.Printf("If conditon %#v (type=%v)\n", cond, cond.Type)
+	log.Debug("if", "condition", cond.String(), "line", stmt.IfToken.Line)
 	if cond.Type != value.ValueBool {
 		return controlflow.ExecResult{Err: fmt.Errorf("if condition must evaluate to bool")}
 	}
@@ -556,6 +825,72 @@ func (i *Interpreter) VisitIfStmt(stmt *ast.IfStmt) controlflow.ExecResult {
 	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
 }
 
+func (i *Interpreter) VisitIfExpr(expr *ast.IfExpr) controlflow.ExecResult {
+	cond, err := i.evalCondition(expr.Conditon, "if")
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	if cond {
+		return i.evalBlockValue(expr.ThenBranch)
+	}
+	if expr.ElseIf != nil {
+		return i.VisitIfExpr(expr.ElseIf)
+	}
+	return i.evalBlockValue(expr.ElseBranch)
+}
+
+func (i *Interpreter) VisitTernaryExpr(expr *ast.TernaryExpr) controlflow.ExecResult {
+	cond, err := i.evalCondition(expr.Conditon, "conditional expression")
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	if cond {
+		return i.Evaluate(expr.Then)
+	}
+	return i.Evaluate(expr.Else)
+}
+
+func (i *Interpreter) evalCondition(expr ast.Expr, what string) (bool, error) {
+	condRes := i.Evaluate(expr)
+	if condRes.Err != nil {
+		return false, condRes.Err
+	}
+	if condRes.Value.Type != value.ValueBool {
+		return false, fmt.Errorf("%s condition must evaluate to bool", what)
+	}
+	return condRes.Value.Data.(bool), nil
+}
+
+// evalBlockValue runs a block in its own scope and yields the value of its final
+// expression statement, or null if the block does not end in one.
+func (i *Interpreter) evalBlockValue(block *ast.BlockStmt) controlflow.ExecResult {
+	blockEnv := environment.NewEnvironment(i.env)
+	i.PushEnv(blockEnv)
+	defer i.PopEnv()
+
+	stmts := block.Statements
+	var last *ast.ExprStmt
+	if n := len(stmts); n > 0 {
+		if es, ok := stmts[n-1].(*ast.ExprStmt); ok {
+			last = es
+			stmts = stmts[:n-1]
+		}
+	}
+	for _, s := range stmts {
+		result := i.Execute(s)
+		if result.Err != nil {
+			return result
+		}
+		if result.Flow != controlflow.FlowNone {
+			return controlflow.ExecResult{Err: fmt.Errorf("break, continue and return are not allowed inside an if expression")}
+		}
+	}
+	if last == nil {
+		return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+	}
+	return i.Evaluate(last.Expr)
+}
+
 func (i *Interpreter) VisitWhileStmt(stmt *ast.WhileStmt) controlflow.ExecResult {
 	for {
 		condRes := i.Evaluate(stmt.Conditon)
@@ -570,13 +905,37 @@ func (i *Interpreter) VisitWhileStmt(stmt *ast.WhileStmt) controlflow.ExecResult
 			break
 		}
 		result := i.Execute(stmt.Body)
-		if result.Flow != controlflow.FlowNone || result.Err != nil {
-			return result
+		if stop, out := loopSignal(result, stmt.Label); stop {
+			return out
 		}
 	}
 	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
 }
 
+// loopSignal decides how a loop reacts to the result of one body execution. It
+// reports whether the loop must stop and, if so, the result the loop returns.
+// Unlabeled break/continue and those naming this loop's label are consumed here;
+// labeled signals for an outer loop, returns and errors are propagated.
+func loopSignal(result controlflow.ExecResult, label token.Token) (bool, controlflow.ExecResult) {
+	if result.Err != nil {
+		return true, result
+	}
+	targetsThisLoop := result.Label == "" || result.Label == label.Lexeme
+	switch result.Flow {
+	case controlflow.FlowNone:
+		return false, result
+	case controlflow.FlowBreak:
+		if targetsThisLoop {
+			return true, controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+		}
+		return true, result
+	case controlflow.FlowContinue:
+		return !targetsThisLoop, result
+	default:
+		return true, result
+	}
+}
+
 func (i *Interpreter) PushEnv(env *environment.Environment) {
 	i.envStack = append(i.envStack, i.env)
 	i.env = env
@@ -591,6 +950,14 @@ func (i *Interpreter) PopEnv() {
 	i.envStack = i.envStack[:n-1]
 }
 
+// within runs run with env pushed as the current scope, popping it however
+// run ends.
+func (i *Interpreter) within(env *environment.Environment, run func() controlflow.ExecResult) controlflow.ExecResult {
+	i.PushEnv(env)
+	defer i.PopEnv()
+	return run()
+}
+
 func (i *Interpreter) GetEnv() *environment.Environment {
 	return i.env
 }
@@ -659,8 +1026,8 @@ func (i *Interpreter) VisitForStmt(stmt *ast.ForStmt) controlflow.ExecResult {
 
 		// Execute body
 		result := i.Execute(stmt.BodyStmt)
-		if result.Flow != controlflow.FlowNone || result.Err != nil {
-			return result
+		if stop, out := loopSignal(result, stmt.Label); stop {
+			return out
 		}
 
 		// Update statement
@@ -675,22 +1042,145 @@ func (i *Interpreter) VisitForStmt(stmt *ast.ForStmt) controlflow.ExecResult {
 	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
 }
 
+// VisitForInStmt iterates over a list, string, dict, tuple or range.
+func (i *Interpreter) VisitForInStmt(stmt *ast.ForInStmt) controlflow.ExecResult {
+	iterRes := i.Evaluate(stmt.Iterable)
+	if iterRes.Err != nil {
+		return controlflow.ExecResult{Err: iterRes.Err}
+	}
+	seq, err := i.iterValue(iterRes.Value)
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	if gen, ok := temporaryGenerator(stmt.Iterable, iterRes.Value); ok {
+		defer gen.Stop()
+	}
+
+	for item, err := range seq {
+		if err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+		loopEnv := environment.NewEnvironment(i.env)
+		if err := loopEnv.DefineVar(&symtable.VarSymbol{
+			SymName: stmt.Name.Lexeme,
+			SymKind: symtable.SymbolVar,
+			Mutable: true,
+		}); err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+		if err := loopEnv.AssignVar(stmt.Name.Lexeme, item); err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+
+		result := i.within(loopEnv, func() controlflow.ExecResult {
+			return i.Execute(stmt.BodyStmt)
+		})
+
+		if stop, out := loopSignal(result, stmt.Label); stop {
+			return out
+		}
+	}
+	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+}
+
+// VisitSwitchStmt runs the first case whose value equals the subject, or the default.
+// An unlabeled break ends the switch; continue, labeled break and return propagate
+// to the enclosing loop or function.
+func (i *Interpreter) VisitSwitchStmt(stmt *ast.SwitchStmt) controlflow.ExecResult {
+	subjectRes := i.Evaluate(stmt.Subject)
+	if subjectRes.Err != nil {
+		return controlflow.ExecResult{Err: subjectRes.Err}
+	}
+	subject := subjectRes.Value
+
+	matched := -1
+	for idx, clause := range stmt.Cases {
+		for _, caseExpr := range clause.Values {
+			caseRes := i.Evaluate(caseExpr)
+			if caseRes.Err != nil {
+				return controlflow.ExecResult{Err: caseRes.Err}
+			}
+			if subject.Equals(caseRes.Value) {
+				matched = idx
+				break
+			}
+		}
+		if matched >= 0 {
+			break
+		}
+	}
+	if matched < 0 {
+		for idx, clause := range stmt.Cases {
+			if clause.IsDefault {
+				matched = idx
+				break
+			}
+		}
+	}
+	if matched < 0 {
+		return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+	}
+
+	for idx := matched; idx < len(stmt.Cases); idx++ {
+		result := i.executeCaseBody(stmt.Cases[idx].Body)
+		switch {
+		case result.Err != nil:
+			return result
+		case result.Flow == controlflow.FlowFallthrough:
+			continue
+		case result.Flow == controlflow.FlowBreak && result.Label == "":
+			return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+		default:
+			return result
+		}
+	}
+	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+}
+
+func (i *Interpreter) executeCaseBody(body []ast.Stmt) controlflow.ExecResult {
+	caseEnv := environment.NewEnvironment(i.env)
+	i.PushEnv(caseEnv)
+	defer i.PopEnv()
+	for _, s := range body {
+		result := i.Execute(s)
+		if result.Flow != controlflow.FlowNone {
+			return result
+		}
+	}
+	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowNone}
+}
+
+// VisitFallthroughStmt transfers control to the next case of the enclosing switch.
+func (i *Interpreter) VisitFallthroughStmt(stmt *ast.FallthroughStmt) controlflow.ExecResult {
+	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowFallthrough}
+}
+
 func (i *Interpreter) VisitCallExpr(expr *ast.CallExpr) controlflow.ExecResult {
+	callable, args, typeSyms, err := i.prepareCall(expr)
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	return i.call(callable, args, typeSyms, expr)
+}
+
+// prepareCall evaluates the callee, arguments and type arguments of a call
+// without invoking it.
+func (i *Interpreter) prepareCall(expr *ast.CallExpr) (function.Callable, []value.Value, []*symtable.TypeSymbol, error) {
 	// Evaluate the callee expression (should be a function)
 	calleeRes := i.Evaluate(expr.Callee)
 	if calleeRes.Err != nil {
-		return controlflow.ExecResult{Err: calleeRes.Err}
+		return nil, nil, nil, calleeRes.Err
 	}
 	calleeVal := calleeRes.Value
 	callable, ok := calleeVal.Data.(function.Callable)
 	if !ok {
-		return controlflow.ExecResult{Err: fmt.Errorf("attempt to call non-function value")}
+		return nil, nil, nil, fmt.Errorf("attempt to call non-function value")
 	}
 	args := make([]value.Value, len(expr.Arguments))
 	for idx, argExpr := range expr.Arguments {
 		argRes := i.Evaluate(argExpr)
 		if argRes.Err != nil {
-			return controlflow.ExecResult{Err: argRes.Err}
+			return nil, nil, nil, argRes.Err
 		}
 		args[idx] = argRes.Value
 	}
@@ -698,12 +1188,12 @@ func (i *Interpreter) VisitCallExpr(expr *ast.CallExpr) controlflow.ExecResult {
 	for j, typeArg := range expr.TypeArgs {
 		tsym, err := i.resolveTypeExpr(typeArg)
 		if err != nil {
-			return controlflow.ExecResult{Err: fmt.Errorf("failed to resolve type arguemnt %d: %w", j+1, err)}
+			return nil, nil, nil, fmt.Errorf("failed to resolve type arguemnt %d: %w", j+1, err)
 		}
 		typeSyms[j] = tsym
 	}
 
-	return callable.Call(args, typeSyms, i)
+	return callable, args, typeSyms, nil
 }
 
 func (i *Interpreter) VisitIndexExpr(expr *ast.IndexExpr) controlflow.ExecResult {
@@ -734,30 +1224,125 @@ func (i *Interpreter) VisitIndexExpr(expr *ast.IndexExpr) controlflow.ExecResult
 			return controlflow.ExecResult{Err: fmt.Errorf("list index must be integer")}
 		}
 		if idx < 0 || idx >= len(list) {
-			return controlflow.ExecResult{Err: fmt.Errorf("list index out of range")}
+			return controlflow.ExecResult{Err: runtimeErrorf(niferrors.CodeIndexOutOfRange, "list index out of range")}
 		}
 		return controlflow.ExecResult{Value: list[idx], Flow: controlflow.FlowNone}
 
 	case value.ValueDict:
-		dict, ok := collectionVal.Data.(map[string]value.Value)
+		dict, ok := collectionVal.Data.(*value.NiftelDict)
 		if !ok {
 			return controlflow.ExecResult{Err: fmt.Errorf("dict data is corrupted")}
 		}
-		keyStr, ok := indexVal.Data.(string)
-		if !ok {
-			return controlflow.ExecResult{Err: fmt.Errorf("dict key must be string")}
-		}
-		val, exists := dict[keyStr]
+		val, exists := dict.Get(indexVal)
 		if !exists {
-			return controlflow.ExecResult{Err: fmt.Errorf("dict key not found: %s", keyStr)}
+			return controlflow.ExecResult{Err: runtimeErrorf(niferrors.CodeKeyNotFound, "dict key not found: %s", indexVal.String())}
 		}
 		return controlflow.ExecResult{Value: val, Flow: controlflow.FlowNone}
 
+	case value.ValueRange:
+		rng, ok := collectionVal.Data.(*value.NiftelRange)
+		if !ok {
+			return controlflow.ExecResult{Err: fmt.Errorf("range data is corrupted")}
+		}
+		idx, ok := intOf(indexVal)
+		if !ok {
+			return controlflow.ExecResult{Err: fmt.Errorf("range index must be integer")}
+		}
+		elem, ok := rng.At(idx)
+		if !ok {
+			return controlflow.ExecResult{Err: runtimeErrorf(niferrors.CodeIndexOutOfRange, "range index out of range")}
+		}
+		return controlflow.ExecResult{Value: value.Value{Type: value.ValueInt, Data: float64(elem)}, Flow: controlflow.FlowNone}
+
 	default:
 		return controlflow.ExecResult{Err: fmt.Errorf("indexing unsupported on type %v", collectionVal.Type)}
 	}
 }
 
+func (i *Interpreter) VisitSliceExpr(expr *ast.SliceExpr) controlflow.ExecResult {
+	collectionRes := i.Evaluate(expr.Collection)
+	if collectionRes.Err != nil {
+		return controlflow.ExecResult{Err: collectionRes.Err}
+	}
+	collectionVal := collectionRes.Value
+
+	length, err := lengthOf(collectionVal)
+	if err != nil {
+		return controlflow.ExecResult{Err: fmt.Errorf("slicing unsupported on type %v", collectionVal.Type)}
+	}
+	low, high := int64(0), length
+	if expr.Low != nil {
+		if low, err = i.evalInt(expr.Low, "slice bound"); err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+	}
+	if expr.High != nil {
+		if high, err = i.evalInt(expr.High, "slice bound"); err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+	}
+	low = min(max(low, 0), length)
+	high = min(max(high, low), length)
+
+	switch collectionVal.Type {
+	case value.ValueList:
+		if err := i.alloc((high - low) * valueSize); err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+		list := collectionVal.Data.([]value.Value)
+		elems := make([]value.Value, high-low)
+		copy(elems, list[low:high])
+		return controlflow.ExecResult{Value: value.Value{Type: value.ValueList, Data: elems}, Flow: controlflow.FlowNone}
+	case value.ValueString:
+		runes := []rune(collectionVal.Data.(string))
+		sliced := string(runes[low:high])
+		if err := i.alloc(int64(len(sliced))); err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+		return controlflow.ExecResult{Value: value.Value{Type: value.ValueString, Data: sliced}, Flow: controlflow.FlowNone}
+	case value.ValueRange:
+		rng := collectionVal.Data.(*value.NiftelRange)
+		return controlflow.ExecResult{Value: value.Value{Type: value.ValueRange, Data: rng.Slice(low, high)}, Flow: controlflow.FlowNone}
+	default:
+		return controlflow.ExecResult{Err: fmt.Errorf("slicing unsupported on type %v", collectionVal.Type)}
+	}
+}
+
+func (i *Interpreter) VisitRangeExpr(expr *ast.RangeExpr) controlflow.ExecResult {
+	start, err := i.evalInt(expr.Start, "range start")
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	end, err := i.evalInt(expr.End, "range end")
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	step := int64(1)
+	if expr.Step != nil {
+		if step, err = i.evalInt(expr.Step, "range step"); err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+	}
+	rng, err := value.NewNiftelRange(start, end, step, expr.Inclusive)
+	if err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
+	return controlflow.ExecResult{Value: value.Value{Type: value.ValueRange, Data: rng}, Flow: controlflow.FlowNone}
+}
+
+// evalInt evaluates expr and requires it to produce a whole int.
+func (i *Interpreter) evalInt(expr ast.Expr, what string) (int64, error) {
+	res := i.Evaluate(expr)
+	if res.Err != nil {
+		return 0, res.Err
+	}
+	n, ok := intOf(res.Value)
+	if !ok {
+		return 0, fmt.Errorf("%s must be integer, got %v", what, res.Value.Type)
+	}
+	return n, nil
+}
+
 func (i *Interpreter) VisitGetExpr(expr *ast.GetExpr) controlflow.ExecResult {
 	// Evaluate object expression
 	objectRes := i.Evaluate(expr.Object)
@@ -778,14 +1363,27 @@ func (i *Interpreter) VisitGetExpr(expr *ast.GetExpr) controlflow.ExecResult {
 
 	fieldName := expr.Name.Lexeme
 
-	val, exists := inst.Fields[fieldName]
-	if !exists {
-		return controlflow.ExecResult{Err: fmt.Errorf("struct field '%s' not found", fieldName)}
+	if val, exists := inst.Fields[fieldName]; exists {
+		return controlflow.ExecResult{Value: val, Flow: controlflow.FlowNone}
 	}
-	return controlflow.ExecResult{Value: val, Flow: controlflow.FlowNone}
+	if method, exists := inst.Type.Methods[fieldName]; exists {
+		fn, ok := method.Data.(*function.Function)
+		if !ok {
+			return controlflow.ExecResult{Err: fmt.Errorf("method '%s' is corrupt", fieldName)}
+		}
+		bound, err := fn.Bind(objectVal)
+		if err != nil {
+			return controlflow.ExecResult{Err: err}
+		}
+		return controlflow.ExecResult{Value: value.Value{Type: value.ValueFunc, Data: bound}, Flow: controlflow.FlowNone}
+	}
+	return controlflow.ExecResult{Err: fmt.Errorf("struct field '%s' not found", fieldName)}
 }
 
 func (i *Interpreter) VisitListExpr(expr *ast.ListExpr) controlflow.ExecResult {
+	if err := i.alloc(int64(len(expr.Elements)) * valueSize); err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
 	elements := make([]value.Value, 0, len(expr.Elements))
 	for _, elementExpr := range expr.Elements {
 		elemRes := i.Evaluate(elementExpr)
@@ -804,6 +1402,9 @@ func (i *Interpreter) VisitListExpr(expr *ast.ListExpr) controlflow.ExecResult {
 }
 
 func (i *Interpreter) VisitDictExpr(expr *ast.DictExpr) controlflow.ExecResult {
+	if err := i.alloc(int64(len(expr.Pairs)) * 2 * valueSize); err != nil {
+		return controlflow.ExecResult{Err: err}
+	}
 	dict := value.NewNiftelDict()
 	for _, pair := range expr.Pairs {
 		keyRes := i.Evaluate(pair[0])
@@ -832,13 +1433,7 @@ func (i *Interpreter) VisitFuncExpr(expr *ast.FuncExpr) controlflow.ExecResult {
 	// For now, pack the FuncExpr itself as data and keep meta for type info
 	// Actual call logic to be implemented in VisitCallExpr
 
-	fn := function.NewUserFunc(
-		"<anonymous>",
-		expr.Params,
-		expr.Body,
-		i.env,
-		expr.Func.Line,
-		expr.Func.Column)
+	fn := newUserFunc("<anonymous>", expr.Params, expr.Body, expr.IsGenerator, i.env, expr.Func)
 
 	return controlflow.ExecResult{
 		Value: value.Value{
@@ -852,10 +1447,6 @@ func (i *Interpreter) VisitFuncExpr(expr *ast.FuncExpr) controlflow.ExecResult {
 
 // VisitFuncStmt defines a function in the environment.
 func (i *Interpreter) VisitFuncStmt(stmt *ast.FuncStmt) controlflow.ExecResult {
-	fmt.Printf("[DEBUG GENERICS: VISIT FUNCSTMT]: %#v", stmt.TypeParams)
-	for idx, tp := range stmt.TypeParams {
-		fmt.Printf("[DEBUG GENERICS: VISIT FUNCSTMT]: TYP PARAM[%d]: %q LEXEME:%q", idx, tp, tp.Lexeme)
-	}
 	oldTypeEnv := i.typEnv
 	if len(stmt.TypeParams) > 0 {
 		i.typEnv = typeenv.NewTypeEnv(oldTypeEnv)
@@ -895,13 +1486,9 @@ func (i *Interpreter) VisitFuncStmt(stmt *ast.FuncStmt) controlflow.ExecResult {
 		if param.Type != nil && param.Type.Name.Lexeme != "" {
 			ts, err := i.resolveTypeExpr(param.Type)
 			if err != nil {
-				fmt.Println("DEBUG: KNOWN TYPES IN INTERPRETER ENV:", func() []string {
-					names := []string{}
-					for name := range i.env.SymbolTable().Types {
-						names = append(names, name)
-					}
-					return names
-				}())
+				if trace.On(trace.Interp, slog.LevelDebug) {
+					log.Debug("unknown parameter type", "type", param.Type.Name.Lexeme, "known", slices.Sorted(maps.Keys(i.env.SymbolTable().Types)))
+				}
 				return controlflow.ExecResult{Err: fmt.Errorf("unknown parameter type '%s' in function '%s': %w", param.Type.Name.Lexeme, name, err)}
 			}
 			typeSym = ts
@@ -915,11 +1502,11 @@ func (i *Interpreter) VisitFuncStmt(stmt *ast.FuncStmt) controlflow.ExecResult {
 
 	}
 
-	fmt.Printf("Defining function: %s\n", stmt.Name.Lexeme)
 	typeParamNames := make([]string, len(stmt.TypeParams))
 	for i, tp := range stmt.TypeParams {
 		typeParamNames[i] = tp.Lexeme
 	}
+	log.Debug("function", "name", name, "typeParams", typeParamNames, "line", stmt.Func.Line)
 	funcSym := &symtable.FuncSymbol{
 		SymName:    name,
 		Params:     params,
@@ -937,13 +1524,7 @@ func (i *Interpreter) VisitFuncStmt(stmt *ast.FuncStmt) controlflow.ExecResult {
 	if err := i.env.DefineVar(varSym); err != nil {
 		return controlflow.ExecResult{Err: err}
 	}
-	fn := function.NewUserFunc(
-		stmt.Name.Lexeme,
-		stmt.Params,
-		stmt.Body,
-		i.env,
-		stmt.Func.Line,
-		stmt.Func.Column)
+	fn := newUserFunc(stmt.Name.Lexeme, stmt.Params, stmt.Body, stmt.IsGenerator, i.env, stmt.Func)
 	if err := i.env.AssignVar(name, value.Value{
 		Type: value.ValueFunc,
 		Data: fn,
@@ -988,10 +1569,10 @@ func (i *Interpreter) VisitReturnStmt(stmt *ast.ReturnStmt) controlflow.ExecResu
 
 // VisitBreakStmt handles break statement in loops.
 func (i *Interpreter) VisitBreakStmt(stmt *ast.BreakStmt) controlflow.ExecResult {
-	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowBreak}
+	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowBreak, Label: stmt.Label.Lexeme}
 }
 
 // VisitContinueStmt handles continue statement in loops.
 func (i *Interpreter) VisitContinueStmt(stmt *ast.ContinueStmt) controlflow.ExecResult {
-	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowContinue}
+	return controlflow.ExecResult{Value: value.Null(), Flow: controlflow.FlowContinue, Label: stmt.Label.Lexeme}
 }
@@ -0,0 +1,240 @@
+package interpreter
+
+import (
+	"fmt"
+	"iter"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/function"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+)
+
+// iterate returns a lazy sequence over the elements of an iterable value. A
+// sequence that fails yields the error as its last element.
+func iterate(v value.Value) (iter.Seq2[value.Value, error], error) {
+	switch v.Type {
+	case value.ValueList:
+		list, ok := v.Data.([]value.Value)
+		if !ok {
+			return nil, fmt.Errorf("list data is corrupted")
+		}
+		return func(yield func(value.Value, error) bool) {
+			for _, elem := range list {
+				if !yield(elem, nil) {
+					return
+				}
+			}
+		}, nil
+	case value.ValueTuple:
+		tuple, ok := v.Data.(*value.NiftelTupleValue)
+		if !ok {
+			return nil, fmt.Errorf("tuple data is corrupted")
+		}
+		return func(yield func(value.Value, error) bool) {
+			for _, elem := range tuple.Elements {
+				if !yield(elem, nil) {
+					return
+				}
+			}
+		}, nil
+	case value.ValueString:
+		str := v.Data.(string)
+		return func(yield func(value.Value, error) bool) {
+			for _, r := range str {
+				if !yield(value.Value{Type: value.ValueString, Data: string(r)}, nil) {
+					return
+				}
+			}
+		}, nil
+	case value.ValueDict:
+		dict, ok := v.Data.(*value.NiftelDict)
+		if !ok {
+			return nil, fmt.Errorf("dict data is corrupted")
+		}
+		return func(yield func(value.Value, error) bool) {
+			for _, key := range dict.Keys() {
+				if !yield(key, nil) {
+					return
+				}
+			}
+		}, nil
+	case value.ValueRange:
+		rng, ok := v.Data.(*value.NiftelRange)
+		if !ok {
+			return nil, fmt.Errorf("range data is corrupted")
+		}
+		return func(yield func(value.Value, error) bool) {
+			n := rng.Len()
+			for idx := int64(0); idx < n; idx++ {
+				elem, _ := rng.At(idx)
+				if !yield(value.Value{Type: value.ValueInt, Data: float64(elem)}, nil) {
+					return
+				}
+			}
+		}, nil
+	case value.ValueGenerator:
+		gen, ok := v.Data.(*value.NiftelGenerator)
+		if !ok {
+			return nil, fmt.Errorf("generator data is corrupted")
+		}
+		return func(yield func(value.Value, error) bool) {
+			for {
+				elem, ok, err := gen.Next()
+				if err != nil {
+					yield(value.Null(), err)
+					return
+				}
+				if !ok || !yield(elem, nil) {
+					return
+				}
+			}
+		}, nil
+	default:
+		return nil, fmt.Errorf("value of type %v is not iterable", v.Type)
+	}
+}
+
+// iterValue extends iterate to structs with an iter() method, whose result is
+// iterated in their place.
+func (i *Interpreter) iterValue(v value.Value) (iter.Seq2[value.Value, error], error) {
+	if v.Type != value.ValueStruct {
+		return iterate(v)
+	}
+	inst, ok := v.Data.(*value.StructInstance)
+	if !ok || inst == nil {
+		return nil, fmt.Errorf("struct instance is corrupt")
+	}
+	method, ok := inst.Type.Methods["iter"]
+	if !ok {
+		return nil, fmt.Errorf("struct '%s' is not iterable: no iter() method", inst.Type.Name)
+	}
+	fn, ok := method.Data.(*function.Function)
+	if !ok {
+		return nil, fmt.Errorf("struct '%s' iter is not a function", inst.Type.Name)
+	}
+	bound, err := fn.Bind(v)
+	if err != nil {
+		return nil, err
+	}
+	result := i.call(bound, nil, nil, nil)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	if result.Value.Type == value.ValueStruct {
+		return nil, fmt.Errorf("iter() of struct '%s' must not return a struct", inst.Type.Name)
+	}
+	seq, err := iterate(result.Value)
+	if gen, ok := result.Value.Data.(*value.NiftelGenerator); ok && err == nil {
+		// The generator iter() made is held by nothing but seq.
+		return stopAfter(gen, seq), nil
+	}
+	return seq, err
+}
+
+// temporaryGenerator returns the generator v if expr made it with a call, so
+// that nothing holds it but the code about to iterate it. That code should
+// stop it when done, as a loop left early would otherwise leave its body
+// parked until the generator is collected.
+func temporaryGenerator(expr ast.Expr, v value.Value) (*value.NiftelGenerator, bool) {
+	if _, isCall := expr.(*ast.CallExpr); !isCall {
+		return nil, false
+	}
+	gen, ok := v.Data.(*value.NiftelGenerator)
+	return gen, ok
+}
+
+// stopAfter returns seq, the elements of gen, stopping gen once it is left.
+func stopAfter(gen *value.NiftelGenerator, seq iter.Seq2[value.Value, error]) iter.Seq2[value.Value, error] {
+	return func(yield func(value.Value, error) bool) {
+		defer gen.Stop()
+		seq(yield)
+	}
+}
+
+func lengthOf(v value.Value) (int64, error) {
+	switch v.Type {
+	case value.ValueList:
+		list, ok := v.Data.([]value.Value)
+		if !ok {
+			return 0, fmt.Errorf("list data is corrupted")
+		}
+		return int64(len(list)), nil
+	case value.ValueTuple:
+		tuple, ok := v.Data.(*value.NiftelTupleValue)
+		if !ok {
+			return 0, fmt.Errorf("tuple data is corrupted")
+		}
+		return int64(len(tuple.Elements)), nil
+	case value.ValueString:
+		return int64(utf8.RuneCountInString(v.Data.(string))), nil
+	case value.ValueDict:
+		dict, ok := v.Data.(*value.NiftelDict)
+		if !ok {
+			return 0, fmt.Errorf("dict data is corrupted")
+		}
+		return int64(len(dict.Keys())), nil
+	case value.ValueRange:
+		rng, ok := v.Data.(*value.NiftelRange)
+		if !ok {
+			return 0, fmt.Errorf("range data is corrupted")
+		}
+		return rng.Len(), nil
+	default:
+		return 0, fmt.Errorf("len() unsupported on type %v", v.Type)
+	}
+}
+
+func contains(container, elem value.Value) (bool, error) {
+	switch container.Type {
+	case value.ValueString:
+		if elem.Type != value.ValueString {
+			return false, fmt.Errorf("'in <string>' requires string as left operand")
+		}
+		return strings.Contains(container.Data.(string), elem.Data.(string)), nil
+	case value.ValueDict:
+		dict, ok := container.Data.(*value.NiftelDict)
+		if !ok {
+			return false, fmt.Errorf("dict data is corrupted")
+		}
+		_, found := dict.Get(elem)
+		return found, nil
+	case value.ValueRange:
+		rng, ok := container.Data.(*value.NiftelRange)
+		if !ok {
+			return false, fmt.Errorf("range data is corrupted")
+		}
+		n, ok := intOf(elem)
+		if !ok {
+			return false, nil
+		}
+		return rng.Contains(n), nil
+	default:
+		seq, err := iterate(container)
+		if err != nil {
+			return false, fmt.Errorf("'in' unsupported on type %v", container.Type)
+		}
+		for item, err := range seq {
+			if err != nil {
+				return false, err
+			}
+			if item.Equals(elem) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// intOf reports the integer held by v, if v is a whole int value.
+func intOf(v value.Value) (int64, bool) {
+	if v.Type != value.ValueInt {
+		return 0, false
+	}
+	f, ok := v.Data.(float64)
+	if !ok || f != float64(int64(f)) {
+		return 0, false
+	}
+	return int64(f), true
+}
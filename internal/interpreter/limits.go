@@ -0,0 +1,155 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/controlflow"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// Options limits what a program may do, so that scripts that are not trusted
+// can run without hanging or exhausting the host. A zero field sets no limit.
+// A program that exceeds a limit stops with a LimitError whose code and
+// wrapped error say which.
+type Options struct {
+	// MaxSteps is how many statements the program may execute, counted
+	// across all of its tasks. Exceeding it raises ErrStepLimit.
+	MaxSteps int64
+	// MaxCallDepth is how many calls may be in progress at once in any one
+	// task. Exceeding it raises ErrCallDepth. Without it, unbounded
+	// recursion ends by overflowing the Go stack, which kills the process.
+	MaxCallDepth int
+	// Context stops the program once it is done, so its deadline is one for
+	// the whole run. Tasks waiting on a channel or another task stop too.
+	// The error wraps the context's error, context.DeadlineExceeded or
+	// context.Canceled.
+	Context context.Context
+	// MaxMemory is roughly how many bytes the program may allocate for
+	// lists, dicts and strings. Allocations are counted as values are built,
+	// across all tasks, and are not given back when the values are dropped,
+	// so this bounds the work a program does as much as the memory it
+	// holds. Exceeding it raises ErrMemoryLimit.
+	MaxMemory int64
+}
+
+var (
+	// ErrStepLimit is wrapped by the error that stops a program once it has
+	// run Options.MaxSteps statements.
+	ErrStepLimit = errors.New("step limit exceeded")
+	// ErrCallDepth is wrapped by the error raised by a call that would make
+	// more than Options.MaxCallDepth calls in progress.
+	ErrCallDepth = errors.New("call depth limit exceeded")
+	// ErrMemoryLimit is wrapped by the error raised by an allocation that
+	// would take a program past Options.MaxMemory.
+	ErrMemoryLimit = errors.New("memory limit exceeded")
+	// ErrInternal is wrapped by the error a panic inside the interpreter is
+	// turned into, so that it does not reach the host.
+	ErrInternal = errors.New("internal error")
+)
+
+// usage is what a program has used of its limits, shared by all its tasks.
+type usage struct {
+	steps  atomic.Int64
+	memory atomic.Int64
+}
+
+// valueSize is what a value takes in a list or dict, not counting what its
+// data points to.
+const valueSize = int64(unsafe.Sizeof(value.Value{}))
+
+// NewInterpreterWithOptions returns a fresh Interpreter that runs programs
+// within the limits of opts.
+func NewInterpreterWithOptions(opts Options) *Interpreter {
+	interp := NewInterpreter()
+	interp.opts = opts
+	return interp
+}
+
+func limitError(code niferrors.Code, err error, format string, args ...any) error {
+	return &niferrors.NifError{Kind: niferrors.LimitError, Code: code, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// step counts a statement against the step limit and checks the context.
+func (i *Interpreter) step() error {
+	if i.opts.MaxSteps > 0 && i.usage.steps.Add(1) > i.opts.MaxSteps {
+		return limitError(niferrors.CodeStepLimit, ErrStepLimit, "step limit of %d exceeded", i.opts.MaxSteps)
+	}
+	return i.canceled()
+}
+
+// canceled returns an error if the program's context is done, for loops in
+// Go that a program can make run for long.
+func (i *Interpreter) canceled() error {
+	if i.opts.Context != nil {
+		select {
+		case <-i.opts.Context.Done():
+			return i.stopped()
+		default:
+		}
+	}
+	return nil
+}
+
+// interrupt returns a channel that is closed once the program should stop
+// waiting, or nil if it never is.
+func (i *Interpreter) interrupt() <-chan struct{} {
+	if i.opts.Context == nil {
+		return nil
+	}
+	return i.opts.Context.Done()
+}
+
+// interrupted returns the error that stops a program whose wait was
+// interrupted, or err if it is some other error.
+func (i *Interpreter) interrupted(err error) error {
+	if errors.Is(err, value.ErrInterrupted) {
+		return i.stopped()
+	}
+	return err
+}
+
+// stopped returns the error that stops a program whose context is done.
+func (i *Interpreter) stopped() error {
+	err := i.opts.Context.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return limitError(niferrors.CodeDeadline, err, "deadline exceeded")
+	}
+	return limitError(niferrors.CodeDeadline, err, "execution canceled")
+}
+
+// enter checks that one more call may be made.
+func (i *Interpreter) enter() error {
+	if i.opts.MaxCallDepth > 0 && len(i.frames) >= i.opts.MaxCallDepth {
+		return limitError(niferrors.CodeCallDepth, ErrCallDepth, "call depth limit of %d exceeded", i.opts.MaxCallDepth)
+	}
+	return nil
+}
+
+// alloc counts n bytes against the memory limit.
+func (i *Interpreter) alloc(n int64) error {
+	if i.opts.MaxMemory > 0 && i.usage.memory.Add(n) > i.opts.MaxMemory {
+		return limitError(niferrors.CodeMemoryLimit, ErrMemoryLimit, "memory limit of %d bytes exceeded", i.opts.MaxMemory)
+	}
+	return nil
+}
+
+// recoverAt turns a panic that reached the outermost Execute or Evaluate into
+// an error located at node. The calls and scopes it unwound through have
+// already restored the interpreter's state.
+func (i *Interpreter) recoverAt(result *controlflow.ExecResult, node ast.Node) {
+	i.running = false
+	if r := recover(); r != nil {
+		*result = controlflow.ExecResult{Err: runtimeError(recovered(r), node)}
+	}
+}
+
+// recovered turns a panic inside the interpreter into an error.
+func recovered(r any) error {
+	return &niferrors.NifError{Kind: niferrors.RuntimeError, Code: niferrors.CodeInternal, Message: fmt.Sprintf("internal error: %v", r), Err: ErrInternal}
+}
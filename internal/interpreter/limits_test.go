@@ -0,0 +1,162 @@
+package interpreter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/function"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// runLimited runs source within opts and returns the first error.
+func runLimited(t *testing.T, opts interpreter.Options, source string) error {
+	t.Helper()
+	value.BuiltinTypesInit()
+	stmts, err := parser.New(lexer.New(source)).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	interp := interpreter.NewInterpreterWithOptions(opts)
+	for _, stmt := range stmts {
+		if res := interp.Execute(stmt); res.Err != nil {
+			return res.Err
+		}
+	}
+	return nil
+}
+
+func TestLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	cases := []struct {
+		name   string
+		opts   interpreter.Options
+		source string
+		code   niferrors.Code
+		err    error
+	}{
+		{"steps", interpreter.Options{MaxSteps: 1000}, "while true {}", niferrors.CodeStepLimit, interpreter.ErrStepLimit},
+		{"call depth", interpreter.Options{MaxCallDepth: 50}, `func f(n: int) -> int {
+	return f(n + 1)
+}
+f(0)`, niferrors.CodeCallDepth, interpreter.ErrCallDepth},
+		{"canceled", interpreter.Options{Context: canceled}, "x := 1", niferrors.CodeDeadline, context.Canceled},
+		{"string memory", interpreter.Options{MaxMemory: 1 << 16}, `s := "ab"
+while true {
+	s = s + s
+}`, niferrors.CodeMemoryLimit, interpreter.ErrMemoryLimit},
+		{"list memory", interpreter.Options{MaxMemory: 1 << 16}, "l := list(0..1000000)", niferrors.CodeMemoryLimit, interpreter.ErrMemoryLimit},
+		{"list literal memory", interpreter.Options{MaxMemory: 1 << 10}, `while true {
+	l := [0, 1, 2, 3]
+}`, niferrors.CodeMemoryLimit, interpreter.ErrMemoryLimit},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := runLimited(t, tc.opts, tc.source)
+			var nifErr *niferrors.NifError
+			if !errors.As(err, &nifErr) {
+				t.Fatalf("expected a NifError, got %T: %v", err, err)
+			}
+			if nifErr.Kind != niferrors.LimitError || nifErr.Code != tc.code {
+				t.Errorf("expected a limit error %s, got %s %s: %v", tc.code, nifErr.Kind, nifErr.Code, err)
+			}
+			if !errors.Is(err, tc.err) {
+				t.Errorf("expected %v to wrap %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestLimits_Deadline(t *testing.T) {
+	for name, source := range map[string]string{
+		"loop":           "while true {}",
+		"blocked recv":   "c := chan[int](0)\nrecv(c)",
+		"blocked join":   "func hang() {\n\twhile true {}\n}\njoin(spawn hang())",
+		"blocked select": "c := chan[int](0)\nselect {\ncase v := recv(c):\n\tprint(v)\n}",
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			err := runLimited(t, interpreter.Options{Context: ctx}, source)
+			var nifErr *niferrors.NifError
+			if !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &nifErr) || nifErr.Code != niferrors.CodeDeadline {
+				t.Fatalf("expected the deadline to stop the program, got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("expected the program to stop soon after its deadline, took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestLimits_NoneByDefault(t *testing.T) {
+	err := runLimited(t, interpreter.Options{}, `func f(n: int) -> int {
+	if n == 0 {
+		return 0
+	}
+	return f(n - 1)
+}
+x := f(500)
+l := list(0..10000)`)
+	if err != nil {
+		t.Fatalf("expected no limits, got %v", err)
+	}
+}
+
+func TestLimits_PanicRestoresState(t *testing.T) {
+	value.BuiltinTypesInit()
+	stmts, err := parser.New(lexer.New(`func f() {
+	for x in [1, 2] {
+		print(x)
+	}
+}
+f()
+y := 1`)).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	interp := interpreter.NewInterpreter()
+	global := interp.GetEnv()
+	open := 0
+	interp.Hook = func(_ *interpreter.Interpreter, stmt ast.Stmt) error {
+		if _, ok := stmt.(*ast.PrintStmt); ok {
+			panic("boom")
+		}
+		return nil
+	}
+	interp.CallHook = func(_ *interpreter.Interpreter, _ function.Callable, returned bool) {
+		if returned {
+			open--
+		} else {
+			open++
+		}
+	}
+	for _, stmt := range stmts[:2] {
+		if res := interp.Execute(stmt); res.Err != nil {
+			err = res.Err
+		}
+	}
+	if !errors.Is(err, interpreter.ErrInternal) {
+		t.Fatalf("expected an internal error, got %v", err)
+	}
+	if depth := interp.CallDepth(); depth != 0 {
+		t.Errorf("expected no calls in progress, got %d", depth)
+	}
+	if open != 0 {
+		t.Errorf("expected every call hook to see its return, %d did not", open)
+	}
+	if interp.GetEnv() != global {
+		t.Errorf("expected the global environment to be restored")
+	}
+	if res := interp.Execute(stmts[2]); res.Err != nil {
+		t.Fatalf("expected the interpreter to run on, got %v", res.Err)
+	}
+}
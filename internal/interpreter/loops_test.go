@@ -0,0 +1,96 @@
+package interpreter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+)
+
+func TestInterpreter_ContinueKeepsLooping(t *testing.T) {
+	interp := runSource(t, `
+w := 0
+i := 0
+while i < 5 {
+	i = i + 1
+	if i == 2 {
+		continue
+	}
+	w = w + 1
+}
+f := 0
+for j := 0; j < 5; j = j + 1 {
+	if j == 3 {
+		continue
+	}
+	f = f + 1
+}
+b := 0
+for j := 0; j < 5; j = j + 1 {
+	if j == 3 {
+		break
+	}
+	b = b + 1
+}
+`)
+	expectInt(t, interp, "w", 4)
+	expectInt(t, interp, "f", 4)
+	expectInt(t, interp, "b", 3)
+}
+
+func TestInterpreter_LabeledBreakContinue(t *testing.T) {
+	interp := runSource(t, `
+pairs := 0
+outer: for i in 0..4 {
+	for j in 0..4 {
+		if j > i {
+			continue outer
+		}
+		if i == 3 {
+			break outer
+		}
+		pairs = pairs + 1
+	}
+}
+inner := 0
+n := 0
+rows: while n < 3 {
+	n = n + 1
+	for k := 0; k < 10; k = k + 1 {
+		if k == 2 {
+			continue rows
+		}
+		inner = inner + 1
+	}
+}
+hits := 0
+scan: for x in 0..3 {
+	switch x {
+	case 1:
+		break scan
+	default:
+		hits = hits + 1
+	}
+}
+`)
+	expectInt(t, interp, "pairs", 6)
+	expectInt(t, interp, "inner", 6)
+	expectInt(t, interp, "n", 3)
+	expectInt(t, interp, "hits", 1)
+}
+
+func TestParser_LabelErrors(t *testing.T) {
+	cases := map[string]string{
+		"for i in 0..3 {\nbreak nowhere\n}":                 "not defined",
+		"outer: for i in 0..3 {\nouter: while true {\n}\n}": "already defined",
+		"outer: print(1)": "must precede a loop",
+		"outer: for i in 0..3 {\nf := func() {\ncontinue outer\n}\n}": "not defined",
+	}
+	for src, want := range cases {
+		_, err := parser.New(lexer.New(src)).Parse()
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parsing %q: expected error containing %q, got %v", src, want, err)
+		}
+	}
+}
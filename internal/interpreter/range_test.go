@@ -0,0 +1,123 @@
+package interpreter_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+)
+
+func runSource(t *testing.T, source string) *interpreter.Interpreter {
+	t.Helper()
+	value.BuiltinTypesInit()
+	interp := interpreter.NewInterpreter()
+	stmts, err := parser.New(lexer.New(source)).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	for _, stmt := range stmts {
+		if res := interp.Execute(stmt); res.Err != nil {
+			t.Fatalf("runtime error: %v", res.Err)
+		}
+	}
+	return interp
+}
+
+// runSourceErr runs source and returns the first parse or runtime error.
+func runSourceErr(t *testing.T, source string) error {
+	t.Helper()
+	return runSourceTo(t, source, nil)
+}
+
+// runSourceTo is runSourceErr with what the program prints written to out;
+// nil leaves it on stdout.
+func runSourceTo(t *testing.T, source string, out io.Writer) error {
+	t.Helper()
+	value.BuiltinTypesInit()
+	interp := interpreter.NewInterpreter()
+	if out != nil {
+		interp.Out = out
+	}
+	stmts, err := parser.New(lexer.New(source)).Parse()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if res := interp.Execute(stmt); res.Err != nil {
+			return res.Err
+		}
+	}
+	return nil
+}
+
+func expectInt(t *testing.T, interp *interpreter.Interpreter, name string, want float64) {
+	t.Helper()
+	got, err := interp.GetEnv().GetVar(name)
+	if err != nil {
+		t.Fatalf("lookup %s: %v", name, err)
+	}
+	if got.Type != value.ValueInt || got.Data.(float64) != want {
+		t.Errorf("expected %s == %v, got %v", name, want, got)
+	}
+}
+
+func TestInterpreter_RangeForIn(t *testing.T) {
+	interp := runSource(t, `
+sum := 0
+for i in 0..5 {
+	sum = sum + i
+}
+incl := 0
+for i in 0..=5 {
+	incl = incl + i
+}
+stepped := 0
+for i in 10..0 step -3 {
+	stepped = stepped + i
+}
+`)
+	expectInt(t, interp, "sum", 10)
+	expectInt(t, interp, "incl", 15)
+	expectInt(t, interp, "stepped", 22)
+}
+
+func TestInterpreter_RangeLenMembershipAndSlice(t *testing.T) {
+	interp := runSource(t, `
+r := 0..=10 step 2
+n := len(r)
+third := r[2]
+sub := r[1:3]
+subLen := len(sub)
+first := sub[0]
+hit := 0
+if 4 in r {
+	hit = hit + 1
+}
+if 5 in r {
+	hit = hit + 10
+}
+l := list(0..3)
+listLen := len(l)
+`)
+	expectInt(t, interp, "n", 6)
+	expectInt(t, interp, "third", 4)
+	expectInt(t, interp, "subLen", 2)
+	expectInt(t, interp, "first", 2)
+	expectInt(t, interp, "hit", 1)
+	expectInt(t, interp, "listLen", 3)
+
+	r, _ := interp.GetEnv().GetVar("r")
+	if r.Type != value.ValueRange {
+		t.Errorf("expected range value, got %v", r.Type)
+	}
+	if r.String() != "0..=10 step 2" {
+		t.Errorf("unexpected range string %q", r.String())
+	}
+	l, _ := interp.GetEnv().GetVar("l")
+	if l.Type != value.ValueList {
+		t.Errorf("expected list() to materialise a list, got %v", l.Type)
+	}
+}
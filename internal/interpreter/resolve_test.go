@@ -0,0 +1,184 @@
+package interpreter_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/resolver"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+)
+
+func parseResolved(tb testing.TB, source string) []ast.Stmt {
+	tb.Helper()
+	stmts, err := parser.New(lexer.New(source)).Parse()
+	if err != nil {
+		tb.Fatalf("parse error: %v", err)
+	}
+	if errs := resolver.Resolve(stmts); len(errs) > 0 {
+		tb.Fatalf("resolve errors: %v", errs)
+	}
+	return stmts
+}
+
+func execAll(tb testing.TB, stmts []ast.Stmt) *interpreter.Interpreter {
+	tb.Helper()
+	value.BuiltinTypesInit()
+	interp := interpreter.NewInterpreter()
+	for _, stmt := range stmts {
+		if res := interp.Execute(stmt); res.Err != nil {
+			tb.Fatalf("runtime error: %v", res.Err)
+		}
+	}
+	return interp
+}
+
+func TestInterpreter_ResolvedLocals(t *testing.T) {
+	interp := execAll(t, parseResolved(t, `
+struct Acc {
+	base: int
+	func add(n: int) -> int {
+		return self.base + n
+	}
+}
+func run(n: int) -> int {
+	total := 0
+	for i := 0; i < n; i += 1 {
+		if (i & 1) == 0 {
+			step := i
+			total += step
+		}
+	}
+	for x in 0..3 {
+		inc := func(v: int) {
+			return v + x + n
+		}
+		total = inc(total)
+	}
+	switch n {
+	case 4:
+		total += 100
+	}
+	acc := Acc{base: total}
+	return acc.add(n)
+}
+func gen(limit: int) {
+	i := 0
+	while i < limit {
+		yield i
+		i += 1
+	}
+}
+result := run(4)
+sum := 0
+for v in gen(4) {
+	sum += v
+}
+`))
+	// Even i: 0+2 = 2; the closures add x+4 for x = 0, 1, 2: 17; +100; +4.
+	expectInt(t, interp, "result", 121)
+	expectInt(t, interp, "sum", 6)
+}
+
+const fibSource = `
+func fib(n: int) -> int {
+	if n < 2 {
+		return n
+	}
+	return fib(n - 1) + fib(n - 2)
+}
+func loop(n: int) -> int {
+	total := 0
+	i := 0
+	while i < n {
+		if i > 0 {
+			total = total + i
+		}
+		i = i + 1
+	}
+	return total
+}
+`
+
+// benchmarkScript runs call after fibSource, with or without resolving
+// variable slots first. Both runs use the current, locking Environment, so
+// they show what slots gain over lookups by name, not what the two gain or
+// lose over the Environment before them; the environment package's
+// BenchmarkGetVar benchmarks compare with that one and time the lock alone.
+func benchmarkScript(b *testing.B, call string, resolve bool) {
+	stmts, err := parser.New(lexer.New(fibSource + call)).Parse()
+	if err != nil {
+		b.Fatalf("parse error: %v", err)
+	}
+	if resolve {
+		if errs := resolver.Resolve(stmts); len(errs) > 0 {
+			b.Fatalf("resolve errors: %v", errs)
+		}
+	}
+	// The interpreter still prints tracing output; keep it out of the timings.
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer devNull.Close()
+	stdout := os.Stdout
+	os.Stdout = devNull
+	defer func() { os.Stdout = stdout }()
+
+	b.ResetTimer()
+	for range b.N {
+		execAll(b, stmts)
+	}
+}
+
+func BenchmarkFib_ByName(b *testing.B)   { benchmarkScript(b, "r := fib(15)", false) }
+func BenchmarkFib_Resolved(b *testing.B) { benchmarkScript(b, "r := fib(15)", true) }
+
+func BenchmarkLoop_ByName(b *testing.B)   { benchmarkScript(b, "r := loop(2000)", false) }
+func BenchmarkLoop_Resolved(b *testing.B) { benchmarkScript(b, "r := loop(2000)", true) }
+
+func TestInterpreter_DeclareWithoutValue(t *testing.T) {
+	interp := execAll(t, parseResolved(t, `
+var x: int
+x = 3
+func f(c: bool) -> int {
+	var y: int
+	if c {
+		y = 1
+	} else {
+		y = 2
+	}
+	return y + x
+}
+r := f(false)
+`))
+	expectInt(t, interp, "x", 3)
+	expectInt(t, interp, "r", 5)
+
+	if err := runSourceErr(t, "var z: int\nw := z"); err == nil {
+		t.Error("expected reading an unassigned variable to fail")
+	}
+}
+
+func TestInterpreter_ReturnClosure(t *testing.T) {
+	interp := execAll(t, parseResolved(t, `
+func counter(start: int) -> func {
+	n := start
+	return func() {
+		n += 1
+		return n
+	}
+}
+c := counter(10)
+first := c()
+second := c()
+var fresh: func = counter(0)
+other := fresh()
+`))
+	expectInt(t, interp, "first", 11)
+	expectInt(t, interp, "second", 12)
+	expectInt(t, interp, "other", 1)
+}
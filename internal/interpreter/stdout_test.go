@@ -0,0 +1,43 @@
+package interpreter_test
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+// TestStdout_OnlyProgramOutput checks that lexing, parsing and running a
+// program writes nothing to stdout but what the program prints.
+func TestStdout_OnlyProgramOutput(t *testing.T) {
+	read, write, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = write
+	runErr := runSourceErr(t, `struct Point {
+	x: int
+	y: int
+}
+func sum(p: Point) -> int {
+	return p.x + p.y
+}
+s := "done"
+p := Point{x: 1, y: 2}
+if sum(p) == 3 {
+	print(sum(p))
+}
+print(s)`)
+	os.Stdout = stdout
+	write.Close()
+	out, err := io.ReadAll(read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runErr != nil {
+		t.Fatal(runErr)
+	}
+	if string(out) != "3\ndone\n" {
+		t.Errorf("expected only the program's output, got %q", out)
+	}
+}
@@ -0,0 +1,74 @@
+package interpreter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+)
+
+func TestInterpreter_SwitchCases(t *testing.T) {
+	interp := runSource(t, `
+a := 0
+b := 0
+c := 0
+for i in 0..5 {
+	switch i {
+	case 0, 1:
+		a = a + 1
+	case 2:
+		b = b + 1
+		fallthrough
+	case 3:
+		b = b + 10
+	default:
+		c = c + 1
+	}
+}
+`)
+	expectInt(t, interp, "a", 2)
+	expectInt(t, interp, "b", 21)
+	expectInt(t, interp, "c", 1)
+}
+
+func TestInterpreter_SwitchBreakAndContinue(t *testing.T) {
+	interp := runSource(t, `
+after := 0
+skipped := 0
+for i in 0..4 {
+	switch i {
+	case 1:
+		break
+	case 2:
+		continue
+	}
+	after = after + 1
+}
+for s in "abc" {
+	switch s {
+	case "b":
+		skipped = skipped + 1
+	}
+}
+`)
+	expectInt(t, interp, "after", 3)
+	expectInt(t, interp, "skipped", 1)
+}
+
+func TestParser_SwitchErrors(t *testing.T) {
+	cases := map[string]string{
+		"switch x {\ncase 1:\nprint(1)\ncase 2, 1:\nprint(2)\n}": "duplicate case",
+		"switch x {\ncase \"a\":\ncase \"a\":\n}":                "duplicate case",
+		"switch x {\ndefault:\ndefault:\n}":                      "multiple defaults",
+		"switch x {\ncase 1:\nfallthrough\n}":                    "final case",
+		"switch x {\ncase 1:\nfallthrough\nprint(1)\ncase 2:\n}": "out of place",
+		"fallthrough": "out of place",
+	}
+	for src, want := range cases {
+		_, err := parser.New(lexer.New(src)).Parse()
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parsing %q: expected error containing %q, got %v", src, want, err)
+		}
+	}
+}
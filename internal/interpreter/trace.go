@@ -0,0 +1,91 @@
+package interpreter
+
+import (
+	"github.com/ithinkiborkedit/niftelv2.git/internal/controlflow"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/function"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// DefaultTraceDepth is the number of calls a traceback shows unless
+// Interpreter.TraceDepth says otherwise.
+const DefaultTraceDepth = 20
+
+// frame is a call in progress. site is nil for calls the interpreter makes
+// itself, such as to a struct's iter method.
+type frame struct {
+	fn   function.Callable
+	site *ast.CallExpr
+}
+
+// call calls fn, recording the call so a runtime error raised inside it
+// carries a traceback, unless it would exceed the call depth limit.
+func (i *Interpreter) call(fn function.Callable, args []value.Value, typeArgs []*symtable.TypeSymbol, site *ast.CallExpr) controlflow.ExecResult {
+	if err := i.enter(); err != nil {
+		return controlflow.ExecResult{Err: i.traceback(err)}
+	}
+	i.frames = append(i.frames, frame{fn: fn, site: site})
+	defer func() {
+		i.frames = i.frames[:len(i.frames)-1]
+	}()
+	if i.CallHook != nil {
+		i.CallHook(i, fn, false)
+		defer i.CallHook(i, fn, true)
+	}
+	result := fn.Call(args, typeArgs, i)
+	if result.Err != nil {
+		if site != nil {
+			result.Err = runtimeError(result.Err, site)
+		}
+		result.Err = i.traceback(result.Err)
+	}
+	return result
+}
+
+// traceback gives err the calls in progress, innermost first, unless an
+// inner call already did.
+func (i *Interpreter) traceback(err error) error {
+	nifErr, ok := err.(*niferrors.NifError)
+	if !ok {
+		nifErr = &niferrors.NifError{Kind: niferrors.RuntimeError, Code: niferrors.CodeRuntime, Message: err.Error(), Err: err}
+	}
+	if nifErr.Trace != nil || i.TraceDepth <= 0 {
+		return nifErr
+	}
+	for idx := len(i.frames) - 1; idx >= 0; idx-- {
+		if len(nifErr.Trace) == i.TraceDepth {
+			nifErr.TraceElided = idx + 1
+			break
+		}
+		nifErr.Trace = append(nifErr.Trace, i.frames[idx].describe())
+	}
+	return nifErr
+}
+
+// CallStack describes the calls in progress, innermost first.
+func (i *Interpreter) CallStack() []niferrors.Frame {
+	stack := make([]niferrors.Frame, 0, len(i.frames))
+	for idx := len(i.frames) - 1; idx >= 0; idx-- {
+		stack = append(stack, i.frames[idx].describe())
+	}
+	return stack
+}
+
+// CallDepth is how many calls are in progress.
+func (i *Interpreter) CallDepth() int {
+	return len(i.frames)
+}
+
+func (f frame) describe() niferrors.Frame {
+	out := niferrors.Frame{Function: f.fn.Name(), Native: f.fn.IsNative()}
+	if !out.Native {
+		out.DefLine, out.DefColumn = f.fn.SourcePos()
+	}
+	if f.site != nil {
+		first, _ := ast.Bounds(f.site)
+		out.Line, out.Column, _, _ = niferrors.Span(first)
+	}
+	return out
+}
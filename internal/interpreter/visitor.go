@@ -12,6 +12,11 @@ type ExprVisitor interface {
 	VisitVariableExpr(expr *ast.VariableExpr) controlflow.ExecResult
 	VisitCallExpr(expr *ast.CallExpr) controlflow.ExecResult
 	VisitIndexExpr(expr *ast.IndexExpr) controlflow.ExecResult
+	VisitSliceExpr(expr *ast.SliceExpr) controlflow.ExecResult
+	VisitRangeExpr(expr *ast.RangeExpr) controlflow.ExecResult
+	VisitIfExpr(expr *ast.IfExpr) controlflow.ExecResult
+	VisitTernaryExpr(expr *ast.TernaryExpr) controlflow.ExecResult
+	VisitSpawnExpr(expr *ast.SpawnExpr) controlflow.ExecResult
 	VisitGetExpr(expr *ast.GetExpr) controlflow.ExecResult
 	VisitListExpr(expr *ast.ListExpr) controlflow.ExecResult
 	VisitDictExpr(expr *ast.DictExpr) controlflow.ExecResult
@@ -23,15 +28,21 @@ type StmtVisitor interface {
 	VisitVarStmt(expr *ast.VarStmt) error
 	VisitShortVarStmt(expr *ast.ShortVarStmt) error
 	VisitAssignStmt(expr *ast.AssignStmt) error
+	VisitCompoundAssignStmt(expr *ast.CompoundAssignStmt) error
 	VisitPrintStmt(expr *ast.PrintStmt) error
 	VisitExprStmt(expr *ast.ExprStmt) error
 	VisitIfStmt(expr *ast.IfStmt) error
 	VisitWhileStmt(expr *ast.WhileStmt) error
 	VisitForStmt(expr *ast.ForStmt) error
+	VisitForInStmt(expr *ast.ForInStmt) error
 	VisitBlockStmt(expr *ast.BlockStmt) error
 	VisitFuncStmt(expr *ast.FuncStmt) error
 	VisitStructStmt(expr *ast.StructStmt) error
 	VisitReturnStmt(expr *ast.ReturnStmt) error
 	VisitBreakStmt(expr *ast.BreakStmt) error
 	VisitContinueStmt(expr *ast.ContinueStmt) error
+	VisitSwitchStmt(expr *ast.SwitchStmt) error
+	VisitFallthroughStmt(expr *ast.FallthroughStmt) error
+	VisitSelectStmt(expr *ast.SelectStmt) error
+	VisitYieldStmt(expr *ast.YieldStmt) error
 }
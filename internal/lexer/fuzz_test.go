@@ -0,0 +1,94 @@
+package lexer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+)
+
+// addSeeds seeds f with the repo's Niftel programs.
+func addSeeds(f *testing.F) {
+	for _, pattern := range []string{"../../cmd/niftel/testdata/*.nif", "../format/testdata/*.input"} {
+		paths, _ := filepath.Glob(pattern)
+		for _, path := range paths {
+			if data, err := os.ReadFile(path); err == nil {
+				f.Add(string(data))
+			}
+		}
+	}
+	f.Add("s := \"a\\nb\" + 'c\n")
+	f.Add("x := 1 $ 2 /* open")
+	f.Add(`e := "" + '' + "\\"" // "`)
+}
+
+// FuzzLexer checks that every source lexes to a stream that reaches EOF,
+// with each token inside the source, and that a source that lexes without
+// errors has a string token for each of its string literals.
+func FuzzLexer(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, src string) {
+		lines := strings.Split(src, "\n")
+		l := New(src)
+		failed, strs := false, 0
+		// Each call consumes at least one rune, or returns EOF.
+		for calls := 0; ; calls++ {
+			if calls > utf8.RuneCountInString(src)+1 {
+				t.Fatalf("no EOF after %d tokens", calls)
+			}
+			tok, err := l.NextToken()
+			if err != nil {
+				failed = true
+				continue
+			}
+			if tok.Line < 1 || tok.Line > len(lines) {
+				t.Fatalf("token %q on line %d of %d", tok.Lexeme, tok.Line, len(lines))
+			}
+			if width := utf8.RuneCountInString(lines[tok.Line-1]); tok.Column < 0 || tok.Column > width {
+				t.Fatalf("token %q at column %d of line %d, which has %d", tok.Lexeme, tok.Column, tok.Line, width)
+			}
+			if tok.Type == token.TokenString {
+				strs++
+			}
+			if tok.Type == token.TokenEOF {
+				break
+			}
+		}
+		if want := stringLiterals(src); !failed && strs != want {
+			t.Fatalf("%d string tokens for %d string literals", strs, want)
+		}
+	})
+}
+
+// stringLiterals counts the string literals in src, outside comments.
+func stringLiterals(src string) int {
+	n := 0
+	for i := 0; i < len(src); i++ {
+		switch {
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				return n
+			}
+			i += end
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return n
+			}
+			i += end + 3
+		case src[i] == '"' || src[i] == '\'':
+			quote := src[i]
+			for i++; i < len(src) && src[i] != quote; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+			n++
+		}
+	}
+	return n
+}
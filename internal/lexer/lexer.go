@@ -1,21 +1,28 @@
 package lexer
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
 
 	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/trace"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
 )
 
+var log = trace.Logger(trace.Lexer)
+
 type Lexer struct {
 	source  string
 	start   int
 	current int
 	line    int
 	column  int
+	// comments collects the comments seen since the last token; lastLine is
+	// the line that token ended on, or 0 before the first token.
+	comments []token.Comment
+	lastLine int
 }
 
 type TokenSource interface {
@@ -23,24 +30,31 @@ type TokenSource interface {
 }
 
 var tokenKeyWords = map[string]token.TokenType{
-	"true":     token.TokenTrue,
-	"type":     token.TokenT,
-	"struct":   token.TokenStruct,
-	"import":   token.TokenImport,
-	"as":       token.TokenAs,
-	"nil":      token.TokenNil,
-	"false":    token.TokenFalse,
-	"if":       token.TokenIf,
-	"else":     token.TokenElse,
-	"for":      token.TokenFor,
-	"in":       token.TokenIn,
-	"var":      token.TokenVar,
-	"func":     token.TokenFunc,
-	"return":   token.TokenReturn,
-	"while":    token.TokenWhile,
-	"print":    token.TokenPrint,
-	"break":    token.TokenBreak,
-	"continue": token.TokenContinue,
+	"true":        token.TokenTrue,
+	"type":        token.TokenT,
+	"struct":      token.TokenStruct,
+	"import":      token.TokenImport,
+	"as":          token.TokenAs,
+	"nil":         token.TokenNil,
+	"false":       token.TokenFalse,
+	"if":          token.TokenIf,
+	"else":        token.TokenElse,
+	"for":         token.TokenFor,
+	"in":          token.TokenIn,
+	"var":         token.TokenVar,
+	"func":        token.TokenFunc,
+	"return":      token.TokenReturn,
+	"while":       token.TokenWhile,
+	"print":       token.TokenPrint,
+	"break":       token.TokenBreak,
+	"continue":    token.TokenContinue,
+	"switch":      token.TokenSwitch,
+	"case":        token.TokenCase,
+	"default":     token.TokenDefault,
+	"fallthrough": token.TokenFallthrough,
+	"spawn":       token.TokenSpawn,
+	"select":      token.TokenSelect,
+	"yield":       token.TokenYield,
 }
 
 func (l *Lexer) skipWhiteSpace() {
@@ -65,17 +79,34 @@ func (l *Lexer) NextToken() (token.Token, error) {
 		if err != nil {
 			return token.Token{}, err
 		}
-		if tok.Type == 0 || tok.Lexeme == "" {
+		if tok.Type == 0 {
 			continue
 		}
-		return tok, nil
+		log.Debug("token", "type", tok.Type, "lexeme", tok.Lexeme, "line", tok.Line, "column", tok.Column)
+		return l.attachComments(tok), nil
 	}
-	return token.Token{
+	return l.attachComments(token.Token{
 		Type:   token.TokenEOF,
 		Lexeme: "",
 		Line:   l.line,
 		Column: l.column,
-	}, nil
+	}), nil
+}
+
+// attachComments hands the comments seen since the last token to tok.
+func (l *Lexer) attachComments(tok token.Token) token.Token {
+	tok.Comments, l.comments = l.comments, nil
+	l.lastLine = l.line
+	return tok
+}
+
+// addComment records the comment that starts at offset start on line.
+func (l *Lexer) addComment(start, line int) {
+	l.comments = append(l.comments, token.Comment{
+		Text:   l.source[start:l.current],
+		Line:   line,
+		Inline: line == l.lastLine,
+	})
 }
 
 func (l *Lexer) makeToken(tt token.TokenType) token.Token {
@@ -89,7 +120,7 @@ func (l *Lexer) makeToken(tt token.TokenType) token.Token {
 }
 
 func New(source string) *Lexer {
-	fmt.Printf("Raw source string: %q\n", source)
+	log.Debug("new lexer", "bytes", len(source))
 	return &Lexer{
 		source:  source,
 		start:   0,
@@ -99,6 +130,14 @@ func New(source string) *Lexer {
 	}
 }
 
+// NewAt returns a lexer for source that starts on the given line, for a
+// piece of a larger file.
+func NewAt(source string, line int) *Lexer {
+	l := New(source)
+	l.line = line
+	return l
+}
+
 func (l *Lexer) isAtEnd() bool {
 	return l.current >= len(l.source)
 }
@@ -130,39 +169,54 @@ func (l *Lexer) match(expected rune) bool {
 	return true
 }
 
+// skipLineComment skips to the end of the line. The newline itself is left
+// for scanToken, which counts it.
 func (l *Lexer) skipLineComment() {
 	for !l.isAtEnd() {
-		r, _ := utf8.DecodeLastRuneInString(l.source[l.current:])
+		r, _ := utf8.DecodeRuneInString(l.source[l.current:])
 		if r == '\n' {
 			break
 		}
 		l.advance()
 	}
-	if !l.isAtEnd() {
-		l.advance()
-	}
 }
 
 func (l *Lexer) skipBlockComment() {
 	for !l.isAtEnd() {
 		r, _ := utf8.DecodeRuneInString(l.source[l.current:])
-		if r == '*' && l.peekNext() == '/' {
+		if r == '*' && l.peekAfter() == '/' {
 			l.advance()
 			l.advance()
 			break
 		}
+		l.advance()
 		if r == '\n' {
 			l.line++
 			l.column = 0
 		}
-		l.advance()
 	}
 }
 
-// func (l *Lexer) errorf(format string, args ...interface{}) {
-// 	msg := fmt.Sprintf(format, args...)
-// 	panic(fmt.Sprintf("Lexer error at line %d, col %d: %s", l.line, l.column, msg))
-// }
+// errorf returns a lex error spanning the source from the start of the
+// current token to the current position.
+func (l *Lexer) errorf(code niferrors.Code, format string, args ...any) error {
+	tok := token.Token{Lexeme: l.source[l.start:l.current], Line: l.line, Column: l.column}
+	return niferrors.New(niferrors.LexError, code, tok, format, args...)
+}
+
+// stringError returns a lex error spanning a string literal from its opening
+// quote at line, col to the current position.
+func (l *Lexer) stringError(line, col int, code niferrors.Code, msg string) error {
+	return &niferrors.NifError{
+		Kind:      niferrors.LexError,
+		Code:      code,
+		Message:   msg,
+		Line:      line,
+		Column:    col,
+		EndLine:   l.line,
+		EndColumn: l.column + 1,
+	}
+}
 
 func (l *Lexer) peekNext() rune {
 	if l.current >= len(l.source) {
@@ -172,21 +226,26 @@ func (l *Lexer) peekNext() rune {
 	return r
 }
 
+func (l *Lexer) peekAfter() rune {
+	if l.current >= len(l.source) {
+		return 0
+	}
+	_, width := utf8.DecodeRuneInString(l.source[l.current:])
+	if l.current+width >= len(l.source) {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(l.source[l.current+width:])
+	return r
+}
+
 func (l *Lexer) string(quote rune) (token.Token, error) {
 	l.current = l.start
 	var sb strings.Builder
 	startLine, startColumn := l.line, l.column
 	for !l.isAtEnd() {
-		fmt.Printf("string loop: l.current=%d remaining source=%q\n", l.current, l.source[l.current:])
-		if l.current < len(l.source) {
-			fmt.Printf("char at l.current=%d char=%q\n", l.current, l.source[l.current])
-		} else {
-			fmt.Println("l.current beyond end of source")
-		}
 		r, _ := utf8.DecodeRuneInString(l.source[l.current:])
 		if r == quote {
 			l.advance()
-			fmt.Printf("after advancing l.current=%d\n", l.current)
 			return token.Token{
 				Type:   token.TokenString,
 				Lexeme: sb.String(),
@@ -196,14 +255,17 @@ func (l *Lexer) string(quote rune) (token.Token, error) {
 		}
 
 		if r == '\n' {
+			sb.WriteRune(r)
+			l.advance()
 			l.line++
 			l.column = 0
+			continue
 		}
 
 		if r == '\\' {
 			l.advance()
 			if l.isAtEnd() {
-				return token.Token{}, fmt.Errorf("unterminated escape sequance")
+				return token.Token{}, l.stringError(startLine, startColumn, niferrors.CodeBadEscape, "unterminated escape sequence")
 			}
 			escRune, _ := utf8.DecodeRuneInString(l.source[l.current:])
 			l.advance()
@@ -211,8 +273,6 @@ func (l *Lexer) string(quote rune) (token.Token, error) {
 			switch escRune {
 			case 'n':
 				sb.WriteRune('\n')
-				l.line++
-				l.column = 0
 			case 'r':
 				sb.WriteRune('\r')
 			case 't':
@@ -232,7 +292,7 @@ func (l *Lexer) string(quote rune) (token.Token, error) {
 			l.advance()
 		}
 	}
-	return token.Token{}, fmt.Errorf("undetermined string literal")
+	return token.Token{}, l.stringError(startLine, startColumn, niferrors.CodeUnterminatedString, "unterminated string literal")
 }
 
 func (l *Lexer) number() (token.Token, error) {
@@ -241,7 +301,7 @@ func (l *Lexer) number() (token.Token, error) {
 	for !l.isAtEnd() {
 		r, _ := utf8.DecodeRuneInString(l.source[l.current:])
 		if r == '.' {
-			if hasDot {
+			if hasDot || !unicode.IsDigit(l.peekAfter()) {
 				break
 			}
 			hasDot = true
@@ -255,7 +315,7 @@ func (l *Lexer) number() (token.Token, error) {
 	if hasDot {
 		val, err := strconv.ParseFloat(lexeme, 64)
 		if err != nil {
-			return token.Token{}, fmt.Errorf("invalid float literal: %s", lexeme)
+			return token.Token{}, l.errorf(niferrors.CodeBadNumber, "invalid float literal: %s", lexeme)
 		}
 		return token.Token{
 			Type:   token.TokenFloat,
@@ -267,7 +327,7 @@ func (l *Lexer) number() (token.Token, error) {
 	} else {
 		val, err := strconv.ParseInt(lexeme, 10, 64)
 		if err != nil {
-			return token.Token{}, fmt.Errorf("invalid int literal: %s", lexeme)
+			return token.Token{}, l.errorf(niferrors.CodeBadNumber, "invalid int literal: %s", lexeme)
 		}
 		return token.Token{
 			Type:   token.TokenNumber,
@@ -330,8 +390,10 @@ func lookupIdentifier(lexeme string) token.TokenType {
 }
 
 func (l *Lexer) scanToken() (token.Token, error) {
+	// begin is where ch starts: utf8.RuneLen(ch) is not its width when the
+	// source is not valid UTF-8.
+	begin := l.current
 	ch := l.advance()
-	fmt.Printf("scanToken advanced: l.current=%d char=%q\n", l.current, l.source)
 	switch ch {
 	case '(':
 		return l.makeToken(token.TokenLParen), nil
@@ -348,6 +410,12 @@ func (l *Lexer) scanToken() (token.Token, error) {
 	case ',':
 		return l.makeToken(token.TokenComma), nil
 	case '.':
+		if l.match('.') {
+			if l.match('=') {
+				return l.makeToken(token.TokenDotDotEq), nil
+			}
+			return l.makeToken(token.TokenDotDot), nil
+		}
 		return l.makeToken(token.TokenDot), nil
 	case ';':
 		return l.makeToken(token.TokenSemicolon), nil
@@ -358,10 +426,15 @@ func (l *Lexer) scanToken() (token.Token, error) {
 			return l.makeToken(token.TokenColon), nil
 		}
 	case '+':
+		if l.match('=') {
+			return l.makeToken(token.TokenPlusEq), nil
+		}
 		return l.makeToken(token.TokenPlus), nil
 	case '-':
 		if l.match('>') {
 			return l.makeToken(token.TokenArrow), nil
+		} else if l.match('=') {
+			return l.makeToken(token.TokenMinEq), nil
 		} else {
 			return l.makeToken(token.TokenMinus), nil
 		}
@@ -369,11 +442,15 @@ func (l *Lexer) scanToken() (token.Token, error) {
 		return l.makeToken(token.TokenStar), nil
 	case '/':
 		if l.match('/') {
+			start, line := l.current-2, l.line
 			l.skipLineComment()
+			l.addComment(start, line)
 			l.start = l.current
 			return l.scanToken()
 		} else if l.match('*') {
+			start, line := l.current-2, l.line
 			l.skipBlockComment()
+			l.addComment(start, line)
 			l.start = l.current
 			return l.scanToken()
 		} else {
@@ -394,13 +471,23 @@ func (l *Lexer) scanToken() (token.Token, error) {
 			return l.makeToken(token.TokenBang), nil
 		}
 	case '<':
-		if l.match('=') {
+		if l.match('<') {
+			if l.match('=') {
+				return l.makeToken(token.TokenShlEq), nil
+			}
+			return l.makeToken(token.TokenShl), nil
+		} else if l.match('=') {
 			return l.makeToken(token.TokenLessEq), nil
 		} else {
 			return l.makeToken(token.TokenLess), nil
 		}
 	case '>':
-		if l.match('=') {
+		if l.match('>') {
+			if l.match('=') {
+				return l.makeToken(token.TokenShrEq), nil
+			}
+			return l.makeToken(token.TokenShr), nil
+		} else if l.match('=') {
 			return l.makeToken(token.TokenGreaterEq), nil
 		} else {
 			return l.makeToken(token.TokenGreater), nil
@@ -408,19 +495,30 @@ func (l *Lexer) scanToken() (token.Token, error) {
 	case '&':
 		if l.match('&') {
 			return l.makeToken(token.TokenAnd), nil
+		} else if l.match('=') {
+			return l.makeToken(token.TokenAmperEq), nil
 		} else {
-			return token.Token{}, fmt.Errorf("unexpected character: %q", ch)
+			return l.makeToken(token.TokenAmper), nil
 		}
 	case '|':
 		if l.match('|') {
 			return l.makeToken(token.TokenOr), nil
+		} else if l.match('=') {
+			return l.makeToken(token.TokenPipeEq), nil
 		} else {
-			return token.Token{}, fmt.Errorf("unexpected character: %q", ch)
+			return l.makeToken(token.TokenPipe), nil
 		}
+	case '^':
+		if l.match('=') {
+			return l.makeToken(token.TokenCaretEq), nil
+		}
+		return l.makeToken(token.TokenCaret), nil
+	case '~':
+		return l.makeToken(token.TokenTilde), nil
+	case '?':
+		return l.makeToken(token.TokenQuestion), nil
 	case '"', '\'':
-		fmt.Printf("scanToken start string literal: l.current=%d char=%q\n", l.current, l.source)
 		l.start = l.current
-		fmt.Printf("scanToken string literal: l.current=%d char=%q\n", l.current, l.current)
 		return l.string(ch)
 	case '\n':
 		l.line++
@@ -429,17 +527,16 @@ func (l *Lexer) scanToken() (token.Token, error) {
 		return l.scanToken()
 	default:
 		if unicode.IsDigit(ch) {
-			l.start = l.current - utf8.RuneLen(ch)
+			l.start = begin
 			return l.number()
 		} else if unicode.IsLetter(ch) || ch == '_' {
-			l.start = l.current - utf8.RuneLen(ch)
+			l.start = begin
 			return l.identifier()
-		} else if ch == 0 {
-			return token.Token{}, nil
-
-		} else {
+		} else if ch == 0 || unicode.IsSpace(ch) {
 			return token.Token{}, nil
 		}
+		l.start = begin
+		return token.Token{}, l.errorf(niferrors.CodeUnexpectedChar, "unexpected character %q", ch)
 	}
 
 }
@@ -72,3 +72,104 @@ struct Person {
 		}
 	}
 }
+
+func TestLexer_RangeTokens(t *testing.T) {
+	lex := New("0..10 1..=5 1.5")
+	want := []token.TokenType{
+		token.TokenNumber, token.TokenDotDot, token.TokenNumber,
+		token.TokenNumber, token.TokenDotDotEq, token.TokenNumber,
+		token.TokenFloat, token.TokenEOF,
+	}
+	for idx, tt := range want {
+		tok, err := lex.NextToken()
+		if err != nil {
+			t.Fatalf("lexer error %v", err)
+		}
+		if tok.Type != tt {
+			t.Fatalf("token %d: expected %v, got %v (%q)", idx, tt, tok.Type, tok.Lexeme)
+		}
+	}
+}
+
+func TestLexer_BitwiseTokens(t *testing.T) {
+	lex := New("a & b | c ^ ~d << 1 >> 2 &= |= ^= <<= >>= += -= && ||")
+	want := []token.TokenType{
+		token.TokenIdentifier, token.TokenAmper, token.TokenIdentifier, token.TokenPipe,
+		token.TokenIdentifier, token.TokenCaret, token.TokenTilde, token.TokenIdentifier,
+		token.TokenShl, token.TokenNumber, token.TokenShr, token.TokenNumber,
+		token.TokenAmperEq, token.TokenPipeEq, token.TokenCaretEq, token.TokenShlEq, token.TokenShrEq,
+		token.TokenPlusEq, token.TokenMinEq, token.TokenAnd, token.TokenOr, token.TokenEOF,
+	}
+	for idx, tt := range want {
+		tok, err := lex.NextToken()
+		if err != nil {
+			t.Fatalf("lexer error %v", err)
+		}
+		if tok.Type != tt {
+			t.Fatalf("token %d: expected %v, got %v (%q)", idx, tt, tok.Type, tok.Lexeme)
+		}
+	}
+}
+
+func TestLexer_EmptyStrings(t *testing.T) {
+	lex := New(`x := "" + ''`)
+	want := []token.TokenType{
+		token.TokenIdentifier, token.TokenColonEqual, token.TokenString,
+		token.TokenPlus, token.TokenString, token.TokenEOF,
+	}
+	for idx, tt := range want {
+		tok, err := lex.NextToken()
+		if err != nil {
+			t.Fatalf("lexer error %v", err)
+		}
+		if tok.Type != tt {
+			t.Fatalf("token %d: expected %v, got %v (%q)", idx, tt, tok.Type, tok.Lexeme)
+		}
+		if tt == token.TokenString && tok.Lexeme != "" {
+			t.Fatalf("token %d: expected an empty string, got %q", idx, tok.Lexeme)
+		}
+	}
+}
+
+func TestLexer_LineCommentEndsAtNewline(t *testing.T) {
+	lex := New("a := 3 // note: x\nb\n")
+	want := []struct {
+		typ  token.TokenType
+		line int
+	}{
+		{token.TokenIdentifier, 1}, {token.TokenColonEqual, 1}, {token.TokenNumber, 1},
+		{token.TokenIdentifier, 2}, {token.TokenEOF, 3},
+	}
+	for idx, w := range want {
+		tok, err := lex.NextToken()
+		if err != nil {
+			t.Fatalf("lexer error %v", err)
+		}
+		if tok.Type != w.typ || tok.Line != w.line {
+			t.Fatalf("token %d: expected %v on line %d, got %v (%q) on line %d", idx, w.typ, w.line, tok.Type, tok.Lexeme, tok.Line)
+		}
+	}
+}
+
+func TestLexer_Comments(t *testing.T) {
+	lex := New("/* head\n*/ a := 1 // one\n// own\nb /* mid */ c\n")
+	want := []struct {
+		lexeme   string
+		comments []token.Comment
+	}{
+		{"a", []token.Comment{{Text: "/* head\n*/", Line: 1}}},
+		{":=", nil},
+		{"1", nil},
+		{"b", []token.Comment{{Text: "// one", Line: 2, Inline: true}, {Text: "// own", Line: 3}}},
+		{"c", []token.Comment{{Text: "/* mid */", Line: 4, Inline: true}}},
+	}
+	for idx, w := range want {
+		tok, err := lex.NextToken()
+		if err != nil {
+			t.Fatalf("lexer error %v", err)
+		}
+		if tok.Lexeme != w.lexeme || fmt.Sprint(tok.Comments) != fmt.Sprint(w.comments) {
+			t.Fatalf("token %d: expected %q with comments %v, got %q with %v", idx, w.lexeme, w.comments, tok.Lexeme, tok.Comments)
+		}
+	}
+}
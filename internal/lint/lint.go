@@ -0,0 +1,203 @@
+// Package lint runs style and correctness rules over a parsed program. Each
+// rule walks the AST and reports diagnostics; rules can be turned off in a
+// config file or for a single line with a // nolint:rule comment.
+package lint
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/typechecker"
+)
+
+// Diagnostic is a problem reported by a rule.
+type Diagnostic struct {
+	Rule    string `json:"rule"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", d.Line, d.Column, d.Message, d.Rule)
+}
+
+// Rule is one lint check.
+type Rule struct {
+	Name string
+	Doc  string
+	Run  func(p *Pass)
+}
+
+// Rules lists every rule, in the order they run.
+var Rules = []*Rule{
+	unusedVariable,
+	unusedParameter,
+	shadow,
+	unusedField,
+	selfAssign,
+	constantCondition,
+	nullCompare,
+}
+
+// RuleByName returns the rule called name, or nil.
+func RuleByName(name string) *Rule {
+	for _, rule := range Rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Pass is what a rule sees of the program being linted.
+type Pass struct {
+	Stmts []ast.Stmt
+	// Types holds the static types the type checker found, by expression.
+	Types map[ast.Expr]*symtable.TypeSymbol
+
+	rule  *Rule
+	decls []*decl
+	diags *[]Diagnostic
+}
+
+// Reportf reports a diagnostic for the running rule at line:column.
+func (p *Pass) Reportf(line, column int, format string, args ...any) {
+	*p.diags = append(*p.diags, Diagnostic{
+		Rule:    p.rule.Name,
+		Line:    line,
+		Column:  column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Config selects the rules to run. Rules not mentioned are enabled.
+type Config struct {
+	Rules map[string]bool `json:"rules"`
+}
+
+// Enabled reports whether the rule called name should run.
+func (c *Config) Enabled(name string) bool {
+	if c == nil {
+		return true
+	}
+	enabled, ok := c.Rules[name]
+	return !ok || enabled
+}
+
+// LoadConfig reads a JSON config file such as
+//
+//	{"rules": {"shadow": false}}
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for name := range cfg.Rules {
+		if RuleByName(name) == nil {
+			return nil, fmt.Errorf("%s: unknown lint rule '%s'", path, name)
+		}
+	}
+	return &cfg, nil
+}
+
+// Lint runs the enabled rules over stmts, parsed from source, and returns
+// their diagnostics sorted by position. Diagnostics silenced by nolint
+// comments in source are dropped.
+func Lint(source string, stmts []ast.Stmt, cfg *Config) []Diagnostic {
+	// Type errors are the checker's to report; the types it did find are
+	// still useful here.
+	checker := typechecker.NewChecker()
+	checker.Types = make(map[ast.Expr]*symtable.TypeSymbol)
+	checker.Check(stmts)
+
+	var diags []Diagnostic
+	pass := &Pass{Stmts: stmts, Types: checker.Types, decls: declarations(stmts), diags: &diags}
+	for _, rule := range Rules {
+		if !cfg.Enabled(rule.Name) {
+			continue
+		}
+		pass.rule = rule
+		rule.Run(pass)
+	}
+
+	ignored := nolintLines(source)
+	diags = slices.DeleteFunc(diags, func(d Diagnostic) bool {
+		rules, ok := ignored[d.Line]
+		return ok && (rules == nil || slices.Contains(rules, d.Rule))
+	})
+	slices.SortStableFunc(diags, func(a, b Diagnostic) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+	return diags
+}
+
+// nolintLines finds // nolint comments in source. The result maps a line to
+// the rules silenced on it; nil means every rule. A comment after code
+// applies to its own line, a comment on a line by itself to the next line.
+func nolintLines(source string) map[int][]string {
+	ignored := make(map[int][]string)
+	for idx, line := range strings.Split(source, "\n") {
+		at := commentStart(line)
+		if at < 0 {
+			continue
+		}
+		text := strings.TrimSpace(line[at+2:])
+		if !strings.HasPrefix(text, "nolint") {
+			continue
+		}
+		var rules []string
+		if names, ok := strings.CutPrefix(text, "nolint:"); ok {
+			// Anything after the rule list is an explanation.
+			names, _, _ = strings.Cut(names, " ")
+			for _, name := range strings.Split(names, ",") {
+				if name != "" {
+					rules = append(rules, name)
+				}
+			}
+			if rules == nil {
+				continue
+			}
+		} else if text != "nolint" && !strings.HasPrefix(text, "nolint ") {
+			continue
+		}
+		target := idx + 1
+		if strings.TrimSpace(line[:at]) == "" {
+			target++
+		}
+		ignored[target] = rules
+	}
+	return ignored
+}
+
+// commentStart returns the index of the // starting a comment in line, or
+// -1. Slashes inside string literals do not count.
+func commentStart(line string) int {
+	var quote byte
+	for idx := 0; idx < len(line); idx++ {
+		c := line[idx]
+		switch {
+		case quote != 0 && c == '\\':
+			idx++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '/' && idx+1 < len(line) && line[idx+1] == '/':
+			return idx
+		}
+	}
+	return -1
+}
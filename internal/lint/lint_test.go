@@ -0,0 +1,179 @@
+package lint_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lint"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+)
+
+func lintSource(t *testing.T, source string, cfg *lint.Config) []lint.Diagnostic {
+	t.Helper()
+	value.BuiltinTypesInit()
+	stmts, err := parser.New(lexer.New(source)).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	return lint.Lint(source, stmts, cfg)
+}
+
+func expectRule(t *testing.T, diags []lint.Diagnostic, rule, want string) {
+	t.Helper()
+	for _, d := range diags {
+		if d.Rule == rule && strings.Contains(d.String(), want) {
+			return
+		}
+	}
+	t.Errorf("expected %s diagnostic containing %q, got %v", rule, want, diags)
+}
+
+func TestLint_Rules(t *testing.T) {
+	cases := []struct {
+		name   string
+		rule   string
+		source string
+		want   string
+	}{
+		{"unused variable", "unused-variable", `func f() {
+	x := 1
+}`, "2:2: variable 'x' is declared but never used"},
+		{"unused loop variable", "unused-variable", `func f() {
+	for i in 0..3 {
+		print(1)
+	}
+}`, "variable 'i' is declared but never used"},
+		{"unused parameter", "unused-parameter", `func f(a: int, b: int) -> int {
+	return a
+}`, "parameter 'b' is never used"},
+		{"shadow", "shadow", `x := 1
+func f() {
+	x := 2
+	print(x)
+}`, "3:2: declaration of 'x' shadows the one on line 1"},
+		{"shadowing parameter", "shadow", `func f(a: int) {
+	if true {
+		a := 2
+		print(a)
+	}
+}`, "declaration of 'a' shadows the one on line 1"},
+		{"unused field", "unused-field", `struct P {
+	x: int
+	y: int
+	func getX() -> int {
+		return self.x
+	}
+}`, "3:2: field 'y' of struct 'P' is never read"},
+		{"self assignment", "self-assign", `x := 1
+x = x`, "2:1: self-assignment of 'x'"},
+		{"constant if", "constant-condition", `if 1 < 2 {
+	print(1)
+}`, "condition is constant"},
+		{"constant while", "constant-condition", `while false {
+	print(1)
+}`, "condition is constant"},
+		{"null compare", "null-compare", `n := 3
+b := n == null`, "int value is never null; comparison is always false"},
+		{"null not equal", "null-compare", `s := "a"
+b := s != null`, "string value is never null; comparison is always true"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			expectRule(t, lintSource(t, tc.source, nil), tc.rule, tc.want)
+		})
+	}
+}
+
+func TestLint_CleanProgram(t *testing.T) {
+	diags := lintSource(t, `struct P {
+	x: int
+}
+total := 0
+func add(p: P, _unused: int) -> int {
+	sum := 0
+	for i := 0; i < p.x; i += 1 {
+		sum += i
+	}
+	return sum
+}
+func later() -> int {
+	get := func() {
+		return value
+	}
+	value := 4
+	return get()
+}
+while true {
+	break
+}
+total = add(P{x: 3}, 0)`, nil)
+	if len(diags) > 0 {
+		t.Errorf("unexpected diagnostics: %v", diags)
+	}
+}
+
+func TestLint_Nolint(t *testing.T) {
+	diags := lintSource(t, `func f(a: int) {
+	x := 1 // nolint:unused-variable
+	y := 2 // nolint:shadow
+	// nolint
+	z := 3
+	s := "// nolint"
+}`, nil)
+	for _, d := range diags {
+		switch {
+		case d.Line == 2, d.Line == 5:
+			t.Errorf("diagnostic should be silenced: %v", d)
+		}
+	}
+	expectRule(t, diags, "unused-variable", "3:2: variable 'y'")
+	expectRule(t, diags, "unused-variable", "6:2: variable 's'")
+	expectRule(t, diags, "unused-parameter", "parameter 'a'")
+}
+
+func TestLint_Config(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lint.json")
+	if err := os.WriteFile(path, []byte(`{"rules": {"unused-variable": false}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := lint.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	diags := lintSource(t, `func f(a: int) {
+	x := 1
+}`, cfg)
+	if len(diags) != 1 || diags[0].Rule != "unused-parameter" {
+		t.Errorf("expected only the unused parameter, got %v", diags)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"rules": {"no-such-rule": false}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lint.LoadConfig(path); err == nil || !strings.Contains(err.Error(), "unknown lint rule 'no-such-rule'") {
+		t.Errorf("expected an unknown rule error, got %v", err)
+	}
+}
+
+func TestLint_JSONOutput(t *testing.T) {
+	diags := lintSource(t, `func f() {
+	x := 1
+}`, nil)
+	var buf bytes.Buffer
+	if err := lint.WriteJSON(&buf, "a.nif", diags); err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if len(got) != 1 || got[0]["file"] != "a.nif" || got[0]["rule"] != "unused-variable" || got[0]["line"] != float64(2) {
+		t.Errorf("unexpected JSON output: %s", buf.String())
+	}
+}
@@ -0,0 +1,33 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// WriteText writes diags one per line as path:line:col: message (rule).
+func WriteText(w io.Writer, path string, diags []Diagnostic) error {
+	for _, d := range diags {
+		if _, err := fmt.Fprintf(w, "%s:%v\n", path, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes diags as a JSON array of objects with file, rule, line,
+// column and message keys.
+func WriteJSON(w io.Writer, path string, diags []Diagnostic) error {
+	type fileDiagnostic struct {
+		File string `json:"file"`
+		Diagnostic
+	}
+	out := make([]fileDiagnostic, 0, len(diags))
+	for _, d := range diags {
+		out = append(out, fileDiagnostic{File: path, Diagnostic: d})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,184 @@
+package lint
+
+import (
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+)
+
+// The unused rules skip globals: a later chunk of a REPL session or file may
+// read them. Names starting with _ are never reported as unused or
+// shadowing.
+
+var unusedVariable = &Rule{
+	Name: "unused-variable",
+	Doc:  "local variables that are never read",
+	Run: func(p *Pass) {
+		for _, d := range p.decls {
+			if d.kind == declVar && !d.global && !d.used && !d.ignored() {
+				p.Reportf(d.name.Line, d.name.Column, "variable '%s' is declared but never used", d.name.Lexeme)
+			}
+		}
+	},
+}
+
+var unusedParameter = &Rule{
+	Name: "unused-parameter",
+	Doc:  "function parameters that are never read",
+	Run: func(p *Pass) {
+		for _, d := range p.decls {
+			if d.kind == declParam && !d.used && !d.ignored() {
+				p.Reportf(d.name.Line, d.name.Column, "parameter '%s' is never used", d.name.Lexeme)
+			}
+		}
+	},
+}
+
+var shadow = &Rule{
+	Name: "shadow",
+	Doc:  "declarations that hide a variable of an enclosing scope",
+	Run: func(p *Pass) {
+		for _, d := range p.decls {
+			if d.shadows != nil && !d.ignored() {
+				p.Reportf(d.name.Line, d.name.Column, "declaration of '%s' shadows the one on line %d", d.name.Lexeme, d.shadows.name.Line)
+			}
+		}
+	},
+}
+
+var unusedField = &Rule{
+	Name: "unused-field",
+	Doc:  "struct fields that are never read",
+	Run: func(p *Pass) {
+		// Field reads are matched by name: without the object's type a read
+		// of p.x counts for every struct with a field x.
+		read := make(map[string]bool)
+		inspectAll(p.Stmts, func(node ast.Node) bool {
+			if get, ok := node.(*ast.GetExpr); ok {
+				read[get.Name.Lexeme] = true
+			}
+			return true
+		})
+		inspectAll(p.Stmts, func(node ast.Node) bool {
+			st, ok := node.(*ast.StructStmt)
+			if !ok {
+				return true
+			}
+			for _, field := range st.Fields {
+				for _, name := range field.Names {
+					if !read[name.Lexeme] {
+						p.Reportf(name.Line, name.Column, "field '%s' of struct '%s' is never read", name.Lexeme, st.Name.Lexeme)
+					}
+				}
+			}
+			return true
+		})
+	},
+}
+
+var selfAssign = &Rule{
+	Name: "self-assign",
+	Doc:  "assignments of a variable to itself",
+	Run: func(p *Pass) {
+		inspectAll(p.Stmts, func(node ast.Node) bool {
+			if assign, ok := node.(*ast.AssignStmt); ok {
+				if v, ok := assign.Value.(*ast.VariableExpr); ok && v.Name.Lexeme == assign.Name.Lexeme {
+					p.Reportf(assign.Name.Line, assign.Name.Column, "self-assignment of '%s'", assign.Name.Lexeme)
+				}
+			}
+			return true
+		})
+	},
+}
+
+var constantCondition = &Rule{
+	Name: "constant-condition",
+	Doc:  "conditions that do not depend on any variable; while true is allowed",
+	Run: func(p *Pass) {
+		check := func(cond ast.Expr) {
+			if cond != nil && isConstant(cond) {
+				line, col := cond.Pos()
+				p.Reportf(line, col, "condition is constant")
+			}
+		}
+		inspectAll(p.Stmts, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.IfStmt:
+				check(n.Conditon)
+			case *ast.IfExpr:
+				check(n.Conditon)
+			case *ast.TernaryExpr:
+				check(n.Conditon)
+			case *ast.WhileStmt:
+				if !isLiteral(n.Conditon, token.TokenTrue) {
+					check(n.Conditon)
+				}
+			case *ast.ForStmt:
+				if !isLiteral(n.CondExpr, token.TokenTrue) {
+					check(n.CondExpr)
+				}
+			}
+			return true
+		})
+	},
+}
+
+var nullCompare = &Rule{
+	Name: "null-compare",
+	Doc:  "comparisons to null of int, float, string or bool values",
+	Run: func(p *Pass) {
+		inspectAll(p.Stmts, func(node ast.Node) bool {
+			bin, ok := node.(*ast.BinaryExpr)
+			if !ok || (bin.Operator.Type != token.TokenEqality && bin.Operator.Type != token.TokenBangEqal) {
+				return true
+			}
+			other := bin.Left
+			if isNullExpr(bin.Left) {
+				other = bin.Right
+			} else if !isNullExpr(bin.Right) {
+				return true
+			}
+			typ := p.Types[other]
+			if typ == nil {
+				return true
+			}
+			switch typ.SymName {
+			case "int", "float", "string", "bool":
+				result := bin.Operator.Type == token.TokenBangEqal
+				p.Reportf(bin.Operator.Line, bin.Operator.Column, "%s value is never null; comparison is always %t", typ.SymName, result)
+			}
+			return true
+		})
+	},
+}
+
+func inspectAll(stmts []ast.Stmt, f func(ast.Node) bool) {
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, f)
+	}
+}
+
+// isConstant reports whether expr is built from literals only.
+func isConstant(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.LiteralExpr:
+		return true
+	case *ast.UnaryExpr:
+		return isConstant(e.Right)
+	case *ast.BinaryExpr:
+		return isConstant(e.Left) && isConstant(e.Right)
+	}
+	return false
+}
+
+func isLiteral(expr ast.Expr, typ token.TokenType) bool {
+	lit, ok := expr.(*ast.LiteralExpr)
+	return ok && lit.Value.Type == typ
+}
+
+// isNullExpr matches null, which parses as a name, and null literals.
+func isNullExpr(expr ast.Expr) bool {
+	if v, ok := expr.(*ast.VariableExpr); ok {
+		return v.Name.Lexeme == "null"
+	}
+	return isLiteral(expr, token.TokenNull) || isLiteral(expr, token.TokenNil)
+}
@@ -0,0 +1,227 @@
+package lint
+
+import (
+	"strings"
+
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+)
+
+type declKind int
+
+const (
+	declVar declKind = iota
+	declParam
+	declFunc
+)
+
+// decl is a name declared by the program, with what the lint rules need to
+// know about it.
+type decl struct {
+	name   token.Token
+	kind   declKind
+	global bool
+	// used is set once the name is read. Assignments do not count.
+	used bool
+	// shadows is the declaration of the same name in an enclosing scope that
+	// this one hides, if any.
+	shadows *decl
+}
+
+// ignored reports whether the name opts out of the unused and shadow rules.
+func (d *decl) ignored() bool {
+	return strings.HasPrefix(d.name.Lexeme, "_")
+}
+
+type lintScope struct {
+	names map[string]*decl
+	// later holds names read from a nested function before any visible
+	// declaration; the function may run after the scope declares them.
+	later map[string]bool
+}
+
+type binder struct {
+	scopes []*lintScope
+	decls  []*decl
+}
+
+// declarations walks stmts with lexical scoping and returns every
+// declaration in them.
+func declarations(stmts []ast.Stmt) []*decl {
+	b := &binder{}
+	b.push()
+	b.stmts(stmts)
+	return b.decls
+}
+
+func (b *binder) push() {
+	b.scopes = append(b.scopes, &lintScope{names: map[string]*decl{}, later: map[string]bool{}})
+}
+
+func (b *binder) pop() {
+	b.scopes = b.scopes[:len(b.scopes)-1]
+}
+
+func (b *binder) declare(name token.Token, kind declKind) {
+	current := b.scopes[len(b.scopes)-1]
+	d := &decl{name: name, kind: kind, global: len(b.scopes) == 1, used: current.later[name.Lexeme]}
+	if kind != declFunc {
+		for idx := len(b.scopes) - 2; idx >= 0; idx-- {
+			if outer, ok := b.scopes[idx].names[name.Lexeme]; ok {
+				d.shadows = outer
+				break
+			}
+		}
+	}
+	current.names[name.Lexeme] = d
+	b.decls = append(b.decls, d)
+}
+
+func (b *binder) use(name string) {
+	for idx := len(b.scopes) - 1; idx >= 0; idx-- {
+		if d, ok := b.scopes[idx].names[name]; ok {
+			d.used = true
+			return
+		}
+	}
+	for _, sc := range b.scopes {
+		sc.later[name] = true
+	}
+}
+
+func (b *binder) stmts(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		b.stmt(stmt)
+	}
+}
+
+func (b *binder) block(stmts []ast.Stmt) {
+	b.push()
+	b.stmts(stmts)
+	b.pop()
+}
+
+func (b *binder) stmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.VarStmt:
+		b.expr(s.Init)
+		for _, name := range s.Names {
+			b.declare(name, declVar)
+		}
+	case *ast.ShortVarStmt:
+		b.expr(s.Init)
+		b.declare(s.Name, declVar)
+	case *ast.AssignStmt:
+		b.expr(s.Value)
+	case *ast.CompoundAssignStmt:
+		b.expr(s.Value)
+	case *ast.PrintStmt:
+		b.expr(s.Expr)
+	case *ast.ExprStmt:
+		b.expr(s.Expr)
+	case *ast.YieldStmt:
+		b.expr(s.Value)
+	case *ast.ReturnStmt:
+		for _, val := range s.Values {
+			b.expr(val)
+		}
+	case *ast.BlockStmt:
+		b.block(s.Statements)
+	case *ast.IfStmt:
+		b.expr(s.Conditon)
+		b.stmt(s.ThenBranch)
+		if s.ElseBranch != nil {
+			b.stmt(s.ElseBranch)
+		}
+	case *ast.WhileStmt:
+		b.expr(s.Conditon)
+		b.stmt(s.Body)
+	case *ast.ForStmt:
+		b.push()
+		if s.Init != nil {
+			b.stmt(s.Init)
+		}
+		b.expr(s.CondExpr)
+		b.stmt(s.BodyStmt)
+		if s.Update != nil {
+			b.stmt(s.Update)
+		}
+		b.pop()
+	case *ast.ForInStmt:
+		b.expr(s.Iterable)
+		b.push()
+		b.declare(s.Name, declVar)
+		b.stmt(s.BodyStmt)
+		b.pop()
+	case *ast.SwitchStmt:
+		b.expr(s.Subject)
+		for _, clause := range s.Cases {
+			for _, val := range clause.Values {
+				b.expr(val)
+			}
+			b.block(clause.Body)
+		}
+	case *ast.SelectStmt:
+		for _, clause := range s.Cases {
+			if clause.Comm != nil {
+				b.expr(clause.Comm)
+			}
+			b.push()
+			if clause.Name.Lexeme != "" {
+				b.declare(clause.Name, declVar)
+			}
+			b.block(clause.Body)
+			b.pop()
+		}
+	case *ast.FuncStmt:
+		b.declare(s.Name, declFunc)
+		b.function(s.Params, s.Body)
+	case *ast.StructStmt:
+		for idx := range s.Methods {
+			b.function(s.Methods[idx].Params, s.Methods[idx].Body)
+		}
+	}
+}
+
+// function walks a body, which shares a scope with its parameters.
+func (b *binder) function(params []ast.Param, body *ast.BlockStmt) {
+	b.push()
+	for _, param := range params {
+		b.declare(param.Name, declParam)
+	}
+	if body != nil {
+		b.stmts(body.Statements)
+	}
+	b.pop()
+}
+
+func (b *binder) expr(expr ast.Expr) {
+	if expr == nil {
+		return
+	}
+	ast.Inspect(expr, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.VariableExpr:
+			b.use(n.Name.Lexeme)
+		case *ast.FuncExpr:
+			b.function(n.Params, n.Body)
+			return false
+		case *ast.IfExpr:
+			b.ifExpr(n)
+			return false
+		}
+		return true
+	})
+}
+
+func (b *binder) ifExpr(e *ast.IfExpr) {
+	b.expr(e.Conditon)
+	if e.ThenBranch != nil {
+		b.block(e.ThenBranch.Statements)
+	}
+	if e.ElseIf != nil {
+		b.ifExpr(e.ElseIf)
+	} else if e.ElseBranch != nil {
+		b.block(e.ElseBranch.Statements)
+	}
+}
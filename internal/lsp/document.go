@@ -0,0 +1,434 @@
+package lsp
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/flow"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/resolver"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/typechecker"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// document is one version of an open file and what the server knows about
+// it. Positions inside are the lexer's: 1-based lines and columns counted in
+// runes, where a token's column is the one just past its end.
+type document struct {
+	uri     string
+	version int
+	lines   []string
+	// parsed is false if the text has a syntax error; the queries then use
+	// the last version that parsed.
+	parsed  bool
+	diags   []Diagnostic
+	types   map[ast.Expr]*symtable.TypeSymbol
+	symbols map[ast.Node]symtable.Symbol
+	index   *indexer
+}
+
+func analyze(uri, text string, version int) *document {
+	d := &document{uri: uri, version: version, lines: strings.Split(text, "\n")}
+	stmts, err := parser.New(lexer.New(text)).Parse()
+	if err != nil {
+		for _, err := range parser.Errors(err) {
+			d.diags = append(d.diags, d.diagnostic(err))
+		}
+		return d
+	}
+	d.parsed = true
+
+	checker := typechecker.NewChecker()
+	checker.Types = make(map[ast.Expr]*symtable.TypeSymbol)
+	checker.Symbols = make(map[ast.Node]symtable.Symbol)
+	scopeErrs := resolver.Resolve(stmts)
+	typeErrs := checker.Check(stmts)
+	flowErrs, warnings := flow.Analyze(stmts)
+	d.types, d.symbols = checker.Types, checker.Symbols
+	d.index = buildIndex(stmts, d.types)
+
+	// Like niftel check, report type errors only for programs whose names
+	// all resolve, as undefined names would be reported twice.
+	errs := scopeErrs
+	if len(scopeErrs) == 0 {
+		errs = append(typeErrs, flowErrs...)
+	}
+	for _, err := range append(errs, warnings...) {
+		d.diags = append(d.diags, d.diagnostic(err))
+	}
+	return d
+}
+
+// diagnostic converts an error from any stage to a diagnostic on its span.
+// Notes and suggestions follow the message on lines of their own.
+func (d *document) diagnostic(err error) Diagnostic {
+	e := niferrors.As(err)
+	rng := d.lineRange(1)
+	if e.Line > 0 {
+		endLine, endCol := e.EndLine, e.EndColumn
+		if endLine == 0 {
+			endLine, endCol = e.Line, e.Column+1
+		}
+		rng = Range{Start: d.position(e.Line, e.Column-1), End: d.position(endLine, endCol-1)}
+	}
+	msg := e.Message
+	for _, note := range e.Notes {
+		msg += "\n" + note
+	}
+	if e.Suggestion != "" {
+		msg += "\n" + e.Suggestion
+	}
+	severity := SeverityError
+	if e.Severity == niferrors.SeverityWarning {
+		severity = SeverityWarning
+	}
+	return Diagnostic{Range: rng, Severity: severity, Code: string(e.Code), Source: "niftel", Message: msg}
+}
+
+// line returns the text of a 1-based line, or "".
+func (d *document) line(line int) string {
+	if line < 1 || line > len(d.lines) {
+		return ""
+	}
+	return strings.TrimSuffix(d.lines[line-1], "\r")
+}
+
+// lineRange covers the text of a 1-based line, without its indentation.
+func (d *document) lineRange(line int) Range {
+	line = max(1, min(line, len(d.lines)))
+	text := d.line(line)
+	indent := utf8.RuneCountInString(text) - utf8.RuneCountInString(strings.TrimLeft(text, " \t"))
+	return Range{Start: d.position(line, indent), End: d.position(line, utf8.RuneCountInString(text))}
+}
+
+// position converts a 1-based line and a 0-based rune column to an LSP
+// position.
+func (d *document) position(line, col int) Position {
+	runes := []rune(d.line(line))
+	col = max(0, min(col, len(runes)))
+	return Position{Line: line - 1, Character: len(utf16.Encode(runes[:col]))}
+}
+
+// runeColumn converts an LSP position to a 1-based line and a 0-based rune
+// column.
+func (d *document) runeColumn(pos Position) (line, col int) {
+	line = pos.Line + 1
+	units := 0
+	for idx, r := range []rune(d.line(line)) {
+		if units >= pos.Character {
+			return line, idx
+		}
+		units += utf16.RuneLen(r)
+	}
+	return line, utf8.RuneCountInString(d.line(line))
+}
+
+// tokenRange covers a name token.
+func (d *document) tokenRange(tok token.Token) Range {
+	end := tok.Column
+	start := end - utf8.RuneCountInString(tok.Lexeme)
+	return Range{Start: d.position(tok.Line, start), End: d.position(tok.Line, end)}
+}
+
+// refAt returns the name at pos, if any. A position just past a name counts,
+// so the cursor can sit at its end.
+func (d *document) refAt(pos Position) (ref, bool) {
+	if d.index == nil {
+		return ref{}, false
+	}
+	line, col := d.runeColumn(pos)
+	found := -1
+	for idx, r := range d.index.refs {
+		start := r.tok.Column - utf8.RuneCountInString(r.tok.Lexeme)
+		if r.tok.Line != line || col < start || col > r.tok.Column {
+			continue
+		}
+		// Prefer the reference that knows its declaration: the same name
+		// can be both a use and, for p.x, a member reference.
+		if found < 0 || d.index.refs[found].decl == nil {
+			found = idx
+		}
+	}
+	if found < 0 {
+		return ref{}, false
+	}
+	return d.index.refs[found], true
+}
+
+func (d *document) definition(pos Position) *Location {
+	r, ok := d.refAt(pos)
+	if !ok || r.decl == nil {
+		return nil
+	}
+	return &Location{URI: d.uri, Range: d.tokenRange(r.decl.name)}
+}
+
+func (d *document) hover(pos Position) *Hover {
+	r, ok := d.refAt(pos)
+	if !ok {
+		return nil
+	}
+	var text string
+	switch {
+	case r.decl != nil:
+		text = d.describe(r.decl)
+	case r.expr != nil && d.types[r.expr] != nil:
+		text = fmt.Sprintf("%s: %s", r.tok.Lexeme, typeString(d.types[r.expr]))
+	default:
+		return nil
+	}
+	rng := d.tokenRange(r.tok)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: "```niftel\n" + text + "\n```"}, Range: &rng}
+}
+
+// describe renders a declaration as it would be written, with the types the
+// checker found.
+func (d *document) describe(dc *decl) string {
+	switch dc.kind {
+	case declVar:
+		return fmt.Sprintf("var %s: %s", dc.name.Lexeme, typeString(d.declType(dc)))
+	case declParam:
+		return fmt.Sprintf("%s: %s (parameter)", dc.name.Lexeme, typeString(d.declType(dc)))
+	case declField:
+		return fmt.Sprintf("%s.%s: %s (field)", dc.owner.name.Lexeme, dc.name.Lexeme, typeString(d.declType(dc)))
+	case declFunc:
+		fn, _ := d.symbols[dc.node].(*symtable.FuncSymbol)
+		return "func " + signature(dc.name.Lexeme, fn)
+	case declMethod:
+		fn, _ := d.symbols[dc.node].(*symtable.FuncSymbol)
+		return fmt.Sprintf("func (%s) %s", dc.owner.name.Lexeme, signature(dc.name.Lexeme, fn))
+	case declStruct:
+		sym, _ := d.symbols[dc.node].(*symtable.TypeSymbol)
+		var sb strings.Builder
+		sb.WriteString("struct " + dc.name.Lexeme)
+		if sym != nil && len(sym.TypeParams) > 0 {
+			sb.WriteString("[" + strings.Join(sym.TypeParams, ", ") + "]")
+		}
+		sb.WriteString(" {")
+		for _, m := range dc.members {
+			sb.WriteString("\n\t")
+			if m.kind == declField {
+				sb.WriteString(m.name.Lexeme + ": " + typeString(d.declType(m)))
+			} else {
+				fn, _ := d.symbols[m.node].(*symtable.FuncSymbol)
+				sb.WriteString("func " + signature(m.name.Lexeme, fn))
+			}
+		}
+		sb.WriteString("\n}")
+		return sb.String()
+	}
+	return dc.name.Lexeme
+}
+
+// declType returns the static type of a variable, parameter or field: the
+// one the checker gave its field, initializer or uses.
+func (d *document) declType(dc *decl) *symtable.TypeSymbol {
+	if dc.kind == declField {
+		if sym, ok := d.symbols[dc.owner.node].(*symtable.TypeSymbol); ok {
+			return sym.Fields[dc.name.Lexeme]
+		}
+		return nil
+	}
+	if dc.init != nil && dc.typ == nil {
+		if typ := d.types[dc.init]; typ != nil {
+			return typ
+		}
+	}
+	for _, use := range dc.uses {
+		if typ := d.types[use]; typ != nil {
+			return typ
+		}
+	}
+	if dc.typ != nil {
+		return &symtable.TypeSymbol{SymName: typeExprString(dc.typ)}
+	}
+	return nil
+}
+
+func signature(name string, fn *symtable.FuncSymbol) string {
+	if fn == nil {
+		return name + "(...)"
+	}
+	var sb strings.Builder
+	sb.WriteString(name)
+	if len(fn.TypeParams) > 0 {
+		sb.WriteString("[" + strings.Join(fn.TypeParams, ", ") + "]")
+	}
+	params := make([]string, len(fn.Params))
+	for idx, param := range fn.Params {
+		params[idx] = param.SymName + ": " + typeString(param.Type)
+	}
+	sb.WriteString("(" + strings.Join(params, ", ") + ")")
+	switch len(fn.ReturnType) {
+	case 0:
+	case 1:
+		sb.WriteString(" -> " + typeString(fn.ReturnType[0]))
+	default:
+		types := make([]string, len(fn.ReturnType))
+		for idx, typ := range fn.ReturnType {
+			types[idx] = typeString(typ)
+		}
+		sb.WriteString(" -> (" + strings.Join(types, ", ") + ")")
+	}
+	return sb.String()
+}
+
+func typeString(typ *symtable.TypeSymbol) string {
+	if typ == nil {
+		return "unknown"
+	}
+	return typ.SymName
+}
+
+func typeExprString(t *ast.TypeExpr) string {
+	if len(t.TypeArgs) == 0 {
+		return t.Name.Lexeme
+	}
+	args := make([]string, len(t.TypeArgs))
+	for idx := range t.TypeArgs {
+		args[idx] = typeExprString(&t.TypeArgs[idx])
+	}
+	return t.Name.Lexeme + "[" + strings.Join(args, ", ") + "]"
+}
+
+var memberAccess = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\.[A-Za-z0-9_]*$`)
+
+// completion offers the fields and methods of a struct after "name.", and
+// otherwise every name visible at pos. text is the current text, which may
+// not parse; d is the last version that did.
+func (d *document) completion(pos Position, text *document) []CompletionItem {
+	if d.index == nil {
+		return nil
+	}
+	line, col := text.runeColumn(pos)
+	before := string([]rune(text.line(line))[:col])
+	if m := memberAccess.FindStringSubmatch(before); m != nil {
+		owner := d.structAt(m[1], line)
+		if owner == nil {
+			return []CompletionItem{}
+		}
+		items := []CompletionItem{}
+		for _, member := range owner.members {
+			items = append(items, d.completionItem(member))
+		}
+		return items
+	}
+
+	seen := make(map[string]bool)
+	items := []CompletionItem{}
+	// Innermost declarations come last; walk backwards so they win.
+	for idx := len(d.index.decls) - 1; idx >= 0; idx-- {
+		dc := d.index.decls[idx]
+		if dc.kind == declField || dc.kind == declMethod || !dc.visibleAt(line) || seen[dc.name.Lexeme] {
+			continue
+		}
+		seen[dc.name.Lexeme] = true
+		items = append(items, d.completionItem(dc))
+	}
+	for _, name := range typechecker.BuiltinNames() {
+		if !seen[name] {
+			items = append(items, CompletionItem{Label: name, Kind: CompletionFunction, Detail: "builtin"})
+		}
+	}
+	slices.SortStableFunc(items, func(a, b CompletionItem) int { return strings.Compare(a.Label, b.Label) })
+	return items
+}
+
+func (d *document) completionItem(dc *decl) CompletionItem {
+	item := CompletionItem{Label: dc.name.Lexeme, Detail: d.describe(dc)}
+	switch dc.kind {
+	case declFunc:
+		item.Kind = CompletionFunction
+	case declStruct:
+		item.Kind, item.Detail = CompletionStruct, "struct "+dc.name.Lexeme
+	case declField:
+		item.Kind = CompletionField
+	case declMethod:
+		item.Kind = CompletionMethod
+	default:
+		item.Kind = CompletionVariable
+	}
+	return item
+}
+
+// structAt returns the struct the variable called name, as seen from line,
+// is an instance of. self is the struct whose method contains line.
+func (d *document) structAt(name string, line int) *decl {
+	var found *decl
+	for _, dc := range d.index.decls {
+		switch {
+		case name == "self" && dc.kind == declMethod && dc.visibleAt(line):
+			fn := dc.node.(*ast.FuncStmt)
+			if fn.Func.Line <= line && line <= fn.Body.RBrace.Line {
+				return dc.owner
+			}
+		case dc.name.Lexeme == name && (dc.kind == declVar || dc.kind == declParam) && dc.visibleAt(line):
+			// Later declarations are the inner ones.
+			found = dc
+		}
+	}
+	if found == nil {
+		return nil
+	}
+	if typ := d.declType(found); typ != nil {
+		return d.index.structOf(typ)
+	}
+	return nil
+}
+
+// documentSymbols lists the top-level declarations, with the members of
+// structs.
+func (d *document) documentSymbols() []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	if d.index == nil {
+		return symbols
+	}
+	for _, dc := range d.index.decls {
+		if dc.to != math.MaxInt {
+			continue
+		}
+		sym := d.documentSymbol(dc)
+		for _, m := range dc.members {
+			sym.Children = append(sym.Children, d.documentSymbol(m))
+		}
+		symbols = append(symbols, sym)
+	}
+	return symbols
+}
+
+func (d *document) documentSymbol(dc *decl) DocumentSymbol {
+	sym := DocumentSymbol{Name: dc.name.Lexeme, SelectionRange: d.tokenRange(dc.name)}
+	sym.Range = sym.SelectionRange
+	switch dc.kind {
+	case declFunc, declMethod:
+		fn := dc.node.(*ast.FuncStmt)
+		sym.Kind = SymbolFunction
+		if dc.kind == declMethod {
+			sym.Kind = SymbolMethod
+		}
+		if f, ok := d.symbols[fn].(*symtable.FuncSymbol); ok {
+			sym.Detail = signature(dc.name.Lexeme, f)
+		}
+		if fn.Body != nil {
+			sym.Range = Range{Start: d.position(fn.Func.Line, 0), End: d.position(fn.Body.RBrace.Line, utf8.RuneCountInString(d.line(fn.Body.RBrace.Line)))}
+		}
+	case declStruct:
+		st := dc.node.(*ast.StructStmt)
+		sym.Kind = SymbolStruct
+		sym.Range = Range{Start: d.position(st.Name.Line, 0), End: d.position(st.RBrace.Line, utf8.RuneCountInString(d.line(st.RBrace.Line)))}
+	case declField:
+		sym.Kind, sym.Detail = SymbolField, typeString(d.declType(dc))
+	default:
+		sym.Kind, sym.Detail = SymbolVariable, typeString(d.declType(dc))
+	}
+	return sym
+}
@@ -0,0 +1,411 @@
+package lsp
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+)
+
+type declKind int
+
+const (
+	declVar declKind = iota
+	declParam
+	declFunc
+	declStruct
+	declField
+	declMethod
+)
+
+// decl is a name declared in a document.
+type decl struct {
+	name token.Token
+	kind declKind
+	// node is the *ast.FuncStmt of a function or method and the
+	// *ast.StructStmt of a struct.
+	node ast.Node
+	// typ is the written type of a variable, parameter or field; init is
+	// the initializer of a variable declared without one.
+	typ  *ast.TypeExpr
+	init ast.Expr
+	// owner is the struct of a field or method; members are the fields and
+	// methods of a struct, in source order.
+	owner   *decl
+	members []*decl
+	// from and to are the lines the name is visible on.
+	from, to int
+	// uses are the reads of a variable, whose types the checker knows even
+	// when the declaration does not spell one out.
+	uses []*ast.VariableExpr
+}
+
+func (d *decl) visibleAt(line int) bool {
+	return d.from <= line && line <= d.to
+}
+
+// member returns the field or method of a struct called name, or nil.
+func (d *decl) member(name string) *decl {
+	for _, m := range d.members {
+		if m.name.Lexeme == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// ref is an occurrence of a name: a declaration or a use. decl is nil for
+// names that do not resolve to a declaration in the document, such as self
+// or builtins; expr is set for names that are expressions.
+type ref struct {
+	tok  token.Token
+	decl *decl
+	expr ast.Expr
+}
+
+type indexScope struct {
+	names map[string]*decl
+	end   int
+}
+
+type pendingRef struct {
+	ref    int
+	name   string
+	scopes []*indexScope
+}
+
+// indexer walks a program with lexical scoping and records every
+// declaration and every reference to one.
+type indexer struct {
+	scopes  []*indexScope
+	decls   []*decl
+	refs    []ref
+	structs map[string]*decl
+	// pending are uses seen before any visible declaration; a nested
+	// function may read a variable its enclosing scope declares later.
+	pending []pendingRef
+}
+
+func buildIndex(stmts []ast.Stmt, types map[ast.Expr]*symtable.TypeSymbol) *indexer {
+	ix := &indexer{structs: make(map[string]*decl)}
+	ix.push(math.MaxInt)
+	ix.stmts(stmts)
+	for _, p := range ix.pending {
+		for idx := len(p.scopes) - 1; idx >= 0; idx-- {
+			if d, ok := p.scopes[idx].names[p.name]; ok {
+				ix.bind(p.ref, d)
+				break
+			}
+		}
+	}
+	ix.resolveMembers(stmts, types)
+	return ix
+}
+
+func (ix *indexer) push(end int) {
+	ix.scopes = append(ix.scopes, &indexScope{names: map[string]*decl{}, end: end})
+}
+
+func (ix *indexer) pop() {
+	ix.scopes = ix.scopes[:len(ix.scopes)-1]
+}
+
+func (ix *indexer) declare(d *decl) *decl {
+	current := ix.scopes[len(ix.scopes)-1]
+	d.from, d.to = d.name.Line, current.end
+	if len(ix.scopes) == 1 || d.kind == declFunc || d.kind == declStruct {
+		// Functions and structs can be used anywhere in their scope.
+		d.from = 0
+	}
+	current.names[d.name.Lexeme] = d
+	ix.addDecl(d)
+	return d
+}
+
+// addDecl records d and its name as a reference to itself.
+func (ix *indexer) addDecl(d *decl) {
+	ix.decls = append(ix.decls, d)
+	ix.refs = append(ix.refs, ref{tok: d.name, decl: d})
+}
+
+func (ix *indexer) bind(idx int, d *decl) {
+	ix.refs[idx].decl = d
+	if v, ok := ix.refs[idx].expr.(*ast.VariableExpr); ok {
+		d.uses = append(d.uses, v)
+	}
+}
+
+func (ix *indexer) use(name token.Token, expr ast.Expr) {
+	ix.refs = append(ix.refs, ref{tok: name, expr: expr})
+	idx := len(ix.refs) - 1
+	for s := len(ix.scopes) - 1; s >= 0; s-- {
+		if d, ok := ix.scopes[s].names[name.Lexeme]; ok {
+			ix.bind(idx, d)
+			return
+		}
+	}
+	ix.pending = append(ix.pending, pendingRef{ref: idx, name: name.Lexeme, scopes: append([]*indexScope(nil), ix.scopes...)})
+}
+
+func (ix *indexer) typeRef(t *ast.TypeExpr) {
+	if t == nil {
+		return
+	}
+	if d, ok := ix.structs[t.Name.Lexeme]; ok {
+		ix.refs = append(ix.refs, ref{tok: t.Name, decl: d})
+	}
+	for idx := range t.TypeArgs {
+		ix.typeRef(&t.TypeArgs[idx])
+	}
+}
+
+// stmts indexes a statement list in the current scope. Its functions and
+// structs are declared first, as the checker does.
+func (ix *indexer) stmts(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.StructStmt:
+			d := ix.declare(&decl{name: s.Name, kind: declStruct, node: s})
+			if len(ix.scopes) == 1 {
+				ix.structs[s.Name.Lexeme] = d
+			}
+		case *ast.FuncStmt:
+			ix.declare(&decl{name: s.Name, kind: declFunc, node: s})
+		}
+	}
+	for _, stmt := range stmts {
+		ix.stmt(stmt)
+	}
+}
+
+func (ix *indexer) block(b *ast.BlockStmt) {
+	if b == nil {
+		return
+	}
+	ix.push(b.RBrace.Line)
+	ix.stmts(b.Statements)
+	ix.pop()
+}
+
+func (ix *indexer) stmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.VarStmt:
+		ix.expr(s.Init)
+		ix.typeRef(s.Type)
+		for _, name := range s.Names {
+			ix.declare(&decl{name: name, kind: declVar, typ: s.Type, init: s.Init})
+		}
+	case *ast.ShortVarStmt:
+		ix.expr(s.Init)
+		ix.declare(&decl{name: s.Name, kind: declVar, init: s.Init})
+	case *ast.AssignStmt:
+		ix.expr(s.Value)
+		ix.use(s.Name, nil)
+	case *ast.CompoundAssignStmt:
+		ix.expr(s.Value)
+		ix.use(s.Name, nil)
+	case *ast.PrintStmt:
+		ix.expr(s.Expr)
+	case *ast.ExprStmt:
+		ix.expr(s.Expr)
+	case *ast.YieldStmt:
+		ix.expr(s.Value)
+	case *ast.ReturnStmt:
+		for _, val := range s.Values {
+			ix.expr(val)
+		}
+	case *ast.BlockStmt:
+		ix.block(s)
+	case *ast.IfStmt:
+		ix.expr(s.Conditon)
+		ix.stmt(s.ThenBranch)
+		if s.ElseBranch != nil {
+			ix.stmt(s.ElseBranch)
+		}
+	case *ast.WhileStmt:
+		ix.expr(s.Conditon)
+		ix.stmt(s.Body)
+	case *ast.ForStmt:
+		ix.push(endLine(s.BodyStmt))
+		if s.Init != nil {
+			ix.stmt(s.Init)
+		}
+		ix.expr(s.CondExpr)
+		if s.Update != nil {
+			ix.stmt(s.Update)
+		}
+		ix.stmt(s.BodyStmt)
+		ix.pop()
+	case *ast.ForInStmt:
+		ix.expr(s.Iterable)
+		ix.push(endLine(s.BodyStmt))
+		ix.declare(&decl{name: s.Name, kind: declVar})
+		ix.stmt(s.BodyStmt)
+		ix.pop()
+	case *ast.SwitchStmt:
+		ix.expr(s.Subject)
+		for idx, clause := range s.Cases {
+			for _, val := range clause.Values {
+				ix.expr(val)
+			}
+			ix.push(clauseEnd(idx, len(s.Cases), func(i int) int { return s.Cases[i].Case.Line }, s.RBrace.Line))
+			ix.stmts(clause.Body)
+			ix.pop()
+		}
+	case *ast.SelectStmt:
+		for idx, clause := range s.Cases {
+			if clause.Comm != nil {
+				ix.expr(clause.Comm)
+			}
+			ix.push(clauseEnd(idx, len(s.Cases), func(i int) int { return s.Cases[i].Case.Line }, s.RBrace.Line))
+			if clause.Name.Lexeme != "" {
+				ix.declare(&decl{name: clause.Name, kind: declVar})
+			}
+			ix.stmts(clause.Body)
+			ix.pop()
+		}
+	case *ast.FuncStmt:
+		ix.signature(s)
+		ix.function(s.Params, s.Body)
+	case *ast.StructStmt:
+		ix.structMembers(s)
+	}
+}
+
+// structMembers declares the fields and methods of a struct and indexes the
+// method bodies.
+func (ix *indexer) structMembers(s *ast.StructStmt) {
+	owner := ix.scopes[len(ix.scopes)-1].names[s.Name.Lexeme]
+	if owner == nil {
+		return
+	}
+	for idx := range s.Fields {
+		field := &s.Fields[idx]
+		ix.typeRef(field.Type)
+		owner.members = append(owner.members, &decl{name: field.Names[0], kind: declField, typ: field.Type, owner: owner})
+	}
+	for idx := range s.Methods {
+		method := &s.Methods[idx]
+		owner.members = append(owner.members, &decl{name: method.Name, kind: declMethod, node: method, owner: owner})
+	}
+	slices.SortStableFunc(owner.members, func(a, b *decl) int { return cmp.Compare(a.name.Line, b.name.Line) })
+	for _, m := range owner.members {
+		m.from, m.to = m.name.Line, s.RBrace.Line
+		ix.addDecl(m)
+	}
+	for idx := range s.Methods {
+		ix.signature(&s.Methods[idx])
+		ix.function(s.Methods[idx].Params, s.Methods[idx].Body)
+	}
+}
+
+func (ix *indexer) signature(fn *ast.FuncStmt) {
+	for _, param := range fn.Params {
+		ix.typeRef(param.Type)
+	}
+	for _, ret := range fn.Return {
+		ix.typeRef(ret)
+	}
+}
+
+// function indexes a body, which shares a scope with its parameters.
+func (ix *indexer) function(params []ast.Param, body *ast.BlockStmt) {
+	if body == nil {
+		return
+	}
+	ix.push(body.RBrace.Line)
+	for idx := range params {
+		ix.declare(&decl{name: params[idx].Name, kind: declParam, typ: params[idx].Type})
+	}
+	ix.stmts(body.Statements)
+	ix.pop()
+}
+
+func (ix *indexer) expr(expr ast.Expr) {
+	if expr == nil {
+		return
+	}
+	ast.Inspect(expr, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.VariableExpr:
+			ix.use(n.Name, n)
+		case *ast.StructLiteralExpr:
+			ix.typeRef(n.TypeName)
+		case *ast.CallExpr:
+			for _, arg := range n.TypeArgs {
+				ix.typeRef(arg)
+			}
+		case *ast.FuncExpr:
+			for _, param := range n.Params {
+				ix.typeRef(param.Type)
+			}
+			ix.function(n.Params, n.Body)
+			return false
+		case *ast.IfExpr:
+			ix.ifExpr(n)
+			return false
+		}
+		return true
+	})
+}
+
+func (ix *indexer) ifExpr(e *ast.IfExpr) {
+	ix.expr(e.Conditon)
+	ix.block(e.ThenBranch)
+	if e.ElseIf != nil {
+		ix.ifExpr(e.ElseIf)
+	} else {
+		ix.block(e.ElseBranch)
+	}
+}
+
+// resolveMembers links p.x to the field or method x of p's struct, using
+// the types the checker found.
+func (ix *indexer) resolveMembers(stmts []ast.Stmt, types map[ast.Expr]*symtable.TypeSymbol) {
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			get, ok := node.(*ast.GetExpr)
+			if !ok {
+				return true
+			}
+			if owner := ix.structOf(types[get.Object]); owner != nil {
+				if m := owner.member(get.Name.Lexeme); m != nil {
+					ix.refs = append(ix.refs, ref{tok: get.Name, decl: m, expr: get})
+				}
+			}
+			return true
+		})
+	}
+}
+
+// structOf returns the declaration of the struct typ is an instance of.
+func (ix *indexer) structOf(typ *symtable.TypeSymbol) *decl {
+	if typ == nil {
+		return nil
+	}
+	if typ.Origin != nil {
+		typ = typ.Origin
+	}
+	return ix.structs[typ.SymName]
+}
+
+// endLine returns the last line of a loop body.
+func endLine(stmt ast.Stmt) int {
+	if b, ok := stmt.(*ast.BlockStmt); ok {
+		return b.RBrace.Line
+	}
+	line, _ := stmt.Pos()
+	return line
+}
+
+// clauseEnd returns the last line of case idx of n, which runs until the
+// next case or the closing brace.
+func clauseEnd(idx, n int, caseLine func(int) int, rbrace int) int {
+	if idx+1 < n {
+		return caseLine(idx+1) - 1
+	}
+	return rbrace
+}
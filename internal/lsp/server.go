@@ -0,0 +1,216 @@
+// Package lsp implements a Language Server Protocol server for Niftel. It
+// speaks JSON-RPC over a reader and writer, normally stdin and stdout, and
+// provides diagnostics, go-to-definition, hover, completion and document
+// symbols from the lexer, parser, resolver and type checker.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNoShutdown is returned by Run when the client exits without asking the
+// server to shut down first.
+var ErrNoShutdown = errors.New("exit without shutdown")
+
+// Server is a language server for one client.
+type Server struct {
+	conn *conn
+	// docs holds the open documents; parsed holds, for each, the last
+	// version without syntax errors, which the queries run against.
+	docs        map[string]*document
+	parsed      map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		conn:   newConn(in, out),
+		docs:   make(map[string]*document),
+		parsed: make(map[string]*document),
+	}
+}
+
+// Run serves the client until it sends exit or closes its end.
+func (s *Server) Run() error {
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			if !s.shutdown {
+				return ErrNoShutdown
+			}
+			return nil
+		}
+		var rpcErr *responseError
+		if errors.As(err, &rpcErr) {
+			if err := s.conn.write(&message{ID: nullID(), Error: rpcErr}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrNoShutdown
+			}
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func nullID() *json.RawMessage {
+	id := json.RawMessage("null")
+	return &id
+}
+
+// handle answers a request or acts on a notification.
+func (s *Server) handle(msg *message) error {
+	if msg.ID == nil {
+		s.notification(msg)
+		return nil
+	}
+	if !s.initialized && msg.Method != "initialize" {
+		return s.reply(msg, nil, &responseError{Code: codeServerNotInitialized, Message: "server not initialized"})
+	}
+	if s.shutdown {
+		return s.reply(msg, nil, &responseError{Code: codeInvalidRequest, Message: "server is shutting down"})
+	}
+	result, rpcErr := s.request(msg)
+	return s.reply(msg, result, rpcErr)
+}
+
+func (s *Server) reply(msg *message, result any, rpcErr *responseError) error {
+	resp := &message{ID: msg.ID, Error: rpcErr}
+	if rpcErr == nil {
+		resp.Result = result
+		if result == nil {
+			resp.Result = json.RawMessage("null")
+		}
+	}
+	return s.conn.write(resp)
+}
+
+func (s *Server) request(msg *message) (any, *responseError) {
+	switch msg.Method {
+	case "initialize":
+		s.initialized = true
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:       1,
+				DefinitionProvider:     true,
+				HoverProvider:          true,
+				CompletionProvider:     &CompletionOptions{TriggerCharacters: []string{"."}},
+				DocumentSymbolProvider: true,
+			},
+			ServerInfo: ServerInfo{Name: "niftel"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		if doc := s.parsed[params.TextDocument.URI]; doc != nil {
+			if loc := doc.definition(params.Position); loc != nil {
+				return loc, nil
+			}
+		}
+		return nil, nil
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		if doc := s.parsed[params.TextDocument.URI]; doc != nil {
+			if hover := doc.hover(params.Position); hover != nil {
+				return hover, nil
+			}
+		}
+		return nil, nil
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		list := CompletionList{Items: []CompletionItem{}}
+		if doc := s.parsed[params.TextDocument.URI]; doc != nil {
+			list.Items = doc.completion(params.Position, s.docs[params.TextDocument.URI])
+		}
+		return list, nil
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		if doc := s.parsed[params.TextDocument.URI]; doc != nil {
+			return doc.documentSymbols(), nil
+		}
+		return []DocumentSymbol{}, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not supported: %s", msg.Method)}
+}
+
+// notification acts on a message that needs no reply. Unknown ones,
+// including before initialization, are ignored as the protocol asks.
+func (s *Server) notification(msg *message) {
+	if !s.initialized {
+		return
+	}
+	switch msg.Method {
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if decode(msg, &params) == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text, params.TextDocument.Version)
+		}
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if decode(msg, &params) == nil && len(params.ContentChanges) > 0 {
+			text := params.ContentChanges[len(params.ContentChanges)-1].Text
+			s.update(params.TextDocument.URI, text, params.TextDocument.Version)
+		}
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if decode(msg, &params) == nil {
+			delete(s.docs, params.TextDocument.URI)
+			delete(s.parsed, params.TextDocument.URI)
+			s.publish(&document{uri: params.TextDocument.URI})
+		}
+	}
+}
+
+// update analyzes a new version of a document and publishes its
+// diagnostics.
+func (s *Server) update(uri, text string, version int) {
+	doc := analyze(uri, text, version)
+	s.docs[uri] = doc
+	if doc.parsed {
+		s.parsed[uri] = doc
+	}
+	s.publish(doc)
+}
+
+func (s *Server) publish(doc *document) {
+	params := PublishDiagnosticsParams{URI: doc.uri, Version: doc.version, Diagnostics: doc.diags}
+	if params.Diagnostics == nil {
+		params.Diagnostics = []Diagnostic{}
+	}
+	body, _ := json.Marshal(params)
+	s.conn.write(&message{Method: "textDocument/publishDiagnostics", Params: body})
+}
+
+func decode(msg *message, params any) *responseError {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
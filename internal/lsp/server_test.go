@@ -0,0 +1,364 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lsp"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+)
+
+const uri = "file:///work/main.nif"
+
+const source = `struct Point {
+	x: int
+	y: int
+	func sum() -> int {
+		return self.x + self.y
+	}
+}
+func add(a: int, b: int) -> int {
+	return a + b
+}
+p := Point{x: 1, y: 2}
+total := add(p.x, 3)
+print(total)
+`
+
+// script collects the messages a client sends, framed for the server.
+type script struct {
+	buf    bytes.Buffer
+	nextID int
+}
+
+func (s *script) send(msg map[string]any) {
+	msg["jsonrpc"] = "2.0"
+	body, _ := json.Marshal(msg)
+	fmt.Fprintf(&s.buf, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *script) request(method string, params any) int {
+	s.nextID++
+	s.send(map[string]any{"id": s.nextID, "method": method, "params": params})
+	return s.nextID
+}
+
+func (s *script) notify(method string, params any) {
+	s.send(map[string]any{"method": method, "params": params})
+}
+
+func (s *script) open(uri, text string) {
+	s.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "niftel", "version": 1, "text": text},
+	})
+}
+
+func (s *script) at(method, uri string, line, char int) int {
+	return s.request(method, map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": char},
+	})
+}
+
+type reply struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// session runs the server over a script, ending it with shutdown and exit,
+// and returns what the server sent.
+type session struct {
+	responses     map[int]reply
+	notifications []reply
+}
+
+func run(t *testing.T, s *script) *session {
+	t.Helper()
+	value.BuiltinTypesInit()
+	s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	var out bytes.Buffer
+	if err := lsp.NewServer(&s.buf, &out).Run(); err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	sess := &session{responses: make(map[int]reply)}
+	in := textproto.NewReader(bufio.NewReader(&out))
+	for {
+		header, err := in.ReadMIMEHeader()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("bad header: %v", err)
+		}
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, length)
+		if _, err := io.ReadFull(in.R, body); err != nil {
+			t.Fatal(err)
+		}
+		var r reply
+		if err := json.Unmarshal(body, &r); err != nil {
+			t.Fatalf("bad message %s: %v", body, err)
+		}
+		if r.ID != nil {
+			sess.responses[*r.ID] = r
+		} else {
+			sess.notifications = append(sess.notifications, r)
+		}
+	}
+	return sess
+}
+
+func (sess *session) result(t *testing.T, id int, into any) {
+	t.Helper()
+	r, ok := sess.responses[id]
+	if !ok {
+		t.Fatalf("no response to request %d", id)
+	}
+	if r.Error != nil {
+		t.Fatalf("request %d failed: %s", id, r.Error.Message)
+	}
+	if err := json.Unmarshal(r.Result, into); err != nil {
+		t.Fatalf("request %d: bad result %s: %v", id, r.Result, err)
+	}
+}
+
+// diagnostics returns the diagnostics of every publishDiagnostics
+// notification, in order.
+func (sess *session) diagnostics(t *testing.T) [][]lsp.Diagnostic {
+	t.Helper()
+	var all [][]lsp.Diagnostic
+	for _, n := range sess.notifications {
+		if n.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var params lsp.PublishDiagnosticsParams
+		if err := json.Unmarshal(n.Params, &params); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, params.Diagnostics)
+	}
+	return all
+}
+
+func initialized() *script {
+	s := &script{}
+	s.request("initialize", map[string]any{"capabilities": map[string]any{}})
+	s.notify("initialized", map[string]any{})
+	return s
+}
+
+func rng(line, from, to int) lsp.Range {
+	return lsp.Range{Start: lsp.Position{Line: line, Character: from}, End: lsp.Position{Line: line, Character: to}}
+}
+
+func TestServer_Initialize(t *testing.T) {
+	s := &script{}
+	early := s.at("textDocument/hover", uri, 0, 0)
+	id := s.request("initialize", map[string]any{"capabilities": map[string]any{}})
+	sess := run(t, s)
+
+	if r := sess.responses[early]; r.Error == nil || r.Error.Code != -32002 {
+		t.Errorf("expected a not-initialized error before initialize, got %+v", r)
+	}
+	var result lsp.InitializeResult
+	sess.result(t, id, &result)
+	caps := result.Capabilities
+	if caps.TextDocumentSync != 1 || !caps.DefinitionProvider || !caps.HoverProvider || caps.CompletionProvider == nil || !caps.DocumentSymbolProvider {
+		t.Errorf("unexpected capabilities: %+v", caps)
+	}
+}
+
+func TestServer_Diagnostics(t *testing.T) {
+	s := initialized()
+	s.open(uri, source)
+	s.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []any{map[string]any{"text": "x := 1\nx = \"s\"\n"}},
+	})
+	s.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 3},
+		"contentChanges": []any{map[string]any{"text": "x := (1\ny := 2\nz := )\n"}},
+	})
+	s.notify("textDocument/didClose", map[string]any{"textDocument": map[string]any{"uri": uri}})
+	all := run(t, s).diagnostics(t)
+
+	if len(all) != 4 {
+		t.Fatalf("expected 4 diagnostic notifications, got %d", len(all))
+	}
+	if len(all[0]) != 0 {
+		t.Errorf("expected no diagnostics for a valid program, got %+v", all[0])
+	}
+	if len(all[1]) != 1 || !strings.Contains(all[1][0].Message, "cannot assign string value to 'x'") ||
+		all[1][0].Severity != lsp.SeverityError || all[1][0].Range.Start.Line != 1 {
+		t.Errorf("expected a type error on line 1, got %+v", all[1])
+	}
+	if len(all[2]) != 2 || all[2][0].Range.Start.Line != 1 || all[2][1].Range.Start.Line != 2 {
+		t.Errorf("expected a syntax error on lines 1 and 2, got %+v", all[2])
+	}
+	if len(all[3]) != 0 {
+		t.Errorf("expected closing to clear diagnostics, got %+v", all[3])
+	}
+}
+
+func TestServer_Definition(t *testing.T) {
+	s := initialized()
+	s.open(uri, source)
+	cases := []struct {
+		name       string
+		line, char int
+		want       lsp.Range
+	}{
+		{"function", 11, 9, rng(7, 5, 8)},
+		{"variable", 12, 7, rng(11, 0, 5)},
+		{"parameter", 8, 8, rng(7, 9, 10)},
+		{"struct", 10, 6, rng(0, 7, 12)},
+		{"field", 11, 15, rng(1, 1, 2)},
+		{"field through self", 4, 15, rng(1, 1, 2)},
+	}
+	ids := make([]int, len(cases))
+	for idx, tc := range cases {
+		ids[idx] = s.at("textDocument/definition", uri, tc.line, tc.char)
+	}
+	missing := s.at("textDocument/definition", uri, 12, 2)
+	sess := run(t, s)
+
+	for idx, tc := range cases {
+		var loc lsp.Location
+		sess.result(t, ids[idx], &loc)
+		if loc.URI != uri || loc.Range != tc.want {
+			t.Errorf("%s: expected %v, got %+v", tc.name, tc.want, loc)
+		}
+	}
+	if r := sess.responses[missing]; string(r.Result) != "null" {
+		t.Errorf("expected no definition for print, got %s", r.Result)
+	}
+}
+
+func TestServer_Hover(t *testing.T) {
+	s := initialized()
+	s.open(uri, source)
+	cases := []struct {
+		name       string
+		line, char int
+		want       string
+	}{
+		{"function", 11, 10, "func add(a: int, b: int) -> int"},
+		{"method", 3, 7, "func (Point) sum() -> int"},
+		{"inferred variable", 12, 8, "var total: int"},
+		{"struct variable", 10, 0, "var p: Point"},
+		{"parameter", 8, 8, "a: int (parameter)"},
+		{"field", 11, 15, "Point.x: int (field)"},
+		{"self", 4, 10, "self: Point"},
+		{"struct", 0, 8, "struct Point {\n\tx: int\n\ty: int\n\tfunc sum() -> int\n}"},
+	}
+	ids := make([]int, len(cases))
+	for idx, tc := range cases {
+		ids[idx] = s.at("textDocument/hover", uri, tc.line, tc.char)
+	}
+	sess := run(t, s)
+
+	for idx, tc := range cases {
+		var hover lsp.Hover
+		sess.result(t, ids[idx], &hover)
+		if want := "```niftel\n" + tc.want + "\n```"; hover.Contents.Value != want {
+			t.Errorf("%s: expected %q, got %q", tc.name, want, hover.Contents.Value)
+		}
+	}
+}
+
+func labels(items []lsp.CompletionItem) []string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Label)
+	}
+	return names
+}
+
+func TestServer_Completion(t *testing.T) {
+	s := initialized()
+	s.open(uri, source)
+	names := s.at("textDocument/completion", uri, 12, 6)
+	inMethod := s.at("textDocument/completion", uri, 4, 9)
+	// While the user types "p." the document does not parse; completion
+	// uses the last version that did.
+	s.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []any{map[string]any{"text": source + "p.\n"}},
+	})
+	members := s.at("textDocument/completion", uri, 13, 2)
+	selfMembers := s.at("textDocument/completion", uri, 4, 14)
+	sess := run(t, s)
+
+	var list lsp.CompletionList
+	sess.result(t, names, &list)
+	got := labels(list.Items)
+	for _, want := range []string{"Point", "add", "p", "total", "len"} {
+		if !slices.Contains(got, want) {
+			t.Errorf("expected %s among completions, got %v", want, got)
+		}
+	}
+	if slices.Contains(got, "a") || slices.Contains(got, "x") {
+		t.Errorf("parameters and fields are not in scope at top level: %v", got)
+	}
+
+	sess.result(t, inMethod, &list)
+	if got := labels(list.Items); slices.Contains(got, "a") {
+		t.Errorf("parameters of add are not in scope in sum: %v", got)
+	}
+
+	for _, id := range []int{members, selfMembers} {
+		sess.result(t, id, &list)
+		if got := labels(list.Items); !slices.Equal(got, []string{"x", "y", "sum"}) {
+			t.Errorf("expected the members of Point, got %v", got)
+		}
+	}
+}
+
+func TestServer_DocumentSymbols(t *testing.T) {
+	s := initialized()
+	s.open(uri, source)
+	id := s.request("textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": uri}})
+	sess := run(t, s)
+
+	var symbols []lsp.DocumentSymbol
+	sess.result(t, id, &symbols)
+	var got []string
+	for _, sym := range symbols {
+		entry := fmt.Sprintf("%s:%d", sym.Name, sym.Kind)
+		for _, child := range sym.Children {
+			entry += fmt.Sprintf(" %s:%d", child.Name, child.Kind)
+		}
+		got = append(got, entry)
+	}
+	want := []string{"Point:23 x:8 y:8 sum:6", "add:12", "p:13", "total:13"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected symbols %v, got %v", want, got)
+	}
+	if len(symbols) > 0 && symbols[0].Range != (lsp.Range{Start: lsp.Position{}, End: lsp.Position{Line: 6, Character: 1}}) {
+		t.Errorf("unexpected range for Point: %+v", symbols[0].Range)
+	}
+}
+
+func TestServer_ExitWithoutShutdown(t *testing.T) {
+	s := initialized()
+	s.notify("exit", nil)
+	if err := lsp.NewServer(&s.buf, io.Discard).Run(); err != lsp.ErrNoShutdown {
+		t.Errorf("expected ErrNoShutdown, got %v", err)
+	}
+}
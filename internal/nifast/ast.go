@@ -5,6 +5,8 @@ import token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
 type Expr interface {
 	exprNode()
 	Pos() (line, column int)
+	// PosToken returns the token whose position Pos reports.
+	PosToken() token.Token
 }
 
 type TypeExpr struct {
@@ -15,6 +17,7 @@ type TypeExpr struct {
 type Stmt interface {
 	stmtNode()
 	Pos() (line, column int)
+	PosToken() token.Token
 }
 
 type BinaryExpr struct {
@@ -23,30 +26,44 @@ type BinaryExpr struct {
 	Right    Expr
 }
 
-func (*BinaryExpr) exprNode()         {}
-func (e *BinaryExpr) Pos() (int, int) { return e.Operator.Line, e.Operator.Column }
+func (*BinaryExpr) exprNode()               {}
+func (e *BinaryExpr) Pos() (int, int)       { return e.Operator.Line, e.Operator.Column }
+func (e *BinaryExpr) PosToken() token.Token { return e.Operator }
 
 type UnaryExpr struct {
 	Operator token.Token
 	Right    Expr
 }
 
-func (*UnaryExpr) exprNode()         {}
-func (e *UnaryExpr) Pos() (int, int) { return e.Operator.Line, e.Operator.Column }
+func (*UnaryExpr) exprNode()               {}
+func (e *UnaryExpr) Pos() (int, int)       { return e.Operator.Line, e.Operator.Column }
+func (e *UnaryExpr) PosToken() token.Token { return e.Operator }
+
+// Binding is where the resolver placed a local variable: Depth scopes out from
+// the use, at index Slot of that scope. Unresolved bindings (globals, and names
+// declared after a closure that uses them) are looked up by name.
+type Binding struct {
+	Depth    int
+	Slot     int
+	Resolved bool
+}
 
 type VariableExpr struct {
-	Name token.Token
+	Name    token.Token
+	Binding Binding
 }
 
-func (*VariableExpr) exprNode()         {}
-func (e *VariableExpr) Pos() (int, int) { return e.Name.Line, e.Name.Column }
+func (*VariableExpr) exprNode()               {}
+func (e *VariableExpr) Pos() (int, int)       { return e.Name.Line, e.Name.Column }
+func (e *VariableExpr) PosToken() token.Token { return e.Name }
 
 type LiteralExpr struct {
 	Value token.Token
 }
 
-func (*LiteralExpr) exprNode()         {}
-func (e *LiteralExpr) Pos() (int, int) { return e.Value.Line, e.Value.Column }
+func (*LiteralExpr) exprNode()               {}
+func (e *LiteralExpr) Pos() (int, int)       { return e.Value.Line, e.Value.Column }
+func (e *LiteralExpr) PosToken() token.Token { return e.Value }
 
 type CallExpr struct {
 	Callee    Expr
@@ -55,8 +72,9 @@ type CallExpr struct {
 	TypeArgs  []*TypeExpr
 }
 
-func (*CallExpr) exprNode()         {}
-func (e *CallExpr) Pos() (int, int) { return e.Paren.Line, e.Paren.Column }
+func (*CallExpr) exprNode()               {}
+func (e *CallExpr) Pos() (int, int)       { return e.Paren.Line, e.Paren.Column }
+func (e *CallExpr) PosToken() token.Token { return e.Paren }
 
 type IndexExpr struct {
 	Collection Expr
@@ -64,32 +82,59 @@ type IndexExpr struct {
 	Index      Expr
 }
 
-func (*IndexExpr) exprNode()         {}
-func (e *IndexExpr) Pos() (int, int) { return e.Bracket.Line, e.Bracket.Column }
+func (*IndexExpr) exprNode()               {}
+func (e *IndexExpr) Pos() (int, int)       { return e.Bracket.Line, e.Bracket.Column }
+func (e *IndexExpr) PosToken() token.Token { return e.Bracket }
+
+type SliceExpr struct {
+	Collection Expr
+	Bracket    token.Token
+	Low        Expr
+	High       Expr
+}
+
+func (*SliceExpr) exprNode()               {}
+func (e *SliceExpr) Pos() (int, int)       { return e.Bracket.Line, e.Bracket.Column }
+func (e *SliceExpr) PosToken() token.Token { return e.Bracket }
+
+type RangeExpr struct {
+	Start     Expr
+	Operator  token.Token
+	End       Expr
+	Step      Expr
+	Inclusive bool
+}
+
+func (*RangeExpr) exprNode()               {}
+func (e *RangeExpr) Pos() (int, int)       { return e.Operator.Line, e.Operator.Column }
+func (e *RangeExpr) PosToken() token.Token { return e.Operator }
 
 type GetExpr struct {
 	Object Expr
 	Name   token.Token
 }
 
-func (*GetExpr) exprNode()         {}
-func (e *GetExpr) Pos() (int, int) { return e.Name.Line, e.Name.Column }
+func (*GetExpr) exprNode()               {}
+func (e *GetExpr) Pos() (int, int)       { return e.Name.Line, e.Name.Column }
+func (e *GetExpr) PosToken() token.Token { return e.Name }
 
 type ListExpr struct {
 	Elements []Expr
 	LBracket token.Token
 }
 
-func (*ListExpr) exprNode()         {}
-func (e *ListExpr) Pos() (int, int) { return e.LBracket.Line, e.LBracket.Column }
+func (*ListExpr) exprNode()               {}
+func (e *ListExpr) Pos() (int, int)       { return e.LBracket.Line, e.LBracket.Column }
+func (e *ListExpr) PosToken() token.Token { return e.LBracket }
 
 type DictExpr struct {
 	Pairs  [][2]Expr
 	LBrace token.Token
 }
 
-func (*DictExpr) exprNode()         {}
-func (e *DictExpr) Pos() (int, int) { return e.LBrace.Line, e.LBrace.Column }
+func (*DictExpr) exprNode()               {}
+func (e *DictExpr) Pos() (int, int)       { return e.LBrace.Line, e.LBrace.Column }
+func (e *DictExpr) PosToken() token.Token { return e.LBrace }
 
 type StructLiteralExpr struct {
 	TypeName *TypeExpr
@@ -97,13 +142,51 @@ type StructLiteralExpr struct {
 	LBrace   token.Token
 }
 
-func (*StructLiteralExpr) exprNode()         {}
-func (e *StructLiteralExpr) Pos() (int, int) { return e.LBrace.Line, e.LBrace.Column }
+func (*StructLiteralExpr) exprNode()               {}
+func (e *StructLiteralExpr) Pos() (int, int)       { return e.LBrace.Line, e.LBrace.Column }
+func (e *StructLiteralExpr) PosToken() token.Token { return e.LBrace }
+
+// IfExpr is an if/else used as a value. Each branch yields its last expression
+// statement; ElseIf is set instead of ElseBranch for else-if chains.
+type IfExpr struct {
+	Conditon   Expr
+	ThenBranch *BlockStmt
+	ElseBranch *BlockStmt
+	ElseIf     *IfExpr
+	IfToken    token.Token
+}
+
+func (*IfExpr) exprNode()               {}
+func (e *IfExpr) Pos() (int, int)       { return e.IfToken.Line, e.IfToken.Column }
+func (e *IfExpr) PosToken() token.Token { return e.IfToken }
+
+// TernaryExpr is the cond ? a : b shorthand for an if-expression.
+type TernaryExpr struct {
+	Conditon Expr
+	Question token.Token
+	Then     Expr
+	Else     Expr
+}
+
+func (*TernaryExpr) exprNode()               {}
+func (e *TernaryExpr) Pos() (int, int)       { return e.Question.Line, e.Question.Column }
+func (e *TernaryExpr) PosToken() token.Token { return e.Question }
+
+// SpawnExpr runs Call on a new task and evaluates to a handle that can be joined.
+type SpawnExpr struct {
+	Spawn token.Token
+	Call  *CallExpr
+}
+
+func (*SpawnExpr) exprNode()               {}
+func (e *SpawnExpr) Pos() (int, int)       { return e.Spawn.Line, e.Spawn.Column }
+func (e *SpawnExpr) PosToken() token.Token { return e.Spawn }
 
 type FuncExpr struct {
-	Params []Param
-	Body   *BlockStmt
-	Func   token.Token
+	Params      []Param
+	Body        *BlockStmt
+	Func        token.Token
+	IsGenerator bool
 }
 
 type Param struct {
@@ -111,8 +194,9 @@ type Param struct {
 	Type *TypeExpr
 }
 
-func (*FuncExpr) exprNode()         {}
-func (e *FuncExpr) Pos() (int, int) { return e.Func.Line, e.Func.Column }
+func (*FuncExpr) exprNode()               {}
+func (e *FuncExpr) Pos() (int, int)       { return e.Func.Line, e.Func.Column }
+func (e *FuncExpr) PosToken() token.Token { return e.Func }
 
 //STATEMENTS
 
@@ -129,37 +213,61 @@ func (s *VarStmt) Pos() (int, int) {
 	}
 	return 0, 0
 }
+func (s *VarStmt) PosToken() token.Token {
+	if len(s.Names) > 0 {
+		return s.Names[0]
+	}
+	return token.Token{}
+}
 
 type ShortVarStmt struct {
 	Name token.Token
 	Init Expr
 }
 
-func (*ShortVarStmt) stmtNode()         {}
-func (s *ShortVarStmt) Pos() (int, int) { return s.Name.Line, s.Name.Column }
+func (*ShortVarStmt) stmtNode()               {}
+func (s *ShortVarStmt) Pos() (int, int)       { return s.Name.Line, s.Name.Column }
+func (s *ShortVarStmt) PosToken() token.Token { return s.Name }
 
 type AssignStmt struct {
-	Name  token.Token
-	Value Expr
+	Name    token.Token
+	Value   Expr
+	Binding Binding
+}
+
+func (*AssignStmt) stmtNode()               {}
+func (s *AssignStmt) Pos() (int, int)       { return s.Name.Line, s.Name.Column }
+func (s *AssignStmt) PosToken() token.Token { return s.Name }
+
+// CompoundAssignStmt is an in-place update such as x += 1 or x <<= 2. Operator is
+// the compound token itself; the binary operator is derived from it when executed.
+type CompoundAssignStmt struct {
+	Name     token.Token
+	Operator token.Token
+	Value    Expr
+	Binding  Binding
 }
 
-func (*AssignStmt) stmtNode()         {}
-func (s *AssignStmt) Pos() (int, int) { return s.Name.Line, s.Name.Column }
+func (*CompoundAssignStmt) stmtNode()               {}
+func (s *CompoundAssignStmt) Pos() (int, int)       { return s.Name.Line, s.Name.Column }
+func (s *CompoundAssignStmt) PosToken() token.Token { return s.Name }
 
 type PrintStmt struct {
 	Expr  Expr
 	Print token.Token
 }
 
-func (*PrintStmt) stmtNode()         {}
-func (s *PrintStmt) Pos() (int, int) { return s.Print.Line, s.Print.Column }
+func (*PrintStmt) stmtNode()               {}
+func (s *PrintStmt) Pos() (int, int)       { return s.Print.Line, s.Print.Column }
+func (s *PrintStmt) PosToken() token.Token { return s.Print }
 
 type ExprStmt struct {
 	Expr Expr
 }
 
-func (*ExprStmt) stmtNode()         {}
-func (s *ExprStmt) Pos() (int, int) { return s.Expr.Pos() }
+func (*ExprStmt) stmtNode()               {}
+func (s *ExprStmt) Pos() (int, int)       { return s.Expr.Pos() }
+func (s *ExprStmt) PosToken() token.Token { return s.Expr.PosToken() }
 
 type IfStmt struct {
 	Conditon   Expr
@@ -168,17 +276,20 @@ type IfStmt struct {
 	IfToken    token.Token
 }
 
-func (*IfStmt) stmtNode()         {}
-func (s *IfStmt) Pos() (int, int) { return s.IfToken.Line, s.IfToken.Column }
+func (*IfStmt) stmtNode()               {}
+func (s *IfStmt) Pos() (int, int)       { return s.IfToken.Line, s.IfToken.Column }
+func (s *IfStmt) PosToken() token.Token { return s.IfToken }
 
 type WhileStmt struct {
 	Conditon Expr
 	Body     Stmt
 	While    token.Token
+	Label    token.Token
 }
 
-func (*WhileStmt) stmtNode()         {}
-func (s *WhileStmt) Pos() (int, int) { return s.While.Line, s.While.Column }
+func (*WhileStmt) stmtNode()               {}
+func (s *WhileStmt) Pos() (int, int)       { return s.While.Line, s.While.Column }
+func (s *WhileStmt) PosToken() token.Token { return s.While }
 
 type ForStmt struct {
 	Init     Stmt
@@ -186,18 +297,34 @@ type ForStmt struct {
 	Update   Stmt
 	BodyStmt Stmt
 	For      token.Token
+	Label    token.Token
+}
+
+func (*ForStmt) stmtNode()               {}
+func (s *ForStmt) Pos() (int, int)       { return s.For.Line, s.For.Column }
+func (s *ForStmt) PosToken() token.Token { return s.For }
+
+type ForInStmt struct {
+	Name     token.Token
+	Iterable Expr
+	BodyStmt Stmt
+	For      token.Token
+	Label    token.Token
 }
 
-func (*ForStmt) stmtNode()         {}
-func (s *ForStmt) Pos() (int, int) { return s.For.Line, s.For.Column }
+func (*ForInStmt) stmtNode()               {}
+func (s *ForInStmt) Pos() (int, int)       { return s.For.Line, s.For.Column }
+func (s *ForInStmt) PosToken() token.Token { return s.For }
 
 type BlockStmt struct {
 	Statements []Stmt
 	LBrace     token.Token
+	RBrace     token.Token
 }
 
-func (*BlockStmt) stmtNode()         {}
-func (s *BlockStmt) Pos() (int, int) { return s.LBrace.Line, s.LBrace.Column }
+func (*BlockStmt) stmtNode()               {}
+func (s *BlockStmt) Pos() (int, int)       { return s.LBrace.Line, s.LBrace.Column }
+func (s *BlockStmt) PosToken() token.Token { return s.LBrace }
 
 type FuncStmt struct {
 	Name        token.Token
@@ -207,10 +334,12 @@ type FuncStmt struct {
 	Body        *BlockStmt
 	Return      []*TypeExpr
 	Func        token.Token
+	IsGenerator bool
 }
 
-func (*FuncStmt) stmtNode()         {}
-func (s *FuncStmt) Pos() (int, int) { return s.Func.Line, s.Func.Column }
+func (*FuncStmt) stmtNode()               {}
+func (s *FuncStmt) Pos() (int, int)       { return s.Func.Line, s.Func.Column }
+func (s *FuncStmt) PosToken() token.Token { return s.Func }
 
 type StructStmt struct {
 	Name       token.Token
@@ -218,29 +347,113 @@ type StructStmt struct {
 	Fields     []VarStmt
 	Methods    []FuncStmt
 	Struct     token.Token
+	RBrace     token.Token
 }
 
-func (*StructStmt) stmtNode()         {}
-func (s *StructStmt) Pos() (int, int) { return s.Struct.Line, s.Struct.Column }
+func (*StructStmt) stmtNode()               {}
+func (s *StructStmt) Pos() (int, int)       { return s.Struct.Line, s.Struct.Column }
+func (s *StructStmt) PosToken() token.Token { return s.Struct }
 
 type ReturnStmt struct {
 	Keyword token.Token
 	Values  []Expr
 }
 
-func (*ReturnStmt) stmtNode()         {}
-func (s *ReturnStmt) Pos() (int, int) { return s.Keyword.Line, s.Keyword.Column }
+func (*ReturnStmt) stmtNode()               {}
+func (s *ReturnStmt) Pos() (int, int)       { return s.Keyword.Line, s.Keyword.Column }
+func (s *ReturnStmt) PosToken() token.Token { return s.Keyword }
 
 type BreakStmt struct {
 	Keyword token.Token
+	Label   token.Token
 }
 
-func (*BreakStmt) stmtNode()         {}
-func (s *BreakStmt) Pos() (int, int) { return s.Keyword.Line, s.Keyword.Column }
+func (*BreakStmt) stmtNode()               {}
+func (s *BreakStmt) Pos() (int, int)       { return s.Keyword.Line, s.Keyword.Column }
+func (s *BreakStmt) PosToken() token.Token { return s.Keyword }
 
 type ContinueStmt struct {
 	Keyword token.Token
+	Label   token.Token
+}
+
+func (*ContinueStmt) stmtNode()               {}
+func (s *ContinueStmt) Pos() (int, int)       { return s.Keyword.Line, s.Keyword.Column }
+func (s *ContinueStmt) PosToken() token.Token { return s.Keyword }
+
+type SwitchCase struct {
+	Case      token.Token
+	Values    []Expr
+	IsDefault bool
+	Body      []Stmt
+}
+
+type SwitchStmt struct {
+	Switch  token.Token
+	Subject Expr
+	Cases   []SwitchCase
+	RBrace  token.Token
+}
+
+func (*SwitchStmt) stmtNode()               {}
+func (s *SwitchStmt) Pos() (int, int)       { return s.Switch.Line, s.Switch.Column }
+func (s *SwitchStmt) PosToken() token.Token { return s.Switch }
+
+type FallthroughStmt struct {
+	Keyword token.Token
+}
+
+func (*FallthroughStmt) stmtNode()               {}
+func (s *FallthroughStmt) Pos() (int, int)       { return s.Keyword.Line, s.Keyword.Column }
+func (s *FallthroughStmt) PosToken() token.Token { return s.Keyword }
+
+// SelectCase is one arm of a select. Comm is a recv(c) or send(c, v) call;
+// Name, when set, binds the received value for the body.
+type SelectCase struct {
+	Case      token.Token
+	Name      token.Token
+	Comm      *CallExpr
+	IsDefault bool
+	Body      []Stmt
+}
+
+// YieldStmt hands Value to the consumer of the enclosing generator.
+type YieldStmt struct {
+	Keyword token.Token
+	Value   Expr
+}
+
+func (*YieldStmt) stmtNode()               {}
+func (s *YieldStmt) Pos() (int, int)       { return s.Keyword.Line, s.Keyword.Column }
+func (s *YieldStmt) PosToken() token.Token { return s.Keyword }
+
+type SelectStmt struct {
+	Select token.Token
+	Cases  []SelectCase
+	RBrace token.Token
+}
+
+func (*SelectStmt) stmtNode()               {}
+func (s *SelectStmt) Pos() (int, int)       { return s.Select.Line, s.Select.Column }
+func (s *SelectStmt) PosToken() token.Token { return s.Select }
+
+// BadStmt stands in for source from From to To that failed to parse, so the
+// statements around it still make up a program.
+type BadStmt struct {
+	From token.Token
+	To   token.Token
+}
+
+func (*BadStmt) stmtNode()               {}
+func (s *BadStmt) Pos() (int, int)       { return s.From.Line, s.From.Column }
+func (s *BadStmt) PosToken() token.Token { return s.From }
+
+// BadExpr is the BadStmt of an expression.
+type BadExpr struct {
+	From token.Token
+	To   token.Token
 }
 
-func (*ContinueStmt) stmtNode()         {}
-func (s *ContinueStmt) Pos() (int, int) { return s.Keyword.Line, s.Keyword.Column }
+func (*BadExpr) exprNode()               {}
+func (e *BadExpr) Pos() (int, int)       { return e.From.Line, e.From.Column }
+func (e *BadExpr) PosToken() token.Token { return e.From }
@@ -0,0 +1,185 @@
+package nifast
+
+import (
+	"maps"
+	"slices"
+
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+)
+
+// Node is any expression or statement.
+type Node interface {
+	Pos() (line, column int)
+	PosToken() token.Token
+}
+
+// Inspect visits node and then its children in source order. If f returns
+// false, the children of that node are skipped. Nil nodes are not visited.
+func Inspect(node Node, f func(Node) bool) {
+	if isNil(node) || !f(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *BinaryExpr:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *UnaryExpr:
+		Inspect(n.Right, f)
+	case *CallExpr:
+		Inspect(n.Callee, f)
+		for _, arg := range n.Arguments {
+			Inspect(arg, f)
+		}
+	case *IndexExpr:
+		Inspect(n.Collection, f)
+		Inspect(n.Index, f)
+	case *SliceExpr:
+		Inspect(n.Collection, f)
+		Inspect(n.Low, f)
+		Inspect(n.High, f)
+	case *RangeExpr:
+		Inspect(n.Start, f)
+		Inspect(n.End, f)
+		Inspect(n.Step, f)
+	case *GetExpr:
+		Inspect(n.Object, f)
+	case *ListExpr:
+		for _, elem := range n.Elements {
+			Inspect(elem, f)
+		}
+	case *DictExpr:
+		for _, pair := range n.Pairs {
+			Inspect(pair[0], f)
+			Inspect(pair[1], f)
+		}
+	case *StructLiteralExpr:
+		for _, name := range slices.Sorted(maps.Keys(n.Fields)) {
+			Inspect(n.Fields[name], f)
+		}
+	case *IfExpr:
+		Inspect(n.Conditon, f)
+		Inspect(n.ThenBranch, f)
+		if n.ElseIf != nil {
+			Inspect(n.ElseIf, f)
+		} else {
+			Inspect(n.ElseBranch, f)
+		}
+	case *TernaryExpr:
+		Inspect(n.Conditon, f)
+		Inspect(n.Then, f)
+		Inspect(n.Else, f)
+	case *SpawnExpr:
+		Inspect(n.Call, f)
+	case *FuncExpr:
+		Inspect(n.Body, f)
+	case *VarStmt:
+		Inspect(n.Init, f)
+	case *ShortVarStmt:
+		Inspect(n.Init, f)
+	case *AssignStmt:
+		Inspect(n.Value, f)
+	case *CompoundAssignStmt:
+		Inspect(n.Value, f)
+	case *PrintStmt:
+		Inspect(n.Expr, f)
+	case *ExprStmt:
+		Inspect(n.Expr, f)
+	case *IfStmt:
+		Inspect(n.Conditon, f)
+		Inspect(n.ThenBranch, f)
+		Inspect(n.ElseBranch, f)
+	case *WhileStmt:
+		Inspect(n.Conditon, f)
+		Inspect(n.Body, f)
+	case *ForStmt:
+		Inspect(n.Init, f)
+		Inspect(n.CondExpr, f)
+		Inspect(n.BodyStmt, f)
+		Inspect(n.Update, f)
+	case *ForInStmt:
+		Inspect(n.Iterable, f)
+		Inspect(n.BodyStmt, f)
+	case *BlockStmt:
+		for _, stmt := range n.Statements {
+			Inspect(stmt, f)
+		}
+	case *FuncStmt:
+		Inspect(n.Body, f)
+	case *StructStmt:
+		for idx := range n.Fields {
+			Inspect(&n.Fields[idx], f)
+		}
+		for idx := range n.Methods {
+			Inspect(&n.Methods[idx], f)
+		}
+	case *ReturnStmt:
+		for _, val := range n.Values {
+			Inspect(val, f)
+		}
+	case *SwitchStmt:
+		Inspect(n.Subject, f)
+		for _, clause := range n.Cases {
+			for _, val := range clause.Values {
+				Inspect(val, f)
+			}
+			for _, stmt := range clause.Body {
+				Inspect(stmt, f)
+			}
+		}
+	case *SelectStmt:
+		for _, clause := range n.Cases {
+			if clause.Comm != nil {
+				Inspect(clause.Comm, f)
+			}
+			for _, stmt := range clause.Body {
+				Inspect(stmt, f)
+			}
+		}
+	case *YieldStmt:
+		Inspect(n.Value, f)
+	}
+}
+
+// isNil reports whether node is nil or a nil pointer held in the interface,
+// as left by optional fields such as IfStmt.ElseBranch.
+func isNil(node Node) bool {
+	switch n := node.(type) {
+	case nil:
+		return true
+	case *BlockStmt:
+		return n == nil
+	case *IfExpr:
+		return n == nil
+	case *CallExpr:
+		return n == nil
+	}
+	return false
+}
+
+// Bounds returns the first and last of the tokens that locate node and the
+// nodes inside it, which together span the source node was parsed from.
+func Bounds(node Node) (first, last token.Token) {
+	first, last = node.PosToken(), node.PosToken()
+	widen := func(tok token.Token) {
+		if tok.Line == 0 {
+			return
+		}
+		if tok.Line < first.Line || (tok.Line == first.Line && tok.Column < first.Column) {
+			first = tok
+		}
+		if tok.Line > last.Line || (tok.Line == last.Line && tok.Column > last.Column) {
+			last = tok
+		}
+	}
+	Inspect(node, func(n Node) bool {
+		widen(n.PosToken())
+		switch bad := n.(type) {
+		case *BadStmt:
+			widen(bad.To)
+		case *BadExpr:
+			widen(bad.To)
+		}
+		return true
+	})
+	return first, last
+}
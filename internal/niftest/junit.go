@@ -0,0 +1,100 @@
+package niftest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes files as JUnit XML, one test suite per file. A file that
+// could not run is a suite with a single errored test case.
+func WriteJUnit(w io.Writer, files []File) error {
+	var out junitSuites
+	for _, file := range files {
+		suite := junitSuite{Name: file.Path}
+		var total time.Duration
+		for _, err := range file.Errs {
+			suite.Errors++
+			suite.Cases = append(suite.Cases, junitCase{
+				Name:      "(file)",
+				ClassName: file.Path,
+				Time:      seconds(0),
+				Error:     &junitMessage{Message: err.Error(), Text: describe(file, err)},
+			})
+		}
+		for _, result := range file.Results {
+			total += result.Duration
+			tc := junitCase{Name: result.Name, ClassName: file.Path, Time: seconds(result.Duration)}
+			switch result.Status {
+			case Fail:
+				suite.Failures++
+				tc.Failure = &junitMessage{Message: niferrors.As(result.Err).Message, Text: describe(file, result.Err)}
+			case Skip:
+				suite.Skipped++
+				tc.Skipped = &junitMessage{Message: niferrors.As(result.Err).Message}
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suite.Tests = len(suite.Cases)
+		suite.Time = seconds(total)
+		out.Suites = append(out.Suites, suite)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// describe renders err as the CLI would, for the body of a failure.
+func describe(file File, err error) string {
+	nifErr := niferrors.As(err)
+	if nifErr.File == "" {
+		nifErr.File = file.Path
+	}
+	var text strings.Builder
+	niferrors.Render(&text, nifErr, file.Source)
+	return text.String()
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
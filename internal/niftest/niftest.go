@@ -0,0 +1,224 @@
+// Package niftest runs the tests written in Niftel: every function named
+// test_* in a *_test.nif file. Each test runs in an interpreter of its own,
+// after the top-level statements of its file, and passes unless it raises an
+// error. The assert_* builtins raise the errors; skip() skips the test.
+package niftest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/coverage"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+)
+
+// Suffix ends the name of every test file.
+const Suffix = "_test.nif"
+
+// Prefix begins the name of every test function.
+const Prefix = "test_"
+
+// Status is the outcome of a test.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Fail Status = "fail"
+	Skip Status = "skip"
+)
+
+// Result is the outcome of one test function.
+type Result struct {
+	Name     string
+	Status   Status
+	Duration time.Duration
+	// Err is why the test failed or was skipped.
+	Err error
+}
+
+// File is the outcome of the tests in one file. Errs holds the errors that
+// kept the file from running at all: it could not be read, parsed or checked.
+type File struct {
+	Path    string
+	Source  string
+	Results []Result
+	Errs    []error
+}
+
+// Options controls which tests run and how.
+type Options struct {
+	// Run, if set, selects the tests whose names it matches.
+	Run *regexp.Regexp
+	// TraceDepth is the traceback depth of a failing test's error.
+	TraceDepth int
+	// Cover, if set, records the lines each test runs.
+	Cover *coverage.Profile
+}
+
+// Discover lists the test files named by patterns, in order. A pattern is a
+// file, a directory, whose test files are listed, or a directory followed by
+// "/..." for the test files of the whole tree below it.
+func Discover(patterns []string) ([]string, error) {
+	var files []string
+	seen := map[string]bool{}
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+	for _, pattern := range patterns {
+		dir, recursive := strings.CutSuffix(pattern, "...")
+		if recursive {
+			dir = filepath.Clean(strings.TrimSuffix(dir, "/"))
+			if dir == "" {
+				dir = "."
+			}
+			err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !entry.IsDir() && strings.HasSuffix(path, Suffix) {
+					add(path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		info, err := os.Stat(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			add(pattern)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(pattern, "*"+Suffix))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range matches {
+			add(path)
+		}
+	}
+	return files, nil
+}
+
+// RunFile runs the tests in the file at path.
+func RunFile(path string, opts Options) File {
+	file := File{Path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		file.Errs = []error{err}
+		return file
+	}
+	file.Source = string(data)
+	file.Results, file.Errs = run(path, file.Source, opts)
+	return file
+}
+
+// Run runs the tests in source. It returns the errors that kept the source
+// from running, if any, instead of results.
+func Run(source string, opts Options) ([]Result, []error) {
+	return run("", source, opts)
+}
+
+// run runs the tests in source, which was read from path.
+func run(path, source string, opts Options) ([]Result, []error) {
+	stmts, err := parser.New(lexer.New(source)).Parse()
+	if err != nil {
+		return nil, parser.Errors(err)
+	}
+	if errs, _ := interpreter.NewInterpreter().Check(stmts); len(errs) > 0 {
+		return nil, errs
+	}
+	if opts.Cover != nil {
+		opts.Cover.Register(path, stmts)
+	}
+	var results []Result
+	for _, test := range Tests(stmts) {
+		if opts.Run != nil && !opts.Run.MatchString(test.Name.Lexeme) {
+			continue
+		}
+		results = append(results, runTest(path, stmts, test, opts))
+	}
+	return results, nil
+}
+
+// Tests returns the test functions among stmts: the top-level functions
+// named test_* that take no parameters.
+func Tests(stmts []ast.Stmt) []*ast.FuncStmt {
+	var tests []*ast.FuncStmt
+	for _, stmt := range stmts {
+		fn, ok := stmt.(*ast.FuncStmt)
+		if ok && strings.HasPrefix(fn.Name.Lexeme, Prefix) && len(fn.Params) == 0 && len(fn.TypeParams) == 0 {
+			tests = append(tests, fn)
+		}
+	}
+	return tests
+}
+
+// runTest runs the top-level statements of the file in a fresh interpreter,
+// then calls test.
+func runTest(path string, stmts []ast.Stmt, test *ast.FuncStmt, opts Options) Result {
+	result := Result{Name: test.Name.Lexeme}
+	start := time.Now()
+	result.Err = execute(path, stmts, test, opts)
+	result.Duration = time.Since(start)
+	switch {
+	case result.Err == nil:
+		result.Status = Pass
+	case errors.Is(result.Err, interpreter.ErrSkipped):
+		result.Status = Skip
+	default:
+		result.Status = Fail
+	}
+	return result
+}
+
+func execute(path string, stmts []ast.Stmt, test *ast.FuncStmt, opts Options) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	interp := interpreter.NewInterpreter()
+	interp.TraceDepth = opts.TraceDepth
+	if opts.Cover != nil {
+		interp.Hook = opts.Cover.Hook(path)
+	}
+	for _, stmt := range stmts {
+		if result := interp.Execute(stmt); result.Err != nil {
+			return result.Err
+		}
+	}
+	call := &ast.CallExpr{Callee: &ast.VariableExpr{Name: test.Name}, Paren: test.Name}
+	return interp.Eval(call).Err
+}
+
+// Counts returns how many of results passed, failed and were skipped.
+func Counts(results []Result) (passed, failed, skipped int) {
+	for _, result := range results {
+		switch result.Status {
+		case Pass:
+			passed++
+		case Fail:
+			failed++
+		case Skip:
+			skipped++
+		}
+	}
+	return passed, failed, skipped
+}
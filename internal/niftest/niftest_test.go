@@ -0,0 +1,180 @@
+package niftest_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/coverage"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/niftest"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+)
+
+const source = `counter := 0
+
+func double(n: int) -> int {
+	return n * 2
+}
+
+func test_double() {
+	assert_eq(double(2), 4)
+}
+
+func test_fresh_globals() {
+	counter = counter + 1
+	assert_eq(counter, 1)
+}
+
+func test_again() {
+	counter = counter + 1
+	assert_eq(counter, 1, "globals leak between tests")
+}
+
+func test_wrong() {
+	assert_eq(double(2), 5)
+}
+
+func test_later() {
+	skip("not written")
+}
+
+func test_with_param(n: int) {
+	assert_true(false)
+}
+
+func helper() {
+	assert_true(false)
+}
+`
+
+func statuses(results []niftest.Result) string {
+	var parts []string
+	for _, result := range results {
+		parts = append(parts, result.Name+"="+string(result.Status))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestRun(t *testing.T) {
+	value.BuiltinTypesInit()
+	results, errs := niftest.Run(source, niftest.Options{})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	want := "test_double=pass test_fresh_globals=pass test_again=pass test_wrong=fail test_later=skip"
+	if got := statuses(results); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if passed, failed, skipped := niftest.Counts(results); passed != 3 || failed != 1 || skipped != 1 {
+		t.Errorf("expected 3/1/1, got %d/%d/%d", passed, failed, skipped)
+	}
+	if msg := results[3].Err.Error(); !strings.Contains(msg, "expected 5, got 4") {
+		t.Errorf("unexpected failure %q", msg)
+	}
+}
+
+func TestRun_Filter(t *testing.T) {
+	value.BuiltinTypesInit()
+	results, _ := niftest.Run(source, niftest.Options{Run: regexp.MustCompile("double|wrong")})
+	if got := statuses(results); got != "test_double=pass test_wrong=fail" {
+		t.Errorf("unexpected results %q", got)
+	}
+}
+
+func TestRun_Cover(t *testing.T) {
+	value.BuiltinTypesInit()
+	cover := coverage.New()
+	niftest.Run(source, niftest.Options{Run: regexp.MustCompile("double|fresh"), Cover: cover})
+	files := cover.Files()
+	if len(files) != 1 {
+		t.Fatalf("expected one file, got %d", len(files))
+	}
+	// Each test runs the top-level statements of the file again.
+	for line, want := range map[int]int64{1: 2, 4: 1, 8: 1, 12: 1, 17: 0, 48: 0} {
+		if got := files[0].Lines[line]; got != want {
+			t.Errorf("line %d: expected %d hits, got %d", line, want, got)
+		}
+	}
+}
+
+func TestRun_BrokenFile(t *testing.T) {
+	value.BuiltinTypesInit()
+	if _, errs := niftest.Run("func test_x() {\n\tx := )\n}", niftest.Options{}); len(errs) != 1 {
+		t.Errorf("expected a syntax error, got %v", errs)
+	}
+	if _, errs := niftest.Run("func test_x() {\n\tassert_eq(1, \"a\")\n}", niftest.Options{}); len(errs) != 1 {
+		t.Errorf("expected a type error, got %v", errs)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a_test.nif", "a.nif", "sub/b_test.nif", "sub/deeper/c_test.nif"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rel := func(paths []string) []string {
+		out := make([]string, len(paths))
+		for idx, path := range paths {
+			out[idx], _ = filepath.Rel(dir, path)
+		}
+		return out
+	}
+
+	got, err := niftest.Discover([]string{dir})
+	if err != nil || !slices.Equal(rel(got), []string{"a_test.nif"}) {
+		t.Errorf("directory: got %v, %v", rel(got), err)
+	}
+	got, err = niftest.Discover([]string{dir + "/...", filepath.Join(dir, "a_test.nif")})
+	want := []string{"a_test.nif", "sub/b_test.nif", "sub/deeper/c_test.nif"}
+	if err != nil || !slices.Equal(rel(got), want) {
+		t.Errorf("tree: expected %v, got %v, %v", want, rel(got), err)
+	}
+	if _, err := niftest.Discover([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Error("expected an error for a missing path")
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	value.BuiltinTypesInit()
+	results, _ := niftest.Run(source, niftest.Options{})
+	files := []niftest.File{{Path: "double_test.nif", Source: source, Results: results}}
+	var out bytes.Buffer
+	if err := niftest.WriteJUnit(&out, files); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Suites []struct {
+			Name     string `xml:"name,attr"`
+			Tests    int    `xml:"tests,attr"`
+			Failures int    `xml:"failures,attr"`
+			Skipped  int    `xml:"skipped,attr"`
+			Cases    []struct {
+				Name    string `xml:"name,attr"`
+				Failure *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"failure"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, out.String())
+	}
+	suite := doc.Suites[0]
+	if suite.Name != "double_test.nif" || suite.Tests != 5 || suite.Failures != 1 || suite.Skipped != 1 {
+		t.Errorf("unexpected suite %+v", suite)
+	}
+	if failure := suite.Cases[3].Failure; failure == nil || failure.Message != "assert_eq failed: expected 5, got 4" {
+		t.Errorf("unexpected failure %+v", failure)
+	}
+}
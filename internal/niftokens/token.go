@@ -45,6 +45,18 @@ const (
 	TokenNewLine
 	TokenArrow
 	TokenColonEqual
+	TokenDotDot
+	TokenDotDotEq
+	TokenCaret
+	TokenTilde
+	TokenShl
+	TokenShr
+	TokenAmperEq
+	TokenPipeEq
+	TokenCaretEq
+	TokenShlEq
+	TokenShrEq
+	TokenQuestion
 	TokenIllegal
 
 	//Keywords
@@ -66,6 +78,13 @@ const (
 	TokenPrint
 	TokenBreak
 	TokenContinue
+	TokenSwitch
+	TokenCase
+	TokenDefault
+	TokenFallthrough
+	TokenSpawn
+	TokenSelect
+	TokenYield
 )
 
 var tokenTypeToString = map[TokenType]string{
@@ -106,6 +125,18 @@ var tokenTypeToString = map[TokenType]string{
 	TokenColon:      ":",
 	TokenSemicolon:  ";",
 	TokenDot:        ".",
+	TokenDotDot:     "..",
+	TokenDotDotEq:   "..=",
+	TokenCaret:      "^",
+	TokenTilde:      "~",
+	TokenShl:        "<<",
+	TokenShr:        ">>",
+	TokenAmperEq:    "&=",
+	TokenPipeEq:     "|=",
+	TokenCaretEq:    "^=",
+	TokenShlEq:      "<<=",
+	TokenShrEq:      ">>=",
+	TokenQuestion:   "?",
 	TokenTrue:       "true",
 	TokenT:          "type",
 	TokenStruct:     "struct",
@@ -113,19 +144,26 @@ var tokenTypeToString = map[TokenType]string{
 	TokenAs:         "as",
 	TokenNull:       "null",
 	// TokenNil:        "nil",
-	TokenFalse:    "false",
-	TokenIf:       "if",
-	TokenElse:     "else",
-	TokenFor:      "for",
-	TokenIn:       "in",
-	TokenVar:      "var",
-	TokenFunc:     "func",
-	TokenReturn:   "return",
-	TokenWhile:    "while",
-	TokenPrint:    "print",
-	TokenBreak:    "break",
-	TokenContinue: "continue",
-	TokenNewLine:  "\n",
+	TokenFalse:       "false",
+	TokenIf:          "if",
+	TokenElse:        "else",
+	TokenFor:         "for",
+	TokenIn:          "in",
+	TokenVar:         "var",
+	TokenFunc:        "func",
+	TokenReturn:      "return",
+	TokenWhile:       "while",
+	TokenPrint:       "print",
+	TokenBreak:       "break",
+	TokenContinue:    "continue",
+	TokenSwitch:      "switch",
+	TokenCase:        "case",
+	TokenDefault:     "default",
+	TokenFallthrough: "fallthrough",
+	TokenSpawn:       "spawn",
+	TokenSelect:      "select",
+	TokenYield:       "yield",
+	TokenNewLine:     "\n",
 }
 
 // var tokenKeyWords = map[string]TokenType{
@@ -149,6 +187,17 @@ var tokenTypeToString = map[TokenType]string{
 // 	"continue": TokenContinue,
 // }
 
+// CompoundOperators maps each compound assignment token to the binary operator it applies.
+var CompoundOperators = map[TokenType]TokenType{
+	TokenPlusEq:  TokenPlus,
+	TokenMinEq:   TokenMinus,
+	TokenAmperEq: TokenAmper,
+	TokenPipeEq:  TokenPipe,
+	TokenCaretEq: TokenCaret,
+	TokenShlEq:   TokenShl,
+	TokenShrEq:   TokenShr,
+}
+
 func (tt TokenType) String() string {
 	if s, ok := tokenTypeToString[tt]; ok {
 		return s
@@ -162,4 +211,17 @@ type Token struct {
 	Data   interface{}
 	Line   int
 	Column int
+	// Comments holds the comments between the previous token and this one.
+	Comments []Comment
+}
+
+// Comment is a // or /* */ comment. The lexer attaches comments to the token
+// that follows them rather than emitting them as tokens.
+type Comment struct {
+	// Text is the comment including its markers, without the newline that
+	// ends a // comment.
+	Text string
+	Line int
+	// Inline is set when the comment follows code on the same line.
+	Inline bool
 }
@@ -0,0 +1,49 @@
+package parser_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+)
+
+// FuzzParse checks that parsing any source returns, and that every node of
+// the statements it returns, even alongside errors, lies inside the source.
+func FuzzParse(f *testing.F) {
+	for _, pattern := range []string{"../../cmd/niftel/testdata/*.nif", "../format/testdata/*.input"} {
+		paths, _ := filepath.Glob(pattern)
+		for _, path := range paths {
+			if data, err := os.ReadFile(path); err == nil {
+				f.Add(string(data))
+			}
+		}
+	}
+	f.Add("func f() {\n\tx := (1 +\n}\n}\ny := ")
+	f.Fuzz(func(t *testing.T, src string) {
+		lines := strings.Split(src, "\n")
+		inside := func(tok token.Token) bool {
+			if tok.Line == 0 && tok.Lexeme == "" {
+				// A node may leave out a part, such as an else branch.
+				return true
+			}
+			return tok.Line >= 1 && tok.Line <= len(lines) && tok.Column >= 0 &&
+				tok.Column <= utf8.RuneCountInString(lines[tok.Line-1])
+		}
+		stmts, _ := parser.New(lexer.New(src)).Parse()
+		for _, stmt := range stmts {
+			ast.Inspect(stmt, func(node ast.Node) bool {
+				first, last := ast.Bounds(node)
+				if !inside(first) || !inside(last) {
+					t.Fatalf("%T spans %d:%d to %d:%d, outside the source", node, first.Line, first.Column, last.Line, last.Column)
+				}
+				return true
+			})
+		}
+	})
+}
@@ -3,51 +3,100 @@ package parser
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
 	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
 	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/trace"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
 )
 
+// ErrIncomplete is wrapped by syntax errors at the end of the input that more
+// input could fix, such as an unclosed parenthesis.
 var ErrIncomplete = errors.New("incomplete input")
 
+// incomplete marks a syntax error at the end of the input as ErrIncomplete.
+func incomplete(err error) error {
+	if nifErr, ok := err.(*niferrors.NifError); ok {
+		nifErr.Err = ErrIncomplete
+		return nifErr
+	}
+	return ErrIncomplete
+}
+
+var log = trace.Logger(trace.Parser)
+
 type Parser struct {
-	src      lexer.TokenSource
-	curr     token.Token
-	ahead    token.Token
-	hasAhead bool
-	prev     token.Token
-	err      error
+	src         lexer.TokenSource
+	curr        token.Token
+	ahead       []token.Token
+	prev        token.Token
+	err         error
+	noStructLit bool
+	labels      []string
+	// yielded is set when the function being parsed contains a yield; it is
+	// nil outside function bodies.
+	yielded *bool
+	// errs holds the errors recovered from so far.
+	errs []error
+	// pos counts the tokens consumed and depth the braces open after them.
+	pos   int
+	depth int
+	// MaxErrors is the number of errors after which Parse stops; zero or
+	// less means no limit.
+	MaxErrors int
 }
 
 func New(src lexer.TokenSource) *Parser {
 	p := &Parser{
-		src: src,
+		src:       src,
+		MaxErrors: DefaultMaxErrors,
 	}
 	p.advance()
 	return p
 }
 
+// Parse parses the whole input. A statement with a syntax error is replaced
+// by an ast.BadStmt and parsing resumes at the next statement, so all the
+// errors in the input, up to MaxErrors, are reported together as an
+// ErrorList, along with the statements that did parse.
 func (p *Parser) Parse() ([]ast.Stmt, error) {
 	var statements []ast.Stmt
 	err := p.skipnewLines()
-	if err != nil {
-		return nil, err
-	}
-	for !p.isAtEnd() {
-		stmt, err := p.statement()
+	for err == nil && !p.isAtEnd() {
+		start := p.mark()
+		var stmt ast.Stmt
+		if p.check(token.TokenRBrace) {
+			err = p.errorAt(p.curr, niferrors.CodeUnexpectedToken, "unexpected '}' outside a block")
+		} else {
+			stmt, err = p.statement()
+		}
 		if err != nil {
-			return nil, err
+			stmt, err = p.badStmt(err, start)
 		}
 		if stmt != nil {
 			statements = append(statements, stmt)
 		}
-		err = p.skipnewLines()
-		if err != nil {
-			return nil, err
+		if err == nil {
+			err = p.skipnewLines()
+		}
+	}
+	if len(p.errs) == 0 {
+		return statements, nil
+	}
+	// Only an error at the very start of what is missing can be fixed by
+	// more input; later errors at the end of the input follow from earlier
+	// ones.
+	if !errors.Is(p.errs[0], ErrIncomplete) {
+		for _, err := range p.errs[1:] {
+			if nifErr, ok := err.(*niferrors.NifError); ok && nifErr.Err == ErrIncomplete {
+				nifErr.Err = nil
+			}
 		}
 	}
-	return statements, nil
+	return statements, ErrorList(p.errs)
 }
 
 func (p *Parser) match(types ...token.TokenType) (bool, error) {
@@ -68,34 +117,60 @@ func (p *Parser) isAtEnd() bool {
 }
 
 func (p *Parser) peek() (token.Token, error) {
-	if !p.hasAhead {
-		tok, err := p.src.NextToken()
+	return p.peekN(1)
+}
+
+// peekN returns the nth token after the current one, buffering as needed.
+func (p *Parser) peekN(n int) (token.Token, error) {
+	for len(p.ahead) < n {
+		tok, err := p.next()
 		if err != nil {
 			return token.Token{}, err
 		}
-		p.ahead = tok
-		p.hasAhead = true
+		p.ahead = append(p.ahead, tok)
+	}
+	return p.ahead[n-1], nil
+}
+
+// next returns the next token from the source. A lexical error is recorded
+// and the token after it returned instead, as the lexer has already moved
+// past the bad input.
+func (p *Parser) next() (token.Token, error) {
+	for {
+		tok, err := p.src.NextToken()
+		if err == nil {
+			return tok, nil
+		}
+		if err := p.record(err); err != nil {
+			return token.Token{}, err
+		}
 	}
-	return p.ahead, nil
 }
 
 func (p *Parser) advance() error {
-	p.prev = p.curr
-	if p.hasAhead {
-		p.curr = p.ahead
-		p.hasAhead = false
+	tok := p.curr
+	if len(p.ahead) > 0 {
+		tok, p.ahead = p.ahead[0], p.ahead[1:]
 	} else {
-		tok, err := p.src.NextToken()
+		next, err := p.next()
 		if err != nil {
 			return err
 		}
-		p.curr = tok
+		tok = next
+	}
+	p.prev, p.curr = p.curr, tok
+	p.pos++
+	switch p.prev.Type {
+	case token.TokenLBrace:
+		p.depth++
+	case token.TokenRBrace:
+		p.depth--
 	}
 	// if !p.isAtEnd() {
 	// 	p.current++
 	// }
 	// return p.previous()
-	fmt.Printf("[ADVANCE] Current: %v (type=%v, line=%v, lexeme=%q)", p.curr.Type, p.curr.Type, p.curr.Line, p.curr.Lexeme)
+	log.Debug("advance", "type", p.curr.Type, "lexeme", p.curr.Lexeme, "line", p.curr.Line)
 	return nil
 }
 
@@ -104,6 +179,24 @@ func (p *Parser) previous() token.Token {
 	// return p.tokens[p.current-1]
 }
 
+// errorAt returns a syntax error spanning tok. An error at the end of the
+// input is placed just after the last token, rather than on the empty line
+// a trailing newline leaves.
+func (p *Parser) errorAt(tok token.Token, code niferrors.Code, format string, args ...any) *niferrors.NifError {
+	if tok.Type == token.TokenEOF && p.prev.Line > 0 {
+		tok = token.Token{Type: token.TokenEOF, Line: p.prev.Line, Column: p.prev.Column + 1}
+	}
+	return niferrors.New(niferrors.ParseError, code, tok, format, args...)
+}
+
+// describe names tok for an error message.
+func describe(tok token.Token) string {
+	if tok.Type == token.TokenEOF {
+		return "end of input"
+	}
+	return fmt.Sprintf("'%s'", strings.TrimSpace(tok.Lexeme))
+}
+
 func (p *Parser) consume(tt token.TokenType, message string) (token.Token, error) {
 	if p.check(tt) {
 		tok := p.curr
@@ -113,7 +206,7 @@ func (p *Parser) consume(tt token.TokenType, message string) (token.Token, error
 		return tok, nil
 	}
 
-	return token.Token{}, fmt.Errorf("[Parse error] %s. Got '%s' at line %d", message, p.curr.Lexeme, p.curr.Line)
+	return token.Token{}, p.errorAt(p.curr, niferrors.CodeExpectedToken, "%s, got %s", message, describe(p.curr))
 	// 	return p.advance()
 	// }
 	// panic(fmt.Sprintf("[Parse error] %s. Got '%s' at line %d", message, p.peek().Lexeme, p.peek().Line))
@@ -133,7 +226,87 @@ func (p *Parser) skipnewLines() error {
 }
 
 func (p *Parser) expression() (ast.Expr, error) {
-	return p.orExpr()
+	return p.ternaryExpr()
+}
+
+// ternaryExpr parses cond ? a : b, which binds loosest and associates to the right.
+func (p *Parser) ternaryExpr() (ast.Expr, error) {
+	cond, err := p.orExpr()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := p.match(token.TokenQuestion)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return cond, nil
+	}
+	question := p.previous()
+	thenExpr, err := p.ternaryExpr()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(token.TokenColon, "expected ':' in conditional expression")
+	if err != nil {
+		return nil, err
+	}
+	elseExpr, err := p.ternaryExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.TernaryExpr{
+		Conditon: cond,
+		Question: question,
+		Then:     thenExpr,
+		Else:     elseExpr,
+	}, nil
+}
+
+func (p *Parser) ifExpression() (*ast.IfExpr, error) {
+	ifTok := p.previous()
+	cond, err := p.headerExpression()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(token.TokenLBrace, "expect '{' after if condition")
+	if err != nil {
+		return nil, err
+	}
+	thenBranch, err := p.blockStatement()
+	if err != nil {
+		return nil, err
+	}
+	expr := &ast.IfExpr{
+		Conditon:   cond,
+		ThenBranch: thenBranch,
+		IfToken:    ifTok,
+	}
+
+	_, err = p.consume(token.TokenElse, "if expression requires an else branch")
+	if err != nil {
+		return nil, err
+	}
+	ok, err := p.match(token.TokenIf)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		expr.ElseIf, err = p.ifExpression()
+		if err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+	_, err = p.consume(token.TokenLBrace, "expect '{' after else")
+	if err != nil {
+		return nil, err
+	}
+	expr.ElseBranch, err = p.blockStatement()
+	if err != nil {
+		return nil, err
+	}
+	return expr, nil
 }
 
 func (p *Parser) orExpr() (ast.Expr, error) {
@@ -201,7 +374,7 @@ func (p *Parser) equalityExpr() (ast.Expr, error) {
 		return nil, err
 	}
 	for {
-		m, err := p.match(token.TokenEqality)
+		m, err := p.match(token.TokenEqality, token.TokenBangEqal)
 		if err != nil {
 			return nil, err
 		}
@@ -224,10 +397,20 @@ func (p *Parser) equalityExpr() (ast.Expr, error) {
 }
 
 func (p *Parser) parseTypeExpr() (*ast.TypeExpr, error) {
-	fmt.Printf("parseTypeExpr: current token = %v\n", p.curr)
-	name, err := p.consume(token.TokenIdentifier, "expected type name")
-	if err != nil {
-		return nil, err
+	log.Debug("type expression", "lexeme", p.curr.Lexeme, "line", p.curr.Line)
+	var name token.Token
+	if p.check(token.TokenFunc) {
+		// func is a keyword, and also the type of every function value.
+		name = p.curr
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		name, err = p.consume(token.TokenIdentifier, "expected type name")
+		if err != nil {
+			return nil, err
+		}
 	}
 	typeExpr := &ast.TypeExpr{
 		Name:     name,
@@ -263,7 +446,7 @@ func (p *Parser) parseTypeExpr() (*ast.TypeExpr, error) {
 }
 
 func (p *Parser) comparissonExpr() (ast.Expr, error) {
-	left, err := p.termExpr()
+	left, err := p.rangeExpr()
 	if err != nil {
 		return nil, err
 	}
@@ -271,7 +454,8 @@ func (p *Parser) comparissonExpr() (ast.Expr, error) {
 		m, err := p.match(token.TokenLess,
 			token.TokenLessEq,
 			token.TokenGreater,
-			token.TokenGreaterEq)
+			token.TokenGreaterEq,
+			token.TokenIn)
 		if err != nil {
 			return nil, err
 		}
@@ -279,7 +463,7 @@ func (p *Parser) comparissonExpr() (ast.Expr, error) {
 			break
 		}
 		operator := p.previous()
-		right, err := p.termExpr()
+		right, err := p.rangeExpr()
 		if err != nil {
 			return nil, err
 		}
@@ -294,6 +478,88 @@ func (p *Parser) comparissonExpr() (ast.Expr, error) {
 	return left, nil
 }
 
+func (p *Parser) rangeExpr() (ast.Expr, error) {
+	start, err := p.bitOrExpr()
+	if err != nil {
+		return nil, err
+	}
+	m, err := p.match(token.TokenDotDot, token.TokenDotDotEq)
+	if err != nil {
+		return nil, err
+	}
+	if !m {
+		return start, nil
+	}
+	operator := p.previous()
+	end, err := p.bitOrExpr()
+	if err != nil {
+		return nil, err
+	}
+	var step ast.Expr
+	if p.check(token.TokenIdentifier) && p.curr.Lexeme == "step" {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		step, err = p.bitOrExpr()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &ast.RangeExpr{
+		Start:     start,
+		Operator:  operator,
+		End:       end,
+		Step:      step,
+		Inclusive: operator.Type == token.TokenDotDotEq,
+	}, nil
+}
+
+// Bitwise operators bind tighter than comparisons and looser than arithmetic:
+// | < ^ < & < shifts < + - < * / %.
+func (p *Parser) bitOrExpr() (ast.Expr, error) {
+	return p.binaryLevel(p.bitXorExpr, token.TokenPipe)
+}
+
+func (p *Parser) bitXorExpr() (ast.Expr, error) {
+	return p.binaryLevel(p.bitAndExpr, token.TokenCaret)
+}
+
+func (p *Parser) bitAndExpr() (ast.Expr, error) {
+	return p.binaryLevel(p.shiftExpr, token.TokenAmper)
+}
+
+func (p *Parser) shiftExpr() (ast.Expr, error) {
+	return p.binaryLevel(p.termExpr, token.TokenShl, token.TokenShr)
+}
+
+// binaryLevel parses a left-associative chain of the given operators over operand.
+func (p *Parser) binaryLevel(operand func() (ast.Expr, error), operators ...token.TokenType) (ast.Expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		m, err := p.match(operators...)
+		if err != nil {
+			return nil, err
+		}
+		if !m {
+			break
+		}
+		operator := p.previous()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &ast.BinaryExpr{
+			Left:     left,
+			Operator: operator,
+			Right:    right,
+		}
+	}
+	return left, nil
+}
+
 func (p *Parser) termExpr() (ast.Expr, error) {
 	left, err := p.factorExpr()
 	if err != nil {
@@ -355,7 +621,7 @@ func (p *Parser) factorExpr() (ast.Expr, error) {
 }
 
 func (p *Parser) UnaryExpr() (ast.Expr, error) {
-	m, err := p.match(token.TokenMinus, token.TokenBang)
+	m, err := p.match(token.TokenMinus, token.TokenBang, token.TokenTilde)
 	if err != nil {
 		return nil, err
 	}
@@ -371,6 +637,23 @@ func (p *Parser) UnaryExpr() (ast.Expr, error) {
 		}, nil
 	}
 
+	m, err = p.match(token.TokenSpawn)
+	if err != nil {
+		return nil, err
+	}
+	if m {
+		spawnTok := p.previous()
+		operand, err := p.CallExpr()
+		if err != nil {
+			return nil, err
+		}
+		call, ok := operand.(*ast.CallExpr)
+		if !ok {
+			return nil, p.errorAt(spawnTok, niferrors.CodeBadSpawn, "expression in spawn must be function call")
+		}
+		return &ast.SpawnExpr{Spawn: spawnTok, Call: call}, nil
+	}
+
 	return p.CallExpr()
 }
 
@@ -408,14 +691,21 @@ func (p *Parser) varDeclaration() (ast.Stmt, error) {
 		}
 
 	}
+	// A typed declaration may leave the variable unassigned: var x: int
+	if typ != nil && !p.check(token.TokenAssign) {
+		return &ast.VarStmt{Names: names, Type: typ}, nil
+	}
 	_, err = p.consume(token.TokenAssign, "expect '=' after variable(s)")
 	if err != nil {
 		return nil, err
 	}
 
+	start := p.mark()
 	init, err := p.expression()
 	if err != nil {
-		return nil, err
+		if init, err = p.badExpr(err, start); err != nil {
+			return nil, err
+		}
 	}
 	return &ast.VarStmt{
 		Names: names,
@@ -436,9 +726,12 @@ func (p *Parser) shortVarDeclaration() (ast.Stmt, error) {
 		return nil, err
 	}
 
+	start := p.mark()
 	init, err := p.expression()
 	if err != nil {
-		return nil, err
+		if init, err = p.badExpr(err, start); err != nil {
+			return nil, err
+		}
 	}
 
 	err = p.skipnewLines()
@@ -459,7 +752,7 @@ func (p *Parser) CallExpr() (ast.Expr, error) {
 	}
 	for {
 		var typeArgs []*ast.TypeExpr
-		if p.check(token.TokenLBracket) {
+		if p.typeArgListEnd() == token.TokenLParen {
 
 			_, err := p.consume(token.TokenLBracket, "expected '[' for generic call type arguments")
 			if err != nil {
@@ -484,7 +777,8 @@ func (p *Parser) CallExpr() (ast.Expr, error) {
 				return nil, err
 			}
 			if !p.check(token.TokenLParen) {
-				return nil, fmt.Errorf("Type arguments only allowed in function calls e.g(foo[T](args...)")
+				return nil, p.errorAt(p.curr, niferrors.CodeTypeArgsNotCall, "type arguments only allowed in function calls").
+					Note("write foo[T](args...) to call a generic function")
 			}
 		}
 		ok, err := p.match(token.TokenLParen)
@@ -520,14 +814,47 @@ func (p *Parser) CallExpr() (ast.Expr, error) {
 			return nil, err
 		}
 		if ok {
-			index, err := p.expression()
+			var index ast.Expr
+			if !p.check(token.TokenColon) {
+				index, err = p.nestedExpression()
+				if err != nil {
+					return nil, err
+				}
+			}
+			ok, err = p.match(token.TokenColon)
 			if err != nil {
 				return nil, err
 			}
+			if ok {
+				var high ast.Expr
+				if !p.check(token.TokenRBracket) {
+					high, err = p.nestedExpression()
+					if err != nil {
+						return nil, err
+					}
+				}
+				bracket, err := p.consume(token.TokenRBracket, "expected ']' after slice")
+				if err != nil {
+					if p.curr.Type == token.TokenEOF {
+						return nil, incomplete(err)
+					}
+					return nil, err
+				}
+				expr = &ast.SliceExpr{
+					Collection: expr,
+					Bracket:    bracket,
+					Low:        index,
+					High:       high,
+				}
+				continue
+			}
+			if index == nil {
+				return nil, p.errorAt(p.curr, niferrors.CodeExpectedIndex, "expected index expression")
+			}
 			bracket, err := p.consume(token.TokenRBracket, "expected ']' after index")
 			if err != nil {
 				if p.curr.Type == token.TokenEOF {
-					return nil, ErrIncomplete
+					return nil, incomplete(err)
 				}
 				return nil, err
 			}
@@ -548,7 +875,7 @@ func (p *Parser) finishCall(callee ast.Expr, typeArgs []*ast.TypeExpr) (ast.Expr
 	var arguments []ast.Expr
 	if !p.check(token.TokenRParen) {
 		for {
-			arg, err := p.expression()
+			arg, err := p.nestedExpression()
 			if err != nil {
 				return nil, err
 			}
@@ -565,11 +892,11 @@ func (p *Parser) finishCall(callee ast.Expr, typeArgs []*ast.TypeExpr) (ast.Expr
 	paren, err := p.consume(token.TokenRParen, "expected ')' after arguments")
 	if err != nil {
 		if p.curr.Type == token.TokenEOF {
-			return nil, ErrIncomplete
+			return nil, incomplete(err)
 		}
 		return nil, err
 	}
-	fmt.Printf("DEBUG CALL callee=%#v, typeArg=%#v, arguments=%#v", callee, typeArgs, arguments)
+	log.Debug("call", "arguments", len(arguments), "typeArgs", len(typeArgs), "line", paren.Line)
 	return &ast.CallExpr{
 		Callee:    callee,
 		Paren:     paren,
@@ -640,6 +967,13 @@ func (p *Parser) primaryExpr() (ast.Expr, error) {
 	if ok {
 		return p.funcExpression()
 	}
+	ok, err = p.match(token.TokenIf)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return p.ifExpression()
+	}
 	ok, err = p.match(token.TokenFalse)
 	if err != nil {
 		return nil, err
@@ -690,11 +1024,15 @@ func (p *Parser) primaryExpr() (ast.Expr, error) {
 		return nil, err
 	}
 	if ok {
-		typeExpr, err := p.parseTypeExprFromToken(p.prev)
+		name := p.prev
+		if p.check(token.TokenLBracket) && (p.noStructLit || p.typeArgListEnd() != token.TokenLBrace) {
+			return &ast.VariableExpr{Name: name}, nil
+		}
+		typeExpr, err := p.parseTypeExprFromToken(name)
 		if err != nil {
 			return nil, err
 		}
-		if p.check(token.TokenLBrace) {
+		if p.check(token.TokenLBrace) && !p.noStructLit {
 			return p.structLiteralExpr(typeExpr)
 		}
 		return &ast.VariableExpr{Name: typeExpr.Name}, nil
@@ -705,14 +1043,14 @@ func (p *Parser) primaryExpr() (ast.Expr, error) {
 		return nil, err
 	}
 	if ok {
-		expr, err := p.expression()
+		expr, err := p.nestedExpression()
 		if err != nil {
 			return nil, err
 		}
 		_, err = p.consume(token.TokenRParen, "expected ')' after expression")
 		if err != nil {
 			if p.curr.Type == token.TokenEOF {
-				return nil, ErrIncomplete
+				return nil, incomplete(err)
 			}
 			return nil, err
 		}
@@ -732,7 +1070,7 @@ func (p *Parser) primaryExpr() (ast.Expr, error) {
 	if ok {
 		return p.dictLiteralExpr()
 	}
-	return nil, fmt.Errorf("unexpected token '%s' as line %d", p.curr.Lexeme, p.curr.Line)
+	return nil, p.errorAt(p.curr, niferrors.CodeUnexpectedToken, "unexpected %s", describe(p.curr))
 }
 
 func (p *Parser) parseTypeExprFromToken(tok token.Token) (*ast.TypeExpr, error) {
@@ -771,10 +1109,11 @@ func (p *Parser) parseTypeExprFromToken(tok token.Token) (*ast.TypeExpr, error)
 }
 
 func (p *Parser) listLiteralExpr() (ast.Expr, error) {
+	lbracket := p.previous()
 	var elements []ast.Expr
 	if !p.check(token.TokenRBracket) {
 		for {
-			elem, err := p.expression()
+			elem, err := p.nestedExpression()
 			if err != nil {
 				return nil, err
 			}
@@ -791,16 +1130,18 @@ func (p *Parser) listLiteralExpr() (ast.Expr, error) {
 	_, err := p.consume(token.TokenRBracket, "expected ']' after list elements")
 	if err != nil {
 		if p.curr.Type == token.TokenEOF {
-			return nil, ErrIncomplete
+			return nil, incomplete(err)
 		}
 		return nil, err
 	}
 	return &ast.ListExpr{
 		Elements: elements,
+		LBracket: lbracket,
 	}, nil
 }
 
 func (p *Parser) dictLiteralExpr() (ast.Expr, error) {
+	lbrace := p.previous()
 	var pairs [][2]ast.Expr
 	if !p.check(token.TokenRBrace) {
 		for {
@@ -829,12 +1170,13 @@ func (p *Parser) dictLiteralExpr() (ast.Expr, error) {
 	_, err := p.consume(token.TokenRBrace, "Expect '}' after dictionary entries")
 	if err != nil {
 		if p.curr.Type == token.TokenEOF {
-			return nil, ErrIncomplete
+			return nil, incomplete(err)
 		}
 		return nil, err
 	}
 	return &ast.DictExpr{
-		Pairs: pairs,
+		Pairs:  pairs,
+		LBrace: lbrace,
 	}, nil
 }
 
@@ -865,7 +1207,44 @@ func (p *Parser) assignmentStatement() (ast.Stmt, error) {
 	}, nil
 }
 
+func (p *Parser) checkNextCompound() bool {
+	tok, err := p.peek()
+	if err != nil {
+		return false
+	}
+	_, ok := token.CompoundOperators[tok.Type]
+	return ok
+}
+
+func (p *Parser) compoundAssignment() (ast.Stmt, error) {
+	name, err := p.consume(token.TokenIdentifier, "expect variable name before compound assignment")
+	if err != nil {
+		return nil, err
+	}
+	operator := p.curr
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	value, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.skipnewLines()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.CompoundAssignStmt{
+		Name:     name,
+		Operator: operator,
+		Value:    value,
+	}, nil
+}
+
 func (p *Parser) printStatement() (ast.Stmt, error) {
+	keyword := p.previous()
 	expr, err := p.expression()
 	if err != nil {
 		return nil, err
@@ -875,7 +1254,8 @@ func (p *Parser) printStatement() (ast.Stmt, error) {
 		return nil, err
 	}
 	return &ast.PrintStmt{
-		Expr: expr,
+		Print: keyword,
+		Expr:  expr,
 	}, nil
 }
 
@@ -910,15 +1290,32 @@ func (p *Parser) returnStatement() (ast.Stmt, error) {
 		return nil, err
 	}
 
-	fmt.Printf("[RETURN] After parsing expr, current=%v (type=%v, lexeme=%q)\n", p.curr.Type, p.curr.Type, p.curr.Lexeme)
+	log.Debug("return", "values", len(values), "line", keyword.Line)
 	return &ast.ReturnStmt{
 		Keyword: keyword,
 		Values:  values,
 	}, nil
 }
 
+func (p *Parser) yieldStatement() (ast.Stmt, error) {
+	keyword := p.previous()
+	if p.yielded == nil {
+		return nil, p.errorAt(keyword, niferrors.CodeYieldOutsideFunc, "yield outside function")
+	}
+	*p.yielded = true
+	val, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.YieldStmt{
+		Keyword: keyword,
+		Value:   val,
+	}, nil
+}
+
 func (p *Parser) ifStatement() (ast.Stmt, error) {
-	cond, err := p.expression()
+	ifTok := p.previous()
+	cond, err := p.headerExpression()
 	if err != nil {
 		return nil, err
 	}
@@ -953,6 +1350,7 @@ func (p *Parser) ifStatement() (ast.Stmt, error) {
 		Conditon:   cond,
 		ThenBranch: thenBranch,
 		ElseBranch: elseBranch,
+		IfToken:    ifTok,
 	}, nil
 }
 
@@ -970,16 +1368,19 @@ func (p *Parser) blockStatement() (*ast.BlockStmt, error) {
 		// if err != nil {
 		// 	return nil, err
 		// }
-		fmt.Printf("[BLOCK] Next token: type=%v lexeme=%q line=%d\n", p.curr.Type, p.curr.Lexeme, p.curr.Line)
+		log.Debug("block statement", "type", p.curr.Type, "lexeme", p.curr.Lexeme, "line", p.curr.Line)
 		if p.check(token.TokenRBrace) || p.isAtEnd() {
 			break
 		}
 		// if p.check(token.TokenRBrace) || p.isAtEnd() {
 		// 	break
 		// }
+		start := p.mark()
 		stmt, err := p.statement()
 		if err != nil {
-			return nil, err
+			if stmt, err = p.badStmt(err, start); err != nil {
+				return nil, err
+			}
 		}
 		if stmt != nil {
 			statements = append(statements, stmt)
@@ -990,10 +1391,10 @@ func (p *Parser) blockStatement() (*ast.BlockStmt, error) {
 		}
 	}
 
-	_, err = p.consume(token.TokenRBrace, "expected '}' after block")
+	rbrace, err := p.consume(token.TokenRBrace, "expected '}' after block")
 	if err != nil {
 		if p.curr.Type == token.TokenEOF {
-			return nil, ErrIncomplete
+			return nil, incomplete(err)
 		}
 		return nil, err
 	}
@@ -1001,21 +1402,93 @@ func (p *Parser) blockStatement() (*ast.BlockStmt, error) {
 	return &ast.BlockStmt{
 		Statements: statements,
 		LBrace:     lbrace,
+		RBrace:     rbrace,
 	}, nil
 
 }
 
 func (p *Parser) continueStatement() (ast.Stmt, error) {
 	keyword := p.previous()
-	err := p.skipnewLines()
+	label, err := p.loopLabelRef(keyword)
+	if err != nil {
+		return nil, err
+	}
+	err = p.skipnewLines()
 	if err != nil {
 		return nil, err
 	}
 	return &ast.ContinueStmt{
 		Keyword: keyword,
+		Label:   label,
 	}, nil
 }
 
+// loopLabelRef parses the optional label after break/continue. The label must be
+// on the same line as the keyword and name an enclosing loop.
+func (p *Parser) loopLabelRef(keyword token.Token) (token.Token, error) {
+	if !p.check(token.TokenIdentifier) || p.curr.Line != keyword.Line {
+		return token.Token{}, nil
+	}
+	label := p.curr
+	if !slices.Contains(p.labels, label.Lexeme) {
+		return token.Token{}, p.errorAt(label, niferrors.CodeUndefinedLabel, "%s label '%s' not defined", keyword.Lexeme, label.Lexeme).
+			DidYouMean(label.Lexeme, p.labels)
+	}
+	if err := p.advance(); err != nil {
+		return token.Token{}, err
+	}
+	return label, nil
+}
+
+func (p *Parser) labeledStatement() (ast.Stmt, error) {
+	label, err := p.consume(token.TokenIdentifier, "expected label name")
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(token.TokenColon, "expected ':' after label")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.skipnewLines(); err != nil {
+		return nil, err
+	}
+	if slices.Contains(p.labels, label.Lexeme) {
+		return nil, p.errorAt(label, niferrors.CodeDuplicateLabel, "label '%s' already defined", label.Lexeme)
+	}
+
+	p.labels = append(p.labels, label.Lexeme)
+	defer func() { p.labels = p.labels[:len(p.labels)-1] }()
+
+	var loop ast.Stmt
+	if ok, err := p.match(token.TokenFor); err != nil {
+		return nil, err
+	} else if ok {
+		loop, err = p.forStatement()
+		if err != nil {
+			return nil, err
+		}
+	} else if ok, err := p.match(token.TokenWhile); err != nil {
+		return nil, err
+	} else if ok {
+		loop, err = p.whileStatement()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, p.errorAt(label, niferrors.CodeLabelWithoutLoop, "label '%s' must precede a loop", label.Lexeme)
+	}
+
+	switch l := loop.(type) {
+	case *ast.ForStmt:
+		l.Label = label
+	case *ast.ForInStmt:
+		l.Label = label
+	case *ast.WhileStmt:
+		l.Label = label
+	}
+	return loop, nil
+}
+
 func (p *Parser) expressionStatement() (ast.Stmt, error) {
 	expr, err := p.expression()
 	if err != nil {
@@ -1033,18 +1506,23 @@ func (p *Parser) expressionStatement() (ast.Stmt, error) {
 
 func (p *Parser) breakStatement() (ast.Stmt, error) {
 	keyword := p.previous()
-	err := p.skipnewLines()
+	label, err := p.loopLabelRef(keyword)
+	if err != nil {
+		return nil, err
+	}
+	err = p.skipnewLines()
 	if err != nil {
 		return nil, err
 	}
 	return &ast.BreakStmt{
 		Keyword: keyword,
+		Label:   label,
 	}, nil
 }
 
 func (p *Parser) whileStatement() (ast.Stmt, error) {
-
-	cond, err := p.expression()
+	whileTok := p.previous()
+	cond, err := p.headerExpression()
 	if err != nil {
 		return nil, err
 	}
@@ -1060,6 +1538,7 @@ func (p *Parser) whileStatement() (ast.Stmt, error) {
 	return &ast.WhileStmt{
 		Conditon: cond,
 		Body:     body,
+		While:    whileTok,
 	}, nil
 }
 
@@ -1068,14 +1547,68 @@ func (p *Parser) checkNext(tt token.TokenType) bool {
 	if err != nil {
 		return false
 	}
-	p.ahead = tok
-	p.hasAhead = true
+	return tok.Type == tt
+}
+
+// typeArgListEnd scans ahead over a '[' ... ']' group at the current token. If the
+// group only contains type names it returns the type of the token following ']',
+// otherwise TokenIllegal. This separates foo[T](...) and Box[T]{...} from indexing.
+func (p *Parser) typeArgListEnd() token.TokenType {
+	if !p.check(token.TokenLBracket) {
+		return token.TokenIllegal
+	}
+	depth := 1
+	for n := 1; ; n++ {
+		tok, err := p.peekN(n)
+		if err != nil {
+			return token.TokenIllegal
+		}
+		switch tok.Type {
+		case token.TokenLBracket:
+			depth++
+		case token.TokenRBracket:
+			depth--
+			if depth == 0 {
+				next, err := p.peekN(n + 1)
+				if err != nil {
+					return token.TokenIllegal
+				}
+				return next.Type
+			}
+		case token.TokenIdentifier, token.TokenComma:
+		default:
+			return token.TokenIllegal
+		}
+	}
+}
+
+// headerExpression parses the condition of an if/while/for, where '{' opens the
+// body rather than a struct literal.
+func (p *Parser) headerExpression() (ast.Expr, error) {
+	prev := p.noStructLit
+	p.noStructLit = true
+	defer func() { p.noStructLit = prev }()
+	return p.expression()
+}
 
-	return p.ahead.Type == tt
+// nestedExpression parses an expression inside brackets or parentheses, where
+// struct literals are allowed again.
+func (p *Parser) nestedExpression() (ast.Expr, error) {
+	prev := p.noStructLit
+	p.noStructLit = false
+	defer func() { p.noStructLit = prev }()
+	return p.expression()
 }
 
 func (p *Parser) funcDeclaration() (ast.Stmt, error) {
 	funcTok := p.previous()
+	outerLabels := p.labels
+	p.labels = nil
+	defer func() { p.labels = outerLabels }()
+	outerYielded := p.yielded
+	var isGenerator bool
+	p.yielded = &isGenerator
+	defer func() { p.yielded = outerYielded }()
 
 	name, err := p.consume(token.TokenIdentifier, "expected function name after 'func'")
 	if err != nil {
@@ -1141,7 +1674,7 @@ func (p *Parser) funcDeclaration() (ast.Stmt, error) {
 	_, err = p.consume(token.TokenRParen, "expect ')' after parameters")
 	if err != nil {
 		if p.curr.Type == token.TokenEOF {
-			return nil, ErrIncomplete
+			return nil, incomplete(err)
 		}
 		return nil, err
 	}
@@ -1157,7 +1690,7 @@ func (p *Parser) funcDeclaration() (ast.Stmt, error) {
 				return nil, err
 			}
 		}
-		if p.check(token.TokenIdentifier) {
+		if p.check(token.TokenIdentifier) || p.check(token.TokenFunc) {
 			typ, err := p.parseTypeExpr()
 			if err != nil {
 				return nil, err
@@ -1201,12 +1734,13 @@ func (p *Parser) funcDeclaration() (ast.Stmt, error) {
 			return nil, err
 		}
 		return &ast.FuncStmt{
-			Func:       funcTok,
-			Name:       name,
-			Params:     params,
-			TypeParams: typeParams,
-			Body:       body,
-			Return:     returnTypes,
+			Func:        funcTok,
+			Name:        name,
+			Params:      params,
+			TypeParams:  typeParams,
+			Body:        body,
+			Return:      returnTypes,
+			IsGenerator: isGenerator,
 		}, nil
 	}
 
@@ -1220,12 +1754,13 @@ func (p *Parser) funcDeclaration() (ast.Stmt, error) {
 	}
 
 	return &ast.FuncStmt{
-		Func:       funcTok,
-		Name:       name,
-		Params:     params,
-		TypeParams: typeParams,
-		Body:       body,
-		Return:     returnTypes,
+		Func:        funcTok,
+		Name:        name,
+		Params:      params,
+		TypeParams:  typeParams,
+		Body:        body,
+		Return:      returnTypes,
+		IsGenerator: isGenerator,
 	}, nil
 }
 
@@ -1233,6 +1768,13 @@ func (p *Parser) funcDeclaration() (ast.Stmt, error) {
 
 func (p *Parser) funcExpression() (ast.Expr, error) {
 	funcTok := p.previous()
+	outerLabels := p.labels
+	p.labels = nil
+	defer func() { p.labels = outerLabels }()
+	outerYielded := p.yielded
+	var isGenerator bool
+	p.yielded = &isGenerator
+	defer func() { p.yielded = outerYielded }()
 	_, err := p.consume(token.TokenLParen, "expect '(' after func in function literal")
 	if err != nil {
 		return nil, err
@@ -1269,7 +1811,7 @@ func (p *Parser) funcExpression() (ast.Expr, error) {
 	_, err = p.consume(token.TokenRParen, "expect ')' after parameter list")
 	if err != nil {
 		if p.curr.Type == token.TokenEOF {
-			return nil, ErrIncomplete
+			return nil, incomplete(err)
 		}
 		return nil, err
 	}
@@ -1284,18 +1826,18 @@ func (p *Parser) funcExpression() (ast.Expr, error) {
 	}
 
 	return &ast.FuncExpr{
-		Func:   funcTok,
-		Params: params,
-		Body:   body,
+		Func:        funcTok,
+		Params:      params,
+		Body:        body,
+		IsGenerator: isGenerator,
 	}, nil
 }
 
 // func (p *Parser) genericStructDeclaration(name token.Token, typeParams []token.Token, field)
 
 func (p *Parser) structDeclartion() (ast.Stmt, error) {
-
-	fmt.Printf("[AFTER SKIP NEW LINES] struct body: token %v lexem='%s' line='%d'\n", p.curr.Type, p.curr.Lexeme, p.curr.Line)
 	structTok := p.previous()
+	log.Debug("struct declaration", "name", p.curr.Lexeme, "line", structTok.Line)
 
 	name, err := p.consume(token.TokenIdentifier, "expected a struct name after 'struct'")
 	if err != nil {
@@ -1327,7 +1869,6 @@ func (p *Parser) structDeclartion() (ast.Stmt, error) {
 		}
 	}
 	_, err = p.consume(token.TokenLBrace, "expected '{' after struct name")
-	fmt.Printf("[AFTER OPENING '{' ] struct body: token %v lexem='%s' line='%d'\n", p.curr.Type, p.curr.Lexeme, p.curr.Line)
 	if err != nil {
 		return nil, err
 	}
@@ -1337,7 +1878,6 @@ func (p *Parser) structDeclartion() (ast.Stmt, error) {
 			return nil, err
 		}
 	}
-	fmt.Printf("[Trying to check for \n after '{' ] struct body: token %v lexem='%s' line='%d'\n", p.curr.Type, p.curr.Lexeme, p.curr.Line)
 
 	var fields []ast.VarStmt
 	var methods []ast.FuncStmt
@@ -1347,7 +1887,7 @@ func (p *Parser) structDeclartion() (ast.Stmt, error) {
 		if err != nil {
 			return nil, err
 		}
-		fmt.Printf("[PARSERLOOP] struct body: type=%d  lexem='%s' line='%d'\n", p.curr.Type, p.curr.Lexeme, p.curr.Line)
+		log.Debug("struct member", "type", p.curr.Type, "lexeme", p.curr.Lexeme, "line", p.curr.Line)
 		// Allow and skip any number of blank lines or newlines
 		err = p.skipnewLines()
 		if err != nil {
@@ -1371,7 +1911,7 @@ func (p *Parser) structDeclartion() (ast.Stmt, error) {
 			}
 			fn, ok := method.(*ast.FuncStmt)
 			if !ok {
-				return nil, fmt.Errorf("expected function statement in struct body")
+				return nil, p.errorAt(method.PosToken(), niferrors.CodeBadStructMember, "expected function statement in struct body")
 			}
 			methods = append(methods, *fn)
 			err = p.skipnewLines()
@@ -1407,7 +1947,8 @@ func (p *Parser) structDeclartion() (ast.Stmt, error) {
 		}
 
 		// Anything else: error
-		return nil, fmt.Errorf("unexpected token in struct body: '%s' at line %d", p.curr.Lexeme, p.curr.Line)
+		return nil, p.errorAt(p.curr, niferrors.CodeBadStructMember, "unexpected %s in struct body", describe(p.curr)).
+			Note("a struct body holds fields (name: type) and methods (func ...)")
 	}
 
 	err = p.skipnewLines()
@@ -1416,7 +1957,7 @@ func (p *Parser) structDeclartion() (ast.Stmt, error) {
 	}
 
 	// Must see a closing brace
-	_, err = p.consume(token.TokenRBrace, "expected '}' after struct body")
+	rbrace, err := p.consume(token.TokenRBrace, "expected '}' after struct body")
 	if err != nil {
 		return nil, err
 	}
@@ -1427,6 +1968,7 @@ func (p *Parser) structDeclartion() (ast.Stmt, error) {
 		Methods:    methods,
 		Struct:     structTok,
 		TypeParams: typeParams,
+		RBrace:     rbrace,
 	}
 
 	return stmt, nil
@@ -1434,6 +1976,9 @@ func (p *Parser) structDeclartion() (ast.Stmt, error) {
 
 func (p *Parser) forStatement() (ast.Stmt, error) {
 	forTok := p.previous()
+	if p.check(token.TokenIdentifier) && p.checkNext(token.TokenIn) {
+		return p.forInStatement(forTok)
+	}
 	var init ast.Stmt
 	var err error
 	if !p.check(token.TokenSemicolon) {
@@ -1458,7 +2003,7 @@ func (p *Parser) forStatement() (ast.Stmt, error) {
 
 	var cond ast.Expr
 	if !p.check(token.TokenSemicolon) {
-		cond, err = p.expression()
+		cond, err = p.headerExpression()
 		if err != nil {
 			return nil, err
 		}
@@ -1475,6 +2020,8 @@ func (p *Parser) forStatement() (ast.Stmt, error) {
 			post, err = p.shortVarDeclaration()
 		} else if p.check(token.TokenIdentifier) && p.checkNext(token.TokenAssign) {
 			post, err = p.assignmentStatement()
+		} else if p.check(token.TokenIdentifier) && p.checkNextCompound() {
+			post, err = p.compoundAssignment()
 		}
 		if err != nil {
 			return nil, err
@@ -1499,11 +2046,287 @@ func (p *Parser) forStatement() (ast.Stmt, error) {
 	}, nil
 }
 
+func (p *Parser) forInStatement(forTok token.Token) (ast.Stmt, error) {
+	name, err := p.consume(token.TokenIdentifier, "expected loop variable after 'for'")
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(token.TokenIn, "expected 'in' after loop variable")
+	if err != nil {
+		return nil, err
+	}
+	iterable, err := p.headerExpression()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(token.TokenLBrace, "expected '{' after for-in clause")
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.blockStatement()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.ForInStmt{
+		Name:     name,
+		Iterable: iterable,
+		BodyStmt: body,
+		For:      forTok,
+	}, nil
+}
+
+func (p *Parser) switchStatement() (ast.Stmt, error) {
+	switchTok := p.previous()
+	subject, err := p.headerExpression()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(token.TokenLBrace, "expected '{' after switch expression")
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []ast.SwitchCase
+	seen := make(map[string]bool)
+	hasDefault := false
+	for {
+		if err := p.skipnewLines(); err != nil {
+			return nil, err
+		}
+		if p.check(token.TokenRBrace) || p.isAtEnd() {
+			break
+		}
+
+		clause := ast.SwitchCase{Case: p.curr}
+		ok, err := p.match(token.TokenDefault)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if hasDefault {
+				return nil, p.errorAt(clause.Case, niferrors.CodeDuplicateDefault, "multiple defaults in switch")
+			}
+			hasDefault = true
+			clause.IsDefault = true
+		} else {
+			_, err := p.consume(token.TokenCase, "expected 'case' or 'default' in switch body")
+			if err != nil {
+				return nil, err
+			}
+			for {
+				val, err := p.expression()
+				if err != nil {
+					return nil, err
+				}
+				if key, ok := constantCaseKey(val); ok {
+					if seen[key] {
+						return nil, p.errorAt(val.PosToken(), niferrors.CodeDuplicateCase, "duplicate case %s in switch", key)
+					}
+					seen[key] = true
+				}
+				clause.Values = append(clause.Values, val)
+				ok, err := p.match(token.TokenComma)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					break
+				}
+			}
+		}
+		_, err = p.consume(token.TokenColon, "expected ':' after case")
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := p.caseBody()
+		if err != nil {
+			return nil, err
+		}
+		clause.Body = body
+		cases = append(cases, clause)
+	}
+
+	rbrace, err := p.consume(token.TokenRBrace, "expected '}' after switch body")
+	if err != nil {
+		if p.curr.Type == token.TokenEOF {
+			return nil, incomplete(err)
+		}
+		return nil, err
+	}
+	if n := len(cases); n > 0 {
+		if body := cases[n-1].Body; len(body) > 0 {
+			if ft, ok := body[len(body)-1].(*ast.FallthroughStmt); ok {
+				return nil, p.errorAt(ft.Keyword, niferrors.CodeBadFallthrough, "cannot fallthrough final case in switch")
+			}
+		}
+	}
+
+	return &ast.SwitchStmt{
+		Switch:  switchTok,
+		Subject: subject,
+		Cases:   cases,
+		RBrace:  rbrace,
+	}, nil
+}
+
+// caseBody parses the statements of a switch clause up to the next case, default or '}'.
+// A fallthrough is only accepted as the final statement of the clause.
+func (p *Parser) caseBody() ([]ast.Stmt, error) {
+	var body []ast.Stmt
+	for {
+		if err := p.skipnewLines(); err != nil {
+			return nil, err
+		}
+		if p.check(token.TokenCase) || p.check(token.TokenDefault) || p.check(token.TokenRBrace) || p.isAtEnd() {
+			return body, nil
+		}
+		if p.check(token.TokenFallthrough) {
+			keyword := p.curr
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if err := p.skipnewLines(); err != nil {
+				return nil, err
+			}
+			if !p.check(token.TokenCase) && !p.check(token.TokenDefault) && !p.check(token.TokenRBrace) {
+				return nil, p.errorAt(keyword, niferrors.CodeBadFallthrough, "fallthrough statement out of place").
+					Note("fallthrough must be the last statement of a case")
+			}
+			return append(body, &ast.FallthroughStmt{Keyword: keyword}), nil
+		}
+		start := p.mark()
+		stmt, err := p.statement()
+		if err != nil {
+			if stmt, err = p.badStmt(err, start); err != nil {
+				return nil, err
+			}
+		}
+		if stmt != nil {
+			body = append(body, stmt)
+		}
+	}
+}
+
+func (p *Parser) selectStatement() (ast.Stmt, error) {
+	selectTok := p.previous()
+	_, err := p.consume(token.TokenLBrace, "expected '{' after select")
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []ast.SelectCase
+	hasDefault := false
+	for {
+		if err := p.skipnewLines(); err != nil {
+			return nil, err
+		}
+		if p.check(token.TokenRBrace) || p.isAtEnd() {
+			break
+		}
+
+		clause := ast.SelectCase{Case: p.curr}
+		ok, err := p.match(token.TokenDefault)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if hasDefault {
+				return nil, p.errorAt(clause.Case, niferrors.CodeDuplicateDefault, "multiple defaults in select")
+			}
+			hasDefault = true
+			clause.IsDefault = true
+		} else {
+			_, err := p.consume(token.TokenCase, "expected 'case' or 'default' in select body")
+			if err != nil {
+				return nil, err
+			}
+			if p.check(token.TokenIdentifier) && p.checkNext(token.TokenColonEqual) {
+				clause.Name = p.curr
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+			}
+			comm, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			call, ok := comm.(*ast.CallExpr)
+			if !ok {
+				return nil, p.errorAt(clause.Case, niferrors.CodeBadSelectCase, "select case must be recv or send call")
+			}
+			callee, ok := call.Callee.(*ast.VariableExpr)
+			if !ok || (callee.Name.Lexeme != "recv" && callee.Name.Lexeme != "send") {
+				return nil, p.errorAt(clause.Case, niferrors.CodeBadSelectCase, "select case must be recv or send call")
+			}
+			if callee.Name.Lexeme == "send" && clause.Name.Lexeme != "" {
+				return nil, p.errorAt(clause.Case, niferrors.CodeBadSelectCase, "send in select case cannot be assigned")
+			}
+			clause.Comm = call
+		}
+		_, err = p.consume(token.TokenColon, "expected ':' after case")
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := p.caseBody()
+		if err != nil {
+			return nil, err
+		}
+		if n := len(body); n > 0 {
+			if ft, ok := body[n-1].(*ast.FallthroughStmt); ok {
+				return nil, p.errorAt(ft.Keyword, niferrors.CodeBadFallthrough, "fallthrough statement out of place")
+			}
+		}
+		clause.Body = body
+		cases = append(cases, clause)
+	}
+
+	rbrace, err := p.consume(token.TokenRBrace, "expected '}' after select body")
+	if err != nil {
+		if p.curr.Type == token.TokenEOF {
+			return nil, incomplete(err)
+		}
+		return nil, err
+	}
+
+	return &ast.SelectStmt{
+		Select: selectTok,
+		Cases:  cases,
+		RBrace: rbrace,
+	}, nil
+}
+
+// constantCaseKey returns a key identifying a literal case value, so duplicate
+// constant cases can be rejected at parse time.
+func constantCaseKey(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.LiteralExpr:
+		switch e.Value.Type {
+		case token.TokenString:
+			return fmt.Sprintf("%q", e.Value.Lexeme), true
+		case token.TokenNumber, token.TokenFloat, token.TokenTrue, token.TokenFalse, token.TokenNull:
+			return e.Value.Lexeme, true
+		}
+	case *ast.UnaryExpr:
+		if e.Operator.Type != token.TokenMinus {
+			return "", false
+		}
+		if lit, ok := e.Right.(*ast.LiteralExpr); ok && (lit.Value.Type == token.TokenNumber || lit.Value.Type == token.TokenFloat) {
+			return "-" + lit.Value.Lexeme, true
+		}
+	}
+	return "", false
+}
+
 func (p *Parser) statement() (ast.Stmt, error) {
 	if p.check(token.TokenRBrace) || p.isAtEnd() {
 		return nil, nil
 	}
-	fmt.Printf("[STATMENT] At: %v Current: %v (type=%v, line=%v, lexeme=%q)", p.curr.Data, p.curr.Type, p.curr.Type, p.curr.Line, p.curr.Lexeme)
+	log.Debug("statement", "type", p.curr.Type, "lexeme", p.curr.Lexeme, "line", p.curr.Line)
 	ok, err := p.match(token.TokenVar)
 	if err != nil {
 		return nil, err
@@ -1527,6 +2350,14 @@ func (p *Parser) statement() (ast.Stmt, error) {
 	if p.check(token.TokenIdentifier) && p.checkNext(token.TokenAssign) {
 		return p.assignmentStatement()
 	}
+
+	if p.check(token.TokenIdentifier) && p.checkNextCompound() {
+		return p.compoundAssignment()
+	}
+
+	if p.check(token.TokenIdentifier) && p.checkNext(token.TokenColon) {
+		return p.labeledStatement()
+	}
 	ok, err = p.match(token.TokenPrint)
 	if err != nil {
 		return nil, err
@@ -1558,6 +2389,25 @@ func (p *Parser) statement() (ast.Stmt, error) {
 	if ok {
 		return p.forStatement()
 	}
+
+	ok, err = p.match(token.TokenSwitch)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return p.switchStatement()
+	}
+
+	ok, err = p.match(token.TokenSelect)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return p.selectStatement()
+	}
+	if p.check(token.TokenFallthrough) {
+		return nil, p.errorAt(p.curr, niferrors.CodeBadFallthrough, "fallthrough statement out of place")
+	}
 	ok, err = p.match(token.TokenFunc)
 	if err != nil {
 		return nil, err
@@ -1574,6 +2424,14 @@ func (p *Parser) statement() (ast.Stmt, error) {
 		return p.returnStatement()
 	}
 
+	ok, err = p.match(token.TokenYield)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return p.yieldStatement()
+	}
+
 	ok, err = p.match(token.TokenBreak)
 	if err != nil {
 		return nil, err
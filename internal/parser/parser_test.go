@@ -0,0 +1,33 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+)
+
+func TestParser_EqualityOperators(t *testing.T) {
+	for src, want := range map[string]token.TokenType{
+		"a == b": token.TokenEqality,
+		"a != b": token.TokenBangEqal,
+	} {
+		stmts, err := parser.New(lexer.New(src)).Parse()
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", src, err)
+		}
+		if len(stmts) != 1 {
+			t.Fatalf("%s: expected one statement, got %d", src, len(stmts))
+		}
+		expr, ok := stmts[0].(*ast.ExprStmt)
+		if !ok {
+			t.Fatalf("%s: expected an expression statement, got %T", src, stmts[0])
+		}
+		binary, ok := expr.Expr.(*ast.BinaryExpr)
+		if !ok || binary.Operator.Type != want {
+			t.Errorf("%s: expected a %v binary expression, got %#v", src, want, expr.Expr)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// DefaultMaxErrors is the number of syntax errors after which Parse gives up
+// on a file.
+const DefaultMaxErrors = 10
+
+// ErrorList holds the syntax errors of one parse, in source order.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+func (l ErrorList) Unwrap() []error {
+	return l
+}
+
+// Errors returns the errors err holds: those of an ErrorList, or err itself.
+func Errors(err error) []error {
+	var list ErrorList
+	if errors.As(err, &list) {
+		return list
+	}
+	if err == nil {
+		return nil
+	}
+	return []error{err}
+}
+
+// errTooManyErrors stops the parse once MaxErrors errors have been recorded.
+// It is passed up like any syntax error but never recorded.
+var errTooManyErrors = errors.New("too many errors")
+
+// mark is where a statement starts: its first token, how many tokens had
+// been consumed before it and how deeply nested in braces it is.
+type mark struct {
+	tok   token.Token
+	pos   int
+	depth int
+}
+
+func (p *Parser) mark() mark {
+	return mark{tok: p.curr, pos: p.pos, depth: p.depth}
+}
+
+// record adds err to the errors of the parse. Once the limit is reached it
+// adds a last error saying the parse stopped where it did, and returns
+// errTooManyErrors.
+func (p *Parser) record(err error) error {
+	if err == errTooManyErrors {
+		return err
+	}
+	p.errs = append(p.errs, err)
+	if p.MaxErrors > 0 && len(p.errs) >= p.MaxErrors {
+		p.errs = append(p.errs, p.errorAt(p.curr, niferrors.CodeTooManyErrors, "too many errors; stopping"))
+		return errTooManyErrors
+	}
+	return nil
+}
+
+// badStmt records err, which cut short the statement that began at start,
+// and skips to where the next statement begins. The BadStmt it returns covers
+// the skipped source.
+func (p *Parser) badStmt(err error, start mark) (ast.Stmt, error) {
+	if err := p.record(err); err != nil {
+		return nil, err
+	}
+	if err := p.synchronize(start); err != nil {
+		return nil, err
+	}
+	return &ast.BadStmt{From: start.tok, To: p.prev}, nil
+}
+
+// badExpr is badStmt for an expression whose statement is still worth
+// keeping, such as the initializer of a declaration: the declared names stay
+// defined, so their uses do not cause errors of their own.
+func (p *Parser) badExpr(err error, start mark) (ast.Expr, error) {
+	if err := p.record(err); err != nil {
+		return nil, err
+	}
+	if err := p.synchronize(start); err != nil {
+		return nil, err
+	}
+	return &ast.BadExpr{From: start.tok, To: p.prev}, nil
+}
+
+// synchronize skips tokens until one that can begin a statement at the
+// nesting depth of start: the first token of a line, a statement keyword or
+// the '}' that closes the enclosing block. At least one token is skipped, so
+// a statement that fails on its first token cannot fail forever.
+func (p *Parser) synchronize(start mark) error {
+	for !p.isAtEnd() {
+		if p.pos > start.pos && p.depth <= start.depth && p.atStatementBoundary() {
+			return nil
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Parser) atStatementBoundary() bool {
+	if p.check(token.TokenRBrace) || p.curr.Line > p.prev.Line {
+		return true
+	}
+	switch p.curr.Type {
+	case token.TokenVar, token.TokenFunc, token.TokenStruct, token.TokenIf, token.TokenWhile,
+		token.TokenFor, token.TokenSwitch, token.TokenSelect, token.TokenReturn, token.TokenPrint,
+		token.TokenBreak, token.TokenContinue, token.TokenYield:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package parser_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// positions returns where each error in err starts, as "line:col".
+func positions(err error) []string {
+	var got []string
+	for _, err := range parser.Errors(err) {
+		nifErr := niferrors.As(err)
+		got = append(got, fmt.Sprintf("%d:%d", nifErr.Line, nifErr.Column))
+	}
+	return got
+}
+
+// shapes names the type of each statement, with the statements of blocks in
+// brackets.
+func shapes(stmts []ast.Stmt) string {
+	var parts []string
+	for _, stmt := range stmts {
+		name := fmt.Sprintf("%T", stmt)[len("*nifast."):]
+		switch s := stmt.(type) {
+		case *ast.FuncStmt:
+			name += "[" + shapes(s.Body.Statements) + "]"
+		case *ast.ShortVarStmt:
+			if _, ok := s.Init.(*ast.BadExpr); ok {
+				name += "(BadExpr)"
+			}
+		}
+		parts = append(parts, name)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParse_Recovery(t *testing.T) {
+	cases := []struct {
+		name   string
+		source string
+		errs   []string
+		stmts  string
+	}{
+		{"one error per statement",
+			"x := 1\ny := )\nprint(x)\nvar = 3\nz := 2",
+			[]string{"2:6", "4:5"},
+			"ShortVarStmt ShortVarStmt(BadExpr) PrintStmt BadStmt ShortVarStmt"},
+		{"errors inside a function body",
+			"func f() {\n\ta := (1 +\n\tprint(a)\n\tb = = 2\n}\nf()",
+			[]string{"3:2", "4:6"},
+			"FuncStmt[ShortVarStmt(BadExpr) PrintStmt BadStmt] ExprStmt"},
+		{"a bad header skips its whole block",
+			"if x == {\n\tprint(1)\n}\ny := 2",
+			[]string{"2:2"},
+			"BadStmt ShortVarStmt"},
+		{"a keyword ends the bad statement",
+			"x := 1 + ) var y = 2",
+			[]string{"1:10"},
+			"ShortVarStmt(BadExpr) VarStmt"},
+		{"a stray brace",
+			"}\nx := 1",
+			[]string{"1:1"},
+			"BadStmt ShortVarStmt"},
+		{"lexical errors do not end the statement",
+			"x := 1 $ + 2\ny := 'open",
+			[]string{"1:8", "2:6", "2:5"},
+			"ShortVarStmt ShortVarStmt(BadExpr)"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stmts, err := parser.New(lexer.New(tc.source)).Parse()
+			var list parser.ErrorList
+			if !errors.As(err, &list) {
+				t.Fatalf("expected an ErrorList, got %T: %v", err, err)
+			}
+			if got := positions(err); strings.Join(got, " ") != strings.Join(tc.errs, " ") {
+				t.Errorf("expected errors at %v, got %v: %v", tc.errs, got, err)
+			}
+			if got := shapes(stmts); got != tc.stmts {
+				t.Errorf("expected statements %q, got %q", tc.stmts, got)
+			}
+		})
+	}
+}
+
+func TestParse_BadStmtBounds(t *testing.T) {
+	stmts, _ := parser.New(lexer.New("x := 1\nwhile x < { print(x) }\ny := 2")).Parse()
+	if len(stmts) != 3 {
+		t.Fatalf("expected 3 statements, got %d", len(stmts))
+	}
+	first, last := ast.Bounds(stmts[1])
+	if first.Lexeme != "while" || strings.TrimSpace(last.Lexeme) != "}" || last.Line != 2 {
+		t.Errorf("expected the bad statement to run from 'while' to '}', got %q to %q", first.Lexeme, last.Lexeme)
+	}
+}
+
+func TestParse_MaxErrors(t *testing.T) {
+	src := strings.Repeat("x := )\n", 20)
+	_, err := parser.New(lexer.New(src)).Parse()
+	errs := parser.Errors(err)
+	if n := len(errs); n != parser.DefaultMaxErrors+1 {
+		t.Fatalf("expected the parse to stop after %d errors and say so, got %d", parser.DefaultMaxErrors, n)
+	}
+	last := niferrors.As(errs[len(errs)-1])
+	if last.Code != niferrors.CodeTooManyErrors || last.Message != "too many errors; stopping" || last.Line != parser.DefaultMaxErrors {
+		t.Errorf("expected a last error saying the parse stopped at line %d, got %s at line %d: %v", parser.DefaultMaxErrors, last.Code, last.Line, last)
+	}
+
+	p := parser.New(lexer.New(src))
+	p.MaxErrors = 0
+	if _, err := p.Parse(); len(parser.Errors(err)) != 20 {
+		t.Errorf("expected every error without a limit, got %d", len(parser.Errors(err)))
+	}
+}
+
+func TestParse_Incomplete(t *testing.T) {
+	cases := []struct {
+		source     string
+		incomplete bool
+	}{
+		{"func f() {\n\tx := 1", true},
+		{"x := [1, 2", true},
+		{"x := )\nfunc f() {", false},
+		{"func f() {\n\tx := )\n", false},
+	}
+	for _, tc := range cases {
+		_, err := parser.New(lexer.New(tc.source)).Parse()
+		if err == nil {
+			t.Errorf("%q: expected an error", tc.source)
+			continue
+		}
+		if got := errors.Is(err, parser.ErrIncomplete); got != tc.incomplete {
+			t.Errorf("%q: expected incomplete to be %v, got %v: %v", tc.source, tc.incomplete, got, err)
+		}
+	}
+}
+
+func TestErrorList_Error(t *testing.T) {
+	_, err := parser.New(lexer.New("x := )\ny := )\nz := )")).Parse()
+	if msg := err.Error(); !strings.HasPrefix(msg, "1:6: ") || !strings.HasSuffix(msg, "(and 2 more errors)") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
@@ -0,0 +1,186 @@
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// WritePprof writes prof in the gzipped protocol buffer format of pprof, so
+// that go tool pprof shows the program's functions. Each sample is a call
+// stack with the number of calls that ended in it and their self time.
+//
+// The format is described by profile.proto in github.com/google/pprof.
+func (prof *Profile) WritePprof(w io.Writer) error {
+	strs := stringTable{index: map[string]int64{"": 0}, list: []string{""}}
+	ids := make(map[*Func]uint64, len(prof.Funcs))
+	for idx, fn := range prof.Funcs {
+		ids[fn] = uint64(idx + 1)
+	}
+
+	var b protobuf
+	for _, typ := range [][2]string{{"calls", "count"}, {"time", "nanoseconds"}} {
+		b.message(profileSampleType, func(b *protobuf) {
+			b.int64(valueTypeType, strs.add(typ[0]))
+			b.int64(valueTypeUnit, strs.add(typ[1]))
+		})
+	}
+	for _, s := range prof.Samples {
+		b.message(profileSample, func(b *protobuf) {
+			locs := make([]uint64, len(s.Stack))
+			for idx, fn := range s.Stack {
+				locs[idx] = ids[fn]
+			}
+			b.packed(sampleLocationID, locs)
+			b.packed(sampleValue, []uint64{uint64(s.Calls), uint64(s.Self.Nanoseconds())})
+		})
+	}
+	// Each function has one location, at its definition.
+	for _, fn := range prof.Funcs {
+		b.message(profileLocation, func(b *protobuf) {
+			b.uint64(locationID, ids[fn])
+			b.message(locationLine, func(b *protobuf) {
+				b.uint64(lineFunctionID, ids[fn])
+				b.int64(lineLine, int64(fn.Line))
+			})
+		})
+	}
+	for _, fn := range prof.Funcs {
+		b.message(profileFunction, func(b *protobuf) {
+			b.uint64(functionID, ids[fn])
+			b.int64(functionName, strs.add(fn.Name))
+			b.int64(functionSystemName, strs.add(fn.Name))
+			if fn.Line > 0 {
+				b.int64(functionFilename, strs.add(prof.File))
+				b.int64(functionStartLine, int64(fn.Line))
+			}
+		})
+	}
+	periodType := [2]int64{strs.add("time"), strs.add("nanoseconds")}
+	defaultType := strs.add("time")
+	for _, s := range strs.list {
+		b.string(profileStringTable, s)
+	}
+	b.int64(profileTimeNanos, prof.Start.UnixNano())
+	b.int64(profileDurationNanos, prof.Duration.Nanoseconds())
+	b.message(profilePeriodType, func(b *protobuf) {
+		b.int64(valueTypeType, periodType[0])
+		b.int64(valueTypeUnit, periodType[1])
+	})
+	b.int64(profilePeriod, 1)
+	b.int64(profileDefaultSampleType, defaultType)
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Field numbers from profile.proto.
+const (
+	profileSampleType        = 1
+	profileSample            = 2
+	profileLocation          = 4
+	profileFunction          = 5
+	profileStringTable       = 6
+	profileTimeNanos         = 9
+	profileDurationNanos     = 10
+	profilePeriodType        = 11
+	profilePeriod            = 12
+	profileDefaultSampleType = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// stringTable is a profile's table of strings, which its messages refer to
+// by index. The first is always empty.
+type stringTable struct {
+	index map[string]int64
+	list  []string
+}
+
+func (t *stringTable) add(s string) int64 {
+	if idx, ok := t.index[s]; ok {
+		return idx
+	}
+	t.index[s] = int64(len(t.list))
+	t.list = append(t.list, s)
+	return t.index[s]
+}
+
+// protobuf encodes a protocol buffer message. Zero numbers are left out, as
+// they are their fields' defaults.
+type protobuf struct {
+	data []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protobuf) tag(field, wire int) {
+	b.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (b *protobuf) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.tag(field, wireVarint)
+	b.varint(x)
+}
+
+func (b *protobuf) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+// string writes s even when it is empty, since the string table depends on
+// the position of each entry.
+func (b *protobuf) string(field int, s string) {
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(s)))
+	b.data = append(b.data, s...)
+}
+
+func (b *protobuf) packed(field int, xs []uint64) {
+	var inner protobuf
+	for _, x := range xs {
+		inner.varint(x)
+	}
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(inner.data)))
+	b.data = append(b.data, inner.data...)
+}
+
+func (b *protobuf) message(field int, encode func(*protobuf)) {
+	var inner protobuf
+	encode(&inner)
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(inner.data)))
+	b.data = append(b.data, inner.data...)
+}
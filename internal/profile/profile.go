@@ -0,0 +1,255 @@
+// Package profile records where a program run by the interpreter spends its
+// time: how often each function is called, the time spent in it and in the
+// calls it makes, and how often the statements on each line run.
+package profile
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/function"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+)
+
+// MainName is the function that top-level statements are charged to. It is
+// not bracketed, as pprof would drop brackets as C++ template arguments.
+const MainName = "main"
+
+// Func is what was recorded for one function. Functions are told apart by
+// name and the position of their definition, so the closures a function
+// expression creates each time it runs are counted together.
+type Func struct {
+	Name string
+	// Line and Column are where the function is defined; both are zero for
+	// builtins.
+	Line, Column int
+	Calls        int64
+	// Self is the time spent in the function itself, Cum that and the time
+	// spent in the calls it made. A recursive call's time is only counted
+	// once in Cum.
+	Self, Cum time.Duration
+}
+
+// Sample is the time spent in the innermost function of a call stack,
+// over all the calls that ended with that stack.
+type Sample struct {
+	// Stack is innermost first, ending with the MainName function.
+	Stack []*Func
+	Calls int64
+	Self  time.Duration
+}
+
+// Profile is the record of one run.
+type Profile struct {
+	File     string
+	Start    time.Time
+	Duration time.Duration
+	// Funcs is ordered by self time, most first, with MainName among them.
+	Funcs []*Func
+	// Lines counts how many times a statement starting on each line ran.
+	Lines   map[int]int64
+	Samples []*Sample
+}
+
+type funcKey struct {
+	name         string
+	line, column int
+}
+
+// activeCall is a call in progress.
+type activeCall struct {
+	fn       *Func
+	start    time.Time
+	children time.Duration
+}
+
+// Profiler records a run. Spawned tasks run their calls concurrently, so each
+// task's calls in progress are kept apart, keyed by its interpreter.
+type Profiler struct {
+	mu      sync.Mutex
+	file    string
+	root    *interpreter.Interpreter
+	start   time.Time
+	main    *Func
+	funcs   map[funcKey]*Func
+	lines   map[int]int64
+	tasks   map[*interpreter.Interpreter][]activeCall
+	samples map[string]*Sample
+	// mainChildren is the time the root task spent in calls.
+	mainChildren time.Duration
+	// hook and callHook are the hooks interp had before Start, which the
+	// profiler calls in turn and puts back on Stop.
+	hook     func(*interpreter.Interpreter, ast.Stmt) error
+	callHook func(*interpreter.Interpreter, function.Callable, bool)
+}
+
+// Start hooks a profiler into interp, which is about to run the program read
+// from file, ahead of any hooks it already has. Profiling ends with Stop.
+func Start(file string, interp *interpreter.Interpreter) *Profiler {
+	p := &Profiler{
+		file:     file,
+		root:     interp,
+		main:     &Func{Name: MainName, Calls: 1},
+		funcs:    make(map[funcKey]*Func),
+		lines:    make(map[int]int64),
+		tasks:    make(map[*interpreter.Interpreter][]activeCall),
+		samples:  make(map[string]*Sample),
+		hook:     interp.Hook,
+		callHook: interp.CallHook,
+	}
+	interp.Hook = p.stmt
+	interp.CallHook = p.call
+	p.start = time.Now()
+	return p
+}
+
+// Stop unhooks the profiler, putting back the hooks it found, and returns
+// what it recorded.
+func (p *Profiler) Stop() *Profile {
+	duration := time.Since(p.start)
+	p.root.Hook, p.root.CallHook = p.hook, p.callHook
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.main.Cum = duration
+	p.main.Self = duration - p.mainChildren
+	p.sample([]*Func{p.main}, 1, p.main.Self)
+
+	prof := &Profile{
+		File:     p.file,
+		Start:    p.start,
+		Duration: duration,
+		Funcs:    append([]*Func{p.main}, slices.Collect(maps.Values(p.funcs))...),
+		Lines:    p.lines,
+		Samples:  slices.Collect(maps.Values(p.samples)),
+	}
+	slices.SortFunc(prof.Funcs, func(a, b *Func) int {
+		return cmp.Or(cmp.Compare(b.Self, a.Self), cmp.Compare(a.Name, b.Name), cmp.Compare(a.Line, b.Line))
+	})
+	slices.SortFunc(prof.Samples, func(a, b *Sample) int {
+		return cmp.Compare(b.Self, a.Self)
+	})
+	return prof
+}
+
+func (p *Profiler) stmt(interp *interpreter.Interpreter, stmt ast.Stmt) error {
+	line, _ := stmt.Pos()
+	p.mu.Lock()
+	p.lines[line]++
+	p.mu.Unlock()
+	if p.hook != nil {
+		return p.hook(interp, stmt)
+	}
+	return nil
+}
+
+func (p *Profiler) call(interp *interpreter.Interpreter, callable function.Callable, returned bool) {
+	if p.callHook != nil {
+		p.callHook(interp, callable, returned)
+	}
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	calls := p.tasks[interp]
+	if !returned {
+		p.tasks[interp] = append(calls, activeCall{fn: p.lookup(callable), start: now})
+		return
+	}
+	if len(calls) == 0 {
+		return
+	}
+	done := calls[len(calls)-1]
+	calls = calls[:len(calls)-1]
+	elapsed := now.Sub(done.start)
+	self := elapsed - done.children
+	done.fn.Calls++
+	done.fn.Self += self
+	if !slices.ContainsFunc(calls, func(c activeCall) bool { return c.fn == done.fn }) {
+		done.fn.Cum += elapsed
+	}
+
+	stack := []*Func{done.fn}
+	for idx := len(calls) - 1; idx >= 0; idx-- {
+		stack = append(stack, calls[idx].fn)
+	}
+	p.sample(append(stack, p.main), 1, self)
+
+	switch {
+	case len(calls) > 0:
+		calls[len(calls)-1].children += elapsed
+		p.tasks[interp] = calls
+	case interp == p.root:
+		p.mainChildren += elapsed
+		delete(p.tasks, interp)
+	default:
+		delete(p.tasks, interp)
+	}
+}
+
+// lookup returns the record of fn, creating it on its first call.
+func (p *Profiler) lookup(fn function.Callable) *Func {
+	line, col := fn.SourcePos()
+	key := funcKey{fn.Name(), line, col}
+	f, ok := p.funcs[key]
+	if !ok {
+		f = &Func{Name: fn.Name(), Line: line, Column: col}
+		p.funcs[key] = f
+	}
+	return f
+}
+
+func (p *Profiler) sample(stack []*Func, calls int64, self time.Duration) {
+	var key strings.Builder
+	for _, fn := range stack {
+		fmt.Fprintf(&key, "%p;", fn)
+	}
+	s, ok := p.samples[key.String()]
+	if !ok {
+		s = &Sample{Stack: stack}
+		p.samples[key.String()] = s
+	}
+	s.Calls += calls
+	s.Self += self
+}
+
+// WriteTable writes prof as a flat table of its functions, most self time
+// first, followed by the statement hits of each line.
+func (prof *Profile) WriteTable(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "profile of %s, %s\n\n", prof.File, prof.Duration.Round(time.Microsecond))
+	fmt.Fprintf(&b, "%10s %12s %7s %12s %7s  %s\n", "calls", "self", "self%", "cum", "cum%", "function")
+	for _, fn := range prof.Funcs {
+		fmt.Fprintf(&b, "%10d %12s %7s %12s %7s  %s\n", fn.Calls,
+			fn.Self.Round(time.Microsecond), percent(fn.Self, prof.Duration),
+			fn.Cum.Round(time.Microsecond), percent(fn.Cum, prof.Duration),
+			prof.location(fn))
+	}
+	fmt.Fprintf(&b, "\n%10s %12s\n", "line", "hits")
+	for _, line := range slices.Sorted(maps.Keys(prof.Lines)) {
+		fmt.Fprintf(&b, "%10d %12d\n", line, prof.Lines[line])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// location names fn with where it is defined.
+func (prof *Profile) location(fn *Func) string {
+	if fn.Line == 0 {
+		return fn.Name
+	}
+	return fn.Name + " " + prof.File + ":" + strconv.Itoa(fn.Line)
+}
+
+func percent(d, total time.Duration) string {
+	if total <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(d)/float64(total))
+}
@@ -0,0 +1,174 @@
+package profile_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/function"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/interpreter"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/profile"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+)
+
+const source = `func fib(n: int) -> int {
+    if n < 2 {
+        return n
+    }
+    return fib(n - 1) + fib(n - 2)
+}
+
+var i: int = 0
+while i < 3 {
+    print(fib(5))
+    i = i + 1
+}
+print(len([1, 2]))
+`
+
+func run(t *testing.T) *profile.Profile {
+	t.Helper()
+	value.BuiltinTypesInit()
+	stmts, err := parser.New(lexer.New(source)).Parse()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	interp := interpreter.NewInterpreter()
+	interp.Out = io.Discard
+	if errs, _ := interp.Check(stmts); len(errs) > 0 {
+		t.Fatalf("check: %v", errs)
+	}
+	profiler := profile.Start("prog.nif", interp)
+	for _, stmt := range stmts {
+		if result := interp.Execute(stmt); result.Err != nil {
+			t.Fatalf("run: %v", result.Err)
+		}
+	}
+	prof := profiler.Stop()
+	if interp.Hook != nil || interp.CallHook != nil {
+		t.Errorf("expected Stop to unhook the interpreter")
+	}
+	return prof
+}
+
+func TestProfile_Counts(t *testing.T) {
+	prof := run(t)
+	calls := make(map[string]*profile.Func)
+	for _, fn := range prof.Funcs {
+		calls[fn.Name] = fn
+	}
+	// fib(5) makes 15 calls, and it is called three times.
+	if fib := calls["fib"]; fib == nil || fib.Calls != 45 || fib.Line != 1 {
+		t.Errorf("expected fib defined on line 1 with 45 calls, got %+v", fib)
+	}
+	if length := calls["len"]; length == nil || length.Calls != 1 || length.Line != 0 {
+		t.Errorf("expected builtin len with 1 call, got %+v", length)
+	}
+	main := calls[profile.MainName]
+	if main == nil || main.Cum != prof.Duration {
+		t.Fatalf("expected %s to account for the whole run, got %+v", profile.MainName, main)
+	}
+	var self, sampled time.Duration
+	for _, fn := range prof.Funcs {
+		self += fn.Self
+		if fn.Cum > main.Cum || fn.Self > fn.Cum {
+			t.Errorf("%s: self %s, cum %s, run %s", fn.Name, fn.Self, fn.Cum, main.Cum)
+		}
+	}
+	for _, s := range prof.Samples {
+		sampled += s.Self
+		if s.Stack[len(s.Stack)-1] != main {
+			t.Errorf("expected every stack to end with %s, got %s", profile.MainName, s.Stack[len(s.Stack)-1].Name)
+		}
+	}
+	if self != prof.Duration || sampled != prof.Duration {
+		t.Errorf("expected self times %s and samples %s to add up to the run, %s", self, sampled, prof.Duration)
+	}
+
+	for line, want := range map[int]int64{2: 45, 3: 24, 5: 21, 8: 1, 9: 1, 10: 3, 11: 3, 13: 1} {
+		if got := prof.Lines[line]; got != want {
+			t.Errorf("line %d: expected %d hits, got %d", line, want, got)
+		}
+	}
+}
+
+func TestProfile_WriteTable(t *testing.T) {
+	var out strings.Builder
+	if err := run(t).WriteTable(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"profile of prog.nif", "function\n", "fib prog.nif:1\n", "  len\n", "  main\n", "line         hits\n", "         2           45\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected table to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestProfile_WritePprof(t *testing.T) {
+	var out bytes.Buffer
+	if err := run(t).WritePprof(&out); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatalf("expected gzipped output: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The string table is field 6: each entry is its tag, its length and
+	// the string.
+	for _, s := range []string{"", "calls", "count", "time", "nanoseconds", "fib", "main", "prog.nif"} {
+		entry := append([]byte{6<<3 | 2, byte(len(s))}, s...)
+		if !bytes.Contains(data, entry) {
+			t.Errorf("expected string table entry %q", s)
+		}
+	}
+}
+
+func TestProfile_KeepsHooks(t *testing.T) {
+	value.BuiltinTypesInit()
+	stmts, err := parser.New(lexer.New(source)).Parse()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	interp := interpreter.NewInterpreter()
+	interp.Out = io.Discard
+	stmtsRun, callsMade := 0, 0
+	interp.Hook = func(*interpreter.Interpreter, ast.Stmt) error {
+		stmtsRun++
+		return nil
+	}
+	interp.CallHook = func(_ *interpreter.Interpreter, _ function.Callable, returned bool) {
+		if !returned {
+			callsMade++
+		}
+	}
+	profiler := profile.Start("prog.nif", interp)
+	for _, stmt := range stmts {
+		if result := interp.Execute(stmt); result.Err != nil {
+			t.Fatalf("run: %v", result.Err)
+		}
+	}
+	prof := profiler.Stop()
+	if stmtsRun == 0 || callsMade != 46 {
+		t.Errorf("expected the earlier hooks to see every statement and the 46 calls, got %d statements and %d calls", stmtsRun, callsMade)
+	}
+	if interp.Hook == nil || interp.CallHook == nil {
+		t.Errorf("expected Stop to put back the earlier hooks")
+	}
+	var lines int64
+	for _, hits := range prof.Lines {
+		lines += hits
+	}
+	if lines != int64(stmtsRun) {
+		t.Errorf("expected the profiler to count the %d statements the earlier hook saw, got %d", stmtsRun, lines)
+	}
+}
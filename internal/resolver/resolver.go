@@ -0,0 +1,319 @@
+// Package resolver places local variables ahead of time. It walks a program
+// with the same scopes the interpreter creates at run time and annotates each
+// variable use and assignment with the (depth, slot) of the variable it refers
+// to, so the interpreter can read it without searching scopes by name.
+package resolver
+
+import (
+	"slices"
+
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// ScopeError is a declaration error found before the program runs. Its Kind
+// is niferrors.ScopeError.
+type ScopeError = niferrors.NifError
+
+// scope mirrors one runtime environment.
+type scope struct {
+	// slots maps the names declared so far to their index, in declaration order.
+	slots map[string]int
+	// pending holds names the scope declares further on.
+	pending map[string]bool
+	// fn is the function nesting level the scope belongs to. Code in a nested
+	// function may run after a pending name is declared; code at the same level
+	// cannot.
+	fn int
+}
+
+// Resolver annotates the bindings of one program.
+type Resolver struct {
+	scopes []*scope
+	fn     int
+	errs   []error
+}
+
+// Resolve annotates stmts, which run in the global scope, and reports every
+// use before declaration and duplicate declaration. Globals are left
+// unresolved: they can be defined by earlier chunks of a REPL session or file.
+func Resolve(stmts []ast.Stmt) []error {
+	r := &Resolver{}
+	r.pushScope()
+	r.resolveStmts(stmts)
+	r.popScope()
+	return r.errs
+}
+
+func (r *Resolver) errorAt(tok token.Token, code niferrors.Code, format string, args ...any) {
+	r.errs = append(r.errs, niferrors.New(niferrors.ScopeError, code, tok, format, args...))
+}
+
+func (r *Resolver) pushScope() {
+	r.scopes = append(r.scopes, &scope{
+		slots:   make(map[string]int),
+		pending: make(map[string]bool),
+		fn:      r.fn,
+	})
+}
+
+func (r *Resolver) popScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *Resolver) current() *scope {
+	return r.scopes[len(r.scopes)-1]
+}
+
+// declare gives name the next slot of the current scope.
+func (r *Resolver) declare(name token.Token) {
+	sc := r.current()
+	delete(sc.pending, name.Lexeme)
+	if _, exists := sc.slots[name.Lexeme]; exists {
+		r.errorAt(name, niferrors.CodeRedeclared, "duplicate declaration of '%s' in this scope", name.Lexeme)
+		return
+	}
+	sc.slots[name.Lexeme] = len(sc.slots)
+}
+
+// expect marks the names stmts declare as pending in the current scope.
+func (r *Resolver) expect(stmts []ast.Stmt) {
+	sc := r.current()
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.VarStmt:
+			for _, name := range s.Names {
+				sc.pending[name.Lexeme] = true
+			}
+		case *ast.ShortVarStmt:
+			sc.pending[s.Name.Lexeme] = true
+		case *ast.FuncStmt:
+			sc.pending[s.Name.Lexeme] = true
+		}
+	}
+}
+
+// resolveName fills in the binding for a use of name.
+func (r *Resolver) resolveName(name token.Token, binding *ast.Binding) {
+	for depth := 0; depth < len(r.scopes); depth++ {
+		idx := len(r.scopes) - 1 - depth
+		sc := r.scopes[idx]
+		if slot, ok := sc.slots[name.Lexeme]; ok {
+			if idx > 0 {
+				*binding = ast.Binding{Depth: depth, Slot: slot, Resolved: true}
+			}
+			return
+		}
+		if sc.pending[name.Lexeme] {
+			if sc.fn == r.fn {
+				r.errorAt(name, niferrors.CodeUseBeforeDecl, "use of '%s' before its declaration", name.Lexeme)
+			}
+			return
+		}
+	}
+}
+
+func (r *Resolver) resolveStmts(stmts []ast.Stmt) {
+	r.expect(stmts)
+	for _, stmt := range stmts {
+		r.resolveStmt(stmt)
+	}
+}
+
+// resolveBlock resolves statements run in a scope of their own.
+func (r *Resolver) resolveBlock(stmts []ast.Stmt) {
+	r.pushScope()
+	r.resolveStmts(stmts)
+	r.popScope()
+}
+
+func (r *Resolver) resolveStmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.VarStmt:
+		// The initializer runs before the names exist, so it sees outer ones.
+		for _, name := range s.Names {
+			delete(r.current().pending, name.Lexeme)
+		}
+		r.resolveExpr(s.Init)
+		for _, name := range s.Names {
+			r.declare(name)
+		}
+	case *ast.ShortVarStmt:
+		delete(r.current().pending, s.Name.Lexeme)
+		r.resolveExpr(s.Init)
+		r.declare(s.Name)
+	case *ast.AssignStmt:
+		r.resolveExpr(s.Value)
+		r.resolveName(s.Name, &s.Binding)
+	case *ast.CompoundAssignStmt:
+		r.resolveName(s.Name, &s.Binding)
+		r.resolveExpr(s.Value)
+	case *ast.PrintStmt:
+		r.resolveExpr(s.Expr)
+	case *ast.ExprStmt:
+		r.resolveExpr(s.Expr)
+	case *ast.StructStmt:
+		for idx := range s.Methods {
+			method := &s.Methods[idx]
+			// Bound methods run in a scope holding self, inside the struct's scope.
+			r.fn++
+			r.pushScope()
+			r.declare(token.Token{Lexeme: "self"})
+			r.resolveFunc(method.Params, method.Body)
+			r.popScope()
+			r.fn--
+		}
+	case *ast.IfStmt:
+		r.resolveExpr(s.Conditon)
+		r.resolveStmt(s.ThenBranch)
+		if s.ElseBranch != nil {
+			r.resolveStmt(s.ElseBranch)
+		}
+	case *ast.WhileStmt:
+		r.resolveExpr(s.Conditon)
+		r.resolveStmt(s.Body)
+	case *ast.ForStmt:
+		r.pushScope()
+		if s.Init != nil {
+			r.resolveStmt(s.Init)
+		}
+		r.resolveExpr(s.CondExpr)
+		r.resolveStmt(s.BodyStmt)
+		if s.Update != nil {
+			r.resolveStmt(s.Update)
+		}
+		r.popScope()
+	case *ast.ForInStmt:
+		r.resolveExpr(s.Iterable)
+		r.pushScope()
+		r.declare(s.Name)
+		r.resolveStmt(s.BodyStmt)
+		r.popScope()
+	case *ast.SwitchStmt:
+		r.resolveExpr(s.Subject)
+		for _, clause := range s.Cases {
+			for _, val := range clause.Values {
+				r.resolveExpr(val)
+			}
+		}
+		for _, clause := range s.Cases {
+			r.resolveBlock(clause.Body)
+		}
+	case *ast.SelectStmt:
+		for _, clause := range s.Cases {
+			if clause.Comm != nil {
+				for _, arg := range clause.Comm.Arguments {
+					r.resolveExpr(arg)
+				}
+			}
+		}
+		for _, clause := range s.Cases {
+			r.pushScope()
+			if clause.Name.Lexeme != "" {
+				r.declare(clause.Name)
+			}
+			r.resolveBlock(clause.Body)
+			r.popScope()
+		}
+	case *ast.YieldStmt:
+		r.resolveExpr(s.Value)
+	case *ast.FuncStmt:
+		r.declare(s.Name)
+		r.fn++
+		r.resolveFunc(s.Params, s.Body)
+		r.fn--
+	case *ast.ReturnStmt:
+		for _, val := range s.Values {
+			r.resolveExpr(val)
+		}
+	case *ast.BlockStmt:
+		r.resolveBlock(s.Statements)
+	case *ast.FallthroughStmt, *ast.BreakStmt, *ast.ContinueStmt, nil:
+	}
+}
+
+// resolveFunc resolves a function body, which runs in the same scope as its
+// parameters. The caller has entered the function's nesting level.
+func (r *Resolver) resolveFunc(params []ast.Param, body *ast.BlockStmt) {
+	r.pushScope()
+	for _, param := range params {
+		r.declare(param.Name)
+	}
+	if body != nil {
+		r.resolveStmts(body.Statements)
+	}
+	r.popScope()
+}
+
+func (r *Resolver) resolveExpr(expr ast.Expr) {
+	switch e := expr.(type) {
+	case *ast.VariableExpr:
+		r.resolveName(e.Name, &e.Binding)
+	case *ast.BinaryExpr:
+		r.resolveExpr(e.Left)
+		r.resolveExpr(e.Right)
+	case *ast.UnaryExpr:
+		r.resolveExpr(e.Right)
+	case *ast.CallExpr:
+		r.resolveExpr(e.Callee)
+		for _, arg := range e.Arguments {
+			r.resolveExpr(arg)
+		}
+	case *ast.GetExpr:
+		r.resolveExpr(e.Object)
+	case *ast.IndexExpr:
+		r.resolveExpr(e.Collection)
+		r.resolveExpr(e.Index)
+	case *ast.SliceExpr:
+		r.resolveExpr(e.Collection)
+		r.resolveExpr(e.Low)
+		r.resolveExpr(e.High)
+	case *ast.RangeExpr:
+		r.resolveExpr(e.Start)
+		r.resolveExpr(e.End)
+		r.resolveExpr(e.Step)
+	case *ast.IfExpr:
+		r.resolveIfExpr(e)
+	case *ast.TernaryExpr:
+		r.resolveExpr(e.Conditon)
+		r.resolveExpr(e.Then)
+		r.resolveExpr(e.Else)
+	case *ast.ListExpr:
+		for _, elem := range e.Elements {
+			r.resolveExpr(elem)
+		}
+	case *ast.DictExpr:
+		for _, pair := range e.Pairs {
+			r.resolveExpr(pair[0])
+			r.resolveExpr(pair[1])
+		}
+	case *ast.StructLiteralExpr:
+		names := make([]string, 0, len(e.Fields))
+		for name := range e.Fields {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			r.resolveExpr(e.Fields[name])
+		}
+	case *ast.FuncExpr:
+		r.fn++
+		r.resolveFunc(e.Params, e.Body)
+		r.fn--
+	case *ast.SpawnExpr:
+		r.resolveExpr(e.Call)
+	}
+}
+
+func (r *Resolver) resolveIfExpr(e *ast.IfExpr) {
+	r.resolveExpr(e.Conditon)
+	if e.ThenBranch != nil {
+		r.resolveBlock(e.ThenBranch.Statements)
+	}
+	if e.ElseIf != nil {
+		r.resolveIfExpr(e.ElseIf)
+	} else if e.ElseBranch != nil {
+		r.resolveBlock(e.ElseBranch.Statements)
+	}
+}
@@ -0,0 +1,139 @@
+package resolver_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/resolver"
+)
+
+func resolve(t *testing.T, source string) ([]ast.Stmt, []error) {
+	t.Helper()
+	stmts, err := parser.New(lexer.New(source)).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	return stmts, resolver.Resolve(stmts)
+}
+
+func expectBinding(t *testing.T, what string, got, want ast.Binding) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s: expected binding %+v, got %+v", what, want, got)
+	}
+}
+
+func TestResolve_Locals(t *testing.T) {
+	stmts, errs := resolve(t, `
+func f(a: int) {
+	b := a
+	if true {
+		c := b + a
+		b = c
+	}
+}`)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	body := stmts[0].(*ast.FuncStmt).Body.Statements
+	expectBinding(t, "a in b := a", body[0].(*ast.ShortVarStmt).Init.(*ast.VariableExpr).Binding, ast.Binding{Depth: 0, Slot: 0, Resolved: true})
+
+	inner := body[1].(*ast.IfStmt).ThenBranch.(*ast.BlockStmt).Statements
+	sum := inner[0].(*ast.ShortVarStmt).Init.(*ast.BinaryExpr)
+	expectBinding(t, "b in block", sum.Left.(*ast.VariableExpr).Binding, ast.Binding{Depth: 1, Slot: 1, Resolved: true})
+	expectBinding(t, "a in block", sum.Right.(*ast.VariableExpr).Binding, ast.Binding{Depth: 1, Slot: 0, Resolved: true})
+	assign := inner[1].(*ast.AssignStmt)
+	expectBinding(t, "b = c", assign.Binding, ast.Binding{Depth: 1, Slot: 1, Resolved: true})
+	expectBinding(t, "c in b = c", assign.Value.(*ast.VariableExpr).Binding, ast.Binding{Depth: 0, Slot: 0, Resolved: true})
+}
+
+func TestResolve_GlobalsStayUnresolved(t *testing.T) {
+	stmts, errs := resolve(t, `
+x := 1
+func f() {
+	print(x)
+}`)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	print := stmts[1].(*ast.FuncStmt).Body.Statements[0].(*ast.PrintStmt)
+	expectBinding(t, "global x", print.Expr.(*ast.VariableExpr).Binding, ast.Binding{})
+}
+
+func TestResolve_InitializerSeesOuterName(t *testing.T) {
+	stmts, errs := resolve(t, `
+func f() {
+	x := 1
+	if true {
+		x := x + 1
+	}
+}`)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	block := stmts[0].(*ast.FuncStmt).Body.Statements[1].(*ast.IfStmt).ThenBranch.(*ast.BlockStmt)
+	init := block.Statements[0].(*ast.ShortVarStmt).Init.(*ast.BinaryExpr)
+	expectBinding(t, "outer x", init.Left.(*ast.VariableExpr).Binding, ast.Binding{Depth: 1, Slot: 0, Resolved: true})
+}
+
+func TestResolve_LaterFunctionFromClosure(t *testing.T) {
+	stmts, errs := resolve(t, `
+func outer() {
+	func a() -> int {
+		return b()
+	}
+	func b() -> int {
+		return 1
+	}
+}`)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	a := stmts[0].(*ast.FuncStmt).Body.Statements[0].(*ast.FuncStmt)
+	call := a.Body.Statements[0].(*ast.ReturnStmt).Values[0].(*ast.CallExpr)
+	expectBinding(t, "b declared later", call.Callee.(*ast.VariableExpr).Binding, ast.Binding{})
+}
+
+func TestResolve_Errors(t *testing.T) {
+	cases := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"use before declaration", `func f() {
+	print(y)
+	y := 1
+}`, "2:8: use of 'y' before its declaration"},
+		{"call before declaration", `g()
+func g() {
+}`, "use of 'g' before its declaration"},
+		{"assign before declaration", `if true {
+	z = 2
+	var z: int = 1
+}`, "use of 'z' before its declaration"},
+		{"duplicate", `func f() {
+	y := 1
+	y := 2
+}`, "3:2: duplicate declaration of 'y' in this scope"},
+		{"duplicate parameter", `func f(a: int, a: int) {
+}`, "duplicate declaration of 'a' in this scope"},
+		{"duplicate function", `func f() {
+}
+func f() {
+}`, "duplicate declaration of 'f' in this scope"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, errs := resolve(t, tc.source)
+			for _, err := range errs {
+				if strings.Contains(err.Error(), tc.want) {
+					return
+				}
+			}
+			t.Errorf("expected error containing %q, got %v", tc.want, errs)
+		})
+	}
+}
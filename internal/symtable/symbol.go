@@ -18,9 +18,11 @@ type Symbol interface {
 }
 
 type TypeSymbol struct {
-	SymName    string
-	SymKind    SymbolKind
-	Fields     map[string]*TypeSymbol
+	SymName string
+	SymKind SymbolKind
+	Fields  map[string]*TypeSymbol
+	// FieldOrder lists the names in Fields in declaration order.
+	FieldOrder []string
 	TypeParams []string
 	TypeArgs   []*TypeSymbol
 	Methods    map[string]*FuncSymbol
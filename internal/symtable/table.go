@@ -82,6 +82,7 @@ func InstantiateGenericType(gen *TypeSymbol, typeArgs []*TypeSymbol) *TypeSymbol
 		TypeParams: nil,
 		TypeArgs:   typeArgs,
 		Fields:     newFields,
+		FieldOrder: gen.FieldOrder,
 		Methods:    nil,
 		IsGeneric:  false,
 		Origin:     gen,
@@ -141,6 +142,11 @@ func (s *SymbolTable) Lookup(kind SymbolKind, name string) (Symbol, bool) {
 	return nil, false
 }
 
+func (s *SymbolTable) LookupLocal(kind SymbolKind, name string) (Symbol, bool) {
+	sym, ok := s.namespace(kind)[name]
+	return sym, ok
+}
+
 func (s *SymbolTable) HasLocal(kind SymbolKind, name string) bool {
 	ns := s.namespace(kind)
 	_, ok := ns[name]
@@ -0,0 +1,127 @@
+// Package trace is the debug logging of the compiler and interpreter. Each
+// component logs through its own slog.Logger, which discards everything
+// until Enable switches the component on.
+package trace
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Component names a part of the toolchain whose tracing can be switched on
+// by itself.
+type Component string
+
+const (
+	Lexer   Component = "lexer"
+	Parser  Component = "parser"
+	Interp  Component = "interp"
+	Codegen Component = "codegen"
+)
+
+// Components lists every component, in pipeline order.
+var Components = []Component{Lexer, Parser, Interp, Codegen}
+
+// config is what Enable set: where records go and which components and
+// levels make it there.
+type config struct {
+	handler    slog.Handler
+	level      slog.Level
+	components []Component
+}
+
+var current atomic.Pointer[config]
+
+// Enable sends the records of components at level or above to w, as text,
+// and silences every other component. With no components, tracing is off.
+func Enable(w io.Writer, level slog.Level, components ...Component) {
+	if len(components) == 0 {
+		current.Store(nil)
+		return
+	}
+	handler := slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})
+	current.Store(&config{handler: handler, level: level, components: slices.Clone(components)})
+}
+
+// On reports whether c logs at level, for callers that would otherwise
+// compute attributes nobody reads.
+func On(c Component, level slog.Level) bool {
+	cfg := current.Load()
+	return cfg != nil && level >= cfg.level && slices.Contains(cfg.components, c)
+}
+
+// Logger returns the logger of c. Loggers can be created before Enable is
+// called; they pick up its settings when they log.
+func Logger(c Component) *slog.Logger {
+	return slog.New(&handler{component: c})
+}
+
+// ParseComponents parses a comma-separated list of component names, as
+// given to --trace. "all" stands for every component.
+func ParseComponents(spec string) ([]Component, error) {
+	var components []Component
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+			continue
+		case name == "all":
+			return slices.Clone(Components), nil
+		case slices.Contains(Components, Component(name)):
+			components = append(components, Component(name))
+		default:
+			return nil, fmt.Errorf("unknown trace component %q: want %s or all", name, joinComponents())
+		}
+	}
+	return components, nil
+}
+
+func joinComponents() string {
+	names := make([]string, len(Components))
+	for idx, c := range Components {
+		names[idx] = string(c)
+	}
+	return strings.Join(names, ", ")
+}
+
+// handler passes the records of its component to the handler Enable set up,
+// if the component is enabled, tagging each with the component name. The
+// attributes and groups added to it are replayed onto that handler, which
+// may change between records.
+type handler struct {
+	component Component
+	derive    []func(slog.Handler) slog.Handler
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return On(h.component, level)
+}
+
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	cfg := current.Load()
+	if cfg == nil {
+		return nil
+	}
+	out := cfg.handler.WithAttrs([]slog.Attr{slog.String("component", string(h.component))})
+	for _, derive := range h.derive {
+		out = derive(out)
+	}
+	return out.Handle(ctx, record)
+}
+
+func (h *handler) with(derive func(slog.Handler) slog.Handler) *handler {
+	return &handler{component: h.component, derive: append(slices.Clip(h.derive), derive)}
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(out slog.Handler) slog.Handler { return out.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(func(out slog.Handler) slog.Handler { return out.WithGroup(name) })
+}
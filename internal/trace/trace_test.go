@@ -0,0 +1,92 @@
+package trace_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/trace"
+)
+
+func TestLogger_SilentByDefault(t *testing.T) {
+	trace.Enable(nil, slog.LevelDebug)
+	if trace.On(trace.Parser, slog.LevelError) {
+		t.Error("expected tracing to be off")
+	}
+	trace.Logger(trace.Parser).Error("nobody listens")
+}
+
+func TestLogger_Components(t *testing.T) {
+	var out bytes.Buffer
+	trace.Enable(&out, slog.LevelDebug, trace.Parser, trace.Codegen)
+	defer trace.Enable(nil, slog.LevelDebug)
+
+	parserLog := trace.Logger(trace.Parser).With("file", "a.nif")
+	parserLog.Debug("statement", "line", 3)
+	trace.Logger(trace.Lexer).Debug("token", "lexeme", "x")
+	trace.Logger(trace.Codegen).WithGroup("emit").Warn("unsupported statement", "type", "*nifast.SelectStmt")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got %d:\n%s", len(lines), out.String())
+	}
+	for _, want := range []string{"level=DEBUG", "msg=statement", "component=parser", "file=a.nif", "line=3"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("expected %q in %q", want, lines[0])
+		}
+	}
+	for _, want := range []string{"level=WARN", "component=codegen", "emit.type=*nifast.SelectStmt"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("expected %q in %q", want, lines[1])
+		}
+	}
+}
+
+func TestLogger_Level(t *testing.T) {
+	var out bytes.Buffer
+	trace.Enable(&out, slog.LevelWarn, trace.Codegen)
+	defer trace.Enable(nil, slog.LevelDebug)
+
+	log := trace.Logger(trace.Codegen)
+	log.Debug("generate", "statements", 4)
+	log.Warn("unsupported print expression")
+	if got := strings.Count(out.String(), "\n"); got != 1 || !strings.Contains(out.String(), "level=WARN") {
+		t.Errorf("expected only the warning, got:\n%s", out.String())
+	}
+	if trace.On(trace.Codegen, slog.LevelInfo) || !trace.On(trace.Codegen, slog.LevelError) {
+		t.Error("expected On to follow the level")
+	}
+}
+
+func TestParseComponents(t *testing.T) {
+	cases := []struct {
+		spec string
+		want []trace.Component
+		err  bool
+	}{
+		{"parser", []trace.Component{trace.Parser}, false},
+		{"lexer, interp,", []trace.Component{trace.Lexer, trace.Interp}, false},
+		{"all", trace.Components, false},
+		{"", nil, false},
+		{"parser,typo", nil, true},
+	}
+	for _, tc := range cases {
+		got, err := trace.ParseComponents(tc.spec)
+		if (err != nil) != tc.err {
+			t.Errorf("%q: expected error %v, got %v", tc.spec, tc.err, err)
+			continue
+		}
+		if strings.Join(names(got), ",") != strings.Join(names(tc.want), ",") {
+			t.Errorf("%q: expected %v, got %v", tc.spec, tc.want, got)
+		}
+	}
+}
+
+func names(components []trace.Component) []string {
+	out := make([]string, len(components))
+	for idx, c := range components {
+		out[idx] = string(c)
+	}
+	return out
+}
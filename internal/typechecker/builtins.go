@@ -0,0 +1,380 @@
+package typechecker
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// builtinFunc checks a call to a native function and returns its result type.
+type builtinFunc func(c *Checker, call *ast.CallExpr, args, typeArgs []*symtable.TypeSymbol) *symtable.TypeSymbol
+
+var builtinFuncs = map[string]builtinFunc{
+	"len": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		if c.expectArgs(call, "len", args, 1, 1) {
+			c.expectArg(call, "len", 0, args[0], "list", "tuple", "string", "dict", "range")
+		}
+		return c.builtinType("int")
+	},
+	"list": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		if c.expectArgs(call, "list", args, 1, 1) {
+			c.elementType(call.Arguments[0], args[0])
+		}
+		return c.builtinType("list")
+	},
+	"send": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		if c.expectArgs(call, "send", args, 2, 2) && c.expectArg(call, "send", 0, args[0], "chan") {
+			if elem := chanElem(args[0]); !assignable(elem, args[1]) {
+				c.errorf(call.Arguments[1], niferrors.CodeMismatchedTypes, "cannot send %s value on %s", typeName(args[1]), args[0].SymName)
+			}
+		}
+		return c.builtinType("null")
+	},
+	"recv": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		if c.expectArgs(call, "recv", args, 1, 1) && c.expectArg(call, "recv", 0, args[0], "chan") {
+			return chanElem(args[0])
+		}
+		return nil
+	},
+	"close": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		if c.expectArgs(call, "close", args, 1, 1) {
+			c.expectArg(call, "close", 0, args[0], "chan", "generator")
+		}
+		return c.builtinType("null")
+	},
+	"join": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		if c.expectArgs(call, "join", args, 1, 1) {
+			c.expectArg(call, "join", 0, args[0], "task")
+		}
+		return nil
+	},
+	"wait": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		for idx, arg := range args {
+			c.expectArg(call, "wait", idx, arg, "task")
+		}
+		return c.builtinType("null")
+	},
+	"mutex": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		c.expectArgs(call, "mutex", args, 0, 0)
+		return c.builtinType("mutex")
+	},
+	"lock": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		if c.expectArgs(call, "lock", args, 1, 1) {
+			c.expectArg(call, "lock", 0, args[0], "mutex")
+		}
+		return c.builtinType("null")
+	},
+	"unlock": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		if c.expectArgs(call, "unlock", args, 1, 1) {
+			c.expectArg(call, "unlock", 0, args[0], "mutex")
+		}
+		return c.builtinType("null")
+	},
+	"next": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		if c.expectArgs(call, "next", args, 1, 2) {
+			c.expectArg(call, "next", 0, args[0], "generator")
+		}
+		return nil
+	},
+	"assert_eq": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		if c.expectArgs(call, "assert_eq", args, 2, 3) {
+			if !comparable(args[0], args[1]) {
+				c.errorf(call, niferrors.CodeMismatchedTypes, "assert_eq compares %s with %s, which are never equal", typeName(args[0]), typeName(args[1]))
+			}
+			if len(args) == 3 {
+				c.expectArg(call, "assert_eq", 2, args[2], "string")
+			}
+		}
+		return c.builtinType("null")
+	},
+	"assert_true": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		if c.expectArgs(call, "assert_true", args, 1, 2) {
+			c.expectArg(call, "assert_true", 0, args[0], "bool")
+			if len(args) == 2 {
+				c.expectArg(call, "assert_true", 1, args[1], "string")
+			}
+		}
+		return c.builtinType("null")
+	},
+	"assert_err": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		if c.expectArgs(call, "assert_err", args, 1, 2) {
+			c.expectArg(call, "assert_err", 0, args[0], "func")
+			if len(args) == 2 {
+				c.expectArg(call, "assert_err", 1, args[1], "string")
+			}
+		}
+		return c.builtinType("null")
+	},
+	"skip": func(c *Checker, call *ast.CallExpr, args, _ []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		if c.expectArgs(call, "skip", args, 0, 1) && len(args) == 1 {
+			c.expectArg(call, "skip", 0, args[0], "string")
+		}
+		return c.builtinType("null")
+	},
+	"chan": func(c *Checker, call *ast.CallExpr, args, typeArgs []*symtable.TypeSymbol) *symtable.TypeSymbol {
+		if len(typeArgs) != 1 {
+			c.errorf(call, niferrors.CodeTypeArgCount, "chan expects 1 type argument, got %d", len(typeArgs))
+			return nil
+		}
+		if c.expectArgs(call, "chan", args, 0, 1) && len(args) == 1 {
+			c.expectArg(call, "chan", 0, args[0], "int")
+		}
+		gen := c.builtinType("chan")
+		if gen == nil {
+			return nil
+		}
+		return instantiate(gen, typeArgs)
+	},
+}
+
+// defineBuiltins declares the builtin types and native functions in the
+// global scope.
+func (c *Checker) defineBuiltins() {
+	for _, typ := range value.BuiltinTypeList() {
+		_ = c.global.DefineValue(typ)
+	}
+	for name, fn := range builtinFuncs {
+		sym := &symtable.VarSymbol{
+			SymName: name,
+			SymKind: symtable.SymbolVar,
+			Type:    c.builtinType("func"),
+			Mutable: false,
+		}
+		_ = c.global.DefineValue(sym)
+		c.builtins[sym] = fn
+	}
+}
+
+func (c *Checker) expectArgs(call *ast.CallExpr, name string, args []*symtable.TypeSymbol, lo, hi int) bool {
+	if len(args) < lo || len(args) > hi {
+		if lo == hi {
+			c.errorf(call, niferrors.CodeArgCount, "%s() expects %d arguments, got %d", name, lo, len(args))
+		} else {
+			c.errorf(call, niferrors.CodeArgCount, "%s() expects %d to %d arguments, got %d", name, lo, hi, len(args))
+		}
+		return false
+	}
+	return true
+}
+
+// expectArg reports an error if a known argument type is not one of kinds.
+// Generic instantiations match on the name of the type they were made from.
+func (c *Checker) expectArg(call *ast.CallExpr, name string, idx int, arg *symtable.TypeSymbol, kinds ...string) bool {
+	if arg == nil || arg.SymKind == symtable.SymbolTypeParams {
+		return false
+	}
+	base := arg
+	if arg.Origin != nil {
+		base = arg.Origin
+	}
+	if slices.Contains(kinds, base.SymName) {
+		return true
+	}
+	c.errorf(call.Arguments[idx], niferrors.CodeMismatchedTypes, "%s() cannot take %s as argument %d", name, arg.SymName, idx+1)
+	return false
+}
+
+// builtinType returns a type from the global scope, or nil (unknown) if it has
+// not been registered.
+func (c *Checker) builtinType(name string) *symtable.TypeSymbol {
+	typ, _ := c.global.ResolveType(name)
+	return typ
+}
+
+// resolveType resolves a type annotation, reporting unknown types. Missing
+// annotations resolve to nil.
+func (c *Checker) resolveType(expr *ast.TypeExpr) *symtable.TypeSymbol {
+	if expr == nil || expr.Name.Lexeme == "" {
+		return nil
+	}
+	base, ok := c.scope.ResolveType(expr.Name.Lexeme)
+	if !ok {
+		c.errorAt(expr.Name, niferrors.CodeUnknownType, "unknown type '%s'", expr.Name.Lexeme).
+			DidYouMean(expr.Name.Lexeme, c.typeNames())
+		return nil
+	}
+	if len(expr.TypeArgs) == 0 {
+		return base
+	}
+	args := make([]*symtable.TypeSymbol, len(expr.TypeArgs))
+	for idx := range expr.TypeArgs {
+		args[idx] = c.resolveType(&expr.TypeArgs[idx])
+		if args[idx] == nil {
+			return nil
+		}
+	}
+	if !base.IsGeneric {
+		c.errorAt(expr.Name, niferrors.CodeTypeArgCount, "type '%s' is not generic", base.SymName)
+		return base
+	}
+	if len(args) != len(base.TypeParams) {
+		c.errorAt(expr.Name, niferrors.CodeTypeArgCount, "type '%s' expects %d type arguments, got %d", base.SymName, len(base.TypeParams), len(args))
+		return nil
+	}
+	return instantiate(base, args)
+}
+
+// instantiate substitutes args for the type parameters of a generic type. It
+// does not share symtable's instantiation cache, since the checker sees struct
+// declarations that may never run.
+func instantiate(gen *symtable.TypeSymbol, args []*symtable.TypeSymbol) *symtable.TypeSymbol {
+	subst := make(map[string]*symtable.TypeSymbol, len(args))
+	for idx, name := range gen.TypeParams {
+		subst[name] = args[idx]
+	}
+	inst := &symtable.TypeSymbol{
+		SymName:    symtable.InstantiationName(gen.SymName, args),
+		SymKind:    gen.SymKind,
+		TypeArgs:   args,
+		Origin:     gen,
+		FieldOrder: gen.FieldOrder,
+	}
+	if gen.Fields != nil {
+		inst.Fields = make(map[string]*symtable.TypeSymbol, len(gen.Fields))
+		for name, field := range gen.Fields {
+			if field != nil && field.SymKind == symtable.SymbolTypeParams {
+				if concrete, ok := subst[field.SymName]; ok {
+					field = concrete
+				}
+			}
+			inst.Fields[name] = field
+		}
+	}
+	return inst
+}
+
+func chanElem(ch *symtable.TypeSymbol) *symtable.TypeSymbol {
+	if ch == nil || len(ch.TypeArgs) != 1 {
+		return nil
+	}
+	return ch.TypeArgs[0]
+}
+
+func isStruct(t *symtable.TypeSymbol) bool {
+	return t != nil && t.Fields != nil
+}
+
+// memberNames returns the fields and then the methods of a struct type, for
+// suggestions.
+func memberNames(t *symtable.TypeSymbol) []string {
+	return append(slices.Sorted(maps.Keys(t.Fields)), slices.Sorted(maps.Keys(methodsOf(t)))...)
+}
+
+// typeNames returns the names of the types in scope, for suggestions.
+func (c *Checker) typeNames() []string {
+	var names []string
+	for scope := c.scope; scope != nil; scope = scope.Parent {
+		names = append(names, slices.Sorted(maps.Keys(scope.Types))...)
+	}
+	return names
+}
+
+// funcString describes a function's signature, e.g. func add(a: int, b: int) -> int.
+func funcString(fn *symtable.FuncSymbol) string {
+	params := make([]string, len(fn.Params))
+	for idx, param := range fn.Params {
+		params[idx] = param.SymName + ": " + typeName(param.Type)
+	}
+	sig := "func " + fn.SymName + "(" + strings.Join(params, ", ") + ")"
+	if len(fn.ReturnType) == 1 {
+		sig += " -> " + typeName(fn.ReturnType[0])
+	} else if len(fn.ReturnType) > 1 {
+		results := make([]string, len(fn.ReturnType))
+		for idx, ret := range fn.ReturnType {
+			results[idx] = typeName(ret)
+		}
+		sig += " -> (" + strings.Join(results, ", ") + ")"
+	}
+	return sig
+}
+
+func methodsOf(t *symtable.TypeSymbol) map[string]*symtable.FuncSymbol {
+	if t == nil {
+		return nil
+	}
+	if t.Origin != nil {
+		return t.Origin.Methods
+	}
+	return t.Methods
+}
+
+func isNull(t *symtable.TypeSymbol) bool {
+	return t != nil && t.SymName == "null"
+}
+
+// BuiltinNames returns the names of the native functions, sorted.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtinFuncs))
+	for name := range builtinFuncs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func typeName(t *symtable.TypeSymbol) string {
+	if t == nil {
+		return "unknown"
+	}
+	return t.SymName
+}
+
+// assignable reports whether a value of type src may be stored where dst is
+// expected. Unknown types, null and type parameters are accepted anywhere.
+func assignable(dst, src *symtable.TypeSymbol) bool {
+	switch {
+	case dst == nil || src == nil || isNull(src):
+		return true
+	case dst.SymKind == symtable.SymbolTypeParams || src.SymKind == symtable.SymbolTypeParams:
+		return true
+	case dst.SymName == src.SymName:
+		return true
+	case dst.IsGeneric && src.Origin != nil && src.Origin.SymName == dst.SymName:
+		return true
+	case dst.Origin != nil && src.Origin != nil && dst.Origin.SymName == src.Origin.SymName:
+		// Instantiations only differ when both sides are concrete.
+		return slices.ContainsFunc(dst.TypeArgs, isTypeParam) || slices.ContainsFunc(src.TypeArgs, isTypeParam)
+	default:
+		return false
+	}
+}
+
+func isTypeParam(t *symtable.TypeSymbol) bool {
+	return t != nil && t.SymKind == symtable.SymbolTypeParams
+}
+
+// comparable reports whether == between the two types can ever be true.
+func comparable(a, b *symtable.TypeSymbol) bool {
+	return a == nil || b == nil || isNull(a) || isNull(b) || isTypeParam(a) || isTypeParam(b) || a.SymName == b.SymName
+}
+
+// operandsAre reports whether both operands may be the same one of the given
+// types, treating unknown operands as matching.
+func operandsAre(left, right *symtable.TypeSymbol, names ...string) bool {
+	if isTypeParam(left) || isTypeParam(right) {
+		return true
+	}
+	if left != nil && !slices.Contains(names, left.SymName) {
+		return false
+	}
+	if right != nil && !slices.Contains(names, right.SymName) {
+		return false
+	}
+	return left == nil || right == nil || left.SymName == right.SymName
+}
+
+// known returns whichever operand type is known.
+func known(left, right *symtable.TypeSymbol) *symtable.TypeSymbol {
+	if left != nil && !isTypeParam(left) {
+		return left
+	}
+	if right != nil && !isTypeParam(right) {
+		return right
+	}
+	return nil
+}
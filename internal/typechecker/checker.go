@@ -0,0 +1,513 @@
+package typechecker
+
+import (
+	"maps"
+	"slices"
+
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// TypeError is a type error found before the program runs. Its Kind is
+// niferrors.TypeError.
+type TypeError = niferrors.NifError
+
+// Checker walks a program and reports type errors before it is executed.
+//
+// Types are *symtable.TypeSymbol. A nil type means the checker cannot know the
+// type statically (list elements, results of dynamic calls, ...); it is
+// compatible with every other type, so the runtime stays the final word there.
+//
+// A Checker keeps its global scope between calls to Check, so a REPL or a
+// file run chunk by chunk can be checked incrementally.
+type Checker struct {
+	// Types, if not nil, records the type of every expression whose type
+	// the checker knows.
+	Types map[ast.Expr]*symtable.TypeSymbol
+	// Symbols, if not nil, records the symbol declared by each function
+	// (*symtable.FuncSymbol), method (*symtable.FuncSymbol) and struct
+	// (*symtable.TypeSymbol) declaration.
+	Symbols map[ast.Node]symtable.Symbol
+
+	global   *symtable.SymbolTable
+	scope    *symtable.SymbolTable
+	fn       *funcContext
+	funcs    map[*symtable.VarSymbol]*symtable.FuncSymbol
+	builtins map[*symtable.VarSymbol]builtinFunc
+	errs     []error
+}
+
+// funcContext describes the function whose body is being checked.
+type funcContext struct {
+	returns      []*symtable.TypeSymbol
+	checkReturns bool
+	isGenerator  bool
+}
+
+func NewChecker() *Checker {
+	c := &Checker{
+		global:   symtable.NewSymbolTable(nil),
+		funcs:    make(map[*symtable.VarSymbol]*symtable.FuncSymbol),
+		builtins: make(map[*symtable.VarSymbol]builtinFunc),
+	}
+	c.scope = c.global
+	c.defineBuiltins()
+	return c
+}
+
+// Check reports every type error in stmts. Declarations from a program with
+// errors are discarded, since it will not be run.
+func (c *Checker) Check(stmts []ast.Stmt) []error {
+	saved := *c.global
+	saved.Vars = maps.Clone(c.global.Vars)
+	saved.Funcs = maps.Clone(c.global.Funcs)
+	saved.Types = maps.Clone(c.global.Types)
+	saved.TypeParams = maps.Clone(c.global.TypeParams)
+
+	c.errs = nil
+	c.scope = c.global
+	c.fn = nil
+	c.checkStmts(stmts)
+
+	errs := c.errs
+	c.errs = nil
+	if len(errs) > 0 {
+		*c.global = saved
+	}
+	return errs
+}
+
+// errorf reports an error spanning node.
+func (c *Checker) errorf(node ast.Node, code niferrors.Code, format string, args ...any) *TypeError {
+	return c.errorAt(node.PosToken(), code, format, args...).Between(ast.Bounds(node))
+}
+
+// errorAt reports an error spanning tok.
+func (c *Checker) errorAt(tok token.Token, code niferrors.Code, format string, args ...any) *TypeError {
+	err := niferrors.New(niferrors.TypeError, code, tok, format, args...)
+	c.errs = append(c.errs, err)
+	return err
+}
+
+// record notes the symbol a declaration defines, if Symbols is being kept.
+func (c *Checker) record(decl ast.Node, sym symtable.Symbol) {
+	if c.Symbols != nil {
+		c.Symbols[decl] = sym
+	}
+}
+
+func (c *Checker) pushScope() {
+	c.scope = symtable.NewSymbolTable(c.scope)
+}
+
+func (c *Checker) popScope() {
+	c.scope = c.scope.Parent
+}
+
+func (c *Checker) defineVar(name token.Token, typ *symtable.TypeSymbol, mutable bool) *symtable.VarSymbol {
+	sym := &symtable.VarSymbol{
+		SymName: name.Lexeme,
+		SymKind: symtable.SymbolVar,
+		Type:    typ,
+		Mutable: mutable,
+	}
+	if err := c.scope.DefineValue(sym); err != nil {
+		c.errorAt(name, niferrors.CodeRedeclared, "variable '%s' already defined in this scope", name.Lexeme)
+	}
+	return sym
+}
+
+// visibleNames returns the names of the variables in scope, innermost scope
+// first, for suggestions.
+func (c *Checker) visibleNames() []string {
+	var names []string
+	for scope := c.scope; scope != nil; scope = scope.Parent {
+		names = append(names, slices.Sorted(maps.Keys(scope.Vars))...)
+	}
+	return names
+}
+
+func (c *Checker) lookupVar(name string) (*symtable.VarSymbol, bool) {
+	sym, ok := c.scope.Lookup(symtable.SymbolVar, name)
+	if !ok {
+		return nil, false
+	}
+	varSym, ok := sym.(*symtable.VarSymbol)
+	return varSym, ok
+}
+
+// checkStmts checks a statement list, declaring its structs and functions up
+// front so they can be referred to before their declaration.
+func (c *Checker) checkStmts(stmts []ast.Stmt) {
+	c.declareStructs(stmts)
+	c.declareFuncs(stmts)
+	for _, stmt := range stmts {
+		c.checkStmt(stmt)
+	}
+}
+
+func (c *Checker) checkBlock(block *ast.BlockStmt) {
+	if block == nil {
+		return
+	}
+	c.pushScope()
+	c.checkStmts(block.Statements)
+	c.popScope()
+}
+
+func (c *Checker) checkStmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.VarStmt:
+		c.checkVarStmt(s)
+	case *ast.ShortVarStmt:
+		typ := c.checkExpr(s.Init)
+		if isNull(typ) {
+			typ = nil
+		}
+		c.defineVar(s.Name, typ, true)
+	case *ast.AssignStmt:
+		c.checkAssign(s.Name, c.checkExpr(s.Value), s.Value)
+	case *ast.CompoundAssignStmt:
+		varSym, ok := c.lookupVar(s.Name.Lexeme)
+		if !ok {
+			c.errorAt(s.Name, niferrors.CodeUndefined, "undefined variable '%s'", s.Name.Lexeme).
+				DidYouMean(s.Name.Lexeme, c.visibleNames())
+			c.checkExpr(s.Value)
+			return
+		}
+		op := s.Operator
+		op.Type = token.CompoundOperators[s.Operator.Type]
+		result := c.binaryResult(s, op, varSym.Type, c.checkExpr(s.Value))
+		c.checkAssign(s.Name, result, s)
+	case *ast.PrintStmt:
+		c.checkExpr(s.Expr)
+	case *ast.ExprStmt:
+		c.checkExpr(s.Expr)
+	case *ast.StructStmt:
+		c.checkStructStmt(s)
+	case *ast.FuncStmt:
+		c.checkFuncBody(s.Params, s.TypeParams, s.Body, c.funcSymbol(s.Name.Lexeme), true, s.IsGenerator)
+	case *ast.IfStmt:
+		c.checkCondition(s.Conditon, "if")
+		c.checkScoped(s.ThenBranch)
+		if s.ElseBranch != nil {
+			c.checkScoped(s.ElseBranch)
+		}
+	case *ast.WhileStmt:
+		c.checkCondition(s.Conditon, "while")
+		c.checkScoped(s.Body)
+	case *ast.ForStmt:
+		c.pushScope()
+		if s.Init != nil {
+			c.checkStmt(s.Init)
+		}
+		if s.CondExpr != nil {
+			c.checkCondition(s.CondExpr, "for")
+		}
+		if s.Update != nil {
+			c.checkStmt(s.Update)
+		}
+		c.checkScoped(s.BodyStmt)
+		c.popScope()
+	case *ast.ForInStmt:
+		elem := c.elementType(s.Iterable, c.checkExpr(s.Iterable))
+		c.pushScope()
+		c.defineVar(s.Name, elem, true)
+		c.checkScoped(s.BodyStmt)
+		c.popScope()
+	case *ast.SwitchStmt:
+		c.checkSwitch(s)
+	case *ast.SelectStmt:
+		c.checkSelect(s)
+	case *ast.ReturnStmt:
+		c.checkReturn(s)
+	case *ast.YieldStmt:
+		c.checkExpr(s.Value)
+		if c.fn == nil || !c.fn.isGenerator {
+			c.errorf(s, niferrors.CodeYieldOutsideGen, "yield outside generator")
+		}
+	case *ast.BlockStmt:
+		c.checkBlock(s)
+	case *ast.BreakStmt, *ast.ContinueStmt, *ast.FallthroughStmt:
+	case nil:
+	default:
+		c.errorf(stmt, niferrors.CodeUnsupported, "unsupported statement %T", stmt)
+	}
+}
+
+// checkScoped checks a statement in a scope of its own.
+func (c *Checker) checkScoped(stmt ast.Stmt) {
+	if block, ok := stmt.(*ast.BlockStmt); ok {
+		c.checkBlock(block)
+		return
+	}
+	c.pushScope()
+	c.checkStmt(stmt)
+	c.popScope()
+}
+
+func (c *Checker) checkVarStmt(s *ast.VarStmt) {
+	initType := c.checkExpr(s.Init)
+	if s.Type == nil {
+		c.errorAt(s.Names[0], niferrors.CodeMissingType, "var declaration of '%s' requires a type", s.Names[0].Lexeme)
+		for _, name := range s.Names {
+			c.defineVar(name, nil, true)
+		}
+		return
+	}
+	declared := c.resolveType(s.Type)
+	if !assignable(declared, initType) {
+		c.errorAt(s.Names[0], niferrors.CodeMismatchedTypes, "cannot use %s value as %s in declaration of '%s'", typeName(initType), typeName(declared), s.Names[0].Lexeme)
+	}
+	for _, name := range s.Names {
+		c.defineVar(name, declared, true)
+	}
+}
+
+func (c *Checker) checkAssign(name token.Token, valType *symtable.TypeSymbol, at ast.Node) {
+	varSym, ok := c.lookupVar(name.Lexeme)
+	if !ok {
+		c.errorAt(name, niferrors.CodeUndefined, "undefined variable '%s'", name.Lexeme).
+			DidYouMean(name.Lexeme, c.visibleNames())
+		return
+	}
+	if !varSym.Mutable {
+		c.errorAt(name, niferrors.CodeNotAssignable, "cannot assign to '%s'", name.Lexeme)
+		return
+	}
+	if !assignable(varSym.Type, valType) {
+		c.errorf(at, niferrors.CodeMismatchedTypes, "cannot assign %s value to '%s' of type %s", typeName(valType), name.Lexeme, typeName(varSym.Type))
+	}
+}
+
+func (c *Checker) checkCondition(expr ast.Expr, what string) {
+	typ := c.checkExpr(expr)
+	if typ != nil && typ.SymName != "bool" {
+		c.errorf(expr, niferrors.CodeNonBoolCondition, "%s condition must be bool, got %s", what, typ.SymName)
+	}
+}
+
+func (c *Checker) checkSwitch(s *ast.SwitchStmt) {
+	subject := c.checkExpr(s.Subject)
+	for _, clause := range s.Cases {
+		for _, val := range clause.Values {
+			caseType := c.checkExpr(val)
+			if !comparable(subject, caseType) {
+				c.errorf(val, niferrors.CodeMismatchedTypes, "case of type %s can never match switch on %s", typeName(caseType), typeName(subject))
+			}
+		}
+		c.pushScope()
+		c.checkStmts(clause.Body)
+		c.popScope()
+	}
+}
+
+func (c *Checker) checkSelect(s *ast.SelectStmt) {
+	for _, clause := range s.Cases {
+		c.pushScope()
+		if !clause.IsDefault {
+			received := c.checkExpr(clause.Comm)
+			if clause.Name.Lexeme != "" {
+				c.defineVar(clause.Name, received, true)
+			}
+		}
+		c.checkStmts(clause.Body)
+		c.popScope()
+	}
+}
+
+func (c *Checker) checkReturn(s *ast.ReturnStmt) {
+	types := make([]*symtable.TypeSymbol, len(s.Values))
+	for idx, val := range s.Values {
+		types[idx] = c.checkExpr(val)
+	}
+	if c.fn == nil {
+		c.errorf(s, niferrors.CodeBadReturn, "return outside function")
+		return
+	}
+	if c.fn.isGenerator {
+		if len(s.Values) > 0 {
+			c.errorf(s, niferrors.CodeBadReturn, "generator cannot return a value")
+		}
+		return
+	}
+	if !c.fn.checkReturns {
+		return
+	}
+	if len(types) != len(c.fn.returns) {
+		c.errorf(s, niferrors.CodeBadReturn, "wrong number of return values: expected %d, got %d", len(c.fn.returns), len(types))
+		return
+	}
+	for idx, want := range c.fn.returns {
+		if !assignable(want, types[idx]) {
+			c.errorf(s.Values[idx], niferrors.CodeMismatchedTypes, "cannot return %s value as %s", typeName(types[idx]), typeName(want))
+		}
+	}
+}
+
+// declareStructs defines the struct types in stmts before any of them are
+// checked, so fields and signatures may refer to structs declared later.
+func (c *Checker) declareStructs(stmts []ast.Stmt) {
+	var structs []*ast.StructStmt
+	for _, stmt := range stmts {
+		s, ok := stmt.(*ast.StructStmt)
+		if !ok {
+			continue
+		}
+		typeParams := make([]string, len(s.TypeParams))
+		for idx, tp := range s.TypeParams {
+			typeParams[idx] = tp.Lexeme
+		}
+		sym := &symtable.TypeSymbol{
+			SymName:    s.Name.Lexeme,
+			SymKind:    symtable.SymbolTypes,
+			Fields:     map[string]*symtable.TypeSymbol{},
+			Methods:    map[string]*symtable.FuncSymbol{},
+			TypeParams: typeParams,
+			IsGeneric:  len(typeParams) > 0,
+		}
+		if err := c.scope.DefineValue(sym); err != nil {
+			c.errorAt(s.Name, niferrors.CodeRedeclared, "struct '%s' already defined", s.Name.Lexeme)
+			continue
+		}
+		c.record(s, sym)
+		structs = append(structs, s)
+	}
+	for _, s := range structs {
+		sym, _ := c.scope.ResolveType(s.Name.Lexeme)
+		c.pushScope()
+		c.defineTypeParams(s.TypeParams)
+		for _, field := range s.Fields {
+			name := field.Names[0].Lexeme
+			if _, exists := sym.Fields[name]; exists {
+				c.errorAt(field.Names[0], niferrors.CodeRedeclared, "duplicate field '%s' in struct '%s'", name, s.Name.Lexeme)
+			}
+			sym.Fields[name] = c.resolveType(field.Type)
+			sym.FieldOrder = append(sym.FieldOrder, name)
+		}
+		for idx := range s.Methods {
+			method := &s.Methods[idx]
+			if _, exists := sym.Methods[method.Name.Lexeme]; exists {
+				c.errorAt(method.Name, niferrors.CodeRedeclared, "method '%s' already defined on struct '%s'", method.Name.Lexeme, s.Name.Lexeme)
+				continue
+			}
+			sym.Methods[method.Name.Lexeme] = c.signature(method)
+			c.record(method, sym.Methods[method.Name.Lexeme])
+		}
+		c.popScope()
+	}
+}
+
+// declareFuncs defines the functions in stmts before any of them are checked.
+func (c *Checker) declareFuncs(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		s, ok := stmt.(*ast.FuncStmt)
+		if !ok {
+			continue
+		}
+		fnSym := c.signature(s)
+		if err := c.scope.DefineValue(fnSym); err != nil {
+			c.errorAt(s.Name, niferrors.CodeRedeclared, "function '%s' already defined in this scope", s.Name.Lexeme)
+			continue
+		}
+		varSym := c.defineVar(s.Name, c.builtinType("func"), false)
+		c.funcs[varSym] = fnSym
+		c.record(s, fnSym)
+	}
+}
+
+// signature resolves the parameter and return types of a function declaration.
+func (c *Checker) signature(s *ast.FuncStmt) *symtable.FuncSymbol {
+	c.pushScope()
+	defer c.popScope()
+	c.defineTypeParams(s.TypeParams)
+
+	fnSym := &symtable.FuncSymbol{SymName: s.Name.Lexeme}
+	for _, tp := range s.TypeParams {
+		fnSym.TypeParams = append(fnSym.TypeParams, tp.Lexeme)
+	}
+	for _, param := range s.Params {
+		fnSym.Params = append(fnSym.Params, symtable.VarSymbol{
+			SymName: param.Name.Lexeme,
+			SymKind: symtable.SymbolVar,
+			Type:    c.resolveType(param.Type),
+			Mutable: true,
+		})
+	}
+	if s.IsGenerator {
+		// Calling a generator function returns the generator, whatever the body yields.
+		fnSym.ReturnType = []*symtable.TypeSymbol{c.builtinType("generator")}
+		return fnSym
+	}
+	for _, ret := range s.Return {
+		if ret == nil || ret.Name.Lexeme == "" {
+			continue
+		}
+		fnSym.ReturnType = append(fnSym.ReturnType, c.resolveType(ret))
+	}
+	return fnSym
+}
+
+func (c *Checker) funcSymbol(name string) *symtable.FuncSymbol {
+	sym, ok := c.scope.Lookup(symtable.SymbolFuncs, name)
+	if !ok {
+		return nil
+	}
+	fnSym, _ := sym.(*symtable.FuncSymbol)
+	return fnSym
+}
+
+func (c *Checker) defineTypeParams(params []token.Token) {
+	for _, tp := range params {
+		if err := c.scope.DefineValue(symtable.NewTypeParamSymbol(tp.Lexeme)); err != nil {
+			c.errorAt(tp, niferrors.CodeRedeclared, "duplicate type parameter '%s'", tp.Lexeme)
+		}
+	}
+}
+
+// checkFuncBody checks a function body against its signature. fnSym is nil for
+// function literals, whose return types are not declared.
+func (c *Checker) checkFuncBody(params []ast.Param, typeParams []token.Token, body *ast.BlockStmt, fnSym *symtable.FuncSymbol, checkReturns, isGenerator bool) {
+	outerFn := c.fn
+	c.pushScope()
+	defer func() {
+		c.popScope()
+		c.fn = outerFn
+	}()
+
+	c.defineTypeParams(typeParams)
+	ctx := &funcContext{checkReturns: checkReturns && fnSym != nil, isGenerator: isGenerator}
+	for idx, param := range params {
+		var typ *symtable.TypeSymbol
+		if fnSym != nil && idx < len(fnSym.Params) {
+			typ = fnSym.Params[idx].Type
+		} else {
+			typ = c.resolveType(param.Type)
+		}
+		c.defineVar(param.Name, typ, true)
+	}
+	if fnSym != nil {
+		ctx.returns = fnSym.ReturnType
+	}
+	c.fn = ctx
+	if body != nil {
+		c.checkStmts(body.Statements)
+	}
+}
+
+func (c *Checker) checkStructStmt(s *ast.StructStmt) {
+	sym, ok := c.scope.ResolveType(s.Name.Lexeme)
+	if !ok {
+		return
+	}
+	for idx := range s.Methods {
+		method := &s.Methods[idx]
+		c.pushScope()
+		c.defineTypeParams(s.TypeParams)
+		c.defineVar(token.Token{Lexeme: "self", Line: method.Func.Line, Column: method.Func.Column}, sym, false)
+		c.checkFuncBody(method.Params, method.TypeParams, method.Body, sym.Methods[method.Name.Lexeme], true, method.IsGenerator)
+		c.popScope()
+	}
+}
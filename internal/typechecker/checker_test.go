@@ -0,0 +1,234 @@
+package typechecker_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/lexer"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/parser"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/typechecker"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+func check(t *testing.T, source string) []error {
+	t.Helper()
+	value.BuiltinTypesInit()
+	stmts, err := parser.New(lexer.New(source)).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	return typechecker.NewChecker().Check(stmts)
+}
+
+func TestChecker_ValidPrograms(t *testing.T) {
+	cases := map[string]string{
+		"inference": `x := 1
+y := x + 2
+s := "a" + "b"
+ok := y > x && s == "ab"`,
+		"generic call": `func id[T](v: T) -> T {
+	return v
+}
+n := id(3) + 1
+s := id[string]("x") + "y"`,
+		"struct": `struct Point {
+	x: int
+	y: int
+	func sum() -> int {
+		return self.x + self.y
+	}
+}
+p := Point{x: 1, y: 2}
+total := p.sum() + p.x`,
+		"hoisting": `func a() -> int {
+	return b()
+}
+func b() -> int {
+	return 1
+}`,
+		"channels": `c := chan[int](1)
+send(c, 4)
+v := recv(c) + 1`,
+		"mutex": `m := mutex()
+lock(m)
+unlock(m)`,
+		"generator": `func count(n: int) {
+	yield n
+}
+for x in count(3) {
+	print(x)
+}`,
+		"closure": `func counter(start: int) -> func {
+	n := start
+	return func() {
+		n += 1
+		return n
+	}
+}
+func apply(f: func, x: int) -> (func, int) {
+	return f, f(x)
+}
+var tick: func = counter(0)
+print(tick())`,
+	}
+	for name, source := range cases {
+		t.Run(name, func(t *testing.T) {
+			if errs := check(t, source); len(errs) > 0 {
+				t.Errorf("unexpected errors: %v", errs)
+			}
+		})
+	}
+}
+
+func TestChecker_Errors(t *testing.T) {
+	cases := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"operator", `x := 1 + "a"`, "1:8: unsupported operand types for +: int and string"},
+		{"inferred assign", `x := 1
+x = "s"`, "2:5: cannot assign string value to 'x' of type int"},
+		{"undefined", `print(y)`, "1:7: undefined variable 'y'"},
+		{"condition", `if 1 {
+}`, "condition must be bool"},
+		{"arity", `func f(a: int) {
+}
+f(1, 2)`, "'f' expects 1 arguments, got 2"},
+		{"argument", `func f(a: int) {
+}
+f("x")`, "cannot use string value as int in argument 1 to 'f'"},
+		{"generic result", `func id[T](v: T) -> T {
+	return v
+}
+n := id("s") + 1`, "unsupported operand types for +: string and int"},
+		{"return type", `func f() -> int {
+	return "s"
+}`, "cannot return string value as int"},
+		{"return count", `func f() -> int {
+	return
+}`, "wrong number of return values: expected 1, got 0"},
+		{"closure without return type", `func f() {
+	return func() {
+	}
+}`, "wrong number of return values: expected 0, got 1"},
+		{"closure as int", `func f() -> int {
+	return func() {
+	}
+}`, "cannot return func value as int"},
+		{"unknown field", `struct P {
+	x: int
+}
+p := P{x: 1, z: 2}`, "unknown field 'z' in struct literal of type P"},
+		{"field type", `struct P {
+	x: int
+}
+p := P{x: "s"}`, "cannot use string value as int in field 'x' of P"},
+		{"field access", `struct P {
+	x: int
+}
+p := P{x: 1}
+y := p.z`, "5:8: struct 'P' has no field or method 'z'"},
+		{"send", `c := chan[int]()
+send(c, "s")`, "cannot send string value on chan[int]"},
+		{"lock", `lock(1)`, "lock() cannot take int as argument 1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := check(t, tc.source)
+			for _, err := range errs {
+				if strings.Contains(err.Error(), tc.want) {
+					return
+				}
+			}
+			t.Errorf("expected error containing %q, got %v", tc.want, errs)
+		})
+	}
+}
+
+func TestChecker_ReportsEveryError(t *testing.T) {
+	errs := check(t, `a := 1 - "x"
+b := true + 1
+c := undefinedName`)
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %d: %v", len(errs), errs)
+	}
+	for idx, err := range errs {
+		typeErr, ok := err.(*typechecker.TypeError)
+		if !ok {
+			t.Fatalf("error %d is %T, want *TypeError", idx, err)
+		}
+		if typeErr.Line != idx+1 {
+			t.Errorf("error %d reported on line %d, want %d", idx, typeErr.Line, idx+1)
+		}
+	}
+}
+
+func TestChecker_KeepsDeclarationsBetweenChecks(t *testing.T) {
+	value.BuiltinTypesInit()
+	checker := typechecker.NewChecker()
+	parse := func(src string) []error {
+		stmts, err := parser.New(lexer.New(src)).Parse()
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		return checker.Check(stmts)
+	}
+	if errs := parse(`x := 1`); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if errs := parse(`y := x + "s"`); len(errs) != 1 {
+		t.Fatalf("expected x to still be an int, got %v", errs)
+	}
+	if errs := parse(`z := y`); len(errs) != 1 {
+		t.Errorf("expected y from a failed check to be discarded, got %v", errs)
+	}
+}
+
+func TestChecker_Diagnostics(t *testing.T) {
+	cases := []struct {
+		name       string
+		source     string
+		code       niferrors.Code
+		span       [4]int
+		suggestion string
+	}{
+		{"misspelled variable", `count := 1
+print(cout)`, niferrors.CodeUndefined, [4]int{2, 7, 2, 11}, "did you mean 'count'?"},
+		{"misspelled field", `struct P {
+	value: int
+}
+p := P{value: 1}
+print(p.valeu)`, niferrors.CodeUnknownMember, [4]int{5, 9, 5, 14}, "did you mean 'value'?"},
+		{"misspelled type", `var x: strng = "a"`, niferrors.CodeUnknownType, [4]int{1, 8, 1, 13}, "did you mean 'string'?"},
+		{"operands at the operator", `x := 1 - "a"`, niferrors.CodeBadOperand, [4]int{1, 8, 1, 9}, ""},
+		{"condition spans the expression", `if 1 + 2 {
+}`, niferrors.CodeNonBoolCondition, [4]int{1, 4, 1, 9}, ""},
+		{"call spans callee to paren", `func f(a: int) {
+}
+f(1, 2)`, niferrors.CodeArgCount, [4]int{3, 1, 3, 8}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := check(t, tc.source)
+			if len(errs) != 1 {
+				t.Fatalf("expected 1 error, got %v", errs)
+			}
+			var typeErr *typechecker.TypeError
+			if !errors.As(errs[0], &typeErr) {
+				t.Fatalf("error is %T, want *TypeError", errs[0])
+			}
+			if typeErr.Kind != niferrors.TypeError || typeErr.Code != tc.code {
+				t.Errorf("expected a type error %s, got %v %s", tc.code, typeErr.Kind, typeErr.Code)
+			}
+			if span := [4]int{typeErr.Line, typeErr.Column, typeErr.EndLine, typeErr.EndColumn}; span != tc.span {
+				t.Errorf("expected span %v, got %v", tc.span, span)
+			}
+			if typeErr.Suggestion != tc.suggestion {
+				t.Errorf("expected suggestion %q, got %q", tc.suggestion, typeErr.Suggestion)
+			}
+		})
+	}
+}
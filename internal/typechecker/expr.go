@@ -0,0 +1,467 @@
+package typechecker
+
+import (
+	"maps"
+	"slices"
+
+	ast "github.com/ithinkiborkedit/niftelv2.git/internal/nifast"
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+// checkExpr checks an expression and returns its static type, or nil if the
+// type is not known until run time.
+func (c *Checker) checkExpr(expr ast.Expr) *symtable.TypeSymbol {
+	typ := c.exprType(expr)
+	if c.Types != nil && expr != nil && typ != nil {
+		c.Types[expr] = typ
+	}
+	return typ
+}
+
+func (c *Checker) exprType(expr ast.Expr) *symtable.TypeSymbol {
+	switch e := expr.(type) {
+	case *ast.LiteralExpr:
+		return c.literalType(e)
+	case *ast.VariableExpr:
+		varSym, ok := c.lookupVar(e.Name.Lexeme)
+		if !ok {
+			c.errorAt(e.Name, niferrors.CodeUndefined, "undefined variable '%s'", e.Name.Lexeme).
+				DidYouMean(e.Name.Lexeme, c.visibleNames())
+			return nil
+		}
+		return varSym.Type
+	case *ast.BinaryExpr:
+		return c.binaryResult(e, e.Operator, c.checkExpr(e.Left), c.checkExpr(e.Right))
+	case *ast.UnaryExpr:
+		return c.checkUnary(e)
+	case *ast.CallExpr:
+		return c.checkCall(e)
+	case *ast.GetExpr:
+		return c.checkGet(e)
+	case *ast.IndexExpr:
+		return c.checkIndex(e)
+	case *ast.SliceExpr:
+		return c.checkSlice(e)
+	case *ast.RangeExpr:
+		for _, bound := range []ast.Expr{e.Start, e.End, e.Step} {
+			if bound != nil {
+				c.expectInt(bound, "range bound")
+			}
+		}
+		return c.builtinType("range")
+	case *ast.IfExpr:
+		return c.checkIfExpr(e)
+	case *ast.TernaryExpr:
+		c.checkCondition(e.Conditon, "ternary")
+		then, other := c.checkExpr(e.Then), c.checkExpr(e.Else)
+		typ, err := UnifyTypes(then, other)
+		if err != nil {
+			c.errorf(e, niferrors.CodeMismatchedTypes, "%v", err)
+		}
+		return typ
+	case *ast.ListExpr:
+		for _, elem := range e.Elements {
+			c.checkExpr(elem)
+		}
+		return c.builtinType("list")
+	case *ast.DictExpr:
+		for _, pair := range e.Pairs {
+			c.checkExpr(pair[0])
+			c.checkExpr(pair[1])
+		}
+		return c.builtinType("dict")
+	case *ast.StructLiteralExpr:
+		return c.checkStructLiteral(e)
+	case *ast.FuncExpr:
+		c.checkFuncBody(e.Params, nil, e.Body, nil, false, e.IsGenerator)
+		return c.builtinType("func")
+	case *ast.SpawnExpr:
+		c.checkCall(e.Call)
+		return c.builtinType("task")
+	case nil:
+		return nil
+	default:
+		c.errorf(expr, niferrors.CodeUnsupported, "unsupported expression %T", expr)
+		return nil
+	}
+}
+
+func (c *Checker) literalType(e *ast.LiteralExpr) *symtable.TypeSymbol {
+	switch e.Value.Type {
+	case token.TokenNumber:
+		return c.builtinType("int")
+	case token.TokenFloat:
+		return c.builtinType("float")
+	case token.TokenString:
+		return c.builtinType("string")
+	case token.TokenTrue, token.TokenFalse, token.TokenBool:
+		return c.builtinType("bool")
+	case token.TokenNull, token.TokenNil:
+		return c.builtinType("null")
+	default:
+		return nil
+	}
+}
+
+func (c *Checker) expectInt(expr ast.Expr, what string) {
+	typ := c.checkExpr(expr)
+	if typ != nil && typ.SymName != "int" {
+		c.errorf(expr, niferrors.CodeMismatchedTypes, "%s must be int, got %s", what, typ.SymName)
+	}
+}
+
+// binaryResult checks the operands of a binary operator and returns the type
+// of the result. Errors are reported at the operator, except for 'in', whose
+// errors span at.
+func (c *Checker) binaryResult(at ast.Node, op token.Token, left, right *symtable.TypeSymbol) *symtable.TypeSymbol {
+	mismatch := func() *symtable.TypeSymbol {
+		c.errorAt(op, niferrors.CodeBadOperand, "unsupported operand types for %s: %s and %s", op.Lexeme, typeName(left), typeName(right))
+		return nil
+	}
+	switch op.Type {
+	case token.TokenPlus:
+		if operandsAre(left, right, "int", "float", "string") {
+			return known(left, right)
+		}
+		return mismatch()
+	case token.TokenMinus, token.TokenStar, token.TokenFWDSlash, token.TokenPercent:
+		if operandsAre(left, right, "int", "float") {
+			return known(left, right)
+		}
+		return mismatch()
+	case token.TokenLess, token.TokenGreater, token.TokenLessEq, token.TokenGreaterEq:
+		if operandsAre(left, right, "int", "float") {
+			return c.builtinType("bool")
+		}
+		mismatch()
+		return c.builtinType("bool")
+	case token.TokenEqality, token.TokenBangEqal:
+		if !comparable(left, right) {
+			c.errorAt(op, niferrors.CodeBadOperand, "mismatched types %s and %s in comparison", left.SymName, right.SymName)
+		}
+		return c.builtinType("bool")
+	case token.TokenAnd, token.TokenOr:
+		if !operandsAre(left, right, "bool") {
+			mismatch()
+		}
+		return c.builtinType("bool")
+	case token.TokenAmper, token.TokenPipe, token.TokenCaret, token.TokenShl, token.TokenShr:
+		if operandsAre(left, right, "int") {
+			return c.builtinType("int")
+		}
+		return mismatch()
+	case token.TokenIn:
+		c.checkMembership(at, left, right)
+		return c.builtinType("bool")
+	default:
+		c.errorAt(op, niferrors.CodeUnsupported, "unsupported binary operator %s", op.Lexeme)
+		return nil
+	}
+}
+
+func (c *Checker) checkMembership(at ast.Node, elem, container *symtable.TypeSymbol) {
+	if container == nil {
+		return
+	}
+	switch container.SymName {
+	case "string":
+		if elem != nil && elem.SymName != "string" {
+			c.errorf(at, niferrors.CodeBadOperand, "'in <string>' requires string as left operand, got %s", elem.SymName)
+		}
+	case "range":
+		if elem != nil && elem.SymName != "int" {
+			c.errorf(at, niferrors.CodeBadOperand, "'in <range>' requires int as left operand, got %s", elem.SymName)
+		}
+	case "list", "tuple", "dict", "generator":
+	default:
+		if container.SymKind != symtable.SymbolTypeParams {
+			c.errorf(at, niferrors.CodeBadOperand, "'in' unsupported on %s", container.SymName)
+		}
+	}
+}
+
+func (c *Checker) checkUnary(e *ast.UnaryExpr) *symtable.TypeSymbol {
+	operand := c.checkExpr(e.Right)
+	var want []string
+	switch e.Operator.Type {
+	case token.TokenBang:
+		want = []string{"bool"}
+	case token.TokenMinus:
+		want = []string{"int", "float"}
+	case token.TokenTilde:
+		want = []string{"int"}
+	default:
+		c.errorf(e, niferrors.CodeUnsupported, "unsupported unary operator %s", e.Operator.Lexeme)
+		return nil
+	}
+	if operand != nil && operand.SymKind != symtable.SymbolTypeParams && !slices.Contains(want, operand.SymName) {
+		c.errorf(e, niferrors.CodeBadOperand, "operator %s not defined on %s", e.Operator.Lexeme, operand.SymName)
+		return nil
+	}
+	return operand
+}
+
+func (c *Checker) checkCall(call *ast.CallExpr) *symtable.TypeSymbol {
+	args := make([]*symtable.TypeSymbol, len(call.Arguments))
+	for idx, arg := range call.Arguments {
+		args[idx] = c.checkExpr(arg)
+	}
+	typeArgs := make([]*symtable.TypeSymbol, len(call.TypeArgs))
+	for idx, typeArg := range call.TypeArgs {
+		typeArgs[idx] = c.resolveType(typeArg)
+	}
+
+	switch callee := call.Callee.(type) {
+	case *ast.VariableExpr:
+		varSym, ok := c.lookupVar(callee.Name.Lexeme)
+		if !ok {
+			c.errorAt(callee.Name, niferrors.CodeUndefined, "undefined function '%s'", callee.Name.Lexeme).
+				DidYouMean(callee.Name.Lexeme, c.visibleNames())
+			return nil
+		}
+		if builtin, ok := c.builtins[varSym]; ok {
+			return builtin(c, call, args, typeArgs)
+		}
+		if fnSym, ok := c.funcs[varSym]; ok {
+			return c.applySignature(call, fnSym, args, typeArgs)
+		}
+		return c.callValue(call, varSym.Type)
+	case *ast.GetExpr:
+		object := c.checkExpr(callee.Object)
+		if method, ok := methodsOf(object)[callee.Name.Lexeme]; ok {
+			return c.applySignature(call, method, args, typeArgs)
+		}
+		return c.callValue(call, c.memberType(callee, object))
+	default:
+		return c.callValue(call, c.checkExpr(call.Callee))
+	}
+}
+
+// callValue is the result of calling a value whose signature is not known statically.
+func (c *Checker) callValue(call *ast.CallExpr, callee *symtable.TypeSymbol) *symtable.TypeSymbol {
+	if callee != nil && callee.SymName != "func" && callee.SymKind != symtable.SymbolTypeParams {
+		c.errorf(call, niferrors.CodeNotCallable, "cannot call non-function value of type %s", callee.SymName)
+	}
+	return nil
+}
+
+// applySignature checks a call against a declared signature, inferring the
+// function's type parameters from its arguments when they are not given.
+func (c *Checker) applySignature(call *ast.CallExpr, fnSym *symtable.FuncSymbol, args, typeArgs []*symtable.TypeSymbol) *symtable.TypeSymbol {
+	bindings := map[string]*symtable.TypeSymbol{}
+	if len(typeArgs) > 0 {
+		if len(typeArgs) != len(fnSym.TypeParams) {
+			c.errorf(call, niferrors.CodeTypeArgCount, "'%s' expects %d type arguments, got %d", fnSym.SymName, len(fnSym.TypeParams), len(typeArgs))
+		}
+		for idx, name := range fnSym.TypeParams {
+			if idx < len(typeArgs) {
+				bindings[name] = typeArgs[idx]
+			}
+		}
+	}
+	if len(args) != len(fnSym.Params) {
+		c.errorf(call, niferrors.CodeArgCount, "'%s' expects %d arguments, got %d", fnSym.SymName, len(fnSym.Params), len(args)).
+			Note("'%s' is declared as %s", fnSym.SymName, funcString(fnSym))
+	} else {
+		for idx, param := range fnSym.Params {
+			want := param.Type
+			if want != nil && want.SymKind == symtable.SymbolTypeParams {
+				bound, ok := bindings[want.SymName]
+				if !ok {
+					if args[idx] != nil && !isNull(args[idx]) {
+						bindings[want.SymName] = args[idx]
+					}
+					continue
+				}
+				want = bound
+			}
+			if !assignable(want, args[idx]) {
+				c.errorf(call.Arguments[idx], niferrors.CodeMismatchedTypes, "cannot use %s value as %s in argument %d to '%s'", typeName(args[idx]), typeName(want), idx+1, fnSym.SymName).
+					Note("'%s' is declared as %s", fnSym.SymName, funcString(fnSym))
+			}
+		}
+	}
+
+	returns := make([]*symtable.TypeSymbol, len(fnSym.ReturnType))
+	for idx, ret := range fnSym.ReturnType {
+		if ret != nil && ret.SymKind == symtable.SymbolTypeParams {
+			ret = bindings[ret.SymName]
+		}
+		returns[idx] = ret
+	}
+	switch len(returns) {
+	case 0:
+		return c.builtinType("null")
+	case 1:
+		return returns[0]
+	default:
+		if slices.Contains(returns, nil) {
+			return c.builtinType("tuple")
+		}
+		return value.GetOrRegisterTupleType(returns)
+	}
+}
+
+func (c *Checker) checkGet(e *ast.GetExpr) *symtable.TypeSymbol {
+	return c.memberType(e, c.checkExpr(e.Object))
+}
+
+// memberType returns the type of a field or method of object.
+func (c *Checker) memberType(e *ast.GetExpr, object *symtable.TypeSymbol) *symtable.TypeSymbol {
+	if object == nil || object.SymKind == symtable.SymbolTypeParams {
+		return nil
+	}
+	if !isStruct(object) {
+		c.errorAt(e.Name, niferrors.CodeNotStruct, "cannot access '%s' on non-struct type %s", e.Name.Lexeme, object.SymName)
+		return nil
+	}
+	if field, ok := object.Fields[e.Name.Lexeme]; ok {
+		return field
+	}
+	if _, ok := methodsOf(object)[e.Name.Lexeme]; ok {
+		return c.builtinType("func")
+	}
+	c.errorAt(e.Name, niferrors.CodeUnknownMember, "struct '%s' has no field or method '%s'", object.SymName, e.Name.Lexeme).
+		DidYouMean(e.Name.Lexeme, memberNames(object))
+	return nil
+}
+
+func (c *Checker) checkIndex(e *ast.IndexExpr) *symtable.TypeSymbol {
+	collection := c.checkExpr(e.Collection)
+	if collection == nil {
+		c.checkExpr(e.Index)
+		return nil
+	}
+	switch collection.SymName {
+	case "list":
+		c.expectInt(e.Index, "list index")
+		return nil
+	case "range":
+		c.expectInt(e.Index, "range index")
+		return c.builtinType("int")
+	case "dict":
+		c.checkExpr(e.Index)
+		return nil
+	default:
+		c.checkExpr(e.Index)
+		if collection.SymKind != symtable.SymbolTypeParams {
+			c.errorf(e, niferrors.CodeNotIndexable, "indexing unsupported on %s", collection.SymName)
+		}
+		return nil
+	}
+}
+
+func (c *Checker) checkSlice(e *ast.SliceExpr) *symtable.TypeSymbol {
+	collection := c.checkExpr(e.Collection)
+	for _, bound := range []ast.Expr{e.Low, e.High} {
+		if bound != nil {
+			c.expectInt(bound, "slice bound")
+		}
+	}
+	if collection == nil {
+		return nil
+	}
+	switch collection.SymName {
+	case "list", "string", "range":
+		return collection
+	default:
+		if collection.SymKind != symtable.SymbolTypeParams {
+			c.errorf(e, niferrors.CodeNotIndexable, "slicing unsupported on %s", collection.SymName)
+		}
+		return nil
+	}
+}
+
+// elementType returns the type of the items produced by iterating over a
+// value of type iterable.
+func (c *Checker) elementType(at ast.Expr, iterable *symtable.TypeSymbol) *symtable.TypeSymbol {
+	if iterable == nil || iterable.SymKind == symtable.SymbolTypeParams {
+		return nil
+	}
+	switch iterable.SymName {
+	case "range":
+		return c.builtinType("int")
+	case "string":
+		return c.builtinType("string")
+	case "list", "tuple", "dict", "generator":
+		return nil
+	}
+	if isStruct(iterable) {
+		if _, ok := methodsOf(iterable)["iter"]; ok {
+			return nil
+		}
+		c.errorf(at, niferrors.CodeNotIterable, "struct '%s' is not iterable: no iter() method", iterable.SymName)
+		return nil
+	}
+	c.errorf(at, niferrors.CodeNotIterable, "value of type %s is not iterable", iterable.SymName)
+	return nil
+}
+
+func (c *Checker) checkIfExpr(e *ast.IfExpr) *symtable.TypeSymbol {
+	c.checkCondition(e.Conditon, "if")
+	then := c.blockValueType(e.ThenBranch)
+	var other *symtable.TypeSymbol
+	if e.ElseIf != nil {
+		other = c.checkIfExpr(e.ElseIf)
+	} else {
+		other = c.blockValueType(e.ElseBranch)
+	}
+	typ, err := UnifyTypes(then, other)
+	if err != nil {
+		c.errorAt(e.IfToken, niferrors.CodeMismatchedTypes, "%v", err)
+	}
+	return typ
+}
+
+// blockValueType checks a block used as a value and returns the type of its
+// final expression statement, or null if it does not end in one.
+func (c *Checker) blockValueType(block *ast.BlockStmt) *symtable.TypeSymbol {
+	if block == nil {
+		return c.builtinType("null")
+	}
+	c.pushScope()
+	defer c.popScope()
+	c.declareStructs(block.Statements)
+	c.declareFuncs(block.Statements)
+	typ := c.builtinType("null")
+	for idx, stmt := range block.Statements {
+		if exprStmt, ok := stmt.(*ast.ExprStmt); ok && idx == len(block.Statements)-1 {
+			typ = c.checkExpr(exprStmt.Expr)
+			continue
+		}
+		c.checkStmt(stmt)
+	}
+	return typ
+}
+
+func (c *Checker) checkStructLiteral(e *ast.StructLiteralExpr) *symtable.TypeSymbol {
+	typ := c.resolveType(e.TypeName)
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if typ != nil && !isStruct(typ) {
+		c.errorf(e, niferrors.CodeNotStruct, "'%s' is not a struct type", typ.SymName)
+		typ = nil
+	}
+	for _, name := range names {
+		fieldType := c.checkExpr(e.Fields[name])
+		if typ == nil {
+			continue
+		}
+		want, ok := typ.Fields[name]
+		if !ok {
+			c.errorf(e.Fields[name], niferrors.CodeUnknownMember, "unknown field '%s' in struct literal of type %s", name, typ.SymName).
+				DidYouMean(name, slices.Sorted(maps.Keys(typ.Fields)))
+			continue
+		}
+		if !assignable(want, fieldType) {
+			c.errorf(e.Fields[name], niferrors.CodeMismatchedTypes, "cannot use %s value as %s in field '%s' of %s", typeName(fieldType), typeName(want), name, typ.SymName)
+		}
+	}
+	return typ
+}
@@ -0,0 +1,27 @@
+package typechecker
+
+import (
+	"fmt"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+)
+
+// UnifyTypes returns the single type a value can have when it comes from either of
+// two branches, as with if-expressions and cond ? a : b. A nil type is treated as
+// unknown and null unifies with anything; otherwise both branches must agree.
+func UnifyTypes(a, b *symtable.TypeSymbol) (*symtable.TypeSymbol, error) {
+	switch {
+	case a == nil:
+		return b, nil
+	case b == nil:
+		return a, nil
+	case a.SymName == b.SymName:
+		return a, nil
+	case a.SymName == "null":
+		return b, nil
+	case b.SymName == "null":
+		return a, nil
+	default:
+		return nil, fmt.Errorf("branches have mismatched types '%s' and '%s'", a.SymName, b.SymName)
+	}
+}
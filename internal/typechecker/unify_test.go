@@ -0,0 +1,27 @@
+package typechecker_test
+
+import (
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+	"github.com/ithinkiborkedit/niftelv2.git/internal/typechecker"
+)
+
+func TestUnifyTypes(t *testing.T) {
+	intT := &symtable.TypeSymbol{SymName: "int", SymKind: symtable.SymbolTypes}
+	strT := &symtable.TypeSymbol{SymName: "string", SymKind: symtable.SymbolTypes}
+	nullT := &symtable.TypeSymbol{SymName: "null", SymKind: symtable.SymbolTypes}
+
+	if got, err := typechecker.UnifyTypes(intT, intT); err != nil || got != intT {
+		t.Errorf("int/int: got %v, err=%v", got, err)
+	}
+	if got, err := typechecker.UnifyTypes(nullT, strT); err != nil || got != strT {
+		t.Errorf("null/string: got %v, err=%v", got, err)
+	}
+	if got, err := typechecker.UnifyTypes(intT, nil); err != nil || got != intT {
+		t.Errorf("int/unknown: got %v, err=%v", got, err)
+	}
+	if _, err := typechecker.UnifyTypes(intT, strT); err == nil {
+		t.Errorf("int/string: expected mismatch error")
+	}
+}
@@ -0,0 +1,104 @@
+package value
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
+)
+
+// ErrInterrupted is returned by an operation that was waiting when it was
+// told to stop.
+var ErrInterrupted = errors.New("interrupted")
+
+// NiftelChan is a typed channel shared between tasks.
+type NiftelChan struct {
+	Type *symtable.TypeSymbol
+	Elem *symtable.TypeSymbol
+	ch   chan Value
+}
+
+func NewNiftelChan(typ, elem *symtable.TypeSymbol, capacity int) (*NiftelChan, error) {
+	if capacity < 0 {
+		return nil, fmt.Errorf("negative channel capacity %d", capacity)
+	}
+	return &NiftelChan{
+		Type: typ,
+		Elem: elem,
+		ch:   make(chan Value, capacity),
+	}, nil
+}
+
+// Chan exposes the underlying Go channel, for multiplexing in select.
+func (c *NiftelChan) Chan() chan Value {
+	return c.ch
+}
+
+// CheckElem reports an error if v cannot be sent on c.
+func (c *NiftelChan) CheckElem(v Value) error {
+	if c.Elem == nil {
+		return nil
+	}
+	vt := v.TypeInfo()
+	if vt == nil {
+		return fmt.Errorf("cannot send value of unknown type on %s", c.String())
+	}
+	if vt.SymName != c.Elem.SymName {
+		return fmt.Errorf("cannot send %s value on %s", vt.SymName, c.String())
+	}
+	return nil
+}
+
+// Send blocks until v is sent on c, or until done is closed, when it returns
+// ErrInterrupted. A nil done is never closed.
+func (c *NiftelChan) Send(v Value, done <-chan struct{}) (err error) {
+	if err := c.CheckElem(v); err != nil {
+		return err
+	}
+	defer func() {
+		if recover() != nil {
+			err = fmt.Errorf("send on closed channel")
+		}
+	}()
+	select {
+	case c.ch <- v:
+		return nil
+	case <-done:
+		return ErrInterrupted
+	}
+}
+
+// Recv blocks for the next value; ok is false once c is closed and drained.
+// If done is closed first it returns ErrInterrupted.
+func (c *NiftelChan) Recv(done <-chan struct{}) (v Value, ok bool, err error) {
+	select {
+	case v, ok = <-c.ch:
+	case <-done:
+		return Null(), false, ErrInterrupted
+	}
+	if !ok {
+		return Null(), false, nil
+	}
+	return v, true, nil
+}
+
+func (c *NiftelChan) Close() (err error) {
+	defer func() {
+		if recover() != nil {
+			err = fmt.Errorf("close of closed channel")
+		}
+	}()
+	close(c.ch)
+	return nil
+}
+
+func (c *NiftelChan) Len() int {
+	return len(c.ch)
+}
+
+func (c *NiftelChan) String() string {
+	if c.Type != nil {
+		return c.Type.SymName
+	}
+	return "chan"
+}
@@ -0,0 +1,60 @@
+package value
+
+import (
+	"iter"
+	"runtime"
+	"sync"
+)
+
+// NiftelGenerator is a suspended generator body. Each call to Next resumes the
+// body until its next yield; the body runs on a coroutine that is released once
+// the generator is exhausted, stopped, or garbage collected.
+type NiftelGenerator struct {
+	Name string
+	mu   sync.Mutex
+	next func() (Value, error, bool)
+	stop func()
+	done bool
+}
+
+// NewNiftelGenerator wraps seq, which yields (value, nil) for each item and
+// (null, err) if the body fails.
+func NewNiftelGenerator(name string, seq iter.Seq2[Value, error]) *NiftelGenerator {
+	next, stop := iter.Pull2(seq)
+	g := &NiftelGenerator{Name: name, next: next, stop: stop}
+	// Loops stop the generators they make and leave early. This is a backstop
+	// for the rest, such as a generator held in a variable that a loop broke
+	// out of, or that next() advanced, and that was then dropped: it would
+	// otherwise park its coroutine forever.
+	runtime.SetFinalizer(g, (*NiftelGenerator).Stop)
+	return g
+}
+
+// Next resumes the generator. ok is false once it has finished.
+func (g *NiftelGenerator) Next() (v Value, ok bool, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.done {
+		return Null(), false, nil
+	}
+	v, err, ok = g.next()
+	if !ok || err != nil {
+		g.done = true
+		g.stop()
+		return Null(), false, err
+	}
+	return v, true, nil
+}
+
+// Stop abandons the generator, unwinding its body. Further calls to Next report
+// that it has finished.
+func (g *NiftelGenerator) Stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.done = true
+	g.stop()
+}
+
+func (g *NiftelGenerator) String() string {
+	return "<generator " + g.Name + ">"
+}
@@ -0,0 +1,42 @@
+package value
+
+import "errors"
+
+// NiftelMutex is the lock returned by mutex(), which tasks hold to update
+// shared variables together. It is a channel with room for one token, so a
+// wait for it can be interrupted like a wait on any other channel.
+type NiftelMutex struct {
+	held chan struct{}
+}
+
+func NewNiftelMutex() *NiftelMutex {
+	return &NiftelMutex{held: make(chan struct{}, 1)}
+}
+
+// Lock blocks until the mutex is free and takes it, or until done is closed,
+// when it returns ErrInterrupted. A nil done is never closed.
+func (m *NiftelMutex) Lock(done <-chan struct{}) error {
+	select {
+	case m.held <- struct{}{}:
+		return nil
+	case <-done:
+		return ErrInterrupted
+	}
+}
+
+// Unlock frees the mutex. Any task may free it, not only the one that took it.
+func (m *NiftelMutex) Unlock() error {
+	select {
+	case <-m.held:
+		return nil
+	default:
+		return errors.New("unlock of unlocked mutex")
+	}
+}
+
+func (m *NiftelMutex) String() string {
+	if len(m.held) > 0 {
+		return "<mutex locked>"
+	}
+	return "<mutex>"
+}
@@ -0,0 +1,116 @@
+package value
+
+import (
+	"fmt"
+	"math"
+)
+
+type NiftelRange struct {
+	Start     int64
+	End       int64
+	Step      int64
+	Inclusive bool
+}
+
+func NewNiftelRange(start, end, step int64, inclusive bool) (*NiftelRange, error) {
+	if step == 0 {
+		return nil, fmt.Errorf("range step cannot be zero")
+	}
+	return &NiftelRange{
+		Start:     start,
+		End:       end,
+		Step:      step,
+		Inclusive: inclusive,
+	}, nil
+}
+
+// Len returns the number of elements in r, or math.MaxInt64 if it has more.
+// It works on the distance between the bounds as a uint64, so that ranges
+// reaching the ends of int64 do not overflow.
+func (r *NiftelRange) Len() int64 {
+	span, ok := r.offset(r.End)
+	if !ok || span == 0 && !r.Inclusive {
+		return 0
+	}
+	if !r.Inclusive {
+		span--
+	}
+	n := span / r.absStep()
+	if n >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(n) + 1
+}
+
+// offset returns how far n is from Start in the direction of Step, and
+// false if n is behind Start.
+func (r *NiftelRange) offset(n int64) (uint64, bool) {
+	if r.Step > 0 {
+		return uint64(n) - uint64(r.Start), n >= r.Start
+	}
+	return uint64(r.Start) - uint64(n), n <= r.Start
+}
+
+func (r *NiftelRange) absStep() uint64 {
+	if r.Step < 0 {
+		return -uint64(r.Step)
+	}
+	return uint64(r.Step)
+}
+
+// At returns the element at idx. Start + idx*Step may wrap while computing,
+// but it ends between Start and End, so the result is exact.
+func (r *NiftelRange) At(idx int64) (int64, bool) {
+	if idx < 0 || idx >= r.Len() {
+		return 0, false
+	}
+	return r.Start + idx*r.Step, true
+}
+
+func (r *NiftelRange) Contains(n int64) bool {
+	off, ok := r.offset(n)
+	if !ok || off%r.absStep() != 0 {
+		return false
+	}
+	idx := off / r.absStep()
+	return idx < uint64(r.Len())
+}
+
+// Slice returns the sub-range covering indices [low, high) of r, clamped to
+// its length. It ends at its last element, inclusively, as the element after
+// it may not fit in an int64.
+func (r *NiftelRange) Slice(low, high int64) *NiftelRange {
+	n := r.Len()
+	low = min(max(low, 0), n)
+	high = min(max(high, low), n)
+	start := r.Start + low*r.Step
+	if high == low {
+		return &NiftelRange{Start: start, End: start, Step: r.Step}
+	}
+	return &NiftelRange{
+		Start:     start,
+		End:       r.Start + (high-1)*r.Step,
+		Step:      r.Step,
+		Inclusive: true,
+	}
+}
+
+func (r *NiftelRange) ToList() []Value {
+	n := r.Len()
+	elems := make([]Value, 0, n)
+	for idx := int64(0); idx < n; idx++ {
+		elems = append(elems, Value{Type: ValueInt, Data: float64(r.Start + idx*r.Step)})
+	}
+	return elems
+}
+
+func (r *NiftelRange) String() string {
+	op := ".."
+	if r.Inclusive {
+		op = "..="
+	}
+	if r.Step != 1 {
+		return fmt.Sprintf("%d%s%d step %d", r.Start, op, r.End, r.Step)
+	}
+	return fmt.Sprintf("%d%s%d", r.Start, op, r.End)
+}
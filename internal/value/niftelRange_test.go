@@ -0,0 +1,65 @@
+package value_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ithinkiborkedit/niftelv2.git/internal/value"
+)
+
+func TestNiftelRange_Len(t *testing.T) {
+	cases := []struct {
+		start, end, step int64
+		inclusive        bool
+		want             int64
+	}{
+		{0, 10, 1, false, 10},
+		{0, 10, 1, true, 11},
+		{0, 10, 3, false, 4},
+		{10, 0, -3, true, 4},
+		{5, 5, 1, false, 0},
+		{5, 5, 1, true, 1},
+		{5, 0, 1, true, 0},
+		{math.MaxInt64 - 2, math.MaxInt64, 1, true, 3},
+		{math.MinInt64 + 2, math.MinInt64, -1, true, 3},
+		{math.MinInt64, math.MaxInt64, math.MaxInt64, true, 3},
+		{0, math.MaxInt64, 1, true, math.MaxInt64},
+		{math.MinInt64, math.MaxInt64, 1, true, math.MaxInt64},
+	}
+	for _, tc := range cases {
+		r, err := value.NewNiftelRange(tc.start, tc.end, tc.step, tc.inclusive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Len(); got != tc.want {
+			t.Errorf("%v: expected length %d, got %d", r, tc.want, got)
+		}
+	}
+}
+
+func TestNiftelRange_TopOfInt64(t *testing.T) {
+	r, err := value.NewNiftelRange(math.MaxInt64-4, math.MaxInt64, 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int64{math.MaxInt64 - 4, math.MaxInt64 - 2, math.MaxInt64} {
+		if !r.Contains(n) {
+			t.Errorf("expected %v to contain %d", r, n)
+		}
+	}
+	for _, n := range []int64{math.MaxInt64 - 3, math.MaxInt64 - 6, math.MinInt64} {
+		if r.Contains(n) {
+			t.Errorf("expected %v not to contain %d", r, n)
+		}
+	}
+	if last, ok := r.At(2); !ok || last != math.MaxInt64 {
+		t.Errorf("expected r[2] to be MaxInt64, got %d, %v", last, ok)
+	}
+	tail := r.Slice(1, 3)
+	if tail.Len() != 2 || !tail.Contains(math.MaxInt64) || tail.Contains(math.MaxInt64-4) {
+		t.Errorf("expected the last two elements, got %v", tail)
+	}
+	if empty := r.Slice(2, 2); empty.Len() != 0 {
+		t.Errorf("expected an empty slice, got %v", empty)
+	}
+}
@@ -0,0 +1,45 @@
+package value
+
+// NiftelTask is the handle returned by spawn. Its result is published once,
+// when the task finishes, and can then be read from any number of tasks.
+type NiftelTask struct {
+	done   chan struct{}
+	result Value
+	err    error
+}
+
+func NewNiftelTask() *NiftelTask {
+	return &NiftelTask{done: make(chan struct{})}
+}
+
+// Finish records the outcome of the task. It must be called exactly once.
+func (t *NiftelTask) Finish(result Value, err error) {
+	t.result = result
+	t.err = err
+	close(t.done)
+}
+
+// Done is closed when the task has finished.
+func (t *NiftelTask) Done() <-chan struct{} {
+	return t.done
+}
+
+// Wait blocks until the task finishes and returns its outcome, or until done
+// is closed, when it returns ErrInterrupted. A nil done is never closed.
+func (t *NiftelTask) Wait(done <-chan struct{}) (Value, error) {
+	select {
+	case <-t.done:
+		return t.result, t.err
+	case <-done:
+		return Null(), ErrInterrupted
+	}
+}
+
+func (t *NiftelTask) String() string {
+	select {
+	case <-t.done:
+		return "<task done>"
+	default:
+		return "<task running>"
+	}
+}
@@ -3,8 +3,9 @@ package value
 import token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
 
 type StructType struct {
-	Name   string
-	Fields []token.Token
+	Name    string
+	Fields  []token.Token
+	Methods map[string]Value
 }
 
 type StructInstance struct {
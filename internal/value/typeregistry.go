@@ -58,7 +58,9 @@ func TupleTypeKey(elementTypes []*symtable.TypeSymbol) string {
 
 func GetOrRegisterTupleType(elementTypes []*symtable.TypeSymbol) *symtable.TypeSymbol {
 	key := TupleTypeKey(elementTypes)
-	if t, ok := GetType(key); ok {
+	builtInTypesMu.Lock()
+	defer builtInTypesMu.Unlock()
+	if t, ok := BuiltInTypes[key]; ok {
 		return t
 	}
 	tupleType := &symtable.TypeSymbol{
@@ -66,7 +68,7 @@ func GetOrRegisterTupleType(elementTypes []*symtable.TypeSymbol) *symtable.TypeS
 		SymKind: symtable.SymbolTypes,
 		Fields:  nil,
 	}
-	RegisterType(key, tupleType)
+	BuiltInTypes[key] = tupleType
 
 	return tupleType
 
@@ -137,10 +139,14 @@ func substituteTypeInfo(t *TypeInfo, subst map[string]string) *TypeInfo {
 }
 
 func RegisterType(name string, sym *symtable.TypeSymbol) {
+	builtInTypesMu.Lock()
+	defer builtInTypesMu.Unlock()
 	BuiltInTypes[name] = sym
 }
 
 func GetType(name string) (*symtable.TypeSymbol, bool) {
+	builtInTypesMu.RLock()
+	defer builtInTypesMu.RUnlock()
 	if sym, ok := BuiltInTypes[name]; ok {
 		return sym, true
 	}
@@ -174,7 +180,13 @@ func ListTypes() []string {
 
 var BuiltInTypes = map[string]*symtable.TypeSymbol{}
 
+// builtInTypesMu guards BuiltInTypes, which spawned tasks read and extend
+// (tuple types are registered on first use).
+var builtInTypesMu sync.RWMutex
+
 func BuiltinTypesInit() {
+	builtInTypesMu.Lock()
+	defer builtInTypesMu.Unlock()
 	BuiltInTypes["int"] = &symtable.TypeSymbol{SymName: "int", SymKind: symtable.SymbolTypes}
 	BuiltInTypes["float"] = &symtable.TypeSymbol{SymName: "float", SymKind: symtable.SymbolTypes}
 	BuiltInTypes["string"] = &symtable.TypeSymbol{SymName: "string", SymKind: symtable.SymbolTypes}
@@ -182,8 +194,25 @@ func BuiltinTypesInit() {
 	BuiltInTypes["null"] = &symtable.TypeSymbol{SymName: "null", SymKind: symtable.SymbolTypes}
 	BuiltInTypes["tuple"] = &symtable.TypeSymbol{SymName: "tuple", SymKind: symtable.SymbolTypes}
 	BuiltInTypes["list"] = &symtable.TypeSymbol{SymName: "list", SymKind: symtable.SymbolTypes}
+	BuiltInTypes["dict"] = &symtable.TypeSymbol{SymName: "dict", SymKind: symtable.SymbolTypes}
+	BuiltInTypes["range"] = &symtable.TypeSymbol{SymName: "range", SymKind: symtable.SymbolTypes}
 	BuiltInTypes["struct"] = &symtable.TypeSymbol{SymName: "struct", SymKind: symtable.SymbolTypes}
 	BuiltInTypes["func"] = &symtable.TypeSymbol{SymName: "func", SymKind: symtable.SymbolTypes}
+	BuiltInTypes["chan"] = &symtable.TypeSymbol{SymName: "chan", SymKind: symtable.SymbolTypes, IsGeneric: true, TypeParams: []string{"T"}}
+	BuiltInTypes["task"] = &symtable.TypeSymbol{SymName: "task", SymKind: symtable.SymbolTypes}
+	BuiltInTypes["generator"] = &symtable.TypeSymbol{SymName: "generator", SymKind: symtable.SymbolTypes}
+	BuiltInTypes["mutex"] = &symtable.TypeSymbol{SymName: "mutex", SymKind: symtable.SymbolTypes}
+}
+
+// BuiltinTypeList returns a snapshot of the registered builtin types.
+func BuiltinTypeList() []*symtable.TypeSymbol {
+	builtInTypesMu.RLock()
+	defer builtInTypesMu.RUnlock()
+	types := make([]*symtable.TypeSymbol, 0, len(BuiltInTypes))
+	for _, typ := range BuiltInTypes {
+		types = append(types, typ)
+	}
+	return types
 }
 
 func (t *TypeInfo) FieldByName(name string) (*TypeInfo, error) {
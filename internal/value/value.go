@@ -5,6 +5,7 @@ import (
 	"hash/fnv"
 	"math"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/ithinkiborkedit/niftelv2.git/internal/symtable"
@@ -23,6 +24,11 @@ const (
 	ValueStruct
 	ValueFunc
 	ValueTuple
+	ValueRange
+	ValueChan
+	ValueTask
+	ValueGenerator
+	ValueMutex
 )
 
 type Value struct {
@@ -35,7 +41,17 @@ func (v Value) String() string {
 	switch v.Type {
 	case ValueNull:
 		return "null"
-	case ValueInt, ValueFloat, ValueBool, ValueString:
+	case ValueInt:
+		// Ints are kept as float64, which %v would print in exponent form
+		// from a million up, and which can be -0.
+		if n, ok := v.Data.(float64); ok {
+			if n == 0 {
+				return "0"
+			}
+			return strconv.FormatFloat(n, 'f', -1, 64)
+		}
+		return fmt.Sprintf("%v", v.Data)
+	case ValueFloat, ValueBool, ValueString:
 		return fmt.Sprintf("%v", v.Data)
 	case ValueList:
 		return formatList(v.Data)
@@ -59,6 +75,37 @@ func (v Value) String() string {
 			return "(" + strings.Join(elems, ", ") + ")"
 		}
 		return "<tuple-corrupt>"
+	case ValueRange:
+		if rng, ok := v.Data.(*NiftelRange); ok {
+			return rng.String()
+		}
+		return "<range-corrupt>"
+	case ValueChan:
+		if ch, ok := v.Data.(*NiftelChan); ok {
+			return ch.String()
+		}
+		return "<chan-corrupt>"
+	case ValueTask:
+		if task, ok := v.Data.(*NiftelTask); ok {
+			return task.String()
+		}
+		return "<task-corrupt>"
+	case ValueGenerator:
+		if gen, ok := v.Data.(*NiftelGenerator); ok {
+			return gen.String()
+		}
+		return "<generator-corrupt>"
+	case ValueMutex:
+		if m, ok := v.Data.(*NiftelMutex); ok {
+			return m.String()
+		}
+		return "<mutex-corrupt>"
+	case ValueFunc:
+		// Functions live in the function package, which imports this one.
+		if fn, ok := v.Data.(interface{ Name() string }); ok && fn.Name() != "" {
+			return "<func " + fn.Name() + ">"
+		}
+		return "<func>"
 	case ValueStruct:
 		inst, ok := v.Data.(*StructInstance)
 		if !ok {
@@ -93,8 +140,7 @@ func formatList(data interface{}) string {
 }
 
 func LookupType(name string) (*symtable.TypeSymbol, bool) {
-	typ, ok := BuiltInTypes[name]
-	return typ, ok
+	return GetType(name)
 }
 
 func Null() Value {
@@ -111,6 +157,8 @@ func (v Value) Equals(other Value) bool {
 	}
 
 	switch v.Type {
+	case ValueNull:
+		return true
 	case ValueInt, ValueBool, ValueString, ValueFloat:
 		return v.Data == other.Data
 	default:
@@ -138,6 +186,23 @@ func (v Value) TypeInfo() *symtable.TypeSymbol {
 	case ValueDict:
 		t, _ := GetType("dict")
 		return t
+	case ValueRange:
+		t, _ := GetType("range")
+		return t
+	case ValueChan:
+		if ch, ok := v.Data.(*NiftelChan); ok {
+			return ch.Type
+		}
+		return nil
+	case ValueTask:
+		t, _ := GetType("task")
+		return t
+	case ValueGenerator:
+		t, _ := GetType("generator")
+		return t
+	case ValueMutex:
+		t, _ := GetType("mutex")
+		return t
 	case ValueStruct:
 		if s, ok := v.Data.(*StructInstance); ok {
 			t, _ := GetType(s.Type.Name)
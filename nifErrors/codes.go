@@ -0,0 +1,84 @@
+package niferrors
+
+// Code identifies a kind of diagnostic. Codes are stable: once published a
+// code keeps its meaning, so tools and documentation can refer to it.
+type Code string
+
+// Lexical errors.
+const (
+	CodeUnterminatedString Code = "E0101"
+	CodeBadEscape          Code = "E0102"
+	CodeBadNumber          Code = "E0103"
+	CodeUnexpectedChar     Code = "E0104"
+)
+
+// Syntax errors.
+const (
+	CodeExpectedToken    Code = "E0201"
+	CodeUnexpectedToken  Code = "E0202"
+	CodeBadSpawn         Code = "E0203"
+	CodeTypeArgsNotCall  Code = "E0204"
+	CodeExpectedIndex    Code = "E0205"
+	CodeYieldOutsideFunc Code = "E0206"
+	CodeUndefinedLabel   Code = "E0207"
+	CodeDuplicateLabel   Code = "E0208"
+	CodeLabelWithoutLoop Code = "E0209"
+	CodeBadStructMember  Code = "E0210"
+	CodeDuplicateDefault Code = "E0211"
+	CodeDuplicateCase    Code = "E0212"
+	CodeBadFallthrough   Code = "E0213"
+	CodeBadSelectCase    Code = "E0214"
+	CodeTooManyErrors    Code = "E0215"
+)
+
+// Name errors.
+const (
+	CodeRedeclared    Code = "E0301"
+	CodeUndefined     Code = "E0302"
+	CodeUseBeforeDecl Code = "E0303"
+	CodeUnknownType   Code = "E0304"
+	CodeUnknownMember Code = "E0305"
+)
+
+// Type errors.
+const (
+	CodeMismatchedTypes  Code = "E0401"
+	CodeBadOperand       Code = "E0402"
+	CodeNonBoolCondition Code = "E0403"
+	CodeArgCount         Code = "E0404"
+	CodeTypeArgCount     Code = "E0405"
+	CodeNotCallable      Code = "E0406"
+	CodeNotIndexable     Code = "E0407"
+	CodeNotIterable      Code = "E0408"
+	CodeMissingType      Code = "E0409"
+	CodeNotAssignable    Code = "E0410"
+	CodeBadReturn        Code = "E0411"
+	CodeYieldOutsideGen  Code = "E0412"
+	CodeNotStruct        Code = "E0413"
+	CodeUnsupported      Code = "E0414"
+)
+
+// Control flow errors and warnings.
+const (
+	CodeMissingReturn Code = "E0501"
+	CodeUnassigned    Code = "E0502"
+	CodeUnreachable   Code = "W0501"
+)
+
+// Runtime errors.
+const (
+	CodeRuntime         Code = "E0601"
+	CodeDivisionByZero  Code = "E0602"
+	CodeIndexOutOfRange Code = "E0603"
+	CodeKeyNotFound     Code = "E0604"
+	CodeAssertion       Code = "E0605"
+	CodeInternal        Code = "E0606"
+)
+
+// Limit errors, raised when a program exceeds the limits it was run with.
+const (
+	CodeStepLimit   Code = "E0701"
+	CodeCallDepth   Code = "E0702"
+	CodeDeadline    Code = "E0703"
+	CodeMemoryLimit Code = "E0704"
+)
@@ -0,0 +1,164 @@
+package niferrors_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+	niferrors "github.com/ithinkiborkedit/niftelv2.git/nifErrors"
+)
+
+func TestSpan(t *testing.T) {
+	cases := []struct {
+		name string
+		tok  token.Token
+		want [4]int
+	}{
+		{"identifier", token.Token{Type: token.TokenIdentifier, Lexeme: "count", Line: 2, Column: 9}, [4]int{2, 5, 2, 10}},
+		{"after newline", token.Token{Type: token.TokenRParen, Lexeme: "\n)", Line: 3, Column: 1}, [4]int{3, 1, 3, 2}},
+		{"string", token.Token{Type: token.TokenString, Lexeme: "hi", Line: 1, Column: 6}, [4]int{1, 6, 1, 10}},
+		{"end of input", token.Token{Type: token.TokenEOF, Line: 4, Column: 0}, [4]int{4, 1, 4, 2}},
+	}
+	for _, tc := range cases {
+		line, col, endLine, endCol := niferrors.Span(tc.tok)
+		if got := [4]int{line, col, endLine, endCol}; got != tc.want {
+			t.Errorf("%s: expected span %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	src := "count := 1\nfunc f() {\n\tprint(cout + 1)\n}\n"
+	err := niferrors.New(niferrors.TypeError, niferrors.CodeUndefined,
+		token.Token{Type: token.TokenIdentifier, Lexeme: "cout", Line: 3, Column: 11},
+		"undefined variable '%s'", "cout").
+		Note("variables must be declared before use").
+		DidYouMean("cout", []string{"f", "count"})
+	err.File = "main.nif"
+
+	var out bytes.Buffer
+	niferrors.Render(&out, err, src)
+	want := `error[E0302]: undefined variable 'cout'
+ --> main.nif:3:8
+  |
+3 | 	print(cout + 1)
+  | 	      ^~~~
+  = note: variables must be declared before use
+  = help: did you mean 'count'?
+`
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+}
+
+func TestRender_Spans(t *testing.T) {
+	src := "x := (1 +\n\t2)\n"
+	cases := []struct {
+		name string
+		err  *niferrors.NifError
+		want string
+	}{
+		{"multi-line span is marked to the end of its first line",
+			&niferrors.NifError{Message: "m", Line: 1, Column: 6, EndLine: 2, EndColumn: 4},
+			"  |      ^~~~\n"},
+		{"empty span still gets a caret",
+			&niferrors.NifError{Message: "m", Line: 1, Column: 3, EndLine: 1, EndColumn: 3},
+			"  |   ^\n"},
+		{"warning",
+			&niferrors.NifError{Message: "unreachable code", Code: niferrors.CodeUnreachable, Severity: niferrors.SeverityWarning, Line: 2, Column: 2, EndLine: 2, EndColumn: 3},
+			"warning[W0501]: unreachable code\n"},
+	}
+	for _, tc := range cases {
+		var out bytes.Buffer
+		niferrors.Render(&out, tc.err, src)
+		if !strings.Contains(out.String(), tc.want) {
+			t.Errorf("%s: expected output containing %q, got\n%s", tc.name, tc.want, out.String())
+		}
+	}
+
+	var out bytes.Buffer
+	niferrors.Render(&out, errors.New("disk full"), src)
+	if out.String() != "error: disk full\n" {
+		t.Errorf("expected a plain error without snippet, got %q", out.String())
+	}
+}
+
+func TestRender_Traceback(t *testing.T) {
+	err := &niferrors.NifError{
+		Kind: niferrors.RuntimeError, Code: niferrors.CodeRuntime, Message: "close of closed channel",
+		File: "main.nif",
+		Trace: []niferrors.Frame{
+			{Function: "close", Native: true, Line: 2, Column: 2},
+			{Function: "shut", Line: 6, Column: 2, DefLine: 1, DefColumn: 1},
+		},
+		TraceElided: 1,
+	}
+	var out bytes.Buffer
+	niferrors.Render(&out, err, "")
+	want := `error[E0601]: close of closed channel
+ --> main.nif
+  = traceback (most recent call first):
+      in close (<native>) called at main.nif:2:2
+      in shut (main.nif:1:1) called at main.nif:6:2
+      ... 1 more
+`
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	err := &niferrors.NifError{
+		Kind:       niferrors.ParseError,
+		Code:       niferrors.CodeExpectedToken,
+		Message:    "expected '->' before return type",
+		Line:       1,
+		Column:     10,
+		EndLine:    1,
+		EndColumn:  11,
+		File:       "a.nif",
+		Suggestion: "did you mean 'x'?",
+	}
+	var out bytes.Buffer
+	if werr := niferrors.WriteJSON(&out, err); werr != nil {
+		t.Fatal(werr)
+	}
+	if !strings.HasSuffix(out.String(), "}\n") || strings.Count(out.String(), "\n") != 1 {
+		t.Errorf("expected one line of JSON, got %q", out.String())
+	}
+	var got map[string]any
+	if jerr := json.Unmarshal(out.Bytes(), &got); jerr != nil {
+		t.Fatal(jerr)
+	}
+	want := map[string]any{
+		"kind": "parse", "code": "E0201", "severity": "error", "message": "expected '->' before return type",
+		"line": 1.0, "column": 10.0, "endLine": 1.0, "endColumn": 11.0, "file": "a.nif", "suggestion": "did you mean 'x'?",
+	}
+	for key, val := range want {
+		if got[key] != val {
+			t.Errorf("%s: expected %v, got %v", key, val, got[key])
+		}
+	}
+}
+
+func TestClosest(t *testing.T) {
+	cases := []struct {
+		name       string
+		candidates []string
+		want       string
+	}{
+		{"cout", []string{"count", "print"}, "count"},
+		{"lenght", []string{"length", "len"}, "length"},
+		{"x", []string{"y", "xs"}, "y"},
+		{"total", []string{"print", "range"}, ""},
+		{"value", []string{"value"}, ""},
+	}
+	for _, tc := range cases {
+		if got := niferrors.Closest(tc.name, tc.candidates); got != tc.want {
+			t.Errorf("Closest(%q, %v) = %q, want %q", tc.name, tc.candidates, got, tc.want)
+		}
+	}
+}
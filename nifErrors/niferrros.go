@@ -1,6 +1,15 @@
+// Package niferrors defines NifError, the diagnostic every stage of the
+// compiler and interpreter reports, and renders diagnostics for people and
+// tools.
 package niferrors
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	token "github.com/ithinkiborkedit/niftelv2.git/internal/niftokens"
+)
 
 type ErrorKind int
 
@@ -9,17 +18,176 @@ const (
 	ParseError
 	RuntimeError
 	IOError
+	ScopeError
+	TypeError
+	FlowError
+	LimitError
 )
 
+var kindNames = map[ErrorKind]string{
+	LexError:     "lex",
+	ParseError:   "parse",
+	RuntimeError: "runtime",
+	IOError:      "io",
+	ScopeError:   "scope",
+	TypeError:    "type",
+	FlowError:    "flow",
+	LimitError:   "limit",
+}
+
+func (k ErrorKind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+func (k ErrorKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// NifError is one diagnostic. Line and Column are where the offending source
+// starts, EndLine and EndColumn where it ends (exclusive). Lines and columns
+// are 1-based and columns count runes. A zero Line means the position is not
+// known.
 type NifError struct {
-	Kind    ErrorKind
-	Message string
-	Line    int
-	Column  int
-	Token   string
-	File    string
+	Kind       ErrorKind `json:"kind"`
+	Code       Code      `json:"code"`
+	Severity   Severity  `json:"severity"`
+	Message    string    `json:"message"`
+	Line       int       `json:"line,omitempty"`
+	Column     int       `json:"column,omitempty"`
+	EndLine    int       `json:"endLine,omitempty"`
+	EndColumn  int       `json:"endColumn,omitempty"`
+	Token      string    `json:"token,omitempty"`
+	File       string    `json:"file,omitempty"`
+	Notes      []string  `json:"notes,omitempty"`
+	Suggestion string    `json:"suggestion,omitempty"`
+	// Trace holds, for a runtime error raised inside a function call, the
+	// calls in progress, innermost first. TraceElided counts the outer
+	// calls left out of it.
+	Trace       []Frame `json:"trace,omitempty"`
+	TraceElided int     `json:"traceElided,omitempty"`
+	// Err is the error this one was made from, if any.
+	Err error `json:"-"`
+}
+
+// Frame is one call in the traceback of a runtime error. Line and Column
+// locate the call, DefLine and DefColumn the declaration of the function
+// called; native functions have none.
+type Frame struct {
+	Function  string `json:"function"`
+	Native    bool   `json:"native,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	DefLine   int    `json:"defLine,omitempty"`
+	DefColumn int    `json:"defColumn,omitempty"`
+}
+
+// describe renders f as a line of a traceback of an error in file.
+func (f Frame) describe(file string) string {
+	pos := func(line, col int) string {
+		if file == "" {
+			return fmt.Sprintf("%d:%d", line, col)
+		}
+		return fmt.Sprintf("%s:%d:%d", file, line, col)
+	}
+	where := "<native>"
+	if !f.Native {
+		where = pos(f.DefLine, f.DefColumn)
+	}
+	desc := fmt.Sprintf("in %s (%s)", f.Function, where)
+	if f.Line > 0 {
+		desc += " called at " + pos(f.Line, f.Column)
+	}
+	return desc
+}
+
+func (e *NifError) Error() string {
+	var sb strings.Builder
+	if e.File != "" {
+		sb.WriteString(e.File + ":")
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&sb, "%d:%d:", e.Line, e.Column)
+	}
+	if sb.Len() > 0 {
+		sb.WriteString(" ")
+	}
+	sb.WriteString(e.Message)
+	return sb.String()
+}
+
+func (e *NifError) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error of the given kind spanning tok.
+func New(kind ErrorKind, code Code, tok token.Token, format string, args ...any) *NifError {
+	err := &NifError{Kind: kind, Code: code, Message: fmt.Sprintf(format, args...)}
+	return err.At(tok)
+}
+
+// At sets the span of e to the source tok covers.
+func (e *NifError) At(tok token.Token) *NifError {
+	e.Line, e.Column, e.EndLine, e.EndColumn = Span(tok)
+	e.Token = strings.TrimSpace(tok.Lexeme)
+	return e
+}
+
+// Between sets the span of e to run from the start of first to the end of
+// last.
+func (e *NifError) Between(first, last token.Token) *NifError {
+	e.At(first)
+	_, _, e.EndLine, e.EndColumn = Span(last)
+	if last.Line != first.Line || last.Column != first.Column {
+		e.Token = ""
+	}
+	return e
+}
+
+// Note adds a note shown under the source snippet.
+func (e *NifError) Note(format string, args ...any) *NifError {
+	e.Notes = append(e.Notes, fmt.Sprintf(format, args...))
+	return e
+}
+
+// DidYouMean suggests the candidate closest to name, if one is close enough
+// to be a likely misspelling.
+func (e *NifError) DidYouMean(name string, candidates []string) *NifError {
+	if match := Closest(name, candidates); match != "" {
+		e.Suggestion = fmt.Sprintf("did you mean '%s'?", match)
+	}
+	return e
 }
 
-func (e NifError) Error() string {
-	return fmt.Sprintf("[%v] %s(line %d, col %d): %s", e.Kind, e.File, e.Line, e.Column, e.Message)
+// Span returns the source tok covers. Tokens record the column of their last
+// rune, except string literals, which record where they start and hold their
+// contents without quotes.
+func Span(tok token.Token) (line, col, endLine, endCol int) {
+	width := max(utf8.RuneCountInString(strings.TrimSpace(tok.Lexeme)), 1)
+	if tok.Type == token.TokenString {
+		width = utf8.RuneCountInString(tok.Lexeme) + 2
+		return tok.Line, tok.Column, tok.Line, tok.Column + width
+	}
+	col = max(tok.Column-width+1, 1)
+	return tok.Line, col, tok.Line, col + width
 }
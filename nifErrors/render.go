@@ -0,0 +1,109 @@
+package niferrors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// As returns the NifError in err's chain, or a NifError carrying just err's
+// message if there is none.
+func As(err error) *NifError {
+	var nifErr *NifError
+	if errors.As(err, &nifErr) {
+		return nifErr
+	}
+	return &NifError{Message: err.Error(), Err: err}
+}
+
+// Render writes err for a person: a header with its severity and code, its
+// location, the source line with the span underlined, then its notes,
+// suggestion and traceback. src is the source the position refers to;
+// without one, or without a position, the snippet is left out.
+func Render(w io.Writer, err error, src string) {
+	e := As(err)
+	header := e.Severity.String()
+	if e.Code != "" {
+		header += "[" + string(e.Code) + "]"
+	}
+	fmt.Fprintf(w, "%s: %s\n", header, e.Message)
+
+	line, ok := sourceLine(src, e.Line)
+	gutter := strings.Repeat(" ", len(strconv.Itoa(e.Line)))
+	if e.Line > 0 {
+		location := fmt.Sprintf("%d:%d", e.Line, e.Column)
+		if e.File != "" {
+			location = e.File + ":" + location
+		}
+		fmt.Fprintf(w, "%s--> %s\n", gutter, location)
+	} else if e.File != "" {
+		fmt.Fprintf(w, "%s--> %s\n", gutter, e.File)
+	}
+	if ok {
+		fmt.Fprintf(w, "%s |\n", gutter)
+		fmt.Fprintf(w, "%d | %s\n", e.Line, line)
+		fmt.Fprintf(w, "%s | %s\n", gutter, underline(line, e))
+	}
+	for _, note := range e.Notes {
+		fmt.Fprintf(w, "%s = note: %s\n", gutter, note)
+	}
+	if e.Suggestion != "" {
+		fmt.Fprintf(w, "%s = help: %s\n", gutter, e.Suggestion)
+	}
+	if len(e.Trace) > 0 {
+		fmt.Fprintf(w, "%s = traceback (most recent call first):\n", gutter)
+		for _, frame := range e.Trace {
+			fmt.Fprintf(w, "%s     %s\n", gutter, frame.describe(e.File))
+		}
+		if e.TraceElided > 0 {
+			fmt.Fprintf(w, "%s     ... %d more\n", gutter, e.TraceElided)
+		}
+	}
+}
+
+// sourceLine returns the 1-based nth line of src.
+func sourceLine(src string, n int) (string, bool) {
+	if n <= 0 || src == "" {
+		return "", false
+	}
+	lines := strings.Split(src, "\n")
+	if n > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[n-1], "\r"), true
+}
+
+// underline marks e's span on line with ^~~~. Tabs before the span are kept
+// so the marks line up with the source whatever the tab width. A span that
+// continues past the line is marked to its end.
+func underline(line string, e *NifError) string {
+	runes := []rune(line)
+	start := min(max(e.Column-1, 0), len(runes))
+	end := e.EndColumn - 1
+	if e.EndLine > e.Line {
+		end = len(runes)
+	}
+	width := max(min(end, len(runes))-start, 1)
+
+	var sb strings.Builder
+	for _, r := range runes[:start] {
+		if r == '\t' {
+			sb.WriteRune('\t')
+		} else {
+			sb.WriteRune(' ')
+		}
+	}
+	sb.WriteString("^" + strings.Repeat("~", width-1))
+	return sb.String()
+}
+
+// WriteJSON writes err as one line of JSON, for tools. Errors that are not
+// NifErrors carry only their message.
+func WriteJSON(w io.Writer, err error) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(As(err))
+}
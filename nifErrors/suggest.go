@@ -0,0 +1,45 @@
+package niferrors
+
+// Closest returns the candidate with the smallest edit distance to name, or
+// "" if none is within a third of name's length (at least one edit). Ties go
+// to the earlier candidate.
+func Closest(name string, candidates []string) string {
+	limit := max(len([]rune(name))/3, 1)
+	best, bestDist := "", limit+1
+	for _, candidate := range candidates {
+		if candidate == name {
+			continue
+		}
+		if dist := distance(name, candidate); dist < bestDist {
+			best, bestDist = candidate, dist
+		}
+	}
+	return best
+}
+
+// distance is the number of rune insertions, deletions, substitutions and
+// swaps of adjacent runes that turn a into b.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}